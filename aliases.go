@@ -0,0 +1,23 @@
+package main
+
+import (
+	"Llore/internal/database"
+	"fmt"
+)
+
+// GetEntryAliases returns the other names an entry goes by, e.g. nicknames or titles.
+func (a *App) GetEntryAliases(entryID int64) ([]string, error) {
+	if a.db == nil {
+		return nil, fmt.Errorf("database is not initialized")
+	}
+	return database.DBGetAliases(a.db, entryID)
+}
+
+// SetEntryAliases replaces the aliases of an entry. An entry named by one of its aliases
+// in a query or the current document is always included in the AI's context.
+func (a *App) SetEntryAliases(entryID int64, aliases []string) error {
+	if a.db == nil {
+		return fmt.Errorf("database is not initialized")
+	}
+	return database.DBSetAliases(a.db, entryID, aliases)
+}
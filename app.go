@@ -5,10 +5,12 @@ import (
 	"Llore/internal/database"
 	"Llore/internal/embeddings"
 	"Llore/internal/llm"
+	"Llore/internal/provider"
 	"Llore/internal/vault"
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -16,14 +18,16 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
-	// Import official OpenAI and Gemini SDKs
+	// Import official OpenAI SDK (Gemini completions live in internal/llm)
 	openai "github.com/openai/openai-go" // Official OpenAI SDK
 	"github.com/openai/openai-go/option" // OpenAI SDK options
-	"google.golang.org/genai"            // Corrected new SDK import path
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
 // ProcessStoryResult holds the separated lists of new, updated, and existing entries.
@@ -33,16 +37,14 @@ type ProcessStoryResult struct {
 	ExistingEntries []database.CodexEntry `json:"existingEntries"`
 }
 
-// Embedding queue system to process embeddings sequentially
-var (
-	// Create a channel for embedding requests
-	embeddingQueue     = make(chan embeddingRequest, 100)
-	embeddingQueueOnce sync.Once
-)
+// embeddingWorkerBatchSize caps how many queued jobs a worker embeds in one call.
+const embeddingWorkerBatchSize = 32
 
 type embeddingRequest struct {
 	entryID int64
 	text    string
+	title   string // Name/Type header repeated on every passage of a long entry
+	body    string // Content (plus image descriptions) that is split into passages
 }
 
 // GetEmbedding retrieves embedding providers for a codex entry
@@ -138,25 +140,11 @@ func (a *App) CreateEntry(name, entryType, content string) (database.CodexEntry,
 		entry.Content = content
 	}
 
-	// Generate embedding for new entry using the queue system
-	if a.embeddingService != nil && entry.ID != 0 {
-		// Initialize worker if not already done
-		a.initEmbeddingWorker()
-
-		// Prepare text for embedding
-		text := fmt.Sprintf("Name: %s\nType: %s\nContent: %s",
-			entry.Name, entry.Type, entry.Content)
-
-		// Send to channel with non-blocking behavior
-		select {
-		case embeddingQueue <- embeddingRequest{entryID: entry.ID, text: text}:
-			log.Printf("Queued embedding generation for entry %d", entry.ID)
-		default:
-			log.Printf("Warning: Embedding queue full, skipping embedding for entry %d", entry.ID)
-		}
-	} else {
-		log.Printf("Skipping embedding generation for new entry %d (service nil: %v, ID zero: %v)",
-			entry.ID, a.embeddingService == nil, entry.ID == 0)
+	// Queue embedding generation for the new entry; the job is stored in the vault, so it
+	// survives a restart and runs once an embedding provider is available
+	if entry.ID != 0 {
+		a.queueEntryEmbedding(entry.ID)
+		log.Printf("Queued embedding generation for entry %d", entry.ID)
 	}
 
 	return entry, nil
@@ -195,26 +183,11 @@ func (a *App) UpdateEntry(entry database.CodexEntry) error {
 	}
 
 	// Only queue embedding update if textual content (name, type, content) relevant to embedding has changed
-	if (nameChanged || typeChanged || contentChanged) && a.embeddingService != nil && entry.ID != 0 {
-		// Initialize worker if not already done
-		a.initEmbeddingWorker()
-
-		// Prepare text for embedding
-		textForEmbedding := fmt.Sprintf("Name: %s\nType: %s\nContent: %s",
-			entry.Name, entry.Type, entry.Content)
-
-		// Send to channel with non-blocking behavior
-		select {
-		case embeddingQueue <- embeddingRequest{entryID: entry.ID, text: textForEmbedding}:
-			log.Printf("Queued embedding update for entry %d ('%s') due to changes.", entry.ID, entry.Name)
-		default:
-			log.Printf("Warning: Embedding queue full, skipping embedding update for entry %d", entry.ID)
-		}
+	if (nameChanged || typeChanged || contentChanged) && entry.ID != 0 {
+		a.queueEntryEmbedding(entry.ID)
+		log.Printf("Queued embedding update for entry %d ('%s') due to changes.", entry.ID, entry.Name)
 	} else if !(nameChanged || typeChanged || contentChanged) {
 		log.Printf("Skipping embedding update for entry %d ('%s'): content relevant to embedding did not change.", entry.ID, entry.Name)
-	} else {
-		log.Printf("Skipping embedding update for entry %d (service nil: %v, ID zero: %v)",
-			entry.ID, a.embeddingService == nil, entry.ID == 0)
 	}
 
 	return nil
@@ -225,7 +198,57 @@ func (a *App) DeleteEntry(id int64) error {
 	if a.db == nil {
 		return fmt.Errorf("database is not initialized")
 	}
-	return database.DBDeleteEntry(a.db, id)
+	if err := database.DBDeleteEntryCascade(a.db, id); err != nil {
+		return err
+	}
+	a.deleteEntryAttachments(id)
+	if a.embeddingService != nil {
+		a.embeddingService.RemoveEntry(id)
+	}
+	return nil
+}
+
+// newEmbeddingRequest builds the text embedded for an entry, including descriptions of
+// its image attachments so pictures are reachable through RAG.
+func (a *App) newEmbeddingRequest(entry database.CodexEntry) embeddingRequest {
+	title := fmt.Sprintf("Name: %s\nType: %s", entry.Name, entry.Type)
+	body := entry.Content
+
+	attachments, err := database.DBListAttachments(a.db, entry.ID)
+	if err != nil {
+		log.Printf("Warning: Failed to load attachments for embedding of entry %d: %v", entry.ID, err)
+	}
+	var descriptions []string
+	for _, att := range attachments {
+		if att.Description != "" {
+			descriptions = append(descriptions, att.Description)
+		}
+	}
+	if len(descriptions) > 0 {
+		body += "\nImages: " + strings.Join(descriptions, "\n")
+	}
+
+	return embeddingRequest{
+		entryID: entry.ID,
+		text:    title + "\nContent: " + body,
+		title:   title,
+		body:    body,
+	}
+}
+
+// queueEntryEmbedding schedules (re-)embedding of an entry whose embedded text changed.
+func (a *App) queueEntryEmbedding(entryID int64) {
+	a.enqueueEmbeddingJob(jobKindEntry, strconv.FormatInt(entryID, 10))
+}
+
+// queueEntryEmbeddings schedules many entries at once and returns how many weren't
+// already queued.
+func (a *App) queueEntryEmbeddings(entryIDs []int64) int {
+	targets := make([]string, len(entryIDs))
+	for i, id := range entryIDs {
+		targets[i] = strconv.FormatInt(id, 10)
+	}
+	return a.enqueueEmbeddingJobs(jobKindEntry, targets)
 }
 
 // GetCurrentVaultPath returns the path of the currently loaded vault
@@ -245,6 +268,11 @@ type App struct {
 	embeddingService *embeddings.EmbeddingService
 	contextBuilder   *ragcontext.ContextBuilder // Use alias
 	promptBuilder    *llm.PromptBuilder
+	jobs             *embeddingJobPool // Background embedding workers for the current vault
+	jobsMu           sync.Mutex
+	embedFallback    string              // Why the built-in embeddings are in use; empty when they aren't
+	migration        *embeddingMigration // Embedding provider migration in progress, if any
+	migrationMu      sync.Mutex
 	// TODO: Add mutex if concurrent access to these services becomes an issue
 }
 
@@ -282,6 +310,9 @@ func (a *App) SwitchVault(path string) error {
 
 	// Close previous DB connection if open
 	if a.db != nil {
+		a.cancelEmbeddingMigration()
+		a.stopEmbeddingJobs() // Workers must not outlive the vault they were started for
+		a.saveVectorIndex()
 		database.DBClose(a.db)
 	}
 
@@ -301,6 +332,7 @@ func (a *App) SwitchVault(path string) error {
 		codex_entry_id INTEGER NOT NULL,
 		embedding BLOB NOT NULL,
 		vector_version TEXT NOT NULL,
+		content_hash TEXT NOT NULL DEFAULT '',
 		created_at DATETIME NOT NULL,
 		updated_at DATETIME NOT NULL,
 		FOREIGN KEY(codex_entry_id) REFERENCES codex_entries(id) ON DELETE CASCADE,
@@ -309,6 +341,42 @@ func (a *App) SwitchVault(path string) error {
 	if err != nil {
 		log.Printf("Warning: Failed to create index on embeddings table: %v", err)
 	}
+	if err := database.DBEnsureEmbeddingHashColumn(a.db); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	if err := database.DBEnsureAttachmentsTable(a.db); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	if err := database.DBEnsureEmbeddingChunksTable(a.db); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	if err := database.DBEnsureFTSIndex(a.db); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	if err := database.DBEnsureLibraryIndexTables(a.db); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	if err := database.DBEnsureChatMemoryTables(a.db); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	if err := database.DBEnsureJobsTable(a.db); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	if err := database.DBEnsureVaultSettingsTable(a.db); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	if err := database.DBEnsureAliasesTable(a.db); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	if err := database.DBEnsureTagsTable(a.db); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	if err := database.DBEnsureEvalTables(a.db); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	if err := database.DBEnsureClusterTables(a.db); err != nil {
+		log.Printf("Warning: %v", err)
+	}
 
 	// Get current config and initialize services
 	currentConfig := llm.GetConfig()
@@ -326,9 +394,7 @@ func (a *App) SwitchVault(path string) error {
 	}
 
 	// Initialize embedding services using the helper
-	if err := a.initializeEmbeddingServices(currentConfig); err != nil {
-		log.Printf("Warning: Failed to initialize embedding services during SwitchVault: %v", err)
-	}
+	a.initializeEmbeddingServices(currentConfig)
 
 	// Load library files
 	if err := a.refreshLibraryFiles(); err != nil {
@@ -339,15 +405,43 @@ func (a *App) SwitchVault(path string) error {
 	return nil
 }
 
-// initializeEmbeddingServices sets up the embedding provider and related services.
-// It should be called on vault switch and after settings are saved.
-func (a *App) initializeEmbeddingServices(cfg llm.OpenRouterConfig) error {
-	log.Printf("Initializing/Re-initializing embedding services for ActiveMode: '%s'", cfg.ActiveMode)
+// newEmbeddingProvider creates the embedding provider that cfg selects. A local Ollama
+// provider is only returned if Ollama is reachable and has the model.
+func newEmbeddingProvider(cfg llm.OpenRouterConfig) (embeddings.EmbeddingProvider, error) {
+	chosenProvider, errProv := configuredEmbeddingProvider(cfg)
+
+	// Ollama may not be running or may lack the model; check now rather than failing
+	// every embedding request later
+	if local, ok := chosenProvider.(*embeddings.LocalEmbeddingProvider); ok && errProv == nil {
+		if err := local.Ping(); err != nil {
+			errProv = err
+		}
+	}
+	if errProv != nil {
+		return nil, errProv
+	}
+	return chosenProvider, nil
+}
 
+// configuredEmbeddingProvider creates the embedding provider that cfg selects without
+// contacting it, so its vector version is known even when it is unreachable. The
+// embedding_provider setting takes precedence over the chat mode.
+func configuredEmbeddingProvider(cfg llm.OpenRouterConfig) (embeddings.EmbeddingProvider, error) {
 	var chosenProvider embeddings.EmbeddingProvider
 	var errProv error
 
-	switch cfg.ActiveMode {
+	mode := cfg.ActiveMode
+	switch cfg.EmbeddingProvider {
+	case "":
+	case "ollama":
+		mode = "local"
+	case "gemini", "openai":
+		mode = cfg.EmbeddingProvider
+	default:
+		return nil, fmt.Errorf("unknown embedding provider %q (use ollama, gemini or openai)", cfg.EmbeddingProvider)
+	}
+
+	switch mode {
 	case "local":
 		// Pure Ollama mode for both LLM and embeddings (offline mode)
 		providerName := cfg.LocalEmbeddingModelName
@@ -368,22 +462,21 @@ func (a *App) initializeEmbeddingServices(cfg llm.OpenRouterConfig) error {
 		if cfg.GeminiApiKey == "" {
 			errProv = fmt.Errorf("Gemini API key missing for 'gemini' mode")
 		} else {
-			chosenProvider = embeddings.NewGeminiEmbeddingProvider(cfg.GeminiApiKey)
+			chosenProvider = embeddings.NewGeminiEmbeddingProvider(cfg.GeminiApiKey, cfg.GeminiEmbeddingModel, cfg.GeminiEmbeddingDimensions)
 		}
 	case "openai":
 		if cfg.OpenAIAPIKey == "" {
 			errProv = fmt.Errorf("OpenAI API key missing for 'openai' mode")
 		} else {
-			// Use the new OpenAIEmbeddingProvider.
-			// The default model "text-embedding-3-small" will be used as defined in the provider.
-			chosenProvider, errProv = embeddings.NewOpenAIEmbeddingProvider(cfg.OpenAIAPIKey)
+			// An empty model uses the provider's default, "text-embedding-3-small"
+			chosenProvider, errProv = embeddings.NewOpenAIEmbeddingProvider(cfg.OpenAIAPIKey, cfg.OpenAIEmbeddingModel)
 		}
 	case "openrouter":
 		// For 'openrouter' mode, embeddings might still use Gemini or local,
 		// depending on your backend logic. Current code defaults to Gemini if key available.
 		log.Println("ActiveMode is 'openrouter'. Embedding provider will be Gemini if API key is set, otherwise check local.")
 		if cfg.GeminiApiKey != "" {
-			chosenProvider = embeddings.NewGeminiEmbeddingProvider(cfg.GeminiApiKey)
+			chosenProvider = embeddings.NewGeminiEmbeddingProvider(cfg.GeminiApiKey, cfg.GeminiEmbeddingModel, cfg.GeminiEmbeddingDimensions)
 		} else if cfg.LocalEmbeddingModelName != "" {
 			log.Printf("Gemini key not found for 'openrouter' mode embeddings, falling back to local Ollama model: %s", cfg.LocalEmbeddingModelName)
 			chosenProvider, errProv = embeddings.NewLocalEmbeddingProvider(cfg.LocalEmbeddingModelName)
@@ -399,25 +492,69 @@ func (a *App) initializeEmbeddingServices(cfg llm.OpenRouterConfig) error {
 		}
 	}
 
+	if errProv != nil {
+		return nil, errProv
+	}
+	return chosenProvider, nil
+}
+
+// initializeEmbeddingServices sets up the embedding provider and related services.
+// It should be called on vault switch and after settings are saved. An unavailable
+// provider is replaced by the built-in one; embedFallback records why.
+func (a *App) initializeEmbeddingServices(cfg llm.OpenRouterConfig) {
+	log.Printf("Initializing/Re-initializing embedding services for ActiveMode: '%s'", cfg.ActiveMode)
+
+	chosenProvider, errProv := newEmbeddingProvider(cfg)
+
+	// Retrieval keeps working without any external service, just less accurately
+	a.embedFallback = ""
 	if errProv != nil || chosenProvider == nil {
-		log.Printf("CRITICAL: Failed to initialize embedding provider for mode '%s': %v. RAG/Embedding features will be disabled.", cfg.ActiveMode, errProv)
-		a.embeddingService = nil
-		a.contextBuilder = nil
-		a.promptBuilder = nil
-		return fmt.Errorf("failed to initialize embedding provider: %w. RAG/Embedding features disabled", errProv)
+		if errProv == nil {
+			errProv = fmt.Errorf("no embedding provider for mode '%s'", cfg.ActiveMode)
+		}
+		log.Printf("Warning: Embedding provider for mode '%s' unavailable: %v. Falling back to built-in offline embeddings.", cfg.ActiveMode, errProv)
+		chosenProvider = embeddings.NewBuiltinEmbeddingProvider()
+		a.embedFallback = errProv.Error()
 	}
 
-	log.Printf("Embedding provider successfully initialized: %s", chosenProvider.ModelIdentifier())
+	log.Printf("Embedding provider initialized: %s", chosenProvider.ModelIdentifier())
+	a.stopEmbeddingJobs()
+	a.saveVectorIndex() // Persist the outgoing provider's index before replacing the service
 	a.embeddingService = embeddings.NewEmbeddingService(a.db, chosenProvider)
-	a.contextBuilder = ragcontext.NewContextBuilder(a.embeddingService)
+	if err := a.embeddingService.SetVectorStorage(a.loadVectorStorage()); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	a.contextBuilder = ragcontext.NewContextBuilder(a.embeddingService, a.db)
+	a.contextBuilder.SetFusionWeights(cfg.RetrievalVectorWeight, cfg.RetrievalKeywordWeight)
+	a.contextBuilder.SetIncludeChatMemory(cfg.ChatMemoryEnabled)
+	a.configureQueryPlanning(cfg)
+	a.configureRetrievalTuning(cfg)
 	a.promptBuilder = llm.NewPromptBuilder(a.contextBuilder)
 
 	// Load cache and process missing embeddings only if DB is available
 	if a.db != nil {
+		a.startEmbeddingJobs() // Resumes jobs left over from the last session
+		service := a.embeddingService
+		indexDir := filepath.Join(a.dbPath, "Codex", "Index")
 		go func() {
+			// Searches fall back to a table scan until the ANN index is loaded
+			if err := service.OpenIndex(indexDir); err != nil {
+				log.Printf("Warning: Failed to open vector index: %v", err)
+			}
 			if err := a.GenerateMissingEmbeddings(); err != nil {
 				log.Printf("Warning: Failed to generate missing embeddings in background: %v", err)
 			}
+			if _, err := a.ReindexLibrary(); err != nil {
+				log.Printf("Warning: Failed to index Library manuscripts in background: %v", err)
+			}
+			if cfg.ChatMemoryEnabled {
+				if _, err := a.ReindexChatMemory(); err != nil {
+					log.Printf("Warning: Failed to index chat memory in background: %v", err)
+				}
+			}
+			if err := service.SaveIndex(); err != nil {
+				log.Printf("Warning: Failed to save vector index: %v", err)
+			}
 		}()
 	} else {
 		log.Println("Database not available, skipping missing embedding generation for now.")
@@ -434,7 +571,6 @@ func (a *App) initializeEmbeddingServices(cfg llm.OpenRouterConfig) error {
 	}
 
 	log.Printf("Successfully initialized embedding services for vault: %s", a.dbPath)
-	return nil
 }
 
 // initializeLLM sets up the LLM service based on the current configuration
@@ -480,6 +616,20 @@ func (a *App) initializeLLM(cfg llm.OpenRouterConfig, vaultPath string) error {
 	return nil
 }
 
+// applyHTTPSettings configures the transport shared by all LLM and embedding providers.
+func applyHTTPSettings(cfg llm.OpenRouterConfig) error {
+	timeouts := make(map[string]time.Duration, len(cfg.ProviderTimeouts))
+	for name, seconds := range cfg.ProviderTimeouts {
+		timeouts[name] = time.Duration(seconds) * time.Second
+	}
+	return provider.ConfigureHTTP(provider.HTTPSettings{
+		ProxyURL:            cfg.HTTPProxyURL,
+		CACertPath:          cfg.CACertPath,
+		Timeouts:            timeouts,
+		MaxIdleConnsPerHost: cfg.MaxIdleConnsPerHost,
+	})
+}
+
 // FetchOllamaModels returns a list of available local Ollama models.
 func (a *App) FetchOllamaModels() ([]llm.OpenRouterModel, error) {
 	log.Println("App.FetchOllamaModels called")
@@ -502,6 +652,7 @@ func (a *App) FetchOpenAIModels() ([]llm.OpenRouterModel, error) {
 	// Create a new OpenAI client with the API key
 	client := openai.NewClient(
 		option.WithAPIKey(cfg.OpenAIAPIKey),
+		option.WithHTTPClient(provider.HTTPClient(provider.ClientModelList)),
 	)
 
 	// Fetch the list of models
@@ -573,7 +724,7 @@ func (a *App) FetchGeminiModels() ([]llm.OpenRouterModel, error) {
 		return nil, fmt.Errorf("error creating request for Gemini models: %w", err)
 	}
 
-	client := &http.Client{Timeout: 15 * time.Second} // Added timeout
+	client := provider.HTTPClient(provider.ClientModelList)
 	resp, err := client.Do(req)
 	if err != nil {
 		log.Printf("FetchGeminiModels: Failed to fetch models from API: %v", err)
@@ -639,102 +790,67 @@ func (a *App) FetchGeminiModels() ([]llm.OpenRouterModel, error) {
 	return openRouterModels, nil
 }
 
-// GenerateLLMContent is a new wrapper to dispatch to the correct LLM provider
+// GenerateLLMContent is a new wrapper to dispatch to the correct LLM provider.
+// Only the final answer is returned; reasoning-model chain-of-thought is stripped.
 func (a *App) GenerateLLMContent(prompt, modelID string) (string, error) {
+	completion, err := a.generateCompletion(prompt, modelID)
+	if err != nil {
+		return "", err
+	}
+	return completion.Content, nil
+}
+
+// generateCompletion dispatches to the active provider and returns the answer with any
+// reasoning separated out. Reasoning is forwarded to the trace view when enabled in settings.
+func (a *App) generateCompletion(prompt, modelID string) (llm.Completion, error) {
 	cfg := llm.GetConfig()
 	log.Printf("GenerateLLMContent called for mode: %s, model: %s", cfg.ActiveMode, modelID)
 
+	completion, err := a.dispatchCompletion(cfg, prompt, modelID)
+	if err != nil {
+		return llm.Completion{}, err
+	}
+	if completion.Reasoning != "" {
+		log.Printf("Separated %d chars of reasoning from model '%s' response", len(completion.Reasoning), completion.Model)
+		if cfg.ExposeReasoning {
+			a.emitTrace("reasoning", completion)
+		}
+	}
+	return completion, nil
+}
+
+// dispatchCompletion sends the prompt to the provider selected by cfg.ActiveMode.
+func (a *App) dispatchCompletion(cfg llm.OpenRouterConfig, prompt, modelID string) (llm.Completion, error) {
 	switch cfg.ActiveMode {
 	case "openai":
-		if cfg.OpenAIAPIKey == "" {
-			return "", fmt.Errorf("OpenAI API key not set. Cannot use OpenAI LLM")
-		}
-		client := openai.NewClient(
-			option.WithAPIKey(cfg.OpenAIAPIKey),
-		)
 		// If modelID is empty, choose a default from available OpenAI models
 		effectiveModelID := modelID
 		if effectiveModelID == "" {
 			effectiveModelID = "gpt-3.5-turbo" // Using string literal for safety with openai-go v0.1.0-beta.10
 			log.Printf("No modelID provided for OpenAI, defaulting to %s", effectiveModelID)
 		}
-		log.Printf("Sending prompt to OpenAI model %s", effectiveModelID)
-
-		// Create a chat completion using the official SDK
-		completion, err := client.Chat.Completions.New(
-			context.Background(),
-			openai.ChatCompletionNewParams{
-				Model: effectiveModelID,
-				Messages: []openai.ChatCompletionMessageParamUnion{
-					openai.UserMessage(prompt),
-				},
-			},
-		)
-		if err != nil {
-			return "", fmt.Errorf("OpenAI chat completion error: %w", err)
-		}
-		if len(completion.Choices) == 0 || completion.Choices[0].Message.Content == "" {
-			return "", fmt.Errorf("OpenAI returned no choices or empty content")
-		}
-		return completion.Choices[0].Message.Content, nil
+		return llm.CompleteWithOpenAI(cfg.OpenAIAPIKey, prompt, effectiveModelID)
 
 	case "gemini":
-		if cfg.GeminiApiKey == "" {
-			return "", fmt.Errorf("Gemini API key not set. Cannot use Gemini LLM")
-		}
 		effectiveModelID := modelID
 		if effectiveModelID == "" {
 			effectiveModelID = "gemini-1.0-pro" // Default, or use "gemini-1.5-pro-latest"
 			log.Printf("No modelID provided for Gemini, defaulting to %s", effectiveModelID)
 		}
-
-		genaiClient, err := genai.NewClient(context.Background(), &genai.ClientConfig{APIKey: cfg.GeminiApiKey})
-		if err != nil {
-			return "", fmt.Errorf("failed to create Gemini client: %w", err)
-		}
-
-		// Use client.Models.GenerateContent directly
-		// The prompt is already a string, so genai.Text(prompt) is appropriate.
-		resp, err := genaiClient.Models.GenerateContent(context.Background(), effectiveModelID, genai.Text(prompt), nil) // Passing nil for config as per simple examples
-		if err != nil {
-			return "", fmt.Errorf("failed to generate content with Gemini: %w", err)
-		}
-
-		// Process and return the response based on example_test.go patterns
-		if resp != nil && len(resp.Candidates) > 0 && resp.Candidates[0].Content != nil && len(resp.Candidates[0].Content.Parts) > 0 {
-			part := resp.Candidates[0].Content.Parts[0]
-			if part != nil {
-				// Assuming the part is text and *genai.Part has a Text field of type string.
-				// The type genai.Text is used for constructing input parts.
-				// For output, the Part struct likely holds the text directly.
-				// If 'Text' is not the correct field, the compiler will tell us.
-				// Based on examples like `result.Candidates[0].Content.Parts[0].Text` in example_test.go for streams.
-				// For a non-streaming GenerateContent, the structure should be similar for a text part.
-				textValue := part.Text // Accessing the Text field directly.
-				if textValue != "" {   // Check if the text content is not empty
-					log.Printf("Gemini Raw Response Part: %s", textValue)
-					return textValue, nil
-				} else {
-					log.Printf("Gemini response part.Text was empty")
-				}
-			} else {
-				log.Printf("Gemini response part was nil")
-			}
-		}
-		return "", fmt.Errorf("gemini response was empty or not in expected format")
+		return llm.CompleteWithGemini(cfg.GeminiApiKey, prompt, effectiveModelID)
 
 	case "openrouter", "hybrid": // OpenRouter for LLM in both openrouter and hybrid modes
 		if cfg.APIKey == "" { // This is OpenRouter API key
-			return "", fmt.Errorf("OpenRouter API key not set. Cannot use OpenRouter LLM")
+			return llm.Completion{}, provider.NewError("openrouter", modelID, provider.ErrNotConfigured, "OpenRouter API key not set. Cannot use OpenRouter LLM", nil)
 		}
 		if modelID == "" {
-			return "", fmt.Errorf("no modelID provided for OpenRouter LLM mode")
+			return llm.Completion{}, provider.NewError("openrouter", modelID, provider.ErrNotConfigured, "no modelID provided for OpenRouter LLM mode", nil)
 		}
-		return llm.GetOpenRouterCompletion(prompt, modelID)
+		return llm.CompleteWithOpenRouter(prompt, modelID)
 	case "local": // Ollama for LLM in pure local mode
 		log.Printf("Using local Ollama model '%s' for LLM content generation.", modelID)
 		if modelID == "" {
-			return "", fmt.Errorf("no modelID provided for Local Ollama LLM mode")
+			return llm.Completion{}, provider.NewError("ollama", modelID, provider.ErrNotConfigured, "no modelID provided for Local Ollama LLM mode", nil)
 		}
 
 		// Add warning for larger models that might take longer
@@ -742,16 +858,17 @@ func (a *App) GenerateLLMContent(prompt, modelID string) (string, error) {
 			log.Printf("WARNING: Using a larger model (%s) which may take longer to respond. Timeout set to 5 minutes.", modelID)
 		}
 
-		// Add extra error handling to prevent application crashes
-		response, err := llm.GetOllamaCompletion(prompt, modelID)
+		// Errors are returned, never folded into the completion text, so they can't end
+		// up saved as codex content or woven into a manuscript.
+		response, err := llm.CompleteWithOllama(prompt, modelID)
 		if err != nil {
 			log.Printf("ERROR: Failed to get Ollama completion: %v", err)
-			return fmt.Sprintf("[Error: Unable to get response from Ollama model '%s'. Please ensure Ollama is running and the model is pulled. Error details: %v]", modelID, err), nil
+			return llm.Completion{}, err
 		}
 		return response, nil
 
 	default:
-		return "", fmt.Errorf("unsupported LLM ActiveMode: %s. Please configure in Settings", cfg.ActiveMode)
+		return llm.Completion{}, provider.NewError(cfg.ActiveMode, modelID, provider.ErrNotConfigured, fmt.Sprintf("unsupported LLM ActiveMode: %s. Please configure in Settings", cfg.ActiveMode), nil)
 	}
 }
 
@@ -859,6 +976,7 @@ func (a *App) ImportStoryTextAndFile(text string, providedFilename string) (Proc
 	if err := a.refreshLibraryFiles(); err != nil {
 		log.Printf("Warning: Failed to refresh library files after import: %v", err)
 	}
+	a.queueLibraryIndex(filename)
 
 	// Process the story into codex entries
 	result, err := a.ProcessStory(text) // This already returns ProcessStoryResult
@@ -920,6 +1038,7 @@ func (a *App) SaveLibraryFile(filename string, content string) error {
 	}
 
 	log.Printf("Successfully saved library file: %s", filePath)
+	a.queueLibraryIndex(filename)
 	return nil
 }
 
@@ -1089,6 +1208,7 @@ func (a *App) DeleteLibraryItem(itemPath string) error {
 		itemType = "folder"
 	}
 	log.Printf("Deleted library %s: %s", itemType, itemPath)
+	a.removeLibraryIndex(itemPath)
 	return nil
 }
 
@@ -1137,6 +1257,7 @@ func (a *App) MoveLibraryItem(sourcePath, destPath string) error {
 	}
 	
 	log.Printf("Successfully moved library item from '%s' to '%s'", sourcePath, destPath)
+	a.moveLibraryIndex(sourcePath, destPath)
 	return nil
 }
 
@@ -1192,6 +1313,7 @@ func (a *App) CopyLibraryItem(sourcePath, destPath string) error {
 	}
 	
 	log.Printf("Successfully copied library item from '%s' to '%s'", sourcePath, destPath)
+	a.queueLibraryIndex(destPath)
 	return nil
 }
 
@@ -1313,6 +1435,7 @@ func (a *App) SaveLibraryFileWithPath(filePath string, content string) error {
 	}
 	
 	log.Printf("Successfully saved library file: %s", filePath)
+	a.queueLibraryIndex(filePath)
 	return nil
 }
 
@@ -1496,6 +1619,7 @@ func (a *App) SaveChatLog(filename string, messages []ChatMessage) error {
 	}
 
 	log.Printf("Saved chat log to: %s", chatFilePath)
+	a.queueChatMemory(filename)
 	return nil
 }
 
@@ -1520,6 +1644,9 @@ func (a *App) DeleteChatLog(filename string) error {
 	}
 
 	log.Printf("Deleted chat log: %s", chatFilePath)
+	if err := database.DBDeleteChatSession(a.db, filename); err != nil {
+		log.Printf("Warning: %v", err)
+	}
 	return nil
 }
 
@@ -1545,18 +1672,26 @@ func (a *App) startup(ctx context.Context) {
 		log.Printf("Warning: Failed to load OpenRouter configuration: %v. API key might be missing.", err)
 	}
 
+	// Proxy and CA settings must be in place before any provider makes a request
+	if err := applyHTTPSettings(llm.GetConfig()); err != nil {
+		log.Printf("Warning: Failed to apply network settings, using defaults: %v", err)
+	}
+
 	log.Println("App startup complete.")
 }
 
 // shutdown is called when the app terminates.
 func (a *App) shutdown(ctx context.Context) {
 	log.Println("Llore application shutting down...")
+	a.cancelEmbeddingMigration()
+	a.stopEmbeddingJobs()
+	a.saveVectorIndex()
 }
 
 // ProcessStory sends a prompt to the LLM and processes the structured response.
 func (a *App) ProcessStory(storyText string) (ProcessStoryResult, error) {
 	// Prepare the prompt based on content type
-	simplifiedPrompt := fmt.Sprintf("Analyze the following text and extract key entities (characters, locations, items, concepts) and their descriptions. Be thorough and try to identify anywhere from 3 to 15 distinct entities. Format the output as a JSON array where each object has 'name', 'type', and 'content' fields. Types should be one of: Character, Location, Item, Concept. Do not include any text before or after the JSON array. Example: [{\"name\": \"Sir Reginald\", \"type\": \"Character\", \"content\": \"A brave knight known for his shiny armor.\"}]. Text to analyze:\n\n%s", storyText)
+	simplifiedPrompt := storyExtractionPrompt(storyText)
 
 	log.Println("Sending prompt for story processing...")
 	cfg := llm.GetConfig()
@@ -1609,46 +1744,18 @@ func (a *App) ProcessStory(storyText string) (ProcessStoryResult, error) {
 	}
 
 	log.Println("Received LLM response, attempting to parse JSON...")
-	// Clean up response - remove code block markers if present
-	llmResponse = strings.TrimSpace(llmResponse)
-	if strings.HasPrefix(llmResponse, "```") {
-		// Find the end of the opening code block
-		newlineIndex := strings.Index(llmResponse, "\n")
-		if newlineIndex > 0 {
-			// Remove opening code block line
-			llmResponse = llmResponse[newlineIndex+1:]
-		}
-		// Remove closing code block
-		llmResponse = strings.TrimSuffix(llmResponse, "```")
-	}
-	llmResponse = strings.TrimSpace(llmResponse)
-
-	// Attempt to parse the structured response (expecting a JSON array of objects)
-	var llmEntries []struct {
-		Name    string `json:"name"`
-		Type    string `json:"type"`
-		Content string `json:"content"`
-	}
-
-	err = json.Unmarshal([]byte(llmResponse), &llmEntries)
+	llmEntries, err := parseExtractedEntities(llmResponse)
 	if err != nil {
 		// Handle cases where the response isn't valid JSON or the expected structure
 		log.Printf("Warning: LLM response was not a valid JSON array of entries. Error: %v", err)
 		log.Printf("LLM Response Text:\n%s", llmResponse)
 		// Fallback: Treat the entire response as the content of a single entry
-		// Remove unused fallbackEntry declaration
-		// Fallback: Treat the entire response as the content of a single entry
 		log.Println("Falling back to creating a single entry with the raw LLM response.")
-		fallbackEntry := struct {
-			Name    string `json:"name"`
-			Type    string `json:"type"`
-			Content string `json:"content"`
-		}{
+		llmEntries = append(llmEntries, extractedEntity{
 			Name:    "Generated Entry",
 			Type:    "Generated",
-			Content: llmResponse,
-		}
-		llmEntries = append(llmEntries, fallbackEntry)
+			Content: stripCodeFence(llmResponse),
+		})
 	}
 
 	// Process the structured entries
@@ -1733,6 +1840,42 @@ func (a *App) ProcessStory(storyText string) (ProcessStoryResult, error) {
 	return ProcessStoryResult{NewEntries: newEntriesResult, UpdatedEntries: updatedEntriesResult}, nil // Return the struct
 }
 
+// extractedEntity is one entity in the JSON array returned by the story extraction prompt.
+type extractedEntity struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Content string `json:"content"`
+}
+
+// storyExtractionPrompt builds the entity extraction prompt used by ProcessStory.
+func storyExtractionPrompt(storyText string) string {
+	return fmt.Sprintf("Analyze the following text and extract key entities (characters, locations, items, concepts) and their descriptions. Be thorough and try to identify anywhere from 3 to 15 distinct entities. Format the output as a JSON array where each object has 'name', 'type', and 'content' fields. Types should be one of: Character, Location, Item, Concept. Do not include any text before or after the JSON array. Example: [{\"name\": \"Sir Reginald\", \"type\": \"Character\", \"content\": \"A brave knight known for his shiny armor.\"}]. Text to analyze:\n\n%s", storyText)
+}
+
+// stripCodeFence removes the ```json ... ``` wrapper models often put around JSON.
+func stripCodeFence(text string) string {
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "```") {
+		// Find the end of the opening code block
+		if newlineIndex := strings.Index(text, "\n"); newlineIndex > 0 {
+			// Remove opening code block line
+			text = text[newlineIndex+1:]
+		}
+		// Remove closing code block
+		text = strings.TrimSuffix(text, "```")
+	}
+	return strings.TrimSpace(text)
+}
+
+// parseExtractedEntities parses the extraction model's JSON array of entities.
+func parseExtractedEntities(llmResponse string) ([]extractedEntity, error) {
+	var entities []extractedEntity
+	if err := json.Unmarshal([]byte(stripCodeFence(llmResponse)), &entities); err != nil {
+		return nil, err
+	}
+	return entities, nil
+}
+
 // GenerateOpenRouterContent calls OpenRouter with prompt/model and returns the response.
 func (a *App) GenerateOpenRouterContent(prompt, model string) (string, error) {
 	if err := llm.LoadOpenRouterConfig(); err != nil { // Ensure config is loaded (or attempt reload)
@@ -1766,7 +1909,6 @@ func (a *App) GenerateMissingEmbeddings() error {
 	}
 	defer rows.Close()
 
-	var processedCount int
 	var entriesToProcess []database.CodexEntry // Collect entries first
 
 	for rows.Next() {
@@ -1782,45 +1924,190 @@ func (a *App) GenerateMissingEmbeddings() error {
 		// Continue processing the entries found so far
 	}
 
+	// Long entries embedded before passage chunking existed still need their passages
+	chunkRows, err := a.db.Query(`
+        SELECT e.id, e.name, e.type, e.content
+        FROM codex_entries e
+        WHERE length(e.content) > ?
+          AND NOT EXISTS (SELECT 1 FROM codex_embedding_chunks c WHERE c.codex_entry_id = e.id AND c.vector_version = ?)
+          AND EXISTS (SELECT 1 FROM codex_embeddings em WHERE em.codex_entry_id = e.id AND em.vector_version = ?)
+    `, embeddings.DefaultChunkSize, currentProviderIdentifier, currentProviderIdentifier)
+	if err != nil {
+		log.Printf("Warning: Failed to query entries missing passage embeddings: %v", err)
+	} else {
+		var needChunks []database.CodexEntry
+		for chunkRows.Next() {
+			var entry database.CodexEntry
+			if err := chunkRows.Scan(&entry.ID, &entry.Name, &entry.Type, &entry.Content); err == nil {
+				needChunks = append(needChunks, entry)
+			}
+		}
+		chunkRows.Close()
+		if len(needChunks) > 0 {
+			log.Printf("Generating passage embeddings for %d long entries...", len(needChunks))
+		}
+		for _, entry := range needChunks {
+			a.saveEntryChunks(a.embeddingService, a.newEmbeddingRequest(entry))
+		}
+	}
+
+	// Edits whose queued re-embedding was dropped leave vectors made from old text
+	if stale, _, err := a.findStaleEntries(false); err != nil {
+		log.Printf("Warning: Failed to check for stale embeddings: %v", err)
+	} else if len(stale) > 0 {
+		log.Printf("Found %d entries with stale embeddings, re-embedding them too.", len(stale))
+		entriesToProcess = append(entriesToProcess, stale...)
+	}
+
 	if len(entriesToProcess) == 0 {
 		log.Println("No missing embeddings found.")
 		return nil
 	}
 
-	log.Printf("Found %d entries missing embeddings. Processing...", len(entriesToProcess))
+	// Queue them as jobs so the work resumes after a restart and reports progress
+	ids := make([]int64, len(entriesToProcess))
+	for i, entry := range entriesToProcess {
+		ids[i] = entry.ID
+	}
+	queued := a.queueEntryEmbeddings(ids)
+	log.Printf("Found %d entries missing embeddings, %d newly queued.", len(entriesToProcess), queued)
+	return nil
+}
 
-	// Process entries sequentially to avoid overwhelming the API
-	for _, entry := range entriesToProcess {
-		// Create text for embedding
-		text := fmt.Sprintf("Name: %s\nType: %s\nContent: %s",
-			entry.Name, entry.Type, entry.Content)
+// embedRequests embeds and saves a batch of entries with service, plus passage embeddings
+// for long ones, returning the error for each entry that could not be saved. The whole-entry
+// vector is made from the first MaxEntryTextChars of the text; passages cover all of it and
+// are saved even when the whole-entry vector fails.
+func (a *App) embedRequests(service *embeddings.EmbeddingService, reqs []embeddingRequest) map[int64]error {
+	ids := make([]int64, len(reqs))
+	texts := make([]string, len(reqs))
+	hashes := make([]string, len(reqs))
+	for i, req := range reqs {
+		ids[i] = req.entryID
+		texts[i] = embeddings.CapText(req.text, embeddings.MaxEntryTextChars)
+		hashes[i] = embeddings.ContentHash(req.text)
+	}
+
+	vectors, err := service.CreateEmbeddings(texts)
+	if err != nil {
+		// One bad entry shouldn't cost the whole batch; retry one entry at a time
+		log.Printf("Warning: Batch embedding failed for %d entries, retrying individually: %v", len(reqs), err)
+		return a.embedEntriesIndividually(service, reqs)
+	}
+	var failed map[int64]error
+	if err := service.SaveEmbeddings(ids, vectors, hashes); err != nil {
+		log.Printf("Warning: Failed to save embeddings for entries %v: %v", ids, err)
+		failed = make(map[int64]error, len(reqs))
+		for _, id := range ids {
+			failed[id] = err
+		}
+	}
+	for _, req := range reqs {
+		a.saveEntryChunks(service, req)
+	}
+	return failed
+}
 
-		// Generate embedding
-		embedding, err := a.embeddingService.CreateEmbedding(text)
+// embedEntriesIndividually embeds and saves entries one by one, returning the error for
+// each entry that failed.
+func (a *App) embedEntriesIndividually(service *embeddings.EmbeddingService, reqs []embeddingRequest) map[int64]error {
+	failed := make(map[int64]error)
+	for _, req := range reqs {
+		a.saveEntryChunks(service, req)
+		embedding, err := service.CreateEmbedding(embeddings.CapText(req.text, embeddings.MaxEntryTextChars))
 		if err != nil {
-			log.Printf("Warning: Failed to create embedding for entry %d: %v", entry.ID, err)
-			// Consider adding a delay or backoff here if API errors are frequent
-			time.Sleep(1 * time.Second) // Simple delay
-			continue                    // Skip this entry
+			log.Printf("Warning: Failed to create embedding for entry %d: %v", req.entryID, err)
+			failed[req.entryID] = err
+			continue
 		}
-
-		// Save embedding
-		if err := a.embeddingService.SaveEmbedding(entry.ID, embedding); err != nil {
-			log.Printf("Warning: Failed to save embedding for entry %d: %v", entry.ID, err)
-			continue // Skip this entry
+		if err := service.SaveEmbedding(req.entryID, embedding, embeddings.ContentHash(req.text)); err != nil {
+			log.Printf("Warning: Failed to save embedding for entry %d: %v", req.entryID, err)
+			failed[req.entryID] = err
 		}
+	}
+	return failed
+}
 
-		processedCount++
-		log.Printf("Generated embedding for entry %d", entry.ID)
-		time.Sleep(500 * time.Millisecond) // Add a small delay between API calls
+// saveEntryChunks refreshes the passage embeddings of an entry; failures only cost passage-level search.
+func (a *App) saveEntryChunks(service *embeddings.EmbeddingService, req embeddingRequest) {
+	if err := service.SaveEntryChunks(req.entryID, req.title, req.body); err != nil {
+		log.Printf("Warning: Failed to save passage embeddings for entry %d: %v", req.entryID, err)
 	}
+}
 
-	log.Printf("Finished generating missing embeddings. Processed %d entries.", processedCount)
-	return nil
+// saveVectorIndex persists the ANN index of the current embedding service, if any.
+func (a *App) saveVectorIndex() {
+	if a.embeddingService == nil || a.db == nil {
+		return
+	}
+	if err := a.embeddingService.SaveIndex(); err != nil {
+		log.Printf("Warning: Failed to save vector index: %v", err)
+	}
+}
+
+// GetVectorIndexStats reports whether the ANN index is loaded and how many vectors it holds.
+func (a *App) GetVectorIndexStats() embeddings.IndexStats {
+	if a.embeddingService == nil {
+		return embeddings.IndexStats{}
+	}
+	return a.embeddingService.IndexStats()
+}
+
+// EmbeddingProviderStatus tells the UI which embedding provider is in use and whether it
+// is the built-in fallback.
+type EmbeddingProviderStatus struct {
+	ModelIdentifier string `json:"modelIdentifier"` // Empty when no provider is set up yet
+	Fallback        bool   `json:"fallback"`        // The configured provider was unavailable
+	Reason          string `json:"reason"`          // Why it was unavailable
+}
+
+// GetEmbeddingProviderStatus reports the embedding provider in use. A fallback to the
+// built-in embeddings lasts until settings are saved or a vault is opened again.
+func (a *App) GetEmbeddingProviderStatus() EmbeddingProviderStatus {
+	if a.embeddingService == nil {
+		return EmbeddingProviderStatus{}
+	}
+	return EmbeddingProviderStatus{
+		ModelIdentifier: a.embeddingService.ModelIdentifier(),
+		Fallback:        a.embedFallback != "",
+		Reason:          a.embedFallback,
+	}
 }
 
 // GetAIResponseWithContext uses the chat model ID from settings.
 func (a *App) GetAIResponseWithContext(query string, modelID string) (string, error) {
+	return a.GetAIResponseWithHistory(query, modelID, nil)
+}
+
+// GetAIResponseWithHistory is GetAIResponseWithContext for a message in a chat. The
+// earlier messages let query rewriting resolve follow-ups before context is retrieved.
+func (a *App) GetAIResponseWithHistory(query string, modelID string, history []ChatMessage) (string, error) {
+	return a.GetAIResponseForDocument(query, modelID, history, "")
+}
+
+// GetAIResponseForDocument is GetAIResponseWithHistory while working on a document, such
+// as a chapter in the write view. Codex entries named in the document are always
+// included in the context.
+func (a *App) GetAIResponseForDocument(query string, modelID string, history []ChatMessage, document string) (string, error) {
+	response, err := a.GetAIResponseWithSources(query, modelID, history, document)
+	if err != nil {
+		return "", err
+	}
+	return response.Answer, nil
+}
+
+// AIResponse is an answer together with the context it was based on.
+type AIResponse struct {
+	Answer  string                     `json:"answer"`
+	Model   string                     `json:"model"` // As reported by the provider, if it did
+	Usage   llm.Usage                  `json:"usage"`
+	Sources []ragcontext.ContextSource `json:"sources"` // Best first; empty if no context was used
+}
+
+// GetAIResponseWithSources is GetAIResponseForDocument that also returns the codex
+// entries and passages put into the prompt, with their scores, and the token usage, so
+// the answer can be shown with its sources.
+func (a *App) GetAIResponseWithSources(query string, modelID string, history []ChatMessage, document string) (AIResponse, error) {
 	// modelID here is expected to be cfg.ChatModelID
 	if modelID == "" {
 		cfg := llm.GetConfig()
@@ -1834,26 +2121,39 @@ func (a *App) GetAIResponseWithContext(query string, modelID string) (string, er
 			case "openrouter", "local":
 				modelID = "openai/gpt-3.5-turbo" // A common OpenRouter default
 			default:
-				return "", fmt.Errorf("chat model not configured and no default for mode %s", cfg.ActiveMode)
+				return AIResponse{}, fmt.Errorf("chat model not configured and no default for mode %s", cfg.ActiveMode)
 			}
 			log.Printf("GetAIResponseWithContext: modelID was empty, defaulted to %s for mode %s", modelID, cfg.ActiveMode)
 		}
 	}
 
+	prompt := query
+	sources := []ragcontext.ContextSource{}
 	if a.promptBuilder == nil {
 		log.Println("Warning: GetAIResponseWithContext called but prompt builder not initialized. Falling back to simple generation.")
-		return a.GenerateLLMContent(query, modelID) // USE NEW METHOD
+	} else {
+		log.Printf("Building prompt with context for query: %s", query)
+		built, used, err := a.promptBuilder.BuildPromptWithSources(ragcontext.Request{Query: query, History: chatTurns(history), Document: document})
+		if err != nil {
+			log.Printf("Error building prompt with context: %v. Falling back to simple prompt.", err)
+		} else {
+			prompt = built
+			if used != nil {
+				sources = used
+			}
+		}
+		log.Printf("Sending RAG prompt (length: %d) to model: %s", len(prompt), modelID)
 	}
 
-	log.Printf("Building prompt with context for query: %s", query)
-	prompt, err := a.promptBuilder.BuildPromptWithContext(query)
+	completion, err := a.generateCompletion(prompt, modelID)
 	if err != nil {
-		log.Printf("Error building prompt with context: %v. Falling back to simple prompt.", err)
-		prompt = query
+		return AIResponse{}, err
 	}
-
-	log.Printf("Sending RAG prompt (length: %d) to model: %s", len(prompt), modelID)
-	return a.GenerateLLMContent(prompt, modelID) // USE NEW METHOD
+	model := completion.Model
+	if model == "" {
+		model = modelID
+	}
+	return AIResponse{Answer: completion.Content, Model: model, Usage: completion.Usage, Sources: sources}, nil
 }
 
 // MergeEntryContentDirect merges existing entry content with new content using direct AI prompting without RAG
@@ -2083,13 +2383,15 @@ func (a *App) SaveSettings(config llm.OpenRouterConfig) error {
 	}
 	log.Println("Settings saved to file successfully. Re-initializing services...")
 
+	if err := applyHTTPSettings(config); err != nil {
+		log.Printf("Warning: Failed to apply network settings: %v", err)
+		return fmt.Errorf("settings saved, but network settings are invalid: %w", err)
+	}
+
 	// CRITICAL: Re-initialize embedding services with the new config
 	// This ensures that if ActiveMode or related keys/models changed, the app uses them.
-	if err := a.initializeEmbeddingServices(config); err != nil {
-		log.Printf("Warning: Failed to re-initialize embedding services after saving settings: %v", err)
-		// Return this error to the frontend so it can display it
-		return fmt.Errorf("settings saved, but failed to apply embedding service changes: %w. Embeddings might not work as expected until next vault switch or app restart", err)
-	}
+	// A provider that can't be used is reported by GetEmbeddingProviderStatus
+	a.initializeEmbeddingServices(config)
 
 	log.Println("Services re-initialized based on new settings.")
 	return nil
@@ -2133,33 +2435,36 @@ func getLastNChars(s string, n int) string {
 	return s[len(s)-n:]
 }
 
-// initEmbeddingWorker initializes the embedding worker goroutine
-func (a *App) initEmbeddingWorker() {
-	embeddingQueueOnce.Do(func() {
-		go func() {
-			for req := range embeddingQueue {
-				if a.embeddingService == nil {
-					log.Println("Warning: Embedding service not initialized, skipping embedding for entry:", req.entryID)
-					continue
-				}
-
-				// Create embedding
-				embedding, err := a.embeddingService.CreateEmbedding(req.text)
-				if err != nil {
-					log.Printf("Warning: Failed to create embedding for entry %d: %v", req.entryID, err)
-					continue
-				}
+// formatBindingError is the Wails ErrorFormatter. Provider failures are sent to the
+// frontend as structured objects with a machine-readable code; all other errors keep
+// their existing plain-string form.
+func formatBindingError(err error) any {
+	var perr *provider.Error
+	if errors.As(err, &perr) {
+		out := *perr
+		// Keep the caller's wrapping context, e.g. "failed after fallbacks", but not the
+		// provider, model and code, which have their own fields
+		if context, ok := strings.CutSuffix(err.Error(), perr.Error()); ok {
+			if context = strings.TrimSuffix(strings.TrimSpace(context), ":"); context != "" {
+				out.Message = context + ": " + perr.Message
+			}
+		}
+		return out
+	}
+	return err.Error()
+}
 
-				// Save embedding (SaveEmbedding now has its own mutex)
-				if err := a.embeddingService.SaveEmbedding(req.entryID, embedding); err != nil {
-					log.Printf("Warning: Failed to save embedding for entry %d: %v", req.entryID, err)
-				} else {
-					log.Printf("Successfully generated and saved embedding for entry %d", req.entryID)
-				}
+// TraceEvent is the payload emitted on the "llore:trace" event for the frontend debug view.
+type TraceEvent struct {
+	Stage     string      `json:"stage"`
+	Data      interface{} `json:"data"`
+	Timestamp time.Time   `json:"timestamp"`
+}
 
-				// Add a small delay between operations to reduce contention
-				time.Sleep(100 * time.Millisecond)
-			}
-		}()
-	})
+// emitTrace sends a debug trace event to the frontend. It is a no-op before startup.
+func (a *App) emitTrace(stage string, data interface{}) {
+	if a.ctx == nil {
+		return
+	}
+	runtime.EventsEmit(a.ctx, "llore:trace", TraceEvent{Stage: stage, Data: data, Timestamp: time.Now()})
 }
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"Llore/internal/database"
+	"Llore/internal/embeddings"
+	"Llore/internal/provider"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// limitedEmbedder embeds any text of at most maxChars bytes, like a model with an input
+// limit, and rejects whole-entry texts outright when rejectEntries is set.
+type limitedEmbedder struct {
+	maxChars      int
+	rejectEntries bool
+}
+
+func (p *limitedEmbedder) CreateEmbedding(text string) ([]float32, error) {
+	if p.rejectEntries && strings.Contains(text, "\nContent: ") {
+		return nil, fmt.Errorf("whole entry rejected")
+	}
+	if p.maxChars > 0 && len(text) > p.maxChars {
+		return nil, fmt.Errorf("input of %d characters exceeds the context length", len(text))
+	}
+	return []float32{1, float32(len(text)), 0.5}, nil
+}
+
+func (p *limitedEmbedder) CreateQueryEmbedding(text string) ([]float32, error) {
+	return p.CreateEmbedding(text)
+}
+
+func (p *limitedEmbedder) ModelIdentifier() string { return "test:limited" }
+
+func TestEmbedRequestsLongEntry(t *testing.T) {
+	body := strings.Repeat("The lighthouse keeper counts ships every night. ", 500) // ~24k characters
+	tests := []struct {
+		name       string
+		provider   *limitedEmbedder
+		wantFailed bool
+	}{
+		{"whole text capped to the input limit", &limitedEmbedder{maxChars: embeddings.MaxEntryTextChars + 100}, false},
+		{"whole entry rejected", &limitedEmbedder{rejectEntries: true}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := openVectorTestVault(t)
+			service := embeddings.NewEmbeddingService(a.db, tt.provider)
+			req := a.newEmbeddingRequest(database.CodexEntry{ID: 1, Name: "Lighthouse", Type: "Location", Content: body})
+
+			failed := a.embedRequests(service, []embeddingRequest{req})
+			if _, ok := failed[1]; ok != tt.wantFailed {
+				t.Fatalf("failed = %v, want entry failed %v", failed, tt.wantFailed)
+			}
+			wantChunks := len(embeddings.ChunkText(req.body, embeddings.DefaultChunkSize, embeddings.DefaultChunkOverlap))
+			if wantChunks < 2 {
+				t.Fatalf("test entry is too short to have passages")
+			}
+			if n := countVaultRows(t, a, `SELECT COUNT(*) FROM codex_embedding_chunks WHERE codex_entry_id = 1`); n != wantChunks {
+				t.Errorf("stored %d passages, want %d", n, wantChunks)
+			}
+			wantWhole := 1
+			if tt.wantFailed {
+				wantWhole = 0
+			}
+			if n := countVaultRows(t, a, `SELECT COUNT(*) FROM codex_embeddings WHERE codex_entry_id = 1 AND content_hash = ?`, embeddings.ContentHash(req.text)); n != wantWhole {
+				t.Errorf("stored %d whole-entry vectors hashed from the full text, want %d", n, wantWhole)
+			}
+		})
+	}
+}
+
+func countVaultRows(t *testing.T, a *App, query string, args ...any) int {
+	t.Helper()
+	var n int
+	if err := a.db.QueryRow(query, args...).Scan(&n); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return n
+}
+
+func TestFormatBindingError(t *testing.T) {
+	perr := provider.NewError("openrouter", "some/model", provider.ErrRateLimit, "too many requests", nil)
+	tests := []struct {
+		name string
+		err  error
+		want any
+	}{
+		{"provider error", perr, *perr},
+		{"wrapped provider error", fmt.Errorf("failed after fallbacks: %w", perr), provider.Error{
+			Code: perr.Code, Provider: "openrouter", Model: "some/model", Message: "failed after fallbacks: too many requests",
+		}},
+		{"twice wrapped", fmt.Errorf("weave: %w", fmt.Errorf("failed after fallbacks: %w", perr)), provider.Error{
+			Code: perr.Code, Provider: "openrouter", Model: "some/model", Message: "weave: failed after fallbacks: too many requests",
+		}},
+		{"other error", fmt.Errorf("no vault loaded"), "no vault loaded"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatBindingError(tt.err); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"Llore/internal/database"
+	"Llore/internal/llm"
+	"Llore/internal/provider"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxAttachmentBytes caps uploaded images; vision APIs reject larger payloads anyway.
+const maxAttachmentBytes = 20 << 20
+
+// describeImagePrompt asks the vision model for lore-oriented prose rather than a caption.
+const describeImagePrompt = "You are helping a writer maintain a story codex. The attached image belongs to the codex entry \"%s\" (type: %s). " +
+	"Describe what the image shows that is relevant to this entry: appearance, clothing, notable features, setting, landmarks, layout or mood. " +
+	"Write 1-3 short paragraphs of plain prose in the same voice as a codex entry. Do not mention that this is an image or a picture. " +
+	"Output ONLY the description."
+
+// ImageDescriptionResult is returned by DescribeEntryImage. ProposedContent is the entry
+// content with the description merged in; it is not saved until the user accepts it.
+type ImageDescriptionResult struct {
+	Attachment      database.CodexAttachment `json:"attachment"`
+	Description     string                   `json:"description"`
+	ProposedContent string                   `json:"proposedContent"`
+	Model           string                   `json:"model"`
+}
+
+// attachmentsDir returns the folder holding an entry's attachments.
+func (a *App) attachmentsDir(entryID int64) string {
+	return filepath.Join(a.dbPath, "Codex", "Attachments", fmt.Sprintf("%d", entryID))
+}
+
+// AttachImageToEntry stores a base64-encoded image in the vault and links it to an entry.
+func (a *App) AttachImageToEntry(entryID int64, filename string, base64Data string) (database.CodexAttachment, error) {
+	if a.db == nil {
+		return database.CodexAttachment{}, fmt.Errorf("database is not initialized")
+	}
+	if _, err := a.getEntryByID(entryID); err != nil {
+		return database.CodexAttachment{}, err
+	}
+
+	// Accept both raw base64 and data URLs from the frontend file reader
+	if idx := strings.Index(base64Data, ","); strings.HasPrefix(base64Data, "data:") && idx != -1 {
+		base64Data = base64Data[idx+1:]
+	}
+	data, err := base64.StdEncoding.DecodeString(base64Data)
+	if err != nil {
+		return database.CodexAttachment{}, fmt.Errorf("invalid image data: %w", err)
+	}
+	if len(data) > maxAttachmentBytes {
+		return database.CodexAttachment{}, fmt.Errorf("image is too large (%d bytes, max %d)", len(data), maxAttachmentBytes)
+	}
+	mimeType := http.DetectContentType(data)
+	if !strings.HasPrefix(mimeType, "image/") {
+		return database.CodexAttachment{}, fmt.Errorf("unsupported attachment type %s: only images can be attached", mimeType)
+	}
+
+	dir := a.attachmentsDir(entryID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return database.CodexAttachment{}, fmt.Errorf("failed to create attachments folder: %w", err)
+	}
+
+	// Pick a free filename so re-attaching "portrait.png" doesn't overwrite the first one
+	finalName := freeAttachmentName(dir, filename, nil)
+
+	fullPath := filepath.Join(dir, finalName)
+	if err := os.WriteFile(fullPath, data, 0644); err != nil {
+		return database.CodexAttachment{}, fmt.Errorf("failed to write attachment: %w", err)
+	}
+
+	id, err := database.DBInsertAttachment(a.db, entryID, finalName, mimeType)
+	if err != nil {
+		os.Remove(fullPath)
+		return database.CodexAttachment{}, err
+	}
+	log.Printf("Attached image '%s' (%s, %d bytes) to entry %d", finalName, mimeType, len(data), entryID)
+	return database.DBGetAttachment(a.db, id)
+}
+
+// freeAttachmentName returns filename, or a numbered variant of it, that no file in dir
+// has yet and that is not in taken.
+func freeAttachmentName(dir, filename string, taken map[string]bool) string {
+	base := filepath.Base(filename)
+	if base == "." || base == string(filepath.Separator) || base == "" {
+		base = "image"
+	}
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	finalName := base
+	for i := 1; ; i++ {
+		if _, err := os.Stat(filepath.Join(dir, finalName)); os.IsNotExist(err) && !taken[finalName] {
+			return finalName
+		}
+		finalName = fmt.Sprintf("%s (%d)%s", stem, i, ext)
+	}
+}
+
+// ListEntryAttachments returns the attachments of a codex entry.
+func (a *App) ListEntryAttachments(entryID int64) ([]database.CodexAttachment, error) {
+	if a.db == nil {
+		return nil, fmt.Errorf("database is not initialized")
+	}
+	return database.DBListAttachments(a.db, entryID)
+}
+
+// ReadEntryAttachment returns an attachment as a data URL for display in the frontend.
+func (a *App) ReadEntryAttachment(attachmentID int64) (string, error) {
+	attachment, data, err := a.loadAttachment(attachmentID)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("data:%s;base64,%s", attachment.MimeType, base64.StdEncoding.EncodeToString(data)), nil
+}
+
+// DeleteEntryAttachment removes an attachment and its file, then refreshes the entry's embedding.
+func (a *App) DeleteEntryAttachment(attachmentID int64) error {
+	if a.db == nil {
+		return fmt.Errorf("database is not initialized")
+	}
+	attachment, err := database.DBGetAttachment(a.db, attachmentID)
+	if err != nil {
+		return err
+	}
+	if err := database.DBDeleteAttachment(a.db, attachmentID); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(a.attachmentsDir(attachment.CodexEntryID), attachment.Filename)); err != nil && !os.IsNotExist(err) {
+		log.Printf("Warning: Failed to remove attachment file '%s': %v", attachment.Filename, err)
+	}
+	if attachment.Description != "" {
+		a.queueEntryEmbedding(attachment.CodexEntryID)
+	}
+	return nil
+}
+
+// DescribeEntryImage asks a vision model to describe an attachment, stores the description
+// (which is embedded with the entry for RAG) and proposes merged entry content.
+// An empty modelID uses the configured vision model.
+func (a *App) DescribeEntryImage(attachmentID int64, modelID string) (ImageDescriptionResult, error) {
+	attachment, data, err := a.loadAttachment(attachmentID)
+	if err != nil {
+		return ImageDescriptionResult{}, err
+	}
+	entry, err := a.getEntryByID(attachment.CodexEntryID)
+	if err != nil {
+		return ImageDescriptionResult{}, err
+	}
+
+	cfg := llm.GetConfig()
+	if modelID == "" {
+		modelID = cfg.VisionModelID
+	}
+	prompt := fmt.Sprintf(describeImagePrompt, entry.Name, entry.Type)
+	image := llm.ImageInput{Data: data, MIMEType: attachment.MimeType}
+
+	log.Printf("Describing attachment %d ('%s') of entry '%s' with mode %s, model '%s'", attachment.ID, attachment.Filename, entry.Name, cfg.ActiveMode, modelID)
+	completion, err := a.dispatchImageDescription(cfg, prompt, image, modelID)
+	if err != nil {
+		return ImageDescriptionResult{}, err
+	}
+	description := strings.TrimSpace(completion.Content)
+
+	if err := database.DBUpdateAttachmentDescription(a.db, attachment.ID, description); err != nil {
+		return ImageDescriptionResult{}, err
+	}
+	attachment.Description = description
+	a.queueEntryEmbedding(entry.ID)
+
+	// Merge with the chat model, like story imports do; the user reviews before saving.
+	proposed, err := a.MergeEntryContentDirect(entry, description, cfg.ChatModelID)
+	if err != nil {
+		return ImageDescriptionResult{}, err
+	}
+
+	return ImageDescriptionResult{
+		Attachment:      attachment,
+		Description:     description,
+		ProposedContent: proposed,
+		Model:           completion.Model,
+	}, nil
+}
+
+// dispatchImageDescription sends the image to the vision model of the active provider.
+func (a *App) dispatchImageDescription(cfg llm.OpenRouterConfig, prompt string, image llm.ImageInput, modelID string) (llm.Completion, error) {
+	switch cfg.ActiveMode {
+	case "openai":
+		if modelID == "" {
+			modelID = "gpt-4o-mini"
+		}
+		return llm.DescribeImageWithOpenAI(cfg.OpenAIAPIKey, prompt, image, modelID)
+	case "gemini":
+		if modelID == "" {
+			modelID = "gemini-1.5-flash"
+		}
+		return llm.DescribeImageWithGemini(cfg.GeminiApiKey, prompt, image, modelID)
+	case "openrouter", "hybrid":
+		if cfg.APIKey == "" {
+			return llm.Completion{}, provider.NewError("openrouter", modelID, provider.ErrNotConfigured, "OpenRouter API key not set. Cannot describe images", nil)
+		}
+		if modelID == "" {
+			return llm.Completion{}, provider.NewError("openrouter", modelID, provider.ErrNotConfigured, "no vision model configured. Choose an image-capable model in Settings", nil)
+		}
+		return llm.DescribeImageWithOpenRouter(prompt, image, modelID)
+	case "local":
+		if modelID == "" {
+			modelID = "llava"
+		}
+		return llm.DescribeImageWithOllama(prompt, image, modelID)
+	default:
+		return llm.Completion{}, provider.NewError(cfg.ActiveMode, modelID, provider.ErrNotConfigured, fmt.Sprintf("unsupported LLM ActiveMode: %s. Please configure in Settings", cfg.ActiveMode), nil)
+	}
+}
+
+// loadAttachment fetches an attachment record and reads its file from the vault.
+func (a *App) loadAttachment(attachmentID int64) (database.CodexAttachment, []byte, error) {
+	if a.db == nil {
+		return database.CodexAttachment{}, nil, fmt.Errorf("database is not initialized")
+	}
+	attachment, err := database.DBGetAttachment(a.db, attachmentID)
+	if err != nil {
+		return attachment, nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(a.attachmentsDir(attachment.CodexEntryID), attachment.Filename))
+	if err != nil {
+		return attachment, nil, fmt.Errorf("failed to read attachment file '%s': %w", attachment.Filename, err)
+	}
+	return attachment, data, nil
+}
+
+// deleteEntryAttachments removes the attachment files of a deleted entry. The rows are
+// deleted with the entry by DBDeleteEntryCascade.
+func (a *App) deleteEntryAttachments(entryID int64) {
+	if err := os.RemoveAll(a.attachmentsDir(entryID)); err != nil {
+		log.Printf("Warning: Failed to remove attachments folder for entry %d: %v", entryID, err)
+	}
+}
+
+// getEntryByID loads a single codex entry.
+func (a *App) getEntryByID(id int64) (database.CodexEntry, error) {
+	var entry database.CodexEntry
+	err := a.db.QueryRow("SELECT id, name, type, content, created_at, updated_at FROM codex_entries WHERE id = ?", id).
+		Scan(&entry.ID, &entry.Name, &entry.Type, &entry.Content, &entry.CreatedAt, &entry.UpdatedAt)
+	if err != nil {
+		return entry, fmt.Errorf("entry with ID %d not found: %w", id, err)
+	}
+	return entry, nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"Llore/internal/database"
+	"Llore/internal/embeddings"
+	"Llore/internal/llm"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// chatExchanges splits a chat log into exchanges: each user message together with the
+// AI replies that follow it. Replies before the first user message form their own exchange.
+func chatExchanges(messages []ChatMessage) []embeddings.ChatExchange {
+	var exchanges []embeddings.ChatExchange
+	var sb strings.Builder
+	start := -1
+	flush := func() {
+		if start >= 0 && strings.TrimSpace(sb.String()) != "" {
+			exchanges = append(exchanges, embeddings.ChatExchange{MessageIndex: start, Text: strings.TrimSpace(sb.String())})
+		}
+		sb.Reset()
+	}
+	for i, msg := range messages {
+		if msg.Sender == "user" || start < 0 {
+			flush()
+			start = i
+		}
+		speaker := "Assistant"
+		if msg.Sender == "user" {
+			speaker = "Writer"
+		}
+		sb.WriteString(fmt.Sprintf("%s: %s\n", speaker, strings.TrimSpace(msg.Text)))
+	}
+	flush()
+	return exchanges
+}
+
+// indexChatSession embeds new exchanges of a saved chat log with service. Excluded
+// sessions and vaults with chat memory turned off are skipped.
+func (a *App) indexChatSession(service *embeddings.EmbeddingService, filename string) (int, error) {
+	if service == nil || a.db == nil {
+		return 0, fmt.Errorf("embedding service not initialized")
+	}
+	if !llm.GetConfig().ChatMemoryEnabled {
+		return 0, nil
+	}
+	excluded, err := database.DBIsChatMemoryExcluded(a.db, filename)
+	if err != nil {
+		return 0, err
+	}
+	if excluded {
+		return 0, nil
+	}
+	messages, err := a.LoadChatLog(filename)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil // Deleted since it was queued
+		}
+		return 0, err
+	}
+	return service.IndexChatSession(filename, chatExchanges(messages))
+}
+
+// queueChatMemory schedules a saved chat log for embedding when chat memory is on.
+func (a *App) queueChatMemory(filename string) {
+	if !llm.GetConfig().ChatMemoryEnabled {
+		return
+	}
+	a.enqueueEmbeddingJob(jobKindChat, filename)
+}
+
+// ReindexChatMemory embeds every saved chat log in the vault that isn't excluded from
+// memory and forgets logs that no longer exist.
+func (a *App) ReindexChatMemory() (IndexSyncResult, error) {
+	return a.reindexChatMemory(a.embeddingService)
+}
+
+// reindexChatMemory is ReindexChatMemory for a given embedding service.
+func (a *App) reindexChatMemory(service *embeddings.EmbeddingService) (IndexSyncResult, error) {
+	var result IndexSyncResult
+	if a.db == nil {
+		return result, fmt.Errorf("no vault is currently loaded")
+	}
+	if service == nil {
+		return result, fmt.Errorf("embedding service not initialized")
+	}
+	if !llm.GetConfig().ChatMemoryEnabled {
+		return result, fmt.Errorf("chat memory is turned off in settings")
+	}
+
+	logs, err := a.ListChatLogs()
+	if err != nil {
+		return result, err
+	}
+	present := make(map[string]bool, len(logs))
+	for _, filename := range logs {
+		present[filename] = true
+		result.Files++
+		embedded, err := a.indexChatSession(service, filename)
+		if err != nil {
+			log.Printf("Warning: Failed to index chat log %s: %v", filename, err)
+			result.Failed++
+			continue
+		}
+		if embedded > 0 {
+			result.Reindexed++
+		}
+	}
+
+	indexed, err := service.IndexedChatSessions()
+	if err != nil {
+		return result, err
+	}
+	for _, session := range indexed {
+		if present[session] {
+			continue
+		}
+		if err := database.DBDeleteChatSession(a.db, session); err != nil {
+			log.Printf("Warning: %v", err)
+			continue
+		}
+		result.Removed++
+	}
+
+	log.Printf("Chat memory up to date: %d logs, %d re-indexed, %d removed, %d failed", result.Files, result.Reindexed, result.Removed, result.Failed)
+	return result, nil
+}
+
+// SetChatSessionMemory opts a chat log in to or out of long-term memory. Opting out
+// deletes what was already embedded from it; opting back in re-embeds it.
+func (a *App) SetChatSessionMemory(filename string, enabled bool) error {
+	if a.db == nil {
+		return fmt.Errorf("no vault is currently loaded")
+	}
+	if strings.Contains(filename, "..") || strings.ContainsRune(filename, filepath.Separator) {
+		return fmt.Errorf("invalid chat log filename")
+	}
+	if err := database.DBSetChatMemoryExcluded(a.db, filename, !enabled); err != nil {
+		return err
+	}
+	if enabled {
+		a.queueChatMemory(filename)
+	}
+	log.Printf("Chat memory for %s: enabled=%v", filename, enabled)
+	return nil
+}
+
+// GetChatSessionMemory reports whether a chat log contributes to long-term memory.
+func (a *App) GetChatSessionMemory(filename string) (bool, error) {
+	if a.db == nil {
+		return false, fmt.Errorf("no vault is currently loaded")
+	}
+	excluded, err := database.DBIsChatMemoryExcluded(a.db, filename)
+	if err != nil {
+		return false, err
+	}
+	return !excluded, nil
+}
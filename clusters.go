@@ -0,0 +1,224 @@
+package main
+
+import (
+	"Llore/internal/database"
+	"Llore/internal/llm"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+)
+
+const (
+	// clusterSampleEntries is how many of a cluster's most typical entries the LLM sees
+	// when naming it.
+	clusterSampleEntries = 8
+	// clusterSampleRunes is how much of each sampled entry's content the LLM sees.
+	clusterSampleRunes = 300
+)
+
+// ClusterSuggestion is a group of related entries proposed as a tag.
+type ClusterSuggestion struct {
+	ID          int64                 `json:"id"`
+	Name        string                `json:"name"` // Suggested tag
+	Description string                `json:"description"`
+	Status      string                `json:"status"`   // pending, accepted or dismissed
+	Cohesion    float64               `json:"cohesion"` // Mean similarity of the entries to the cluster centre
+	CreatedAt   string                `json:"createdAt"`
+	Entries     []database.CodexEntry `json:"entries"` // Most typical first
+}
+
+// ClusterCodexEntries groups entries by their stored embeddings with k-means, has the LLM
+// name each group, and stores the groups as pending tag suggestions, replacing the pending
+// ones of an earlier run. types limits the entries clustered; empty clusters all of them.
+// k <= 0 picks the number of clusters automatically. Single-entry clusters are dropped.
+func (a *App) ClusterCodexEntries(types []string, k int, modelID string) ([]ClusterSuggestion, error) {
+	if a.db == nil {
+		return nil, fmt.Errorf("database is not initialized")
+	}
+	if a.embeddingService == nil {
+		return nil, fmt.Errorf("embedding service not initialized")
+	}
+	if modelID == "" {
+		modelID = llm.GetConfig().ChatModelID
+	}
+
+	entries, err := a.GetAllEntries()
+	if err != nil {
+		return nil, err
+	}
+	keep := CodexSearchFilter{Types: types}.matcher(nil)
+	byID := make(map[int64]database.CodexEntry, len(entries))
+	ids := []int64{}
+	for _, entry := range entries {
+		if keep(entry) {
+			byID[entry.ID] = entry
+			ids = append(ids, entry.ID)
+		}
+	}
+	clusters, err := a.embeddingService.ClusterEntries(ids, k)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := database.DBListTags(a.db)
+	if err != nil {
+		return nil, err
+	}
+	tags := make([]string, 0, len(existing))
+	for _, t := range existing {
+		tags = append(tags, t.Tag)
+	}
+
+	records := []database.ClusterSuggestionRecord{}
+	for _, cluster := range clusters {
+		if len(cluster.EntryIDs) < 2 {
+			continue
+		}
+		members := make([]database.CodexEntry, 0, len(cluster.EntryIDs))
+		for _, id := range cluster.EntryIDs {
+			members = append(members, byID[id])
+		}
+		name, description, err := a.nameCluster(members, tags, modelID)
+		if err != nil {
+			log.Printf("Warning: Failed to name cluster of %d entries: %v", len(members), err)
+			name = members[0].Name + " and related"
+		}
+		records = append(records, database.ClusterSuggestionRecord{
+			Name:        name,
+			Description: description,
+			Cohesion:    float64(cluster.Cohesion),
+			EntryIDs:    cluster.EntryIDs,
+		})
+	}
+
+	if _, err := database.DBReplacePendingClusterSuggestions(a.db, records); err != nil {
+		return nil, err
+	}
+	log.Printf("Clustered %d entries into %d suggested tags", len(ids), len(records))
+	return a.ListClusterSuggestions(database.ClusterPending)
+}
+
+// nameCluster asks the LLM for a short tag and a one-sentence description of a cluster,
+// preferring an existing tag when one fits.
+func (a *App) nameCluster(members []database.CodexEntry, tags []string, modelID string) (string, string, error) {
+	var sb strings.Builder
+	sb.WriteString("The following codex entries of a story's world were grouped together because their content is similar. ")
+	sb.WriteString("Name the theme they share as a short tag of one to three words, and describe it in one sentence. ")
+	if len(tags) > 0 {
+		sb.WriteString(fmt.Sprintf("If one of these existing tags fits, use it exactly: %s. ", strings.Join(tags, ", ")))
+	}
+	sb.WriteString("Respond with ONLY a JSON object: {\"name\": \"...\", \"description\": \"...\"}\n\n")
+	for i, e := range members {
+		if i == clusterSampleEntries {
+			sb.WriteString(fmt.Sprintf("...and %d more entries.\n", len(members)-i))
+			break
+		}
+		content := []rune(strings.TrimSpace(e.Content))
+		if len(content) > clusterSampleRunes {
+			content = append(content[:clusterSampleRunes], '…')
+		}
+		sb.WriteString(fmt.Sprintf("ENTRY: %s (%s)\n%s\n\n", e.Name, e.Type, string(content)))
+	}
+
+	reply, err := a.GenerateLLMContent(sb.String(), modelID)
+	if err != nil {
+		return "", "", err
+	}
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return "", "", fmt.Errorf("no JSON object in reply")
+	}
+	var parsed struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &parsed); err != nil {
+		return "", "", fmt.Errorf("failed to parse cluster name: %w", err)
+	}
+	name := strings.TrimSpace(parsed.Name)
+	if name == "" {
+		return "", "", fmt.Errorf("reply has no name")
+	}
+	return name, strings.TrimSpace(parsed.Description), nil
+}
+
+// ListClusterSuggestions returns the stored cluster suggestions, newest first. status
+// filters them ("pending", "accepted" or "dismissed"); empty returns all of them.
+func (a *App) ListClusterSuggestions(status string) ([]ClusterSuggestion, error) {
+	if a.db == nil {
+		return nil, fmt.Errorf("database is not initialized")
+	}
+	records, err := database.DBListClusterSuggestions(a.db, status)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := a.GetAllEntries()
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]database.CodexEntry, len(entries))
+	for _, entry := range entries {
+		byID[entry.ID] = entry
+	}
+	suggestions := make([]ClusterSuggestion, 0, len(records))
+	for _, rec := range records {
+		suggestions = append(suggestions, clusterSuggestion(rec, byID))
+	}
+	return suggestions, nil
+}
+
+// AcceptClusterSuggestion tags entries of a suggestion and marks it accepted. tag
+// overrides the suggested name, and entryIDs the suggested entries; empty keeps them.
+func (a *App) AcceptClusterSuggestion(id int64, tag string, entryIDs []int64) error {
+	if a.db == nil {
+		return fmt.Errorf("database is not initialized")
+	}
+	suggestion, err := database.DBGetClusterSuggestion(a.db, id)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(tag) == "" {
+		tag = suggestion.Name
+	}
+	if len(entryIDs) == 0 {
+		entryIDs = suggestion.EntryIDs
+	}
+	for _, entryID := range entryIDs {
+		if err := database.DBAddTag(a.db, entryID, tag); err != nil {
+			return err
+		}
+	}
+	log.Printf("Tagged %d entries %q from cluster suggestion %d", len(entryIDs), strings.TrimSpace(tag), id)
+	return database.DBSetClusterSuggestionStatus(a.db, id, database.ClusterAccepted)
+}
+
+// DismissClusterSuggestion marks a suggestion dismissed without tagging anything.
+func (a *App) DismissClusterSuggestion(id int64) error {
+	if a.db == nil {
+		return fmt.Errorf("database is not initialized")
+	}
+	if _, err := database.DBGetClusterSuggestion(a.db, id); err != nil {
+		return err
+	}
+	return database.DBSetClusterSuggestionStatus(a.db, id, database.ClusterDismissed)
+}
+
+// clusterSuggestion resolves a stored suggestion's members to entries.
+func clusterSuggestion(rec database.ClusterSuggestionRecord, byID map[int64]database.CodexEntry) ClusterSuggestion {
+	s := ClusterSuggestion{
+		ID:          rec.ID,
+		Name:        rec.Name,
+		Description: rec.Description,
+		Status:      rec.Status,
+		Cohesion:    rec.Cohesion,
+		CreatedAt:   rec.CreatedAt,
+		Entries:     []database.CodexEntry{},
+	}
+	for _, id := range rec.EntryIDs {
+		if entry, ok := byID[id]; ok {
+			s.Entries = append(s.Entries, entry)
+		}
+	}
+	return s
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"Llore/internal/database"
+	"Llore/internal/llm"
+	"fmt"
+	"html"
+	"log"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Modes of SearchCodex.
+const (
+	searchModeSemantic = "semantic"
+	searchModeKeyword  = "keyword"
+	searchModeHybrid   = "hybrid"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 200
+	// filteredKeywordHits is how many keyword hits are fetched when filters will drop some.
+	filteredKeywordHits = 1000
+	// searchRRFK damps top ranks in the hybrid mode's reciprocal-rank fusion, as in retrieval.
+	searchRRFK = 60
+	// snippetChars is the approximate length of a result snippet.
+	snippetChars = 200
+)
+
+// CodexSearchFilter narrows a codex search. Empty fields don't filter. Dates are
+// "YYYY-MM-DD" and inclusive.
+type CodexSearchFilter struct {
+	Types         []string `json:"types"`
+	Tags          []string `json:"tags"` // Entries with any of these tags
+	CreatedAfter  string   `json:"createdAfter"`
+	CreatedBefore string   `json:"createdBefore"`
+	UpdatedAfter  string   `json:"updatedAfter"`
+	UpdatedBefore string   `json:"updatedBefore"`
+}
+
+// CodexSearchResult is one ranked entry of a codex search.
+type CodexSearchResult struct {
+	Entry        database.CodexEntry `json:"entry"`
+	Tags         []string            `json:"tags"`
+	Score        float64             `json:"score"`        // Of the mode used: cosine, BM25 or fused
+	VectorScore  float32             `json:"vectorScore"`  // 0 if not a semantic hit
+	KeywordScore float64             `json:"keywordScore"` // Negated BM25; 0 if not a keyword hit
+	Snippet      string              `json:"snippet"`      // HTML-escaped, with query words in <mark>
+}
+
+// SearchCodex ranks codex entries against query and keeps those of the given types.
+// mode is "semantic", "keyword" or "hybrid" (the default); limit <= 0 returns 20.
+func (a *App) SearchCodex(query string, types []string, limit int, mode string) ([]CodexSearchResult, error) {
+	return a.SearchCodexWithFilters(query, CodexSearchFilter{Types: types}, limit, mode)
+}
+
+// SearchCodexWithFilters is SearchCodex with filters on tags and dates as well. With an
+// empty query it lists the matching entries by name.
+func (a *App) SearchCodexWithFilters(query string, filter CodexSearchFilter, limit int, mode string) ([]CodexSearchResult, error) {
+	if a.db == nil {
+		return nil, fmt.Errorf("database is not initialized")
+	}
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+	if mode == "" {
+		mode = searchModeHybrid
+	}
+	if mode != searchModeSemantic && mode != searchModeKeyword && mode != searchModeHybrid {
+		return nil, fmt.Errorf("unknown search mode '%s'", mode)
+	}
+	if mode != searchModeKeyword && a.embeddingService == nil {
+		if mode == searchModeSemantic {
+			return nil, fmt.Errorf("semantic search needs an embedding provider")
+		}
+		mode = searchModeKeyword
+	}
+
+	tags, err := database.DBAllEntryTags(a.db)
+	if err != nil {
+		return nil, err
+	}
+	keep := filter.matcher(tags)
+	filtered := len(filter.Types) > 0 || len(filter.Tags) > 0 || filter.CreatedAfter != "" ||
+		filter.CreatedBefore != "" || filter.UpdatedAfter != "" || filter.UpdatedBefore != ""
+
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return a.listFilteredEntries(keep, tags, limit)
+	}
+
+	byID := make(map[int64]*CodexSearchResult)
+	var results []*CodexSearchResult
+	get := func(entry database.CodexEntry) *CodexSearchResult {
+		if r, ok := byID[entry.ID]; ok {
+			return r
+		}
+		r := &CodexSearchResult{Entry: entry, Tags: tags[entry.ID]}
+		byID[entry.ID] = r
+		results = append(results, r)
+		return r
+	}
+	passages := make(map[int64]string) // Best-matching passage of long entries, for snippets
+
+	if mode != searchModeKeyword {
+		vectorLimit := limit
+		if filtered {
+			vectorLimit = 0 // Score every entry so filters can't starve the results
+		}
+		hits, err := a.embeddingService.FindSimilarEntries(query, vectorLimit)
+		if err != nil {
+			if mode == searchModeSemantic {
+				return nil, fmt.Errorf("semantic search failed: %w", err)
+			}
+			log.Printf("Warning: Vector side of codex search failed, using keywords only: %v", err)
+		}
+		threshold := a.resolveSimilarityThreshold(llm.GetConfig()).Threshold
+		rank := 0
+		for _, hit := range hits {
+			if hit.Score < threshold || !keep(hit.Entry) {
+				continue
+			}
+			rank++
+			r := get(hit.Entry)
+			r.VectorScore = hit.Score
+			if mode == searchModeSemantic {
+				r.Score = float64(hit.Score)
+			} else {
+				r.Score += 1 / float64(searchRRFK+rank)
+			}
+			if hit.Passage != nil {
+				passages[hit.Entry.ID] = hit.Passage.Text
+			}
+		}
+	}
+
+	if mode != searchModeSemantic {
+		keywordLimit := limit
+		if filtered {
+			keywordLimit = filteredKeywordHits
+		}
+		hits, err := database.DBKeywordSearch(a.db, query, keywordLimit)
+		if err != nil {
+			return nil, err
+		}
+		rank := 0
+		for _, hit := range hits {
+			if !keep(hit.Entry) {
+				continue
+			}
+			rank++
+			r := get(hit.Entry)
+			r.KeywordScore = hit.Score
+			if mode == searchModeKeyword {
+				r.Score = hit.Score
+			} else {
+				r.Score += 1 / float64(searchRRFK+rank)
+			}
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	terms := snippetTerms(query)
+	ranked := make([]CodexSearchResult, len(results))
+	for i, r := range results {
+		text, ok := passages[r.Entry.ID]
+		if !ok {
+			text = r.Entry.Content
+		}
+		r.Snippet = highlightSnippet(text, terms)
+		if r.Tags == nil {
+			r.Tags = []string{}
+		}
+		ranked[i] = *r
+	}
+	return ranked, nil
+}
+
+// listFilteredEntries returns the entries that pass the filter, by name.
+func (a *App) listFilteredEntries(keep func(database.CodexEntry) bool, tags map[int64][]string, limit int) ([]CodexSearchResult, error) {
+	entries, err := a.GetAllEntries()
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return strings.ToLower(entries[i].Name) < strings.ToLower(entries[j].Name) })
+	results := []CodexSearchResult{}
+	for _, entry := range entries {
+		if len(results) == limit {
+			break
+		}
+		if keep(entry) {
+			entryTags := tags[entry.ID]
+			if entryTags == nil {
+				entryTags = []string{}
+			}
+			results = append(results, CodexSearchResult{Entry: entry, Tags: entryTags, Snippet: highlightSnippet(entry.Content, nil)})
+		}
+	}
+	return results, nil
+}
+
+// matcher returns whether an entry passes the filter.
+func (f CodexSearchFilter) matcher(tags map[int64][]string) func(database.CodexEntry) bool {
+	types := make(map[string]bool, len(f.Types))
+	for _, t := range f.Types {
+		types[strings.ToLower(t)] = true
+	}
+	wanted := make(map[string]bool, len(f.Tags))
+	for _, t := range f.Tags {
+		wanted[strings.ToLower(t)] = true
+	}
+	return func(entry database.CodexEntry) bool {
+		if len(types) > 0 && !types[strings.ToLower(entry.Type)] {
+			return false
+		}
+		if len(wanted) > 0 {
+			found := false
+			for _, t := range tags[entry.ID] {
+				if wanted[strings.ToLower(t)] {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return inDateRange(entry.CreatedAt, f.CreatedAfter, f.CreatedBefore) &&
+			inDateRange(entry.UpdatedAt, f.UpdatedAfter, f.UpdatedBefore)
+	}
+}
+
+// inDateRange compares the date part of a SQLite timestamp with inclusive bounds.
+func inDateRange(timestamp, after, before string) bool {
+	if after == "" && before == "" {
+		return true
+	}
+	if len(timestamp) < 10 {
+		return false // Undated entries can't be placed in a range
+	}
+	date := timestamp[:10]
+	return (after == "" || date >= after) && (before == "" || date <= before)
+}
+
+// snippetStopWords are question words and fillers not worth highlighting.
+var snippetStopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "are": true, "was": true, "who": true, "what": true,
+	"where": true, "when": true, "why": true, "how": true, "does": true, "did": true, "with": true,
+	"about": true, "from": true, "this": true, "that": true, "his": true, "her": true, "their": true,
+}
+
+// snippetTerms are the lowercase words of a query worth highlighting.
+func snippetTerms(query string) map[string]bool {
+	terms := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(query), isNotWordRune) {
+		if snippetStopWords[word] {
+			continue
+		}
+		if len([]rune(word)) >= 3 || unicode.IsNumber([]rune(word)[0]) {
+			terms[word] = true
+		}
+	}
+	return terms
+}
+
+func isNotWordRune(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+}
+
+// highlightSnippet cuts about snippetChars of text around the densest run of query terms
+// and wraps the terms in <mark>. The rest of the text is HTML-escaped.
+func highlightSnippet(text string, terms map[string]bool) string {
+	runes := []rune(strings.Join(strings.Fields(text), " "))
+	type span struct{ start, end int }
+	var matches []span
+	for i := 0; i < len(runes); {
+		if isNotWordRune(runes[i]) {
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && !isNotWordRune(runes[j]) {
+			j++
+		}
+		if terms[strings.ToLower(string(runes[i:j]))] {
+			matches = append(matches, span{i, j})
+		}
+		i = j
+	}
+
+	// Start the window at the match followed by the most others within snippetChars
+	start, best := 0, 0
+	for i, m := range matches {
+		count := 0
+		for _, other := range matches[i:] {
+			if other.end-m.start > snippetChars {
+				break
+			}
+			count++
+		}
+		if count > best {
+			start, best = m.start, count
+		}
+	}
+	if start > 0 {
+		// Back up a little for context, to a word boundary
+		start -= snippetChars / 5
+		if start < 0 {
+			start = 0
+		}
+		for start > 0 && !isNotWordRune(runes[start-1]) {
+			start++
+		}
+	}
+	end := start + snippetChars
+	if end >= len(runes) {
+		end = len(runes)
+	} else {
+		for end > start && !isNotWordRune(runes[end]) {
+			end--
+		}
+	}
+
+	var sb strings.Builder
+	if start > 0 {
+		sb.WriteString("…")
+	}
+	pos := start
+	for _, m := range matches {
+		if m.start < start || m.end > end {
+			continue
+		}
+		sb.WriteString(html.EscapeString(string(runes[pos:m.start])))
+		sb.WriteString("<mark>")
+		sb.WriteString(html.EscapeString(string(runes[m.start:m.end])))
+		sb.WriteString("</mark>")
+		pos = m.end
+	}
+	sb.WriteString(html.EscapeString(string(runes[pos:end])))
+	if end < len(runes) {
+		sb.WriteString("…")
+	}
+	return sb.String()
+}
+
+// GetEntryTags returns the tags of an entry.
+func (a *App) GetEntryTags(entryID int64) ([]string, error) {
+	if a.db == nil {
+		return nil, fmt.Errorf("database is not initialized")
+	}
+	return database.DBGetTags(a.db, entryID)
+}
+
+// SetEntryTags replaces the tags of an entry.
+func (a *App) SetEntryTags(entryID int64, tags []string) error {
+	if a.db == nil {
+		return fmt.Errorf("database is not initialized")
+	}
+	return database.DBSetTags(a.db, entryID, tags)
+}
+
+// ListCodexTags returns every tag in use, most used first, for the search filters.
+func (a *App) ListCodexTags() ([]database.TagCount, error) {
+	if a.db == nil {
+		return nil, fmt.Errorf("database is not initialized")
+	}
+	return database.DBListTags(a.db)
+}
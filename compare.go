@@ -0,0 +1,138 @@
+package main
+
+import (
+	"Llore/internal/llm"
+	"Llore/internal/provider"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxConcurrentComparisons bounds how many models are queried at once in a comparison run.
+const maxConcurrentComparisons = 4
+
+// ModelTarget identifies one provider/model pair in a comparison run. Provider uses the
+// ActiveMode names from Settings: "openrouter", "openai", "gemini" or "local" (Ollama).
+type ModelTarget struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+}
+
+// ModelRunResult is the outcome of one model in a comparison run.
+type ModelRunResult struct {
+	Provider  string    `json:"provider"`
+	Model     string    `json:"model"`
+	Output    string    `json:"output"`
+	Reasoning string    `json:"reasoning,omitempty"`
+	LatencyMs int64     `json:"latencyMs"`
+	Usage     llm.Usage `json:"usage"`
+	Error     string    `json:"error,omitempty"`
+	ErrorCode string    `json:"errorCode,omitempty"`
+
+	// Extraction runs only
+	EntityCount      int            `json:"entityCount,omitempty"`
+	EntityTypeCounts map[string]int `json:"entityTypeCounts,omitempty"`
+	ParseError       string         `json:"parseError,omitempty"`
+}
+
+// CompareModels sends the same prompt to every target concurrently. Results are returned
+// in target order; a failing model reports its error in its result instead of failing the run.
+func (a *App) CompareModels(prompt string, targets []ModelTarget) ([]ModelRunResult, error) {
+	if strings.TrimSpace(prompt) == "" {
+		return nil, fmt.Errorf("prompt cannot be empty")
+	}
+	return a.runComparison(prompt, targets)
+}
+
+// CompareExtractionModels runs the ProcessStory extraction prompt against every target and
+// reports how many entities each one found. Nothing is written to the codex.
+func (a *App) CompareExtractionModels(storyText string, targets []ModelTarget) ([]ModelRunResult, error) {
+	if strings.TrimSpace(storyText) == "" {
+		return nil, fmt.Errorf("story text cannot be empty")
+	}
+	results, err := a.runComparison(storyExtractionPrompt(storyText), targets)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range results {
+		if results[i].Error != "" {
+			continue
+		}
+		entities, perr := parseExtractedEntities(results[i].Output)
+		if perr != nil {
+			results[i].ParseError = perr.Error()
+			continue
+		}
+		results[i].EntityTypeCounts = make(map[string]int)
+		for _, entity := range entities {
+			if entity.Name == "" {
+				continue // ProcessStory skips these too
+			}
+			results[i].EntityCount++
+			results[i].EntityTypeCounts[entity.Type]++
+		}
+	}
+	return results, nil
+}
+
+// runComparison fans the prompt out to all targets with bounded concurrency.
+func (a *App) runComparison(prompt string, targets []ModelTarget) ([]ModelRunResult, error) {
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no models selected for comparison")
+	}
+
+	baseCfg := llm.GetConfig()
+	results := make([]ModelRunResult, len(targets))
+	sem := make(chan struct{}, maxConcurrentComparisons)
+	var wg sync.WaitGroup
+
+	log.Printf("Starting model comparison across %d targets (prompt length: %d)", len(targets), len(prompt))
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target ModelTarget) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			results[i] = a.runComparisonTarget(baseCfg, prompt, target)
+		}(i, target)
+	}
+	wg.Wait()
+
+	log.Printf("Model comparison finished for %d targets", len(targets))
+	return results, nil
+}
+
+// runComparisonTarget runs a single target with the active mode overridden to its provider.
+func (a *App) runComparisonTarget(baseCfg llm.OpenRouterConfig, prompt string, target ModelTarget) ModelRunResult {
+	cfg := baseCfg
+	cfg.ActiveMode = strings.ToLower(strings.TrimSpace(target.Provider))
+	if cfg.ActiveMode == "ollama" {
+		cfg.ActiveMode = "local"
+	}
+	result := ModelRunResult{Provider: cfg.ActiveMode, Model: target.Model}
+
+	start := time.Now()
+	completion, err := a.dispatchCompletion(cfg, prompt, target.Model)
+	result.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		log.Printf("Comparison: %s/%s failed after %dms: %v", result.Provider, result.Model, result.LatencyMs, err)
+		result.Error = err.Error()
+		result.ErrorCode = string(provider.CodeOf(err))
+		return result
+	}
+
+	result.Output = completion.Content
+	if cfg.ExposeReasoning {
+		result.Reasoning = completion.Reasoning
+	}
+	result.Usage = completion.Usage
+	if completion.Model != "" {
+		result.Model = completion.Model
+	}
+	log.Printf("Comparison: %s/%s answered in %dms (%d tokens)", result.Provider, result.Model, result.LatencyMs, result.Usage.TotalTokens)
+	return result
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"Llore/internal/database"
+	"Llore/internal/llm"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// relatedEntriesLimit is how many entries RelatedEntries returns.
+	relatedEntriesLimit = 10
+	// duplicateNameSlack lowers the duplicate threshold for entries whose names share a
+	// word, like "Sir Reginald" and "Reginald the Brave".
+	duplicateNameSlack = 0.1
+)
+
+// RelatedEntry is an entry close to another in embedding space.
+type RelatedEntry struct {
+	Entry database.CodexEntry `json:"entry"`
+	Score float32             `json:"score"`
+}
+
+// DuplicatePair is two entries that look like the same thing.
+type DuplicatePair struct {
+	A          int64   `json:"a"`
+	B          int64   `json:"b"`
+	Score      float32 `json:"score"`
+	SharedName bool    `json:"sharedName"` // Their names share a word
+}
+
+// DuplicateCluster is a group of entries linked by duplicate pairs, to be merged into one.
+type DuplicateCluster struct {
+	Entries   []database.CodexEntry `json:"entries"`
+	Pairs     []DuplicatePair       `json:"pairs"`
+	Score     float32               `json:"score"`     // Of the closest pair
+	Suggested int64                 `json:"suggested"` // Entry to keep: the one with the most content
+}
+
+// EntryMerge describes how to merge entries into a survivor.
+type EntryMerge struct {
+	SurvivorID int64    `json:"survivorId"`
+	MergedIDs  []int64  `json:"mergedIds"`
+	Name       string   `json:"name"`
+	Type       string   `json:"type"`
+	Content    string   `json:"content"`
+	Aliases    []string `json:"aliases"` // The survivor's aliases after the merge
+}
+
+// RelatedEntries returns the entries whose stored vectors are closest to an entry's.
+func (a *App) RelatedEntries(entryID int64) ([]RelatedEntry, error) {
+	if a.embeddingService == nil {
+		return nil, fmt.Errorf("embedding service not initialized")
+	}
+	results, err := a.embeddingService.RelatedEntries(entryID, relatedEntriesLimit)
+	if err != nil {
+		return nil, err
+	}
+	related := make([]RelatedEntry, len(results))
+	for i, r := range results {
+		related[i] = RelatedEntry{Entry: r.Entry, Score: r.Score}
+	}
+	return related, nil
+}
+
+// defaultDuplicateThreshold sits three quarters of the way from the retrieval threshold
+// to 1, so it adapts to how the current model spreads scores.
+func (a *App) defaultDuplicateThreshold() float32 {
+	t := a.resolveSimilarityThreshold(llm.GetConfig()).Threshold
+	return 1 - (1-t)/4
+}
+
+// FindDuplicateEntries scans the vault for entries that are probably the same thing:
+// pairs with a similarity of at least threshold, or threshold minus 0.1 if their names
+// share a word. Linked pairs are grouped into clusters, closest first. threshold <= 0
+// uses a default for the current model.
+func (a *App) FindDuplicateEntries(threshold float32) ([]DuplicateCluster, error) {
+	if a.db == nil || a.embeddingService == nil {
+		return nil, fmt.Errorf("no vault loaded")
+	}
+	if threshold <= 0 {
+		threshold = a.defaultDuplicateThreshold()
+	}
+	candidates, err := a.embeddingService.SimilarPairs(threshold - duplicateNameSlack)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := a.GetAllEntries()
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]database.CodexEntry, len(entries))
+	for _, e := range entries {
+		byID[e.ID] = e
+	}
+
+	// Union-find over the accepted pairs
+	parent := make(map[int64]int64)
+	var find func(id int64) int64
+	find = func(id int64) int64 {
+		if p, ok := parent[id]; ok && p != id {
+			root := find(p)
+			parent[id] = root
+			return root
+		}
+		parent[id] = id
+		return id
+	}
+	var pairs []DuplicatePair
+	for _, c := range candidates {
+		ea, okA := byID[c.A]
+		eb, okB := byID[c.B]
+		if !okA || !okB {
+			continue
+		}
+		shared := namesShareWord(ea.Name, eb.Name)
+		if c.Score < threshold && !shared {
+			continue
+		}
+		pairs = append(pairs, DuplicatePair{A: c.A, B: c.B, Score: c.Score, SharedName: shared})
+		parent[find(c.A)] = find(c.B)
+	}
+
+	clusters := make(map[int64]*DuplicateCluster)
+	for _, p := range pairs {
+		root := find(p.A)
+		cluster, ok := clusters[root]
+		if !ok {
+			cluster = &DuplicateCluster{}
+			clusters[root] = cluster
+		}
+		cluster.Pairs = append(cluster.Pairs, p)
+		if p.Score > cluster.Score {
+			cluster.Score = p.Score
+		}
+	}
+	result := make([]DuplicateCluster, 0, len(clusters))
+	for _, cluster := range clusters {
+		members := make(map[int64]bool)
+		for _, p := range cluster.Pairs {
+			members[p.A], members[p.B] = true, true
+		}
+		for id := range members {
+			cluster.Entries = append(cluster.Entries, byID[id])
+		}
+		sort.Slice(cluster.Entries, func(i, j int) bool { return cluster.Entries[i].ID < cluster.Entries[j].ID })
+		for _, e := range cluster.Entries {
+			if cluster.Suggested == 0 || len(e.Content) > len(byID[cluster.Suggested].Content) {
+				cluster.Suggested = e.ID
+			}
+		}
+		result = append(result, *cluster)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Score > result[j].Score })
+	log.Printf("Duplicate scan at %.3f found %d clusters from %d pairs", threshold, len(result), len(pairs))
+	return result, nil
+}
+
+// namesShareWord reports whether two names have a word of 4 or more letters in common,
+// ignoring case.
+func namesShareWord(a, b string) bool {
+	wordsA := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(strings.ToLower(a), isNotWordRune) {
+		if len([]rune(w)) >= 4 {
+			wordsA[w] = true
+		}
+	}
+	for _, w := range strings.FieldsFunc(strings.ToLower(b), isNotWordRune) {
+		if wordsA[w] {
+			return true
+		}
+	}
+	return false
+}
+
+// PreviewEntryMerge proposes how to merge entries into the survivor: its name and type,
+// the content of all of them combined by the LLM, and the other entries' names and
+// aliases as aliases. Nothing is changed until MergeEntries.
+func (a *App) PreviewEntryMerge(survivorID int64, mergedIDs []int64, modelID string) (EntryMerge, error) {
+	if a.db == nil {
+		return EntryMerge{}, fmt.Errorf("database is not initialized")
+	}
+	survivor, merged, err := a.loadMergeEntries(survivorID, mergedIDs)
+	if err != nil {
+		return EntryMerge{}, err
+	}
+	if modelID == "" {
+		modelID = llm.GetConfig().ChatModelID
+	}
+
+	aliases, err := database.DBGetAliases(a.db, survivor.ID)
+	if err != nil {
+		return EntryMerge{}, err
+	}
+	var sb strings.Builder
+	sb.WriteString("The following codex entries describe the same thing and are being merged into one. ")
+	sb.WriteString("Combine their content into a single, coherent entry: keep every distinct fact, drop repetition, and where they contradict each other keep both versions and say so. ")
+	sb.WriteString("Provide ONLY the merged content, with no commentary, headings about the merge, or meta-text.\n\n")
+	sb.WriteString(fmt.Sprintf("ENTRY TO KEEP: %s (%s)\n%s\n\n", survivor.Name, survivor.Type, survivor.Content))
+	for _, e := range merged {
+		sb.WriteString(fmt.Sprintf("ENTRY TO MERGE IN: %s (%s)\n%s\n\n", e.Name, e.Type, e.Content))
+		otherAliases, err := database.DBGetAliases(a.db, e.ID)
+		if err != nil {
+			return EntryMerge{}, err
+		}
+		aliases = append(aliases, e.Name)
+		aliases = append(aliases, otherAliases...)
+	}
+	sb.WriteString("Merged content:")
+
+	content, err := a.GenerateLLMContent(sb.String(), modelID)
+	if err != nil {
+		return EntryMerge{}, fmt.Errorf("failed to merge content: %w", err)
+	}
+	return EntryMerge{
+		SurvivorID: survivor.ID,
+		MergedIDs:  mergedIDs,
+		Name:       survivor.Name,
+		Type:       survivor.Type,
+		Content:    strings.TrimSpace(content),
+		Aliases:    uniqueAliases(aliases, survivor.Name),
+	}, nil
+}
+
+// MergeEntries applies a (possibly edited) merge: the survivor gets the merged name,
+// type, content and aliases plus the tags and attachments of the merged entries, which
+// are then deleted with their embeddings. The database changes are made in one
+// transaction and the attachment files are moved only once it has committed, so a
+// failure leaves every entry as it was. The survivor is re-embedded in the background.
+func (a *App) MergeEntries(merge EntryMerge) (database.CodexEntry, error) {
+	if a.db == nil {
+		return database.CodexEntry{}, fmt.Errorf("database is not initialized")
+	}
+	if strings.TrimSpace(merge.Name) == "" {
+		return database.CodexEntry{}, fmt.Errorf("merged entry needs a name")
+	}
+	survivor, merged, err := a.loadMergeEntries(merge.SurvivorID, merge.MergedIDs)
+	if err != nil {
+		return database.CodexEntry{}, err
+	}
+	moves, files, err := a.planAttachmentMoves(merged, survivor.ID)
+	if err != nil {
+		return database.CodexEntry{}, err
+	}
+
+	survivor.Name, survivor.Type, survivor.Content = merge.Name, merge.Type, merge.Content
+	mergedIDs := make([]int64, len(merged))
+	for i, e := range merged {
+		mergedIDs[i] = e.ID
+	}
+	if err := database.DBMergeEntries(a.db, survivor, uniqueAliases(merge.Aliases, survivor.Name), mergedIDs, moves); err != nil {
+		return database.CodexEntry{}, err
+	}
+
+	// The records already point at the new files, so a failed move is only logged
+	if len(files) > 0 {
+		if err := os.MkdirAll(a.attachmentsDir(survivor.ID), 0755); err != nil {
+			log.Printf("Warning: Failed to create attachments folder for entry %d: %v", survivor.ID, err)
+		}
+	}
+	for from, to := range files {
+		if err := os.Rename(from, to); err != nil {
+			log.Printf("Warning: Failed to move attachment '%s' to '%s': %v", from, to, err)
+		}
+	}
+	for _, e := range merged {
+		a.deleteEntryAttachments(e.ID)
+		if a.embeddingService != nil {
+			a.embeddingService.RemoveEntry(e.ID)
+		}
+		log.Printf("Merged entry %d ('%s') into %d ('%s')", e.ID, e.Name, survivor.ID, survivor.Name)
+	}
+	// Attachment descriptions are part of the embedded text
+	a.queueEntryEmbedding(survivor.ID)
+	return a.getEntryByID(survivor.ID)
+}
+
+// loadMergeEntries loads the survivor and the entries merged into it.
+func (a *App) loadMergeEntries(survivorID int64, mergedIDs []int64) (database.CodexEntry, []database.CodexEntry, error) {
+	if len(mergedIDs) == 0 {
+		return database.CodexEntry{}, nil, fmt.Errorf("no entries to merge")
+	}
+	survivor, err := a.getEntryByID(survivorID)
+	if err != nil {
+		return database.CodexEntry{}, nil, err
+	}
+	merged := make([]database.CodexEntry, 0, len(mergedIDs))
+	seen := map[int64]bool{survivorID: true}
+	for _, id := range mergedIDs {
+		if seen[id] {
+			return database.CodexEntry{}, nil, fmt.Errorf("entry %d is listed twice in the merge", id)
+		}
+		seen[id] = true
+		e, err := a.getEntryByID(id)
+		if err != nil {
+			return database.CodexEntry{}, nil, err
+		}
+		merged = append(merged, e)
+	}
+	return survivor, merged, nil
+}
+
+// planAttachmentMoves picks a free filename in the survivor's folder for every attachment
+// of the merged entries. It returns the record updates and the file moves (old path to
+// new path), and fails if an attachment file is missing.
+func (a *App) planAttachmentMoves(merged []database.CodexEntry, to int64) ([]database.AttachmentMove, map[string]string, error) {
+	var moves []database.AttachmentMove
+	files := make(map[string]string)
+	dir := a.attachmentsDir(to)
+	taken := make(map[string]bool)
+	for _, e := range merged {
+		attachments, err := database.DBListAttachments(a.db, e.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, att := range attachments {
+			from := filepath.Join(a.attachmentsDir(e.ID), att.Filename)
+			if _, err := os.Stat(from); err != nil {
+				return nil, nil, fmt.Errorf("failed to find attachment '%s' of entry %d: %w", att.Filename, e.ID, err)
+			}
+			name := freeAttachmentName(dir, att.Filename, taken)
+			taken[name] = true
+			moves = append(moves, database.AttachmentMove{ID: att.ID, Filename: name})
+			files[from] = filepath.Join(dir, name)
+		}
+	}
+	return moves, files, nil
+}
+
+// uniqueAliases drops blanks, repeats and the entry's own name, ignoring case.
+func uniqueAliases(aliases []string, name string) []string {
+	seen := map[string]bool{strings.ToLower(strings.TrimSpace(name)): true}
+	unique := []string{}
+	for _, alias := range aliases {
+		alias = strings.TrimSpace(alias)
+		if key := strings.ToLower(alias); alias != "" && !seen[key] {
+			seen[key] = true
+			unique = append(unique, alias)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"Llore/internal/database"
+	"Llore/internal/embeddings"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNamesShareWord(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"Sir Reginald", "Reginald the Brave", true},
+		{"Sir Reginald", "REGINALD", true},
+		{"Castle-Black", "Black Keep", true},
+		{"Sir Al", "Al the Great", false}, // Words under 4 letters don't count
+		{"The Keep", "The Tower", false},
+		{"Blackstone", "Black", false},
+		{"", "Reginald", false},
+	}
+	for _, tt := range tests {
+		if got := namesShareWord(tt.a, tt.b); got != tt.want {
+			t.Errorf("namesShareWord(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestFindDuplicateEntries(t *testing.T) {
+	a := openVectorTestVault(t)
+	a.embeddingService = embeddings.NewEmbeddingService(a.db, embeddings.NewBuiltinEmbeddingProvider())
+	version := a.embeddingService.ModelIdentifier()
+
+	// Two groups in separate dimensions; within a group the scores to the first entry are
+	// given, so the vectors at opposite sides of it stay below the threshold
+	entries := []struct {
+		name, content string
+		vec           []float32
+	}{
+		{"Reginald the Brave", "A knight.", []float32{1, 0, 0, 0}},
+		{"Sir Reginald", "A knight of the realm.", []float32{0.85, -0.5268, 0, 0}}, // 0.85, shares a name
+		{"The Brave Knight", "A knight.", []float32{0.95, 0.3122, 0, 0}},           // 0.95
+		{"Castle Black", "A fortress.", []float32{0, 0, 1, 0}},
+		{"Blackstone Keep", "A fortress in the north.", []float32{0, 0, 0.97, 0.2431}}, // 0.97
+		{"Old Tower", "A ruin.", []float32{0, 0, 0.85, -0.5268}},                       // 0.85, no shared name
+	}
+	ids := make([]int64, len(entries))
+	for i, e := range entries {
+		id, err := database.DBInsertEntry(a.db, e.name, "Character", e.content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = id
+		storeTestVector(t, a.db, id, version, e.vec)
+	}
+
+	clusters, err := a.FindDuplicateEntries(0.9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clusters) != 2 {
+		t.Fatalf("found %d clusters, want 2: %+v", len(clusters), clusters)
+	}
+	tests := []struct {
+		members   []int64
+		pairs     int
+		suggested int64
+	}{
+		{[]int64{ids[3], ids[4]}, 1, ids[4]},         // Closest pair first
+		{[]int64{ids[0], ids[1], ids[2]}, 2, ids[1]}, // Linked through the first entry
+	}
+	for i, tt := range tests {
+		c := clusters[i]
+		var members []int64
+		for _, e := range c.Entries {
+			members = append(members, e.ID)
+		}
+		if !reflect.DeepEqual(members, tt.members) {
+			t.Errorf("cluster %d has entries %v, want %v", i, members, tt.members)
+		}
+		if len(c.Pairs) != tt.pairs {
+			t.Errorf("cluster %d has %d pairs, want %d", i, len(c.Pairs), tt.pairs)
+		}
+		if c.Suggested != tt.suggested {
+			t.Errorf("cluster %d suggests %d, want %d", i, c.Suggested, tt.suggested)
+		}
+	}
+	for _, p := range clusters[1].Pairs {
+		if p.B == ids[1] && !p.SharedName {
+			t.Error("pair below the threshold was not marked as sharing a name")
+		}
+	}
+}
+
+func TestMergeEntries(t *testing.T) {
+	a := openVectorTestVault(t)
+	a.dbPath = t.TempDir()
+	for _, ensure := range []func(*sql.DB) error{database.DBEnsureAliasesTable, database.DBEnsureTagsTable, database.DBEnsureJobsTable} {
+		if err := ensure(a.db); err != nil {
+			t.Fatal(err)
+		}
+	}
+	insert := func(name string, attachments ...string) int64 {
+		t.Helper()
+		id, err := database.DBInsertEntry(a.db, name, "Character", name+" content")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := database.DBAddTag(a.db, id, name+" tag"); err != nil {
+			t.Fatal(err)
+		}
+		for _, filename := range attachments {
+			if _, err := database.DBInsertAttachment(a.db, id, filename, "image/png"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return id
+	}
+	writeFile := func(entryID int64, filename string) {
+		t.Helper()
+		if err := os.MkdirAll(a.attachmentsDir(entryID), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(a.attachmentsDir(entryID), filename), []byte(filename), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	survivor := insert("Reginald", "portrait.png")
+	writeFile(survivor, "portrait.png")
+	merged := insert("Sir Reginald", "portrait.png")
+	writeFile(merged, "portrait.png")
+	broken := insert("Reggie", "missing.png") // Its attachment file is gone
+
+	// A merge that can't move every attachment changes nothing
+	_, err := a.MergeEntries(EntryMerge{SurvivorID: survivor, MergedIDs: []int64{merged, broken}, Name: "Reginald", Content: "merged"})
+	if err == nil {
+		t.Fatal("merged an entry whose attachment file is missing")
+	}
+	if n := countVaultRows(t, a, `SELECT COUNT(*) FROM codex_entries`); n != 3 {
+		t.Errorf("%d entries left after a failed merge, want 3", n)
+	}
+	if n := countVaultRows(t, a, `SELECT COUNT(*) FROM codex_entries WHERE id = ? AND content = 'merged'`, survivor); n != 0 {
+		t.Error("failed merge changed the survivor")
+	}
+	if _, err := os.Stat(filepath.Join(a.attachmentsDir(merged), "portrait.png")); err != nil {
+		t.Errorf("failed merge moved an attachment: %v", err)
+	}
+
+	entry, err := a.MergeEntries(EntryMerge{SurvivorID: survivor, MergedIDs: []int64{merged}, Name: "Reginald", Type: "Character", Content: "merged", Aliases: []string{"Sir Reginald", "reginald"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Content != "merged" {
+		t.Errorf("survivor content = %q", entry.Content)
+	}
+	if n := countVaultRows(t, a, `SELECT COUNT(*) FROM codex_entries WHERE id = ?`, merged); n != 0 {
+		t.Error("merged entry still exists")
+	}
+	aliases, err := database.DBGetAliases(a.db, survivor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(aliases, []string{"Sir Reginald"}) {
+		t.Errorf("aliases = %v, want [Sir Reginald]", aliases)
+	}
+	if n := countVaultRows(t, a, `SELECT COUNT(*) FROM codex_tags WHERE codex_entry_id = ?`, survivor); n != 2 {
+		t.Errorf("survivor has %d tags, want 2", n)
+	}
+	attachments, err := database.DBListAttachments(a.db, survivor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attachments) != 2 {
+		t.Fatalf("survivor has %d attachments, want 2", len(attachments))
+	}
+	for _, att := range attachments {
+		data, err := os.ReadFile(filepath.Join(a.attachmentsDir(survivor), att.Filename))
+		if err != nil {
+			t.Errorf("attachment %q has no file: %v", att.Filename, err)
+		} else if string(data) != "portrait.png" {
+			t.Errorf("attachment %q holds %q", att.Filename, data)
+		}
+	}
+	if _, err := os.Stat(a.attachmentsDir(merged)); !os.IsNotExist(err) {
+		t.Error("merged entry's attachments folder was not removed")
+	}
+}
@@ -0,0 +1,403 @@
+package main
+
+import (
+	"Llore/internal/database"
+	"Llore/internal/provider"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Kinds of embedding job.
+const (
+	jobKindEntry   = database.JobKindEntry // Target is a codex entry ID
+	jobKindLibrary = "library"             // Target is a Library-relative file or folder path
+	jobKindChat    = "chat"                // Target is a chat log filename
+)
+
+const (
+	embeddingJobWorkers     = 2
+	embeddingJobMaxAttempts = 5
+	embeddingJobBaseBackoff = 5 * time.Second
+	embeddingJobMaxBackoff  = 10 * time.Minute
+	embeddingJobIdlePoll    = 30 * time.Second
+)
+
+// EmbeddingJobProgress is emitted as "llore:embedding-jobs" whenever the queue changes.
+type EmbeddingJobProgress struct {
+	Queued  int `json:"queued"`  // Waiting, including jobs backing off after a failed attempt
+	Running int `json:"running"` // Claimed by a worker
+	Done    int `json:"done"`    // Finished since the queue was last empty
+	Failed  int `json:"failed"`  // Gave up after retries; see ListFailedEmbeddingJobs
+	Total   int `json:"total"`   // Done + Queued + Running, for "Indexing 340/2000"
+}
+
+// embeddingJobPool runs persisted embedding jobs for one vault and embedding service.
+// It is replaced whenever either changes, so workers never see a different vault's state.
+type embeddingJobPool struct {
+	app  *App
+	db   *sql.DB
+	wake chan struct{}
+	stop chan struct{}
+	wg   sync.WaitGroup
+
+	claimMu sync.Mutex // Claims are read-then-update; one worker at a time
+
+	mu      sync.Mutex
+	done    int
+	dirty   bool // Vectors were saved since the index was last persisted
+	running int  // Workers currently processing a batch
+}
+
+// startEmbeddingJobs starts a worker pool for the current vault and embedding service,
+// stopping any previous one first.
+func (a *App) startEmbeddingJobs() {
+	a.stopEmbeddingJobs()
+	if a.db == nil || a.embeddingService == nil {
+		return
+	}
+	pool := &embeddingJobPool{
+		app:  a,
+		db:   a.db,
+		wake: make(chan struct{}, 1),
+		stop: make(chan struct{}),
+	}
+	for i := 0; i < embeddingJobWorkers; i++ {
+		pool.wg.Add(1)
+		go pool.work()
+	}
+	a.jobsMu.Lock()
+	a.jobs = pool
+	a.jobsMu.Unlock()
+	pool.emitProgress()
+}
+
+// stopEmbeddingJobs stops the worker pool and waits for in-flight batches to finish.
+// Jobs still queued stay in the vault and are picked up next time.
+func (a *App) stopEmbeddingJobs() {
+	a.jobsMu.Lock()
+	pool := a.jobs
+	a.jobs = nil
+	a.jobsMu.Unlock()
+	if pool == nil {
+		return
+	}
+	close(pool.stop)
+	pool.wg.Wait()
+}
+
+func (a *App) embeddingJobs() *embeddingJobPool {
+	a.jobsMu.Lock()
+	defer a.jobsMu.Unlock()
+	return a.jobs
+}
+
+// enqueueEmbeddingJob persists a job and wakes the workers. Jobs are stored even when no
+// embedding provider is configured; they run once one is.
+func (a *App) enqueueEmbeddingJob(kind, target string) {
+	if a.db == nil {
+		return
+	}
+	if _, err := database.DBEnqueueJob(a.db, kind, target); err != nil {
+		log.Printf("Warning: %v", err)
+		return
+	}
+	if pool := a.embeddingJobs(); pool != nil {
+		pool.signal()
+		pool.emitProgress()
+	}
+}
+
+// enqueueEmbeddingJobs persists many jobs of one kind in one transaction and returns
+// how many were new.
+func (a *App) enqueueEmbeddingJobs(kind string, targets []string) int {
+	if a.db == nil || len(targets) == 0 {
+		return 0
+	}
+	added, err := database.DBEnqueueJobs(a.db, kind, targets)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return 0
+	}
+	if pool := a.embeddingJobs(); pool != nil {
+		pool.signal()
+		pool.emitProgress()
+	}
+	return added
+}
+
+func (p *embeddingJobPool) signal() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (p *embeddingJobPool) work() {
+	defer p.wg.Done()
+	for {
+		select {
+		case <-p.stop:
+			return
+		default:
+		}
+
+		p.claimMu.Lock()
+		jobs, err := database.DBClaimJobs(p.db, embeddingWorkerBatchSize)
+		if len(jobs) > 0 {
+			p.mu.Lock()
+			p.running++
+			p.mu.Unlock()
+		}
+		p.claimMu.Unlock()
+		if err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		if len(jobs) > 0 {
+			p.emitProgress()
+			p.process(jobs)
+			p.mu.Lock()
+			p.running--
+			p.mu.Unlock()
+			p.emitProgress()
+			continue
+		}
+
+		p.onIdle()
+		wait := embeddingJobIdlePoll
+		if due, ok, err := database.DBNextJobDue(p.db); err == nil && ok && due < wait {
+			wait = due + time.Second // datetime() has one-second resolution
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-p.stop:
+			timer.Stop()
+			return
+		case <-p.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// onIdle persists the vector index after a burst of work and resets the done counter
+// once nothing is left, so the next burst's progress starts from zero.
+func (p *embeddingJobPool) onIdle() {
+	p.mu.Lock()
+	busy := p.running > 0
+	dirty := p.dirty && !busy
+	if dirty {
+		p.dirty = false
+	}
+	p.mu.Unlock()
+	if busy {
+		return
+	}
+	if counts, err := database.DBCountJobs(p.db); err == nil && counts.Queued == 0 && counts.Running == 0 {
+		p.mu.Lock()
+		p.done = 0
+		p.mu.Unlock()
+	}
+	if dirty {
+		if service := p.app.embeddingService; service != nil {
+			if err := service.SaveIndex(); err != nil {
+				log.Printf("Warning: Failed to save vector index: %v", err)
+			}
+		}
+	}
+}
+
+// process runs a claimed batch. Entry jobs are embedded together; Library and chat jobs
+// one at a time.
+func (p *embeddingJobPool) process(jobs []database.EmbeddingJob) {
+	results := make(map[int64]error, len(jobs))
+	switch jobs[0].Kind {
+	case jobKindEntry:
+		p.processEntries(jobs, results)
+	case jobKindLibrary:
+		for _, job := range jobs {
+			_, err := p.app.indexLibraryPath(job.Target)
+			results[job.ID] = err
+		}
+	case jobKindChat:
+		for _, job := range jobs {
+			_, err := p.app.indexChatSession(p.app.embeddingService, job.Target)
+			results[job.ID] = err
+		}
+	default:
+		for _, job := range jobs {
+			results[job.ID] = fmt.Errorf("unknown job kind %q", job.Kind)
+		}
+	}
+
+	for _, job := range jobs {
+		p.finish(job, results[job.ID])
+	}
+}
+
+func (p *embeddingJobPool) processEntries(jobs []database.EmbeddingJob, results map[int64]error) {
+	service := p.app.embeddingService
+	if service == nil {
+		for _, job := range jobs {
+			results[job.ID] = fmt.Errorf("embedding service not initialized")
+		}
+		return
+	}
+	var reqs []embeddingRequest
+	jobForEntry := make(map[int64]int64, len(jobs))
+	for _, job := range jobs {
+		entryID, err := strconv.ParseInt(job.Target, 10, 64)
+		if err != nil {
+			results[job.ID] = fmt.Errorf("invalid entry ID %q", job.Target)
+			continue
+		}
+		entry, err := p.app.getEntryByID(entryID)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue // Deleted since it was queued; nothing to do
+		}
+		if err != nil {
+			results[job.ID] = err
+			continue
+		}
+		jobForEntry[entryID] = job.ID
+		reqs = append(reqs, p.app.newEmbeddingRequest(entry))
+	}
+	if len(reqs) == 0 {
+		return
+	}
+	failed := p.app.embedRequests(service, reqs)
+	for entryID, jobID := range jobForEntry {
+		results[jobID] = failed[entryID]
+	}
+	if len(failed) < len(reqs) {
+		p.mu.Lock()
+		p.dirty = true
+		p.mu.Unlock()
+	}
+}
+
+// finish records a job's outcome: done, retried later with exponential backoff, or
+// failed. Errors that retrying can't fix, like a bad API key, fail immediately.
+func (p *embeddingJobPool) finish(job database.EmbeddingJob, err error) {
+	if err == nil {
+		if _, err := database.DBCompleteJob(p.db, job.ID); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		p.mu.Lock()
+		p.done++
+		p.mu.Unlock()
+		return
+	}
+
+	attempts := job.Attempts + 1
+	switch provider.CodeOf(err) {
+	case provider.ErrAuth, provider.ErrModelNotFound, provider.ErrNotConfigured, provider.ErrContentFiltered, provider.ErrContextTooLong:
+		attempts = embeddingJobMaxAttempts
+	}
+	if attempts >= embeddingJobMaxAttempts {
+		log.Printf("Embedding job %d (%s %s) failed after %d attempts: %v", job.ID, job.Kind, job.Target, attempts, err)
+		if dbErr := database.DBFailJob(p.db, job.ID, attempts, err.Error()); dbErr != nil {
+			log.Printf("Warning: %v", dbErr)
+		}
+		return
+	}
+
+	delay := embeddingJobBaseBackoff << (attempts - 1)
+	if delay > embeddingJobMaxBackoff {
+		delay = embeddingJobMaxBackoff
+	}
+	log.Printf("Embedding job %d (%s %s) failed (attempt %d), retrying in %s: %v", job.ID, job.Kind, job.Target, attempts, delay, err)
+	if dbErr := database.DBRetryJobLater(p.db, job.ID, attempts, err.Error(), delay); dbErr != nil {
+		log.Printf("Warning: %v", dbErr)
+	}
+}
+
+func (p *embeddingJobPool) progress() (EmbeddingJobProgress, error) {
+	counts, err := database.DBCountJobs(p.db)
+	if err != nil {
+		return EmbeddingJobProgress{}, err
+	}
+	p.mu.Lock()
+	done := p.done
+	p.mu.Unlock()
+	return EmbeddingJobProgress{
+		Queued:  counts.Queued,
+		Running: counts.Running,
+		Done:    done,
+		Failed:  counts.Failed,
+		Total:   done + counts.Queued + counts.Running,
+	}, nil
+}
+
+func (p *embeddingJobPool) emitProgress() {
+	if p.app.ctx == nil {
+		return
+	}
+	progress, err := p.progress()
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return
+	}
+	runtime.EventsEmit(p.app.ctx, "llore:embedding-jobs", progress)
+}
+
+// GetEmbeddingJobProgress returns the current queue counts, the same data as the
+// "llore:embedding-jobs" event.
+func (a *App) GetEmbeddingJobProgress() (EmbeddingJobProgress, error) {
+	if a.db == nil {
+		return EmbeddingJobProgress{}, fmt.Errorf("no vault is currently loaded")
+	}
+	if pool := a.embeddingJobs(); pool != nil {
+		return pool.progress()
+	}
+	counts, err := database.DBCountJobs(a.db)
+	if err != nil {
+		return EmbeddingJobProgress{}, err
+	}
+	return EmbeddingJobProgress{Queued: counts.Queued, Running: counts.Running, Failed: counts.Failed, Total: counts.Queued + counts.Running}, nil
+}
+
+// ListFailedEmbeddingJobs returns jobs that gave up, with their last error.
+func (a *App) ListFailedEmbeddingJobs() ([]database.EmbeddingJob, error) {
+	if a.db == nil {
+		return nil, fmt.Errorf("no vault is currently loaded")
+	}
+	return database.DBListJobs(a.db, database.JobFailed)
+}
+
+// RetryFailedEmbeddingJobs puts failed jobs back in the queue and returns how many.
+func (a *App) RetryFailedEmbeddingJobs() (int, error) {
+	if a.db == nil {
+		return 0, fmt.Errorf("no vault is currently loaded")
+	}
+	n, err := database.DBRetryFailedJobs(a.db)
+	if err != nil {
+		return 0, err
+	}
+	if pool := a.embeddingJobs(); pool != nil {
+		pool.signal()
+		pool.emitProgress()
+	}
+	return n, nil
+}
+
+// ClearFailedEmbeddingJobs discards failed jobs and returns how many.
+func (a *App) ClearFailedEmbeddingJobs() (int, error) {
+	if a.db == nil {
+		return 0, fmt.Errorf("no vault is currently loaded")
+	}
+	n, err := database.DBClearFailedJobs(a.db)
+	if err != nil {
+		return 0, err
+	}
+	if pool := a.embeddingJobs(); pool != nil {
+		pool.emitProgress()
+	}
+	return n, nil
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"Llore/internal/database"
+	"Llore/internal/embeddings"
+	"Llore/internal/llm"
+	"fmt"
+	"log"
+	"path/filepath"
+)
+
+// Reasons an embedding is reported as stale.
+const (
+	staleChanged    = "changed"    // The entry's text changed after it was embedded
+	staleUnverified = "unverified" // Embedded before content hashes were recorded
+)
+
+// StaleEmbedding is an entry whose stored embedding may not match its current text.
+type StaleEmbedding struct {
+	EntryID int64  `json:"entryId"`
+	Name    string `json:"name"`
+	Reason  string `json:"reason"`
+}
+
+// EmbeddingCoverage describes what is stored for one vector version (embedding provider
+// and model) in the current vault.
+type EmbeddingCoverage struct {
+	VectorVersion      string `json:"vectorVersion"`
+	Active             bool   `json:"active"` // Used by the current embedding provider
+	TotalEntries       int    `json:"totalEntries"`
+	Embedded           int    `json:"embedded"`
+	Missing            int    `json:"missing"`
+	Stale              int    `json:"stale"`
+	Unverified         int    `json:"unverified"`
+	Passages           int    `json:"passages"`
+	ManuscriptPassages int    `json:"manuscriptPassages"`
+	ChatMemories       int    `json:"chatMemories"`
+	Bytes              int64  `json:"bytes"`
+}
+
+// currentEntryHashes returns every entry alongside the hash of the text it would be
+// embedded from today.
+func (a *App) currentEntryHashes() ([]database.CodexEntry, map[int64]string, error) {
+	entries, err := a.GetAllEntries()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load entries: %w", err)
+	}
+	hashes := make(map[int64]string, len(entries))
+	for _, entry := range entries {
+		hashes[entry.ID] = embeddings.ContentHash(a.newEmbeddingRequest(entry).text)
+	}
+	return entries, hashes, nil
+}
+
+// findStaleEntries compares stored hashes for the current provider with the entries'
+// current text. Unverified embeddings are only reported when includeUnverified is set.
+func (a *App) findStaleEntries(includeUnverified bool) ([]database.CodexEntry, []StaleEmbedding, error) {
+	if a.db == nil {
+		return nil, nil, fmt.Errorf("no vault is currently loaded")
+	}
+	if a.embeddingService == nil {
+		return nil, nil, fmt.Errorf("embedding service not initialized")
+	}
+	entries, current, err := a.currentEntryHashes()
+	if err != nil {
+		return nil, nil, err
+	}
+	all, err := database.DBListEmbeddingHashes(a.db)
+	if err != nil {
+		return nil, nil, err
+	}
+	stored := all[a.embeddingService.ModelIdentifier()]
+
+	var staleEntries []database.CodexEntry
+	report := []StaleEmbedding{}
+	for _, entry := range entries {
+		hash, ok := stored[entry.ID]
+		if !ok {
+			continue // Missing, not stale; GenerateMissingEmbeddings handles these
+		}
+		reason := ""
+		switch {
+		case hash == "":
+			if includeUnverified {
+				reason = staleUnverified
+			}
+		case hash != current[entry.ID]:
+			reason = staleChanged
+		}
+		if reason != "" {
+			staleEntries = append(staleEntries, entry)
+			report = append(report, StaleEmbedding{EntryID: entry.ID, Name: entry.Name, Reason: reason})
+		}
+	}
+	return staleEntries, report, nil
+}
+
+// FindStaleEmbeddings lists entries whose embedding for the current provider was made
+// from different text than they have now, plus embeddings that predate hash tracking.
+func (a *App) FindStaleEmbeddings() ([]StaleEmbedding, error) {
+	_, report, err := a.findStaleEntries(true)
+	return report, err
+}
+
+// ReembedStaleEntries queues re-embedding of entries whose text changed since they were
+// embedded. With includeUnverified, embeddings that predate hash tracking are redone as
+// well. Returns how many entries were queued.
+func (a *App) ReembedStaleEntries(includeUnverified bool) (int, error) {
+	stale, _, err := a.findStaleEntries(includeUnverified)
+	if err != nil {
+		return 0, err
+	}
+	if len(stale) == 0 {
+		return 0, nil
+	}
+	ids := make([]int64, len(stale))
+	for i, entry := range stale {
+		ids[i] = entry.ID
+	}
+	a.queueEntryEmbeddings(ids)
+	log.Printf("Queued re-embedding of %d stale entries", len(stale))
+	return len(stale), nil
+}
+
+// GetEmbeddingCoverage reports, per vector version stored in the vault, how many entries
+// are embedded, missing or stale, and how much space the vectors take. The active
+// provider is always listed, even before anything has been embedded with it.
+func (a *App) GetEmbeddingCoverage() ([]EmbeddingCoverage, error) {
+	if a.db == nil {
+		return nil, fmt.Errorf("no vault is currently loaded")
+	}
+	usage, err := database.DBListVectorVersions(a.db)
+	if err != nil {
+		return nil, err
+	}
+	entries, current, err := a.currentEntryHashes()
+	if err != nil {
+		return nil, err
+	}
+	stored, err := database.DBListEmbeddingHashes(a.db)
+	if err != nil {
+		return nil, err
+	}
+
+	active := ""
+	if a.embeddingService != nil {
+		active = a.embeddingService.ModelIdentifier()
+	}
+	listed := false
+	coverage := make([]EmbeddingCoverage, 0, len(usage)+1)
+	for _, u := range usage {
+		c := EmbeddingCoverage{
+			VectorVersion:      u.VectorVersion,
+			Active:             u.VectorVersion == active,
+			TotalEntries:       len(entries),
+			Passages:           u.Passages,
+			ManuscriptPassages: u.ManuscriptPassages,
+			ChatMemories:       u.ChatMemories,
+			Bytes:              u.Bytes,
+		}
+		listed = listed || c.Active
+		hashes := stored[u.VectorVersion]
+		for _, entry := range entries {
+			hash, ok := hashes[entry.ID]
+			switch {
+			case !ok:
+				c.Missing++
+			case hash == "":
+				c.Embedded++
+				c.Unverified++
+			case hash != current[entry.ID]:
+				c.Embedded++
+				c.Stale++
+			default:
+				c.Embedded++
+			}
+		}
+		coverage = append(coverage, c)
+	}
+	if active != "" && !listed {
+		coverage = append(coverage, EmbeddingCoverage{VectorVersion: active, Active: true, TotalEntries: len(entries), Missing: len(entries)})
+	}
+	return coverage, nil
+}
+
+// PurgeVectorVersion deletes everything stored for a vector version that is no longer
+// in use: entry and passage vectors, manuscript and chat memory vectors, and its saved
+// index file. Protected versions (see protectedVectorVersions) can't be purged. Returns
+// the rows removed.
+func (a *App) PurgeVectorVersion(version string) (int64, error) {
+	if a.db == nil {
+		return 0, fmt.Errorf("no vault is currently loaded")
+	}
+	if version == "" {
+		return 0, fmt.Errorf("vector version is required")
+	}
+	if reason, ok := a.protectedVectorVersions()[version]; ok {
+		return 0, fmt.Errorf("cannot purge %s: %s", version, reason)
+	}
+	removed, err := database.DBPurgeVectorVersion(a.db, version)
+	if err != nil {
+		return 0, err
+	}
+	if err := embeddings.RemoveIndexFile(filepath.Join(a.dbPath, "Codex", "Index"), version); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	log.Printf("Purged vector version %s: %d rows removed", version, removed)
+	return removed, nil
+}
+
+// PurgeUnusedVectorVersions purges every vector version that isn't protected and returns
+// the versions removed. A version that fails to purge doesn't stop the others; the first
+// failure is returned once all were tried.
+func (a *App) PurgeUnusedVectorVersions() ([]string, error) {
+	if a.db == nil {
+		return nil, fmt.Errorf("no vault is currently loaded")
+	}
+	if a.embeddingService == nil {
+		// Without an active provider there's no way to tell which version is still wanted
+		return nil, fmt.Errorf("embedding service not initialized")
+	}
+	usage, err := database.DBListVectorVersions(a.db)
+	if err != nil {
+		return nil, err
+	}
+	protected := a.protectedVectorVersions()
+	purged := []string{}
+	var firstErr error
+	for _, u := range usage {
+		if reason, ok := protected[u.VectorVersion]; ok {
+			log.Printf("Keeping vector version %s: %s", u.VectorVersion, reason)
+			continue
+		}
+		if _, err := a.PurgeVectorVersion(u.VectorVersion); err != nil {
+			log.Printf("Warning: %v", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		purged = append(purged, u.VectorVersion)
+	}
+	return purged, firstErr
+}
+
+// protectedVectorVersions returns the vector versions that must be kept, each with the
+// reason: the active provider's, the configured provider's, which differs while the
+// built-in fallback stands in for it, and the target of a migration in any state.
+func (a *App) protectedVectorVersions() map[string]string {
+	protected := make(map[string]string)
+	if m := a.embeddingMigration(); m != nil {
+		protected[m.snapshot().ToVersion] = "an embedding migration to it has not been completed or cancelled"
+	}
+	if configured, err := configuredEmbeddingProvider(llm.GetConfig()); err == nil && configured != nil {
+		protected[a.loadVectorStorage().VectorVersion(configured.ModelIdentifier())] = "it belongs to the configured embedding provider"
+	}
+	if a.embeddingService != nil {
+		protected[a.embeddingService.ModelIdentifier()] = "it is used by the current embedding provider"
+	}
+	return protected
+}
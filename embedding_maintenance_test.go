@@ -0,0 +1,69 @@
+package main
+
+import (
+	"Llore/internal/embeddings"
+	"Llore/internal/llm"
+	"reflect"
+	"testing"
+)
+
+func TestPurgeUnusedVectorVersionsKeepsConfiguredProvider(t *testing.T) {
+	previous := llm.GetConfig()
+	t.Cleanup(func() { llm.SetConfig(previous) })
+	llm.SetConfig(llm.OpenRouterConfig{ActiveMode: "local", LocalEmbeddingModelName: "mxbai-embed-large"})
+
+	a := openVectorTestVault(t)
+	a.dbPath = t.TempDir()
+	// Ollama was unreachable at startup, so the built-in provider stands in
+	a.embeddingService = embeddings.NewEmbeddingService(a.db, embeddings.NewBuiltinEmbeddingProvider())
+	a.embedFallback = "Ollama is not running"
+
+	configured := "ollama:mxbai-embed-large"
+	fallback := a.embeddingService.ModelIdentifier()
+	for i, version := range []string{configured, fallback, "gemini:text-embedding-004", "openai:text-embedding-3-small"} {
+		storeTestVector(t, a.db, int64(i+1), version, testVector(8))
+	}
+
+	purged, err := a.PurgeUnusedVectorVersions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"gemini:text-embedding-004", "openai:text-embedding-3-small"}; !reflect.DeepEqual(purged, want) {
+		t.Errorf("purged %v, want %v", purged, want)
+	}
+	for _, version := range []string{configured, fallback} {
+		if n := countVaultRows(t, a, `SELECT COUNT(*) FROM codex_embeddings WHERE vector_version = ?`, version); n != 1 {
+			t.Errorf("%s lost its vectors", version)
+		}
+	}
+	if _, err := a.PurgeVectorVersion(configured); err == nil {
+		t.Error("purged the configured provider's version")
+	}
+}
+
+func TestPurgeKeepsMigrationTarget(t *testing.T) {
+	previous := llm.GetConfig()
+	t.Cleanup(func() { llm.SetConfig(previous) })
+	llm.SetConfig(llm.OpenRouterConfig{})
+
+	for _, state := range []string{migrationRunning, migrationReady, migrationFailed} {
+		t.Run(state, func(t *testing.T) {
+			a := openVectorTestVault(t)
+			a.dbPath = t.TempDir()
+			a.embeddingService = embeddings.NewEmbeddingService(a.db, embeddings.NewBuiltinEmbeddingProvider())
+			target := "gemini:gemini-embedding-001@768"
+			storeTestVector(t, a.db, 1, target, testVector(8))
+			a.migration = &embeddingMigration{status: EmbeddingMigrationStatus{State: state, ToVersion: target}}
+
+			if _, err := a.PurgeVectorVersion(target); err == nil {
+				t.Error("purged the target of a migration")
+			}
+			if purged, err := a.PurgeUnusedVectorVersions(); err != nil || len(purged) != 0 {
+				t.Errorf("purged %v (err %v), want nothing", purged, err)
+			}
+			if n := countVaultRows(t, a, `SELECT COUNT(*) FROM codex_embeddings WHERE vector_version = ?`, target); n != 1 {
+				t.Error("migration target lost its vectors")
+			}
+		})
+	}
+}
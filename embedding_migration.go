@@ -0,0 +1,453 @@
+package main
+
+import (
+	"Llore/internal/database"
+	"Llore/internal/embeddings"
+	"Llore/internal/llm"
+	"fmt"
+	"log"
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Migration states.
+const (
+	migrationRunning = "running" // Embedding the vault with the new provider
+	migrationReady   = "ready"   // Everything embedded; can be compared and cut over
+	migrationFailed  = "failed"  // Stopped on errors; starting again resumes where it left off
+)
+
+const (
+	migrationBatchSize     = 32
+	migrationSampleQueries = 8  // Queries drawn from the codex when none are given
+	migrationCompareLimit  = 10 // Results compared per query
+)
+
+// EmbeddingMigrationStatus is emitted as "llore:embedding-migration" as a migration
+// progresses.
+type EmbeddingMigrationStatus struct {
+	State       string          `json:"state"` // Empty when no migration has been started
+	FromVersion string          `json:"fromVersion"`
+	ToVersion   string          `json:"toVersion"`
+	Entries     int             `json:"entries"`  // Entries in the vault
+	Embedded    int             `json:"embedded"` // Entries embedded for the new version so far
+	Failed      int             `json:"failed"`
+	Manuscripts IndexSyncResult `json:"manuscripts"`
+	ChatMemory  IndexSyncResult `json:"chatMemory"`
+	Error       string          `json:"error,omitempty"`
+	StartedAt   time.Time       `json:"startedAt"`
+}
+
+// MigrationHit is one search result in a migration comparison.
+type MigrationHit struct {
+	EntryID int64   `json:"entryId"`
+	Name    string  `json:"name"`
+	Score   float32 `json:"score"`
+}
+
+// MigrationComparison shows what the current and the new embeddings retrieve for a query.
+type MigrationComparison struct {
+	Query     string         `json:"query"`
+	Current   []MigrationHit `json:"current"`
+	Candidate []MigrationHit `json:"candidate"`
+	Overlap   float64        `json:"overlap"` // Share of the current results the new ones also return
+}
+
+// embeddingMigration embeds the vault with a new provider next to the one in use. The
+// current provider keeps serving retrieval until the migration is completed.
+type embeddingMigration struct {
+	config  llm.OpenRouterConfig // Settings that select the new provider
+	service *embeddings.EmbeddingService
+	stop    chan struct{}
+	done    chan struct{}
+
+	mu     sync.Mutex
+	status EmbeddingMigrationStatus
+}
+
+// StartEmbeddingMigration begins embedding the vault with the provider that config's
+// embedding settings select, without changing the provider in use. To move to another
+// embedding provider, set config.EmbeddingProvider; the chat mode is never changed.
+// Entries, manuscripts and chat memory already embedded for the new version are skipped,
+// so starting again after a failure resumes. Progress is emitted as
+// "llore:embedding-migration".
+func (a *App) StartEmbeddingMigration(config llm.OpenRouterConfig) (EmbeddingMigrationStatus, error) {
+	if a.db == nil {
+		return EmbeddingMigrationStatus{}, fmt.Errorf("no vault is currently loaded")
+	}
+	if m := a.embeddingMigration(); m != nil && m.snapshot().State == migrationRunning {
+		return m.snapshot(), fmt.Errorf("a migration to %s is already running", m.snapshot().ToVersion)
+	}
+
+	// The provider is chosen exactly as CompleteEmbeddingMigration will choose it
+	selected := llm.GetConfig()
+	applyEmbeddingSettings(&selected, config)
+	chosenProvider, err := newEmbeddingProvider(selected)
+	if err != nil || chosenProvider == nil {
+		return EmbeddingMigrationStatus{}, fmt.Errorf("new embedding provider is unavailable: %v", err)
+	}
+	service := embeddings.NewEmbeddingService(a.db, chosenProvider)
+	if err := service.SetVectorStorage(a.loadVectorStorage()); err != nil {
+		return EmbeddingMigrationStatus{}, err
+	}
+	from := ""
+	if a.embeddingService != nil {
+		from = a.embeddingService.ModelIdentifier()
+	}
+	if service.ModelIdentifier() == from {
+		return EmbeddingMigrationStatus{}, fmt.Errorf("the vault already uses %s", from)
+	}
+
+	a.cancelEmbeddingMigration()
+	m := &embeddingMigration{
+		config:  selected,
+		service: service,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+		status: EmbeddingMigrationStatus{
+			State:       migrationRunning,
+			FromVersion: from,
+			ToVersion:   service.ModelIdentifier(),
+			StartedAt:   time.Now(),
+		},
+	}
+	a.migrationMu.Lock()
+	a.migration = m
+	a.migrationMu.Unlock()
+
+	log.Printf("Embedding migration: %s -> %s started", from, m.status.ToVersion)
+	go a.runEmbeddingMigration(m)
+	return m.snapshot(), nil
+}
+
+// GetEmbeddingMigrationStatus returns the state of the current migration, if any.
+func (a *App) GetEmbeddingMigrationStatus() EmbeddingMigrationStatus {
+	if m := a.embeddingMigration(); m != nil {
+		return m.snapshot()
+	}
+	return EmbeddingMigrationStatus{}
+}
+
+// CompareEmbeddingMigration runs each query against both the current and the new
+// embeddings and returns the top results side by side. Without queries, a sample is
+// drawn from the opening sentences of random codex entries.
+func (a *App) CompareEmbeddingMigration(queries []string) ([]MigrationComparison, error) {
+	m := a.embeddingMigration()
+	if m == nil {
+		return nil, fmt.Errorf("no embedding migration has been started")
+	}
+	current := a.embeddingService
+	if current == nil {
+		return nil, fmt.Errorf("embedding service not initialized")
+	}
+	if len(queries) == 0 {
+		var err error
+		if queries, err = a.sampleMigrationQueries(); err != nil {
+			return nil, err
+		}
+	}
+
+	comparisons := make([]MigrationComparison, 0, len(queries))
+	for _, query := range queries {
+		query = strings.TrimSpace(query)
+		if query == "" {
+			continue
+		}
+		c := MigrationComparison{Query: query}
+		before, err := current.FindSimilarEntries(query, migrationCompareLimit)
+		if err != nil {
+			return nil, fmt.Errorf("current embeddings failed on %q: %w", query, err)
+		}
+		after, err := m.service.FindSimilarEntries(query, migrationCompareLimit)
+		if err != nil {
+			return nil, fmt.Errorf("new embeddings failed on %q: %w", query, err)
+		}
+		c.Current, c.Candidate = migrationHits(before), migrationHits(after)
+
+		found := make(map[int64]bool, len(c.Candidate))
+		for _, hit := range c.Candidate {
+			found[hit.EntryID] = true
+		}
+		shared := 0
+		for _, hit := range c.Current {
+			if found[hit.EntryID] {
+				shared++
+			}
+		}
+		if len(c.Current) > 0 {
+			c.Overlap = float64(shared) / float64(len(c.Current))
+		}
+		comparisons = append(comparisons, c)
+	}
+	return comparisons, nil
+}
+
+// CompleteEmbeddingMigration switches the vault to the new provider once the migration
+// is ready. Entries edited since they were migrated are embedded again first, so the new
+// version is complete at the moment it takes over. Only the settings that select the
+// embedding provider are taken from the migration; everything else is left as it is now.
+// With dropOld, the previous version's vectors are deleted afterwards.
+func (a *App) CompleteEmbeddingMigration(dropOld bool) error {
+	m := a.embeddingMigration()
+	if m == nil {
+		return fmt.Errorf("no embedding migration has been started")
+	}
+	status := m.snapshot()
+	if status.State != migrationReady {
+		return fmt.Errorf("the migration to %s is not ready (state: %s)", status.ToVersion, status.State)
+	}
+
+	if failed, err := a.syncMigrationEntries(m); err != nil {
+		return err
+	} else if failed > 0 {
+		return fmt.Errorf("%d entries changed since they were migrated and could not be embedded again", failed)
+	}
+	if err := m.service.SaveIndex(); err != nil {
+		log.Printf("Warning: Failed to save the new vector index: %v", err)
+	}
+
+	config := llm.GetConfig()
+	applyEmbeddingSettings(&config, m.config)
+	if err := a.SaveSettings(config); err != nil {
+		return err
+	}
+	if a.embeddingService == nil || a.embeddingService.ModelIdentifier() != status.ToVersion {
+		return fmt.Errorf("settings were saved, but the vault did not switch to %s", status.ToVersion)
+	}
+	// Until the switch has happened the migration stays, so it can be completed again or cancelled
+	a.migrationMu.Lock()
+	if a.migration == m {
+		a.migration = nil
+	}
+	a.migrationMu.Unlock()
+	log.Printf("Embedding migration: switched from %s to %s", status.FromVersion, status.ToVersion)
+
+	if dropOld && status.FromVersion != "" {
+		removed, err := a.PurgeVectorVersion(status.FromVersion)
+		if err != nil {
+			return fmt.Errorf("switched to %s, but failed to drop %s: %w", status.ToVersion, status.FromVersion, err)
+		}
+		log.Printf("Embedding migration: dropped %d rows of %s", removed, status.FromVersion)
+	}
+	return nil
+}
+
+// CancelEmbeddingMigration stops the migration and keeps the current provider. With
+// dropNew, whatever was embedded for the new version is deleted.
+func (a *App) CancelEmbeddingMigration(dropNew bool) error {
+	m := a.embeddingMigration()
+	if m == nil {
+		return fmt.Errorf("no embedding migration has been started")
+	}
+	a.cancelEmbeddingMigration()
+	to := m.snapshot().ToVersion
+	if dropNew {
+		if _, err := a.PurgeVectorVersion(to); err != nil {
+			return err
+		}
+	}
+	log.Printf("Embedding migration to %s cancelled (dropNew=%v)", to, dropNew)
+	return nil
+}
+
+// cancelEmbeddingMigration stops any migration and waits for it to finish its batch.
+func (a *App) cancelEmbeddingMigration() {
+	a.migrationMu.Lock()
+	m := a.migration
+	a.migration = nil
+	a.migrationMu.Unlock()
+	if m == nil {
+		return
+	}
+	select {
+	case <-m.stop:
+	default:
+		close(m.stop)
+	}
+	<-m.done
+}
+
+func (a *App) embeddingMigration() *embeddingMigration {
+	a.migrationMu.Lock()
+	defer a.migrationMu.Unlock()
+	return a.migration
+}
+
+// runEmbeddingMigration embeds entries, then manuscripts and chat memory, with the new
+// provider.
+func (a *App) runEmbeddingMigration(m *embeddingMigration) {
+	defer close(m.done)
+
+	indexDir := filepath.Join(a.dbPath, "Codex", "Index")
+	if err := m.service.OpenIndex(indexDir); err != nil {
+		log.Printf("Warning: Failed to open vector index for %s: %v", m.service.ModelIdentifier(), err)
+	}
+
+	failed, err := a.syncMigrationEntries(m)
+	if m.stopped() {
+		return
+	}
+	if err == nil && failed > 0 {
+		err = fmt.Errorf("%d entries could not be embedded; start the migration again to retry them", failed)
+	}
+	if err != nil {
+		m.finish(a, migrationFailed, err)
+		return
+	}
+
+	library, err := a.reindexLibrary(m.service)
+	if err != nil {
+		log.Printf("Warning: Embedding migration: Library index failed: %v", err)
+	}
+	m.update(a, func(s *EmbeddingMigrationStatus) { s.Manuscripts = library })
+	if m.stopped() {
+		return
+	}
+	if llm.GetConfig().ChatMemoryEnabled {
+		chat, err := a.reindexChatMemory(m.service)
+		if err != nil {
+			log.Printf("Warning: Embedding migration: chat memory index failed: %v", err)
+		}
+		m.update(a, func(s *EmbeddingMigrationStatus) { s.ChatMemory = chat })
+	}
+
+	if err := m.service.SaveIndex(); err != nil {
+		log.Printf("Warning: Failed to save vector index for %s: %v", m.service.ModelIdentifier(), err)
+	}
+	m.finish(a, migrationReady, nil)
+	log.Printf("Embedding migration to %s ready", m.snapshot().ToVersion)
+}
+
+// syncMigrationEntries embeds every entry that has no embedding for the new version, or
+// one made from text that has since changed. It returns how many entries failed.
+func (a *App) syncMigrationEntries(m *embeddingMigration) (int, error) {
+	entries, current, err := a.currentEntryHashes()
+	if err != nil {
+		return 0, err
+	}
+	stored, err := database.DBListEmbeddingHashes(a.db)
+	if err != nil {
+		return 0, err
+	}
+	hashes := stored[m.service.ModelIdentifier()]
+
+	var pending []embeddingRequest
+	for _, entry := range entries {
+		if hash, ok := hashes[entry.ID]; !ok || hash != current[entry.ID] {
+			pending = append(pending, a.newEmbeddingRequest(entry))
+		}
+	}
+	embedded := len(entries) - len(pending)
+	m.update(a, func(s *EmbeddingMigrationStatus) {
+		s.Entries, s.Embedded, s.Failed = len(entries), embedded, 0
+	})
+
+	failed := 0
+	for start := 0; start < len(pending); start += migrationBatchSize {
+		if m.stopped() {
+			return failed, nil
+		}
+		end := start + migrationBatchSize
+		if end > len(pending) {
+			end = len(pending)
+		}
+		errs := a.embedRequests(m.service, pending[start:end])
+		failed += len(errs)
+		embedded += end - start - len(errs)
+		m.update(a, func(s *EmbeddingMigrationStatus) { s.Embedded, s.Failed = embedded, failed })
+	}
+	return failed, nil
+}
+
+// sampleMigrationQueries draws comparison queries from the opening sentences of random
+// entries, which read like the questions writers ask about them.
+func (a *App) sampleMigrationQueries() ([]string, error) {
+	entries, err := a.GetAllEntries()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load entries: %w", err)
+	}
+	var queries []string
+	for _, i := range rand.Perm(len(entries)) {
+		content := strings.TrimSpace(entries[i].Content)
+		if end := strings.IndexAny(content, ".!?\n"); end > 0 {
+			content = content[:end]
+		}
+		if len(content) > 200 {
+			content = content[:200]
+		}
+		if len(strings.Fields(content)) < 3 {
+			continue
+		}
+		queries = append(queries, content)
+		if len(queries) == migrationSampleQueries {
+			break
+		}
+	}
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("no entries with enough text to sample queries from")
+	}
+	return queries, nil
+}
+
+// applyEmbeddingSettings copies the settings that select the embedding provider and model.
+// The active mode also selects the chat provider, so it is left alone; a different
+// embedding provider is chosen with EmbeddingProvider.
+func applyEmbeddingSettings(dst *llm.OpenRouterConfig, src llm.OpenRouterConfig) {
+	dst.EmbeddingProvider = src.EmbeddingProvider
+	dst.OpenAIEmbeddingModel = src.OpenAIEmbeddingModel
+	dst.LocalEmbeddingModelName = src.LocalEmbeddingModelName
+	dst.GeminiApiKey = src.GeminiApiKey
+	dst.GeminiEmbeddingModel = src.GeminiEmbeddingModel
+	dst.GeminiEmbeddingDimensions = src.GeminiEmbeddingDimensions
+	dst.OpenAIAPIKey = src.OpenAIAPIKey
+}
+
+func migrationHits(results []embeddings.SearchResult) []MigrationHit {
+	hits := make([]MigrationHit, len(results))
+	for i, r := range results {
+		hits[i] = MigrationHit{EntryID: r.Entry.ID, Name: r.Entry.Name, Score: r.Score}
+	}
+	return hits
+}
+
+func (m *embeddingMigration) snapshot() EmbeddingMigrationStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.status
+}
+
+func (m *embeddingMigration) stopped() bool {
+	select {
+	case <-m.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// update changes the status and emits it.
+func (m *embeddingMigration) update(a *App, fn func(s *EmbeddingMigrationStatus)) {
+	m.mu.Lock()
+	fn(&m.status)
+	status := m.status
+	m.mu.Unlock()
+	if a.ctx != nil {
+		runtime.EventsEmit(a.ctx, "llore:embedding-migration", status)
+	}
+}
+
+func (m *embeddingMigration) finish(a *App, state string, err error) {
+	m.update(a, func(s *EmbeddingMigrationStatus) {
+		s.State = state
+		s.Error = ""
+		if err != nil {
+			s.Error = err.Error()
+			log.Printf("Embedding migration to %s failed: %v", s.ToVersion, err)
+		}
+	})
+}
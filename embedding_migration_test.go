@@ -0,0 +1,50 @@
+package main
+
+import (
+	"Llore/internal/llm"
+	"testing"
+)
+
+func TestMigrationSelectsEmbeddingProvider(t *testing.T) {
+	current := llm.OpenRouterConfig{
+		ActiveMode:              "openrouter",
+		APIKey:                  "or-key",
+		GeminiApiKey:            "gemini-key",
+		LocalEmbeddingModelName: "mxbai-embed-large",
+	}
+	tests := []struct {
+		name     string
+		settings llm.OpenRouterConfig
+		want     string // Vector version; "" when the settings are rejected
+	}{
+		{"unchanged follows the chat mode", current, "gemini:text-embedding-004"},
+		{"another gemini model", llm.OpenRouterConfig{GeminiApiKey: "gemini-key", GeminiEmbeddingModel: "gemini-embedding-001", GeminiEmbeddingDimensions: 768}, "gemini:gemini-embedding-001@768"},
+		{"to ollama", llm.OpenRouterConfig{EmbeddingProvider: "ollama", LocalEmbeddingModelName: "nomic-embed-text", GeminiApiKey: "gemini-key"}, "ollama:nomic-embed-text+prefixed"},
+		{"to openai", llm.OpenRouterConfig{EmbeddingProvider: "openai", OpenAIAPIKey: "sk-test"}, "openai:text-embedding-3-small"},
+		{"to another openai model", llm.OpenRouterConfig{EmbeddingProvider: "openai", OpenAIAPIKey: "sk-test", OpenAIEmbeddingModel: "text-embedding-3-large"}, "openai:text-embedding-3-large"},
+		{"openai without a key", llm.OpenRouterConfig{EmbeddingProvider: "openai"}, ""},
+		{"unknown provider", llm.OpenRouterConfig{EmbeddingProvider: "cohere"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected := current
+			applyEmbeddingSettings(&selected, tt.settings)
+			if selected.ActiveMode != current.ActiveMode || selected.APIKey != current.APIKey {
+				t.Fatalf("migration changed the chat settings: mode %q", selected.ActiveMode)
+			}
+			p, err := configuredEmbeddingProvider(selected)
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("selected %s, want an error", p.ModelIdentifier())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := p.ModelIdentifier(); got != tt.want {
+				t.Errorf("selected %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
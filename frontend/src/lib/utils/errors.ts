@@ -0,0 +1,46 @@
+// Provider failures (LLM and embedding calls) reach the frontend as structured
+// objects so the UI can react to the error code; every other backend error is
+// still a plain string.
+export type ProviderErrorCode =
+  | 'auth'
+  | 'rate_limit'
+  | 'model_not_found'
+  | 'context_too_long'
+  | 'content_filtered'
+  | 'network'
+  | 'timeout'
+  | 'empty_response'
+  | 'invalid_response'
+  | 'not_configured'
+  | 'unknown';
+
+export interface ProviderError {
+  code: ProviderErrorCode;
+  provider: string;
+  model?: string;
+  status?: number;
+  message: string;
+}
+
+export function isProviderError(err: unknown): err is ProviderError {
+  return typeof err === 'object' && err !== null && 'code' in err && 'message' in err;
+}
+
+const hints: Partial<Record<ProviderErrorCode, string>> = {
+  auth: 'Check the API key in Settings.',
+  rate_limit: 'The provider is rate limiting or out of quota. Try again later.',
+  model_not_found: 'The selected model is unavailable. Pick another model in Settings.',
+  context_too_long: 'The request is too long for this model. Try a shorter selection.',
+  content_filtered: 'The provider blocked this request.',
+  network: 'Could not reach the provider. Check your connection.',
+  timeout: 'The provider took too long to respond.',
+};
+
+// formatError turns any rejected binding value into a user-facing message.
+export function formatError(err: unknown): string {
+  if (isProviderError(err)) {
+    const hint = hints[err.code];
+    return hint ? `${err.message} (${hint})` : err.message;
+  }
+  return `${err}`;
+}
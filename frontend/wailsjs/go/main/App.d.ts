@@ -1,8 +1,31 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 import {database} from '../models';
-import {llm} from '../models';
+import {embeddings} from '../models';
 import {main} from '../models';
+import {llm} from '../models';
+
+export function AcceptClusterSuggestion(arg1:number,arg2:string,arg3:Array<number>):Promise<void>;
+
+export function AttachImageToEntry(arg1:number,arg2:string,arg3:string):Promise<database.CodexAttachment>;
+
+export function CalibrateSimilarityThreshold():Promise<embeddings.ThresholdCalibration>;
+
+export function CancelEmbeddingMigration(arg1:boolean):Promise<void>;
+
+export function ClearFailedEmbeddingJobs():Promise<number>;
+
+export function ClearSimilarityCalibration():Promise<main.SimilarityThreshold>;
+
+export function ClusterCodexEntries(arg1:Array<string>,arg2:number,arg3:string):Promise<Array<main.ClusterSuggestion>>;
+
+export function CompareEmbeddingMigration(arg1:Array<string>):Promise<Array<main.MigrationComparison>>;
+
+export function CompareExtractionModels(arg1:string,arg2:Array<main.ModelTarget>):Promise<Array<main.ModelRunResult>>;
+
+export function CompareModels(arg1:string,arg2:Array<main.ModelTarget>):Promise<Array<main.ModelRunResult>>;
+
+export function CompleteEmbeddingMigration(arg1:boolean):Promise<void>;
 
 export function CopyLibraryItem(arg1:string,arg2:string):Promise<void>;
 
@@ -16,8 +39,18 @@ export function DeleteChatLog(arg1:string):Promise<void>;
 
 export function DeleteEntry(arg1:number):Promise<void>;
 
+export function DeleteEntryAttachment(arg1:number):Promise<void>;
+
+export function DeleteGoldenQuery(arg1:number):Promise<void>;
+
 export function DeleteLibraryItem(arg1:string):Promise<void>;
 
+export function DeleteRetrievalEvalRun(arg1:number):Promise<void>;
+
+export function DescribeEntryImage(arg1:number,arg2:string):Promise<main.ImageDescriptionResult>;
+
+export function DismissClusterSuggestion(arg1:number):Promise<void>;
+
 export function FetchGeminiModels():Promise<Array<llm.OpenRouterModel>>;
 
 export function FetchOllamaModels():Promise<Array<llm.OpenRouterModel>>;
@@ -26,52 +59,120 @@ export function FetchOpenAIModels():Promise<Array<llm.OpenRouterModel>>;
 
 export function FetchOpenRouterModelsWithKey(arg1:string):Promise<Array<llm.OpenRouterModel>>;
 
+export function FindDuplicateEntries(arg1:number):Promise<Array<main.DuplicateCluster>>;
+
+export function FindStaleEmbeddings():Promise<Array<main.StaleEmbedding>>;
+
 export function GenerateLLMContent(arg1:string,arg2:string):Promise<string>;
 
 export function GenerateMissingEmbeddings():Promise<void>;
 
 export function GenerateOpenRouterContent(arg1:string,arg2:string):Promise<string>;
 
+export function GetAIResponseForDocument(arg1:string,arg2:string,arg3:Array<main.ChatMessage>,arg4:string):Promise<string>;
+
 export function GetAIResponseWithContext(arg1:string,arg2:string):Promise<string>;
 
+export function GetAIResponseWithHistory(arg1:string,arg2:string,arg3:Array<main.ChatMessage>):Promise<string>;
+
+export function GetAIResponseWithSources(arg1:string,arg2:string,arg3:Array<main.ChatMessage>,arg4:string):Promise<main.AIResponse>;
+
 export function GetAllEntries():Promise<Array<database.CodexEntry>>;
 
+export function GetChatSessionMemory(arg1:string):Promise<boolean>;
+
 export function GetCurrentVaultPath():Promise<string>;
 
 export function GetEmbedding(arg1:number):Promise<Array<string>>;
 
+export function GetEmbeddingCoverage():Promise<Array<main.EmbeddingCoverage>>;
+
+export function GetEmbeddingJobProgress():Promise<main.EmbeddingJobProgress>;
+
+export function GetEmbeddingMigrationStatus():Promise<main.EmbeddingMigrationStatus>;
+
+export function GetEmbeddingProviderStatus():Promise<main.EmbeddingProviderStatus>;
+
+export function GetEntryAliases(arg1:number):Promise<Array<string>>;
+
+export function GetEntryTags(arg1:number):Promise<Array<string>>;
+
 export function GetSettings():Promise<llm.OpenRouterConfig>;
 
+export function GetSimilarityThreshold():Promise<main.SimilarityThreshold>;
+
+export function GetVectorIndexStats():Promise<embeddings.IndexStats>;
+
+export function GetVectorStorage():Promise<embeddings.VectorStorage>;
+
 export function ImportStoryTextAndFile(arg1:string,arg2:string):Promise<main.ProcessStoryResult>;
 
 export function ListChatLogs():Promise<Array<string>>;
 
+export function ListClusterSuggestions(arg1:string):Promise<Array<main.ClusterSuggestion>>;
+
+export function ListCodexTags():Promise<Array<database.TagCount>>;
+
+export function ListEntryAttachments(arg1:number):Promise<Array<database.CodexAttachment>>;
+
+export function ListFailedEmbeddingJobs():Promise<Array<database.EmbeddingJob>>;
+
+export function ListGoldenQueries():Promise<Array<database.GoldenQuery>>;
+
 export function ListLibraryFiles():Promise<Array<string>>;
 
 export function ListLibraryHierarchy():Promise<Array<main.LibraryItem>>;
 
+export function ListRetrievalEvalRuns():Promise<Array<main.EvalRun>>;
+
 export function ListTemplates():Promise<Array<string>>;
 
 export function LoadChatLog(arg1:string):Promise<Array<main.ChatMessage>>;
 
+export function MeasureVectorStorage():Promise<embeddings.StorageReport>;
+
+export function MergeEntries(arg1:main.EntryMerge):Promise<database.CodexEntry>;
+
 export function MergeEntryContentDirect(arg1:database.CodexEntry,arg2:string,arg3:string):Promise<string>;
 
 export function MergeEntryContentWithRAG(arg1:database.CodexEntry,arg2:string,arg3:string):Promise<string>;
 
 export function MoveLibraryItem(arg1:string,arg2:string):Promise<void>;
 
+export function PreviewEntryMerge(arg1:number,arg2:Array<number>,arg3:string):Promise<main.EntryMerge>;
+
 export function ProcessAndSaveTextAsEntries(arg1:string):Promise<number>;
 
 export function ProcessStory(arg1:string):Promise<main.ProcessStoryResult>;
 
+export function PurgeUnusedVectorVersions():Promise<Array<string>>;
+
+export function PurgeVectorVersion(arg1:string):Promise<number>;
+
+export function ReadEntryAttachment(arg1:number):Promise<string>;
+
 export function ReadLibraryFile(arg1:string):Promise<string>;
 
 export function ReadLibraryFileWithPath(arg1:string):Promise<string>;
 
+export function ReembedStaleEntries(arg1:boolean):Promise<number>;
+
+export function ReindexChatMemory():Promise<main.IndexSyncResult>;
+
+export function ReindexLibrary():Promise<main.IndexSyncResult>;
+
+export function RelatedEntries(arg1:number):Promise<Array<main.RelatedEntry>>;
+
+export function RetryFailedEmbeddingJobs():Promise<number>;
+
+export function RunRetrievalEval(arg1:number):Promise<main.EvalRun>;
+
 export function SaveAPIKeyOnly(arg1:string):Promise<void>;
 
 export function SaveChatLog(arg1:string,arg2:Array<main.ChatMessage>):Promise<void>;
 
+export function SaveGoldenQuery(arg1:database.GoldenQuery):Promise<database.GoldenQuery>;
+
 export function SaveLibraryFile(arg1:string,arg2:string):Promise<void>;
 
 export function SaveLibraryFileWithPath(arg1:string,arg2:string):Promise<void>;
@@ -80,8 +181,22 @@ export function SaveSettings(arg1:llm.OpenRouterConfig):Promise<void>;
 
 export function SaveTemplate(arg1:string,arg2:string):Promise<void>;
 
+export function SearchCodex(arg1:string,arg2:Array<string>,arg3:number,arg4:string):Promise<Array<main.CodexSearchResult>>;
+
+export function SearchCodexWithFilters(arg1:string,arg2:main.CodexSearchFilter,arg3:number,arg4:string):Promise<Array<main.CodexSearchResult>>;
+
 export function SelectVaultFolder():Promise<string>;
 
+export function SetChatSessionMemory(arg1:string,arg2:boolean):Promise<void>;
+
+export function SetEntryAliases(arg1:number,arg2:Array<string>):Promise<void>;
+
+export function SetEntryTags(arg1:number,arg2:Array<string>):Promise<void>;
+
+export function SetVectorStorage(arg1:embeddings.VectorStorage):Promise<void>;
+
+export function StartEmbeddingMigration(arg1:llm.OpenRouterConfig):Promise<main.EmbeddingMigrationStatus>;
+
 export function SwitchVault(arg1:string):Promise<void>;
 
 export function UpdateEntry(arg1:database.CodexEntry):Promise<void>;
@@ -2,6 +2,50 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
+export function AcceptClusterSuggestion(arg1, arg2, arg3) {
+  return window['go']['main']['App']['AcceptClusterSuggestion'](arg1, arg2, arg3);
+}
+
+export function AttachImageToEntry(arg1, arg2, arg3) {
+  return window['go']['main']['App']['AttachImageToEntry'](arg1, arg2, arg3);
+}
+
+export function CalibrateSimilarityThreshold() {
+  return window['go']['main']['App']['CalibrateSimilarityThreshold']();
+}
+
+export function CancelEmbeddingMigration(arg1) {
+  return window['go']['main']['App']['CancelEmbeddingMigration'](arg1);
+}
+
+export function ClearFailedEmbeddingJobs() {
+  return window['go']['main']['App']['ClearFailedEmbeddingJobs']();
+}
+
+export function ClearSimilarityCalibration() {
+  return window['go']['main']['App']['ClearSimilarityCalibration']();
+}
+
+export function ClusterCodexEntries(arg1, arg2, arg3) {
+  return window['go']['main']['App']['ClusterCodexEntries'](arg1, arg2, arg3);
+}
+
+export function CompareEmbeddingMigration(arg1) {
+  return window['go']['main']['App']['CompareEmbeddingMigration'](arg1);
+}
+
+export function CompareExtractionModels(arg1, arg2) {
+  return window['go']['main']['App']['CompareExtractionModels'](arg1, arg2);
+}
+
+export function CompareModels(arg1, arg2) {
+  return window['go']['main']['App']['CompareModels'](arg1, arg2);
+}
+
+export function CompleteEmbeddingMigration(arg1) {
+  return window['go']['main']['App']['CompleteEmbeddingMigration'](arg1);
+}
+
 export function CopyLibraryItem(arg1, arg2) {
   return window['go']['main']['App']['CopyLibraryItem'](arg1, arg2);
 }
@@ -26,10 +70,30 @@ export function DeleteEntry(arg1) {
   return window['go']['main']['App']['DeleteEntry'](arg1);
 }
 
+export function DeleteEntryAttachment(arg1) {
+  return window['go']['main']['App']['DeleteEntryAttachment'](arg1);
+}
+
+export function DeleteGoldenQuery(arg1) {
+  return window['go']['main']['App']['DeleteGoldenQuery'](arg1);
+}
+
 export function DeleteLibraryItem(arg1) {
   return window['go']['main']['App']['DeleteLibraryItem'](arg1);
 }
 
+export function DeleteRetrievalEvalRun(arg1) {
+  return window['go']['main']['App']['DeleteRetrievalEvalRun'](arg1);
+}
+
+export function DescribeEntryImage(arg1, arg2) {
+  return window['go']['main']['App']['DescribeEntryImage'](arg1, arg2);
+}
+
+export function DismissClusterSuggestion(arg1) {
+  return window['go']['main']['App']['DismissClusterSuggestion'](arg1);
+}
+
 export function FetchGeminiModels() {
   return window['go']['main']['App']['FetchGeminiModels']();
 }
@@ -46,6 +110,14 @@ export function FetchOpenRouterModelsWithKey(arg1) {
   return window['go']['main']['App']['FetchOpenRouterModelsWithKey'](arg1);
 }
 
+export function FindDuplicateEntries(arg1) {
+  return window['go']['main']['App']['FindDuplicateEntries'](arg1);
+}
+
+export function FindStaleEmbeddings() {
+  return window['go']['main']['App']['FindStaleEmbeddings']();
+}
+
 export function GenerateLLMContent(arg1, arg2) {
   return window['go']['main']['App']['GenerateLLMContent'](arg1, arg2);
 }
@@ -58,14 +130,30 @@ export function GenerateOpenRouterContent(arg1, arg2) {
   return window['go']['main']['App']['GenerateOpenRouterContent'](arg1, arg2);
 }
 
+export function GetAIResponseForDocument(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['GetAIResponseForDocument'](arg1, arg2, arg3, arg4);
+}
+
 export function GetAIResponseWithContext(arg1, arg2) {
   return window['go']['main']['App']['GetAIResponseWithContext'](arg1, arg2);
 }
 
+export function GetAIResponseWithHistory(arg1, arg2, arg3) {
+  return window['go']['main']['App']['GetAIResponseWithHistory'](arg1, arg2, arg3);
+}
+
+export function GetAIResponseWithSources(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['GetAIResponseWithSources'](arg1, arg2, arg3, arg4);
+}
+
 export function GetAllEntries() {
   return window['go']['main']['App']['GetAllEntries']();
 }
 
+export function GetChatSessionMemory(arg1) {
+  return window['go']['main']['App']['GetChatSessionMemory'](arg1);
+}
+
 export function GetCurrentVaultPath() {
   return window['go']['main']['App']['GetCurrentVaultPath']();
 }
@@ -74,10 +162,46 @@ export function GetEmbedding(arg1) {
   return window['go']['main']['App']['GetEmbedding'](arg1);
 }
 
+export function GetEmbeddingCoverage() {
+  return window['go']['main']['App']['GetEmbeddingCoverage']();
+}
+
+export function GetEmbeddingJobProgress() {
+  return window['go']['main']['App']['GetEmbeddingJobProgress']();
+}
+
+export function GetEmbeddingMigrationStatus() {
+  return window['go']['main']['App']['GetEmbeddingMigrationStatus']();
+}
+
+export function GetEmbeddingProviderStatus() {
+  return window['go']['main']['App']['GetEmbeddingProviderStatus']();
+}
+
+export function GetEntryAliases(arg1) {
+  return window['go']['main']['App']['GetEntryAliases'](arg1);
+}
+
+export function GetEntryTags(arg1) {
+  return window['go']['main']['App']['GetEntryTags'](arg1);
+}
+
 export function GetSettings() {
   return window['go']['main']['App']['GetSettings']();
 }
 
+export function GetSimilarityThreshold() {
+  return window['go']['main']['App']['GetSimilarityThreshold']();
+}
+
+export function GetVectorIndexStats() {
+  return window['go']['main']['App']['GetVectorIndexStats']();
+}
+
+export function GetVectorStorage() {
+  return window['go']['main']['App']['GetVectorStorage']();
+}
+
 export function ImportStoryTextAndFile(arg1, arg2) {
   return window['go']['main']['App']['ImportStoryTextAndFile'](arg1, arg2);
 }
@@ -86,6 +210,26 @@ export function ListChatLogs() {
   return window['go']['main']['App']['ListChatLogs']();
 }
 
+export function ListClusterSuggestions(arg1) {
+  return window['go']['main']['App']['ListClusterSuggestions'](arg1);
+}
+
+export function ListCodexTags() {
+  return window['go']['main']['App']['ListCodexTags']();
+}
+
+export function ListEntryAttachments(arg1) {
+  return window['go']['main']['App']['ListEntryAttachments'](arg1);
+}
+
+export function ListFailedEmbeddingJobs() {
+  return window['go']['main']['App']['ListFailedEmbeddingJobs']();
+}
+
+export function ListGoldenQueries() {
+  return window['go']['main']['App']['ListGoldenQueries']();
+}
+
 export function ListLibraryFiles() {
   return window['go']['main']['App']['ListLibraryFiles']();
 }
@@ -94,6 +238,10 @@ export function ListLibraryHierarchy() {
   return window['go']['main']['App']['ListLibraryHierarchy']();
 }
 
+export function ListRetrievalEvalRuns() {
+  return window['go']['main']['App']['ListRetrievalEvalRuns']();
+}
+
 export function ListTemplates() {
   return window['go']['main']['App']['ListTemplates']();
 }
@@ -102,6 +250,14 @@ export function LoadChatLog(arg1) {
   return window['go']['main']['App']['LoadChatLog'](arg1);
 }
 
+export function MeasureVectorStorage() {
+  return window['go']['main']['App']['MeasureVectorStorage']();
+}
+
+export function MergeEntries(arg1) {
+  return window['go']['main']['App']['MergeEntries'](arg1);
+}
+
 export function MergeEntryContentDirect(arg1, arg2, arg3) {
   return window['go']['main']['App']['MergeEntryContentDirect'](arg1, arg2, arg3);
 }
@@ -114,6 +270,10 @@ export function MoveLibraryItem(arg1, arg2) {
   return window['go']['main']['App']['MoveLibraryItem'](arg1, arg2);
 }
 
+export function PreviewEntryMerge(arg1, arg2, arg3) {
+  return window['go']['main']['App']['PreviewEntryMerge'](arg1, arg2, arg3);
+}
+
 export function ProcessAndSaveTextAsEntries(arg1) {
   return window['go']['main']['App']['ProcessAndSaveTextAsEntries'](arg1);
 }
@@ -122,6 +282,18 @@ export function ProcessStory(arg1) {
   return window['go']['main']['App']['ProcessStory'](arg1);
 }
 
+export function PurgeUnusedVectorVersions() {
+  return window['go']['main']['App']['PurgeUnusedVectorVersions']();
+}
+
+export function PurgeVectorVersion(arg1) {
+  return window['go']['main']['App']['PurgeVectorVersion'](arg1);
+}
+
+export function ReadEntryAttachment(arg1) {
+  return window['go']['main']['App']['ReadEntryAttachment'](arg1);
+}
+
 export function ReadLibraryFile(arg1) {
   return window['go']['main']['App']['ReadLibraryFile'](arg1);
 }
@@ -130,6 +302,30 @@ export function ReadLibraryFileWithPath(arg1) {
   return window['go']['main']['App']['ReadLibraryFileWithPath'](arg1);
 }
 
+export function ReembedStaleEntries(arg1) {
+  return window['go']['main']['App']['ReembedStaleEntries'](arg1);
+}
+
+export function ReindexChatMemory() {
+  return window['go']['main']['App']['ReindexChatMemory']();
+}
+
+export function ReindexLibrary() {
+  return window['go']['main']['App']['ReindexLibrary']();
+}
+
+export function RelatedEntries(arg1) {
+  return window['go']['main']['App']['RelatedEntries'](arg1);
+}
+
+export function RetryFailedEmbeddingJobs() {
+  return window['go']['main']['App']['RetryFailedEmbeddingJobs']();
+}
+
+export function RunRetrievalEval(arg1) {
+  return window['go']['main']['App']['RunRetrievalEval'](arg1);
+}
+
 export function SaveAPIKeyOnly(arg1) {
   return window['go']['main']['App']['SaveAPIKeyOnly'](arg1);
 }
@@ -138,6 +334,10 @@ export function SaveChatLog(arg1, arg2) {
   return window['go']['main']['App']['SaveChatLog'](arg1, arg2);
 }
 
+export function SaveGoldenQuery(arg1) {
+  return window['go']['main']['App']['SaveGoldenQuery'](arg1);
+}
+
 export function SaveLibraryFile(arg1, arg2) {
   return window['go']['main']['App']['SaveLibraryFile'](arg1, arg2);
 }
@@ -154,10 +354,38 @@ export function SaveTemplate(arg1, arg2) {
   return window['go']['main']['App']['SaveTemplate'](arg1, arg2);
 }
 
+export function SearchCodex(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['SearchCodex'](arg1, arg2, arg3, arg4);
+}
+
+export function SearchCodexWithFilters(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['SearchCodexWithFilters'](arg1, arg2, arg3, arg4);
+}
+
 export function SelectVaultFolder() {
   return window['go']['main']['App']['SelectVaultFolder']();
 }
 
+export function SetChatSessionMemory(arg1, arg2) {
+  return window['go']['main']['App']['SetChatSessionMemory'](arg1, arg2);
+}
+
+export function SetEntryAliases(arg1, arg2) {
+  return window['go']['main']['App']['SetEntryAliases'](arg1, arg2);
+}
+
+export function SetEntryTags(arg1, arg2) {
+  return window['go']['main']['App']['SetEntryTags'](arg1, arg2);
+}
+
+export function SetVectorStorage(arg1) {
+  return window['go']['main']['App']['SetVectorStorage'](arg1);
+}
+
+export function StartEmbeddingMigration(arg1) {
+  return window['go']['main']['App']['StartEmbeddingMigration'](arg1);
+}
+
 export function SwitchVault(arg1) {
   return window['go']['main']['App']['SwitchVault'](arg1);
 }
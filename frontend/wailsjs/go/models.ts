@@ -1,5 +1,110 @@
+export namespace context {
+	
+	export class ContextSource {
+	    source: string;
+	    entryId?: number;
+	    name: string;
+	    type?: string;
+	    filePath?: string;
+	    startLine?: number;
+	    endLine?: number;
+	    session?: string;
+	    messageIndex?: number;
+	    excerpt?: string;
+	    score: number;
+	    fusedScore: number;
+	    rerankScore?: number;
+	    pinned?: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new ContextSource(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.source = source["source"];
+	        this.entryId = source["entryId"];
+	        this.name = source["name"];
+	        this.type = source["type"];
+	        this.filePath = source["filePath"];
+	        this.startLine = source["startLine"];
+	        this.endLine = source["endLine"];
+	        this.session = source["session"];
+	        this.messageIndex = source["messageIndex"];
+	        this.excerpt = source["excerpt"];
+	        this.score = source["score"];
+	        this.fusedScore = source["fusedScore"];
+	        this.rerankScore = source["rerankScore"];
+	        this.pinned = source["pinned"];
+	    }
+	}
+	export class Settings {
+	    embeddingModel: string;
+	    maxEntries: number;
+	    similarityThreshold: number;
+	    vectorWeight: number;
+	    keywordWeight: number;
+	    injectPassages: boolean;
+	    includeManuscripts: boolean;
+	    includeChatMemory: boolean;
+	    rewriteQueries: boolean;
+	    hyde: boolean;
+	    maxSubQueries: number;
+	    reranker: string;
+	    rerankCandidates: number;
+	    diversity: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Settings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.embeddingModel = source["embeddingModel"];
+	        this.maxEntries = source["maxEntries"];
+	        this.similarityThreshold = source["similarityThreshold"];
+	        this.vectorWeight = source["vectorWeight"];
+	        this.keywordWeight = source["keywordWeight"];
+	        this.injectPassages = source["injectPassages"];
+	        this.includeManuscripts = source["includeManuscripts"];
+	        this.includeChatMemory = source["includeChatMemory"];
+	        this.rewriteQueries = source["rewriteQueries"];
+	        this.hyde = source["hyde"];
+	        this.maxSubQueries = source["maxSubQueries"];
+	        this.reranker = source["reranker"];
+	        this.rerankCandidates = source["rerankCandidates"];
+	        this.diversity = source["diversity"];
+	    }
+	}
+
+}
+
 export namespace database {
 	
+	export class CodexAttachment {
+	    id: number;
+	    codexEntryId: number;
+	    filename: string;
+	    mimeType: string;
+	    description: string;
+	    createdAt: string;
+	    updatedAt: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new CodexAttachment(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.codexEntryId = source["codexEntryId"];
+	        this.filename = source["filename"];
+	        this.mimeType = source["mimeType"];
+	        this.description = source["description"];
+	        this.createdAt = source["createdAt"];
+	        this.updatedAt = source["updatedAt"];
+	    }
+	}
 	export class CodexEntry {
 	    id: number;
 	    name: string;
@@ -22,6 +127,195 @@ export namespace database {
 	        this.updatedAt = source["updatedAt"];
 	    }
 	}
+	export class EmbeddingJob {
+	    id: number;
+	    kind: string;
+	    target: string;
+	    status: string;
+	    attempts: number;
+	    lastError: string;
+	    nextAttemptAt: string;
+	    createdAt: string;
+	    updatedAt: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new EmbeddingJob(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.kind = source["kind"];
+	        this.target = source["target"];
+	        this.status = source["status"];
+	        this.attempts = source["attempts"];
+	        this.lastError = source["lastError"];
+	        this.nextAttemptAt = source["nextAttemptAt"];
+	        this.createdAt = source["createdAt"];
+	        this.updatedAt = source["updatedAt"];
+	    }
+	}
+	export class GoldenQuery {
+	    id: number;
+	    query: string;
+	    expected: string[];
+	    createdAt: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new GoldenQuery(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.query = source["query"];
+	        this.expected = source["expected"];
+	        this.createdAt = source["createdAt"];
+	    }
+	}
+	export class TagCount {
+	    tag: string;
+	    entries: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new TagCount(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.tag = source["tag"];
+	        this.entries = source["entries"];
+	    }
+	}
+
+}
+
+export namespace embeddings {
+	
+	export class IndexStats {
+	    ready: boolean;
+	    entries: number;
+	    passages: number;
+	    quantization: string;
+	    memoryBytes: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new IndexStats(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.ready = source["ready"];
+	        this.entries = source["entries"];
+	        this.passages = source["passages"];
+	        this.quantization = source["quantization"];
+	        this.memoryBytes = source["memoryBytes"];
+	    }
+	}
+	export class StorageOption {
+	    quantization: string;
+	    dimensions: number;
+	    storedBytes: number;
+	    indexBytes: number;
+	    savedPercent: number;
+	    recallAtK: number;
+	    rescoredRecallAtK: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new StorageOption(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.quantization = source["quantization"];
+	        this.dimensions = source["dimensions"];
+	        this.storedBytes = source["storedBytes"];
+	        this.indexBytes = source["indexBytes"];
+	        this.savedPercent = source["savedPercent"];
+	        this.recallAtK = source["recallAtK"];
+	        this.rescoredRecallAtK = source["rescoredRecallAtK"];
+	    }
+	}
+	export class StorageReport {
+	    vectorVersion: string;
+	    vectors: number;
+	    dimensions: number;
+	    queries: number;
+	    k: number;
+	    options: StorageOption[];
+	
+	    static createFrom(source: any = {}) {
+	        return new StorageReport(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.vectorVersion = source["vectorVersion"];
+	        this.vectors = source["vectors"];
+	        this.dimensions = source["dimensions"];
+	        this.queries = source["queries"];
+	        this.k = source["k"];
+	        this.options = this.convertValues(source["options"], StorageOption);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ThresholdCalibration {
+	    vectorVersion: string;
+	    entries: number;
+	    pairs: number;
+	    mean: number;
+	    stdDev: number;
+	    p95: number;
+	    threshold: number;
+	    default: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new ThresholdCalibration(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.vectorVersion = source["vectorVersion"];
+	        this.entries = source["entries"];
+	        this.pairs = source["pairs"];
+	        this.mean = source["mean"];
+	        this.stdDev = source["stdDev"];
+	        this.p95 = source["p95"];
+	        this.threshold = source["threshold"];
+	        this.default = source["default"];
+	    }
+	}
+	export class VectorStorage {
+	    quantization: string;
+	    dimensions: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new VectorStorage(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.quantization = source["quantization"];
+	        this.dimensions = source["dimensions"];
+	    }
+	}
 
 }
 
@@ -35,6 +329,30 @@ export namespace llm {
 	    active_mode?: string;
 	    openai_api_key?: string;
 	    local_embedding_model_name?: string;
+	    vision_model_id?: string;
+	    embedding_provider?: string;
+	    gemini_embedding_model?: string;
+	    gemini_embedding_dimensions?: number;
+	    openai_embedding_model?: string;
+	    expose_reasoning?: boolean;
+	    retrieval_vector_weight?: number;
+	    retrieval_keyword_weight?: number;
+	    retrieval_diversity?: number;
+	    chat_memory_enabled?: boolean;
+	    query_rewrite_enabled?: boolean;
+	    query_sub_queries?: number;
+	    query_hyde_enabled?: boolean;
+	    query_rewrite_model_id?: string;
+	    rerank_mode?: string;
+	    rerank_model_id?: string;
+	    rerank_endpoint?: string;
+	    rerank_api_key?: string;
+	    rerank_candidates?: number;
+	    similarity_thresholds?: Record<string, number>;
+	    http_proxy_url?: string;
+	    ca_cert_path?: string;
+	    provider_timeouts?: Record<string, number>;
+	    max_idle_conns_per_host?: number;
 	
 	    static createFrom(source: any = {}) {
 	        return new OpenRouterConfig(source);
@@ -49,6 +367,30 @@ export namespace llm {
 	        this.active_mode = source["active_mode"];
 	        this.openai_api_key = source["openai_api_key"];
 	        this.local_embedding_model_name = source["local_embedding_model_name"];
+	        this.vision_model_id = source["vision_model_id"];
+	        this.embedding_provider = source["embedding_provider"];
+	        this.gemini_embedding_model = source["gemini_embedding_model"];
+	        this.gemini_embedding_dimensions = source["gemini_embedding_dimensions"];
+	        this.openai_embedding_model = source["openai_embedding_model"];
+	        this.expose_reasoning = source["expose_reasoning"];
+	        this.retrieval_vector_weight = source["retrieval_vector_weight"];
+	        this.retrieval_keyword_weight = source["retrieval_keyword_weight"];
+	        this.retrieval_diversity = source["retrieval_diversity"];
+	        this.chat_memory_enabled = source["chat_memory_enabled"];
+	        this.query_rewrite_enabled = source["query_rewrite_enabled"];
+	        this.query_sub_queries = source["query_sub_queries"];
+	        this.query_hyde_enabled = source["query_hyde_enabled"];
+	        this.query_rewrite_model_id = source["query_rewrite_model_id"];
+	        this.rerank_mode = source["rerank_mode"];
+	        this.rerank_model_id = source["rerank_model_id"];
+	        this.rerank_endpoint = source["rerank_endpoint"];
+	        this.rerank_api_key = source["rerank_api_key"];
+	        this.rerank_candidates = source["rerank_candidates"];
+	        this.similarity_thresholds = source["similarity_thresholds"];
+	        this.http_proxy_url = source["http_proxy_url"];
+	        this.ca_cert_path = source["ca_cert_path"];
+	        this.provider_timeouts = source["provider_timeouts"];
+	        this.max_idle_conns_per_host = source["max_idle_conns_per_host"];
 	    }
 	}
 	export class OpenRouterModel {
@@ -65,11 +407,63 @@ export namespace llm {
 	        this.name = source["name"];
 	    }
 	}
+	export class Usage {
+	    promptTokens: number;
+	    completionTokens: number;
+	    totalTokens: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Usage(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.promptTokens = source["promptTokens"];
+	        this.completionTokens = source["completionTokens"];
+	        this.totalTokens = source["totalTokens"];
+	    }
+	}
 
 }
 
 export namespace main {
 	
+	export class AIResponse {
+	    answer: string;
+	    model: string;
+	    usage: llm.Usage;
+	    sources: context.ContextSource[];
+	
+	    static createFrom(source: any = {}) {
+	        return new AIResponse(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.answer = source["answer"];
+	        this.model = source["model"];
+	        this.usage = this.convertValues(source["usage"], llm.Usage);
+	        this.sources = this.convertValues(source["sources"], context.ContextSource);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
 	export class ChatMessage {
 	    sender: string;
 	    text: string;
@@ -84,27 +478,28 @@ export namespace main {
 	        this.text = source["text"];
 	    }
 	}
-	export class LibraryItem {
+	export class ClusterSuggestion {
+	    id: number;
 	    name: string;
-	    path: string;
-	    isDir: boolean;
-	    size: number;
-	    // Go type: time
-	    modTime: any;
-	    children: LibraryItem[];
+	    description: string;
+	    status: string;
+	    cohesion: number;
+	    createdAt: string;
+	    entries: database.CodexEntry[];
 	
 	    static createFrom(source: any = {}) {
-	        return new LibraryItem(source);
+	        return new ClusterSuggestion(source);
 	    }
 	
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
 	        this.name = source["name"];
-	        this.path = source["path"];
-	        this.isDir = source["isDir"];
-	        this.size = source["size"];
-	        this.modTime = this.convertValues(source["modTime"], null);
-	        this.children = this.convertValues(source["children"], LibraryItem);
+	        this.description = source["description"];
+	        this.status = source["status"];
+	        this.cohesion = source["cohesion"];
+	        this.createdAt = source["createdAt"];
+	        this.entries = this.convertValues(source["entries"], database.CodexEntry);
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -125,20 +520,102 @@ export namespace main {
 		    return a;
 		}
 	}
-	export class ProcessStoryResult {
-	    newEntries: database.CodexEntry[];
-	    updatedEntries: database.CodexEntry[];
-	    existingEntries: database.CodexEntry[];
+	export class CodexSearchFilter {
+	    types: string[];
+	    tags: string[];
+	    createdAfter: string;
+	    createdBefore: string;
+	    updatedAfter: string;
+	    updatedBefore: string;
 	
 	    static createFrom(source: any = {}) {
-	        return new ProcessStoryResult(source);
+	        return new CodexSearchFilter(source);
 	    }
 	
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
-	        this.newEntries = this.convertValues(source["newEntries"], database.CodexEntry);
-	        this.updatedEntries = this.convertValues(source["updatedEntries"], database.CodexEntry);
-	        this.existingEntries = this.convertValues(source["existingEntries"], database.CodexEntry);
+	        this.types = source["types"];
+	        this.tags = source["tags"];
+	        this.createdAfter = source["createdAfter"];
+	        this.createdBefore = source["createdBefore"];
+	        this.updatedAfter = source["updatedAfter"];
+	        this.updatedBefore = source["updatedBefore"];
+	    }
+	}
+	export class CodexSearchResult {
+	    entry: database.CodexEntry;
+	    tags: string[];
+	    score: number;
+	    vectorScore: number;
+	    keywordScore: number;
+	    snippet: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new CodexSearchResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.entry = this.convertValues(source["entry"], database.CodexEntry);
+	        this.tags = source["tags"];
+	        this.score = source["score"];
+	        this.vectorScore = source["vectorScore"];
+	        this.keywordScore = source["keywordScore"];
+	        this.snippet = source["snippet"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class DuplicatePair {
+	    a: number;
+	    b: number;
+	    score: number;
+	    sharedName: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new DuplicatePair(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.a = source["a"];
+	        this.b = source["b"];
+	        this.score = source["score"];
+	        this.sharedName = source["sharedName"];
+	    }
+	}
+	export class DuplicateCluster {
+	    entries: database.CodexEntry[];
+	    pairs: DuplicatePair[];
+	    score: number;
+	    suggested: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new DuplicateCluster(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.entries = this.convertValues(source["entries"], database.CodexEntry);
+	        this.pairs = this.convertValues(source["pairs"], DuplicatePair);
+	        this.score = source["score"];
+	        this.suggested = source["suggested"];
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -159,6 +636,535 @@ export namespace main {
 		    return a;
 		}
 	}
+	
+	export class EmbeddingCoverage {
+	    vectorVersion: string;
+	    active: boolean;
+	    totalEntries: number;
+	    embedded: number;
+	    missing: number;
+	    stale: number;
+	    unverified: number;
+	    passages: number;
+	    manuscriptPassages: number;
+	    chatMemories: number;
+	    bytes: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new EmbeddingCoverage(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.vectorVersion = source["vectorVersion"];
+	        this.active = source["active"];
+	        this.totalEntries = source["totalEntries"];
+	        this.embedded = source["embedded"];
+	        this.missing = source["missing"];
+	        this.stale = source["stale"];
+	        this.unverified = source["unverified"];
+	        this.passages = source["passages"];
+	        this.manuscriptPassages = source["manuscriptPassages"];
+	        this.chatMemories = source["chatMemories"];
+	        this.bytes = source["bytes"];
+	    }
+	}
+	export class EmbeddingJobProgress {
+	    queued: number;
+	    running: number;
+	    done: number;
+	    failed: number;
+	    total: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new EmbeddingJobProgress(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.queued = source["queued"];
+	        this.running = source["running"];
+	        this.done = source["done"];
+	        this.failed = source["failed"];
+	        this.total = source["total"];
+	    }
+	}
+	export class IndexSyncResult {
+	    files: number;
+	    reindexed: number;
+	    removed: number;
+	    failed: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new IndexSyncResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.files = source["files"];
+	        this.reindexed = source["reindexed"];
+	        this.removed = source["removed"];
+	        this.failed = source["failed"];
+	    }
+	}
+	export class EmbeddingMigrationStatus {
+	    state: string;
+	    fromVersion: string;
+	    toVersion: string;
+	    entries: number;
+	    embedded: number;
+	    failed: number;
+	    manuscripts: IndexSyncResult;
+	    chatMemory: IndexSyncResult;
+	    error?: string;
+	    // Go type: time
+	    startedAt: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new EmbeddingMigrationStatus(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.state = source["state"];
+	        this.fromVersion = source["fromVersion"];
+	        this.toVersion = source["toVersion"];
+	        this.entries = source["entries"];
+	        this.embedded = source["embedded"];
+	        this.failed = source["failed"];
+	        this.manuscripts = this.convertValues(source["manuscripts"], IndexSyncResult);
+	        this.chatMemory = this.convertValues(source["chatMemory"], IndexSyncResult);
+	        this.error = source["error"];
+	        this.startedAt = this.convertValues(source["startedAt"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class EmbeddingProviderStatus {
+	    modelIdentifier: string;
+	    fallback: boolean;
+	    reason: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new EmbeddingProviderStatus(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.modelIdentifier = source["modelIdentifier"];
+	        this.fallback = source["fallback"];
+	        this.reason = source["reason"];
+	    }
+	}
+	export class EntryMerge {
+	    survivorId: number;
+	    mergedIds: number[];
+	    name: string;
+	    type: string;
+	    content: string;
+	    aliases: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new EntryMerge(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.survivorId = source["survivorId"];
+	        this.mergedIds = source["mergedIds"];
+	        this.name = source["name"];
+	        this.type = source["type"];
+	        this.content = source["content"];
+	        this.aliases = source["aliases"];
+	    }
+	}
+	export class EvalQueryResult {
+	    queryId: number;
+	    query: string;
+	    expected: string[];
+	    retrieved: string[];
+	    missing: string[];
+	    recall: number;
+	    precision: number;
+	    reciprocalRank: number;
+	    error?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new EvalQueryResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.queryId = source["queryId"];
+	        this.query = source["query"];
+	        this.expected = source["expected"];
+	        this.retrieved = source["retrieved"];
+	        this.missing = source["missing"];
+	        this.recall = source["recall"];
+	        this.precision = source["precision"];
+	        this.reciprocalRank = source["reciprocalRank"];
+	        this.error = source["error"];
+	    }
+	}
+	export class EvalRun {
+	    id: number;
+	    createdAt: string;
+	    k: number;
+	    requestedK?: number;
+	    recall: number;
+	    precision: number;
+	    mrr: number;
+	    queries: number;
+	    settings: context.Settings;
+	    results: EvalQueryResult[];
+	    regressions: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new EvalRun(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.createdAt = source["createdAt"];
+	        this.k = source["k"];
+	        this.requestedK = source["requestedK"];
+	        this.recall = source["recall"];
+	        this.precision = source["precision"];
+	        this.mrr = source["mrr"];
+	        this.queries = source["queries"];
+	        this.settings = this.convertValues(source["settings"], context.Settings);
+	        this.results = this.convertValues(source["results"], EvalQueryResult);
+	        this.regressions = source["regressions"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ImageDescriptionResult {
+	    attachment: database.CodexAttachment;
+	    description: string;
+	    proposedContent: string;
+	    model: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ImageDescriptionResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.attachment = this.convertValues(source["attachment"], database.CodexAttachment);
+	        this.description = source["description"];
+	        this.proposedContent = source["proposedContent"];
+	        this.model = source["model"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class LibraryItem {
+	    name: string;
+	    path: string;
+	    isDir: boolean;
+	    size: number;
+	    // Go type: time
+	    modTime: any;
+	    children: LibraryItem[];
+	
+	    static createFrom(source: any = {}) {
+	        return new LibraryItem(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.path = source["path"];
+	        this.isDir = source["isDir"];
+	        this.size = source["size"];
+	        this.modTime = this.convertValues(source["modTime"], null);
+	        this.children = this.convertValues(source["children"], LibraryItem);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class MigrationHit {
+	    entryId: number;
+	    name: string;
+	    score: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new MigrationHit(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.entryId = source["entryId"];
+	        this.name = source["name"];
+	        this.score = source["score"];
+	    }
+	}
+	export class MigrationComparison {
+	    query: string;
+	    current: MigrationHit[];
+	    candidate: MigrationHit[];
+	    overlap: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new MigrationComparison(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.query = source["query"];
+	        this.current = this.convertValues(source["current"], MigrationHit);
+	        this.candidate = this.convertValues(source["candidate"], MigrationHit);
+	        this.overlap = source["overlap"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class ModelRunResult {
+	    provider: string;
+	    model: string;
+	    output: string;
+	    reasoning?: string;
+	    latencyMs: number;
+	    usage: llm.Usage;
+	    error?: string;
+	    errorCode?: string;
+	    entityCount?: number;
+	    entityTypeCounts?: Record<string, number>;
+	    parseError?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ModelRunResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.provider = source["provider"];
+	        this.model = source["model"];
+	        this.output = source["output"];
+	        this.reasoning = source["reasoning"];
+	        this.latencyMs = source["latencyMs"];
+	        this.usage = this.convertValues(source["usage"], llm.Usage);
+	        this.error = source["error"];
+	        this.errorCode = source["errorCode"];
+	        this.entityCount = source["entityCount"];
+	        this.entityTypeCounts = source["entityTypeCounts"];
+	        this.parseError = source["parseError"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ModelTarget {
+	    provider: string;
+	    model: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ModelTarget(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.provider = source["provider"];
+	        this.model = source["model"];
+	    }
+	}
+	export class ProcessStoryResult {
+	    newEntries: database.CodexEntry[];
+	    updatedEntries: database.CodexEntry[];
+	    existingEntries: database.CodexEntry[];
+	
+	    static createFrom(source: any = {}) {
+	        return new ProcessStoryResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.newEntries = this.convertValues(source["newEntries"], database.CodexEntry);
+	        this.updatedEntries = this.convertValues(source["updatedEntries"], database.CodexEntry);
+	        this.existingEntries = this.convertValues(source["existingEntries"], database.CodexEntry);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class RelatedEntry {
+	    entry: database.CodexEntry;
+	    score: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new RelatedEntry(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.entry = this.convertValues(source["entry"], database.CodexEntry);
+	        this.score = source["score"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class SimilarityThreshold {
+	    vectorVersion: string;
+	    threshold: number;
+	    source: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new SimilarityThreshold(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.vectorVersion = source["vectorVersion"];
+	        this.threshold = source["threshold"];
+	        this.source = source["source"];
+	    }
+	}
+	export class StaleEmbedding {
+	    entryId: number;
+	    name: string;
+	    reason: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new StaleEmbedding(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.entryId = source["entryId"];
+	        this.name = source["name"];
+	        this.reason = source["reason"];
+	    }
+	}
 
 }
 
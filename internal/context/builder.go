@@ -2,30 +2,92 @@
 package context
 
 import (
+	"Llore/internal/database"
 	"Llore/internal/embeddings" // Use the embeddings package
+	"database/sql"
 	"fmt"
 	"log" // Added for logging
 	"sort"
 	"strings"
 )
 
-// ContextBuilder builds context for LLM prompts using embeddings
+// reciprocalRankK damps the contribution of top ranks in reciprocal-rank fusion. 60 is
+// the value from the original RRF paper and works well without tuning.
+const reciprocalRankK = 60
+
+// ContextBuilder builds context for LLM prompts using embeddings and keyword search
 type ContextBuilder struct {
 	embeddingService    *embeddings.EmbeddingService
+	db                  *sql.DB // Used for FTS5 keyword search; nil disables the lexical side
 	maxEntries          int     // Max number of entries to retrieve
 	similarityThreshold float32 // Minimum similarity score to include
+	injectPassages      bool    // For long entries, include only the best-matching passage
+	vectorWeight        float64 // Weight of the vector ranking in reciprocal-rank fusion
+	keywordWeight       float64 // Weight of the BM25 ranking in reciprocal-rank fusion
+	includeManuscripts  bool    // Also search passages of Library manuscripts
+	includeChatMemory   bool    // Also search past chat sessions
+
+	// Pre-retrieval query planning; see query_plan.go
+	generate       Generator // LLM for query rewriting and HyDE; nil searches queries as typed
+	rewriteQueries bool      // Rewrite follow-ups into standalone queries using recent turns
+	hyde           bool      // Also search with a hypothetical answer
+	maxSubQueries  int       // Split multi-part questions into up to this many searches
+	trace          Tracer
+
+	reranker         Reranker // Reorders the top fused candidates; nil keeps fused order
+	rerankCandidates int      // How many candidates the reranker sees
+	mmrLambda        float64  // Relevance vs. diversity of the final selection; see selection.go
 }
 
-// NewContextBuilder creates a new context builder
-func NewContextBuilder(embeddingService *embeddings.EmbeddingService) *ContextBuilder {
-	if embeddingService == nil {
-		log.Fatal("FATAL: EmbeddingService cannot be nil in NewContextBuilder") // Use Fatal as this is critical
+// Sources of retrieved context items.
+const (
+	SourceCodex      = "codex"
+	SourceManuscript = "manuscript"
+	SourceChatMemory = "chat"
+)
+
+// RetrievedItem is one codex entry or manuscript passage selected for context, with the
+// evidence from each retriever. Manuscript and chat memory items carry only Manuscript or
+// ChatMemory; Entry is empty.
+type RetrievedItem struct {
+	Source       string
+	Entry        database.CodexEntry
+	Manuscript   *embeddings.LibraryPassage
+	ChatMemory   *embeddings.ChatMemory
+	Passage      *embeddings.Passage // Best-matching passage of a long entry, from vector search
+	VectorScore  float32             // Cosine similarity; 0 when only keyword search matched
+	VectorRank   int                 // 1-based rank among vector hits; 0 if not a vector hit
+	KeywordScore float64             // Negated BM25; 0 when only vector search matched
+	KeywordRank  int                 // 1-based rank among keyword hits; 0 if not a keyword hit
+	FusedScore   float64             // Weighted reciprocal-rank fusion score
+	RerankScore  float64             // Reranker relevance; only set when Reranked
+	Reranked     bool
+	Pinned       bool // Named in the query or document, so included regardless of score
+}
+
+// NewContextBuilder creates a new context builder. Either dependency may be nil: without
+// an embedding service retrieval is keyword-only, without a database it is vector-only.
+func NewContextBuilder(embeddingService *embeddings.EmbeddingService, db *sql.DB) *ContextBuilder {
+	if embeddingService == nil && db == nil {
+		log.Fatal("FATAL: ContextBuilder needs an EmbeddingService or a database") // Use Fatal as this is critical
 	}
-	return &ContextBuilder{
+	b := &ContextBuilder{
 		embeddingService:    embeddingService,
+		db:                  db,
 		maxEntries:          20,  // Default max entries (increased from 10)
-		similarityThreshold: 0.4, // Default minimum similarity score
+		similarityThreshold: 0.4, // Default minimum similarity score; see below
+		injectPassages:      true,
+		vectorWeight:        1.0,
+		keywordWeight:       1.0,
+		includeManuscripts:  true,
+		rerankCandidates:    DefaultRerankCandidates,
+		mmrLambda:           DefaultDiversity,
 	}
+	if embeddingService != nil {
+		// Cosine scores of unrelated texts differ a lot between embedding models
+		b.similarityThreshold = embeddings.DefaultSimilarityThreshold(embeddingService.ModelIdentifier())
+	}
+	return b
 }
 
 // SetMaxEntries allows customizing the maximum number of context entries
@@ -35,29 +97,246 @@ func (b *ContextBuilder) SetMaxEntries(max int) {
 	}
 }
 
-// SetSimilarityThreshold allows customizing the minimum similarity score
+// SetSimilarityThreshold allows customizing the minimum similarity score. The default
+// depends on the embedding model; see embeddings.DefaultSimilarityThreshold.
 func (b *ContextBuilder) SetSimilarityThreshold(threshold float32) {
 	if threshold >= 0.0 && threshold <= 1.0 {
 		b.similarityThreshold = threshold
 	}
 }
 
-// BuildContextForQuery creates a context string based on similarity search results
-func (b *ContextBuilder) BuildContextForQuery(query string) (string, error) {
-	if b.embeddingService == nil {
-		return "", fmt.Errorf("embedding service is not initialized in ContextBuilder")
+// SetInjectPassages controls whether long entries contribute only their best-matching
+// passage (the default) or their full content.
+func (b *ContextBuilder) SetInjectPassages(enabled bool) {
+	b.injectPassages = enabled
+}
+
+// SetIncludeManuscripts controls whether passages from Library manuscripts are retrieved
+// alongside codex entries.
+func (b *ContextBuilder) SetIncludeManuscripts(enabled bool) {
+	b.includeManuscripts = enabled
+}
+
+// SetIncludeChatMemory controls whether relevant exchanges from past chat sessions are
+// retrieved alongside codex entries. Off by default.
+func (b *ContextBuilder) SetIncludeChatMemory(enabled bool) {
+	b.includeChatMemory = enabled
+}
+
+// SetFusionWeights sets how much the vector and keyword rankings each contribute to the
+// fused ranking. A weight of 0 turns that retriever off. Negative weights, or both being
+// zero, are ignored.
+func (b *ContextBuilder) SetFusionWeights(vector, keyword float64) {
+	if vector < 0 || keyword < 0 || (vector == 0 && keyword == 0) {
+		return
+	}
+	b.vectorWeight = vector
+	b.keywordWeight = keyword
+}
+
+// Settings describes how a ContextBuilder retrieves, e.g. to record it with an evaluation.
+type Settings struct {
+	EmbeddingModel      string  `json:"embeddingModel"` // Empty without an embedding service
+	MaxEntries          int     `json:"maxEntries"`
+	SimilarityThreshold float32 `json:"similarityThreshold"`
+	VectorWeight        float64 `json:"vectorWeight"`
+	KeywordWeight       float64 `json:"keywordWeight"`
+	InjectPassages      bool    `json:"injectPassages"`
+	IncludeManuscripts  bool    `json:"includeManuscripts"`
+	IncludeChatMemory   bool    `json:"includeChatMemory"`
+	RewriteQueries      bool    `json:"rewriteQueries"`
+	HyDE                bool    `json:"hyde"`
+	MaxSubQueries       int     `json:"maxSubQueries"`
+	Reranker            string  `json:"reranker"` // Empty when off
+	RerankCandidates    int     `json:"rerankCandidates"`
+	Diversity           float64 `json:"diversity"`
+}
+
+// Settings returns the builder's current retrieval settings.
+func (b *ContextBuilder) Settings() Settings {
+	s := Settings{
+		MaxEntries:          b.maxEntries,
+		SimilarityThreshold: b.similarityThreshold,
+		VectorWeight:        b.vectorWeight,
+		KeywordWeight:       b.keywordWeight,
+		InjectPassages:      b.injectPassages,
+		IncludeManuscripts:  b.includeManuscripts,
+		IncludeChatMemory:   b.includeChatMemory,
+		RewriteQueries:      b.rewriteQueries && b.generate != nil,
+		HyDE:                b.hyde && b.generate != nil,
+		MaxSubQueries:       b.maxSubQueries,
+		RerankCandidates:    b.rerankCandidates,
+		Diversity:           b.mmrLambda,
+	}
+	if b.embeddingService != nil {
+		s.EmbeddingModel = b.embeddingService.ModelIdentifier()
+	}
+	if b.reranker != nil {
+		s.Reranker = b.reranker.Name()
 	}
+	if b.generate == nil {
+		s.MaxSubQueries = 0
+	}
+	return s
+}
 
-	// Find similar entries using the embedding service
-	results, err := b.embeddingService.FindSimilarEntries(query, b.maxEntries)
+// Retrieve runs vector and keyword search over the codex, and vector search over Library
+// manuscripts and (when enabled) past chat sessions, and merges the rankings with weighted reciprocal-rank fusion. Vector hits
+// below the similarity threshold are dropped before fusion; keyword hits have no
+// threshold since BM25 scores aren't comparable across queries. A failing retriever is
+// logged and skipped so the others can still answer. The final items are picked by
+// selectItems.
+func (b *ContextBuilder) Retrieve(query string) ([]RetrievedItem, error) {
+	items, err := b.retrieve(query, query)
 	if err != nil {
-		// Log the error but don't necessarily stop; maybe return an empty context
-		log.Printf("Warning: Failed to find similar entries for context: %v", err)
-		// Depending on the desired behavior, you might return the error or just an empty context
-		// return "", fmt.Errorf("failed to find similar entries: %w", err)
-		return "", nil // Return empty context on search error for now
+		return nil, err
 	}
+	return b.selectItems(Request{Query: query}, b.rerank(query, items)), nil
+}
 
+// retrieve is Retrieve with separate texts for vector and keyword search, and without
+// reranking. An empty keywordQuery skips keyword search. It returns up to candidateLimit
+// items.
+func (b *ContextBuilder) retrieve(query, keywordQuery string) ([]RetrievedItem, error) {
+	if b.embeddingService == nil && b.db == nil {
+		return nil, fmt.Errorf("no retrievers are initialized in ContextBuilder")
+	}
+	limit := b.candidateLimit()
+
+	byID := make(map[int64]*RetrievedItem)
+	var items []*RetrievedItem
+	get := func(entry database.CodexEntry) *RetrievedItem {
+		if r, ok := byID[entry.ID]; ok {
+			return r
+		}
+		r := &RetrievedItem{Source: SourceCodex, Entry: entry}
+		byID[entry.ID] = r
+		items = append(items, r)
+		return r
+	}
+
+	if b.embeddingService != nil && b.vectorWeight > 0 {
+		results, err := b.embeddingService.FindSimilarEntries(query, limit)
+		if err != nil {
+			log.Printf("Warning: Vector search failed, continuing with keyword search only: %v", err)
+		} else {
+			sort.Slice(results, func(i, j int) bool {
+				return results[i].Score > results[j].Score
+			})
+			rank := 0
+			for _, result := range results {
+				if result.Score < b.similarityThreshold {
+					break
+				}
+				rank++
+				r := get(result.Entry)
+				r.Passage = result.Passage
+				r.VectorScore = result.Score
+				r.VectorRank = rank
+				r.FusedScore += b.vectorWeight / float64(reciprocalRankK+rank)
+			}
+		}
+	}
+
+	if b.db != nil && b.keywordWeight > 0 && keywordQuery != "" {
+		hits, err := database.DBKeywordSearch(b.db, keywordQuery, limit)
+		if err != nil {
+			log.Printf("Warning: Keyword search failed, continuing with vector search only: %v", err)
+		} else {
+			for i, hit := range hits {
+				r := get(hit.Entry)
+				r.KeywordScore = hit.Score
+				r.KeywordRank = i + 1
+				r.FusedScore += b.keywordWeight / float64(reciprocalRankK+i+1)
+			}
+		}
+	}
+
+	if b.embeddingService != nil && b.vectorWeight > 0 && b.includeManuscripts {
+		passages, err := b.embeddingService.FindSimilarPassages(query, limit)
+		if err != nil {
+			log.Printf("Warning: Manuscript search failed, continuing without manuscript passages: %v", err)
+		} else {
+			rank := 0
+			for i := range passages {
+				if passages[i].Score < b.similarityThreshold {
+					break
+				}
+				rank++
+				items = append(items, &RetrievedItem{
+					Source:      SourceManuscript,
+					Manuscript:  &passages[i],
+					VectorScore: passages[i].Score,
+					VectorRank:  rank,
+					FusedScore:  b.vectorWeight / float64(reciprocalRankK+rank),
+				})
+			}
+		}
+	}
+
+	if b.embeddingService != nil && b.vectorWeight > 0 && b.includeChatMemory {
+		memories, err := b.embeddingService.FindSimilarChatMemories(query, limit)
+		if err != nil {
+			log.Printf("Warning: Chat memory search failed, continuing without past discussion: %v", err)
+		} else {
+			rank := 0
+			for i := range memories {
+				if memories[i].Score < b.similarityThreshold {
+					break
+				}
+				rank++
+				items = append(items, &RetrievedItem{
+					Source:      SourceChatMemory,
+					ChatMemory:  &memories[i],
+					VectorScore: memories[i].Score,
+					VectorRank:  rank,
+					FusedScore:  b.vectorWeight / float64(reciprocalRankK+rank),
+				})
+			}
+		}
+	}
+
+	retrieved := make([]RetrievedItem, 0, len(items))
+	for _, item := range items {
+		retrieved = append(retrieved, *item)
+	}
+	sort.SliceStable(retrieved, func(i, j int) bool {
+		return retrieved[i].FusedScore > retrieved[j].FusedScore
+	})
+	if len(retrieved) > limit {
+		retrieved = retrieved[:limit]
+	}
+	return retrieved, nil
+}
+
+// BuildContextForQuery creates a context string from the fused codex, manuscript and chat results
+func (b *ContextBuilder) BuildContextForQuery(query string) (string, error) {
+	return b.BuildContextForConversation(query, nil)
+}
+
+// BuildContextForConversation is BuildContextForQuery for a message in a conversation;
+// history lets query rewriting resolve references to earlier turns.
+func (b *ContextBuilder) BuildContextForConversation(query string, history []Turn) (string, error) {
+	return b.BuildContext(Request{Query: query, History: history})
+}
+
+// BuildContext creates the context string for a request.
+func (b *ContextBuilder) BuildContext(req Request) (string, error) {
+	contextStr, _, err := b.BuildContextWithSources(req)
+	return contextStr, err
+}
+
+// BuildContextWithSources is BuildContext that also describes the items it included.
+func (b *ContextBuilder) BuildContextWithSources(req Request) (string, []ContextSource, error) {
+	results, err := b.RetrieveFor(req)
+	if err != nil {
+		return "", nil, err
+	}
+	return b.formatContext(results), b.Sources(results), nil
+}
+
+// formatContext writes retrieved items as the context section of a prompt.
+func (b *ContextBuilder) formatContext(results []RetrievedItem) string {
 	// Build context string
 	var sb strings.Builder
 	includedCount := 0
@@ -65,41 +344,60 @@ func (b *ContextBuilder) BuildContextForQuery(query string) (string, error) {
 
 	if len(results) == 0 {
 		log.Println("No relevant context found for query.")
-		return "", nil // No relevant context found
+		return "" // No relevant context found
 	}
 
 	sb.WriteString("CONTEXT INFORMATION (ordered by relevance):\n") // Add header
 
-	// Sort results by score descending (most similar first)
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Score > results[j].Score
-	})
-
 	for _, result := range results {
-		// Skip entries below the similarity threshold
-		if result.Score < b.similarityThreshold {
-			break
+		if result.Source == SourceManuscript {
+			// Manuscript passages carry their location so the model can cite them
+			sb.WriteString("--- Manuscript Passage Start ---\n")
+			sb.WriteString(fmt.Sprintf("Source: %s\n", result.Manuscript.Citation()))
+			sb.WriteString(fmt.Sprintf("Text:\n%s\n", result.Manuscript.Text))
+			sb.WriteString(fmt.Sprintf("(Relevance Score: %.2f)\n", result.VectorScore))
+			sb.WriteString("--- Manuscript Passage End ---\n\n")
+
+			includedCount++
+			includedEntryInfo = append(includedEntryInfo, fmt.Sprintf("[%s] (Fused: %.4f, vector #%d)", result.Manuscript.Citation(), result.FusedScore, result.VectorRank))
+			continue
+		}
+		if result.Source == SourceChatMemory {
+			sb.WriteString("--- Prior Discussion Start ---\n")
+			sb.WriteString(fmt.Sprintf("Session: %s\n", result.ChatMemory.Session))
+			sb.WriteString(fmt.Sprintf("%s\n", result.ChatMemory.Text))
+			sb.WriteString(fmt.Sprintf("(Relevance Score: %.2f)\n", result.VectorScore))
+			sb.WriteString("--- Prior Discussion End ---\n\n")
+
+			includedCount++
+			includedEntryInfo = append(includedEntryInfo, fmt.Sprintf("[chat %s #%d] (Fused: %.4f, vector #%d)", result.ChatMemory.Session, result.ChatMemory.MessageIndex, result.FusedScore, result.VectorRank))
+			continue
 		}
 
 		// Add entry to context string
 		// Using a clear format for the LLM
 		sb.WriteString(fmt.Sprintf("--- Entry Start ---\n"))
 		sb.WriteString(fmt.Sprintf("Name: %s\n", result.Entry.Name))
-		sb.WriteString(fmt.Sprintf("Content:\n%s\n", result.Entry.Content))
-		sb.WriteString(fmt.Sprintf("(Relevance Score: %.2f)\n", result.Score))
+		if b.injectPassages && result.Passage != nil {
+			sb.WriteString(fmt.Sprintf("Relevant Passage (excerpt from a longer entry):\n%s\n", result.Passage.Text))
+		} else {
+			sb.WriteString(fmt.Sprintf("Content:\n%s\n", result.Entry.Content))
+		}
+		if result.VectorRank > 0 {
+			sb.WriteString(fmt.Sprintf("(Relevance Score: %.2f)\n", result.VectorScore))
+		} else if result.Pinned {
+			sb.WriteString("(Named in the query or document)\n")
+		} else {
+			sb.WriteString("(Matched by keyword search)\n")
+		}
 		sb.WriteString(fmt.Sprintf("--- Entry End ---\n\n"))
 
 		includedCount++
-		includedEntryInfo = append(includedEntryInfo, fmt.Sprintf("%s (Score: %.2f)", result.Entry.Name, result.Score)) // Store info
-	}
-
-	if includedCount == 0 {
-		log.Println("No entries met the similarity threshold for the query.")
-		return "", nil // No entries met the threshold
+		includedEntryInfo = append(includedEntryInfo, fmt.Sprintf("%s (Fused: %.4f, vector #%d, keyword #%d)", result.Entry.Name, result.FusedScore, result.VectorRank, result.KeywordRank)) // Store info
 	}
 
 	// Format the included entries as a bulleted list for logging
 	formattedEntries := "\n - " + strings.Join(includedEntryInfo, "\n - ")
 	log.Printf("Built context with %d entries for query:%s", includedCount, formattedEntries) // Modify log message format
-	return sb.String(), nil
+	return sb.String()
 }
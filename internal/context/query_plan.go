@@ -0,0 +1,301 @@
+// internal/context/query_plan.go
+package context
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// Generator completes a prompt with an LLM. The context package can't depend on the llm
+// package, so the app passes one in for query rewriting and HyDE.
+type Generator func(prompt string) (string, error)
+
+// Tracer receives the intermediate steps of retrieval for the trace view.
+type Tracer func(stage string, data interface{})
+
+// Turn is one message of the conversation leading up to a query.
+type Turn struct {
+	Role string // "user" or "assistant"
+	Text string
+}
+
+// Kinds of search in a QueryPlan.
+const (
+	SearchOriginal     = "original"     // The query as typed
+	SearchRewritten    = "rewritten"    // The query, rewritten to stand on its own
+	SearchSubQuery     = "sub-query"    // One aspect of a query that asks about several things
+	SearchHypothetical = "hypothetical" // A hypothetical answer, searched by vector only (HyDE)
+)
+
+// rewriteHistoryTurns is how many recent turns are shown to the model when rewriting.
+const rewriteHistoryTurns = 6
+
+// PlannedSearch is one search run for a query.
+type PlannedSearch struct {
+	Kind string `json:"kind"`
+	Text string `json:"text"`
+}
+
+// QueryPlan records how a query was turned into searches.
+type QueryPlan struct {
+	Original string          `json:"original"`
+	Searches []PlannedSearch `json:"searches"`
+}
+
+// SearchTrace is the outcome of one planned search, as shown in the trace view.
+type SearchTrace struct {
+	PlannedSearch
+	Results []string `json:"results"` // Labels of the items found, best first
+}
+
+// SetQueryGenerator sets the LLM used for query rewriting and HyDE. Without one, queries
+// are searched as typed.
+func (b *ContextBuilder) SetQueryGenerator(generate Generator) {
+	b.generate = generate
+}
+
+// SetQueryRewriting configures the pre-retrieval step. rewrite resolves references to
+// earlier turns ("what does she think of him?") into a standalone query; maxSubQueries
+// above 0 also lets it split multi-part questions into up to that many searches. hyde
+// additionally searches with a hypothetical answer to the query. All are off by default.
+func (b *ContextBuilder) SetQueryRewriting(rewrite, hyde bool, maxSubQueries int) {
+	b.rewriteQueries = rewrite
+	b.hyde = hyde
+	if maxSubQueries < 0 {
+		maxSubQueries = 0
+	}
+	b.maxSubQueries = maxSubQueries
+}
+
+// SetTracer sets where retrieval steps are reported. Nil turns tracing off.
+func (b *ContextBuilder) SetTracer(trace Tracer) {
+	b.trace = trace
+}
+
+func (b *ContextBuilder) emit(stage string, data interface{}) {
+	if b.trace != nil {
+		b.trace(stage, data)
+	}
+}
+
+// PlanQuery turns a query and the conversation before it into the searches to run. A
+// failing LLM call is logged and that step skipped, so the original query is always
+// searched at worst.
+func (b *ContextBuilder) PlanQuery(query string, history []Turn) QueryPlan {
+	plan := QueryPlan{Original: query}
+	main := query
+	var subQueries []string
+
+	if b.generate != nil && (b.rewriteQueries || b.maxSubQueries > 0) {
+		rewritten, subs, err := b.rewriteQuery(query, history)
+		if err != nil {
+			log.Printf("Warning: Query rewriting failed, searching the query as typed: %v", err)
+		} else {
+			if rewritten != "" {
+				main = rewritten
+			}
+			subQueries = subs
+		}
+	}
+	kind := SearchOriginal
+	if main != query {
+		kind = SearchRewritten
+	}
+	plan.Searches = append(plan.Searches, PlannedSearch{Kind: kind, Text: main})
+	seen := map[string]bool{strings.ToLower(main): true}
+	for _, sub := range subQueries {
+		key := strings.ToLower(sub)
+		if sub == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		plan.Searches = append(plan.Searches, PlannedSearch{Kind: SearchSubQuery, Text: sub})
+	}
+
+	if b.generate != nil && b.hyde {
+		answer, err := b.generate(hydePrompt(main))
+		if err != nil {
+			log.Printf("Warning: Hypothetical answer generation failed, skipping HyDE: %v", err)
+		} else if answer = strings.TrimSpace(answer); answer != "" {
+			plan.Searches = append(plan.Searches, PlannedSearch{Kind: SearchHypothetical, Text: answer})
+		}
+	}
+
+	b.emit("query-plan", plan)
+	return plan
+}
+
+// rewriteQuery asks the LLM for a standalone version of the query and, if allowed, its
+// sub-queries.
+func (b *ContextBuilder) rewriteQuery(query string, history []Turn) (string, []string, error) {
+	if len(history) > rewriteHistoryTurns {
+		history = history[len(history)-rewriteHistoryTurns:]
+	}
+	var sb strings.Builder
+	sb.WriteString("You prepare search queries for a fiction writer's worldbuilding codex (characters, places, lore) and manuscripts.\n")
+	if b.rewriteQueries {
+		sb.WriteString("Rewrite the latest message as a standalone search query: replace pronouns and references to earlier messages with the names they refer to, and keep every name exactly as written. If it already stands on its own, return it unchanged.\n")
+	} else {
+		sb.WriteString("Return the latest message unchanged as the query.\n")
+	}
+	if b.maxSubQueries > 0 {
+		sb.WriteString(fmt.Sprintf("If the message asks about several distinct things, also list up to %d short standalone sub-queries, one per thing. Otherwise return an empty list.\n", b.maxSubQueries))
+	} else {
+		sb.WriteString("Return an empty list of sub-queries.\n")
+	}
+	sb.WriteString("Reply with JSON only, in the form {\"query\": \"...\", \"sub_queries\": [\"...\"]}.\n\n")
+	if len(history) > 0 {
+		sb.WriteString("CONVERSATION:\n")
+		for _, turn := range history {
+			role := "Assistant"
+			if turn.Role == "user" {
+				role = "Writer"
+			}
+			sb.WriteString(fmt.Sprintf("%s: %s\n", role, strings.TrimSpace(turn.Text)))
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("LATEST MESSAGE:\n")
+	sb.WriteString(query)
+
+	reply, err := b.generate(sb.String())
+	if err != nil {
+		return "", nil, err
+	}
+	var parsed struct {
+		Query      string   `json:"query"`
+		SubQueries []string `json:"sub_queries"`
+	}
+	if err := json.Unmarshal([]byte(extractJSONObject(reply)), &parsed); err != nil {
+		return "", nil, fmt.Errorf("unreadable rewrite %q: %w", reply, err)
+	}
+	subs := make([]string, 0, len(parsed.SubQueries))
+	for _, sub := range parsed.SubQueries {
+		if sub = strings.TrimSpace(sub); sub != "" && len(subs) < b.maxSubQueries {
+			subs = append(subs, sub)
+		}
+	}
+	return strings.TrimSpace(parsed.Query), subs, nil
+}
+
+// hydePrompt asks for a short passage that would answer the query. Embedding it finds
+// entries written like answers, which queries phrased as questions often miss.
+func hydePrompt(query string) string {
+	return "Write a short passage (3-4 sentences) in the style of a worldbuilding codex entry that would answer the question below. " +
+		"Invent plausible details where needed; the passage is only used to search for similar entries. Reply with the passage only.\n\n" +
+		"QUESTION:\n" + query
+}
+
+// extractJSONObject returns the outermost {...} of a reply, dropping code fences or
+// chatter around it.
+func extractJSONObject(reply string) string {
+	start := strings.Index(reply, "{")
+	end := strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return reply
+	}
+	return reply[start : end+1]
+}
+
+// RetrieveForConversation is RetrieveFor a message in a conversation.
+func (b *ContextBuilder) RetrieveForConversation(query string, history []Turn) ([]RetrievedItem, error) {
+	return b.RetrieveFor(Request{Query: query, History: history})
+}
+
+// RetrieveFor plans the query, runs every planned search and merges the results with
+// reciprocal-rank fusion across searches, then reranks them against the main query and
+// selects the final items. Each step is reported to the tracer.
+func (b *ContextBuilder) RetrieveFor(req Request) ([]RetrievedItem, error) {
+	plan := b.PlanQuery(req.Query, req.History)
+	if len(plan.Searches) == 1 {
+		items, err := b.retrieve(plan.Searches[0].Text, plan.Searches[0].Text)
+		if err != nil {
+			return nil, err
+		}
+		b.emit("search-results", SearchTrace{PlannedSearch: plan.Searches[0], Results: itemLabels(items)})
+		return b.selectItems(req, b.rerank(plan.Searches[0].Text, items)), nil
+	}
+
+	merged := make(map[string]*RetrievedItem)
+	var order []string
+	var lastErr error
+	succeeded := 0
+	for _, search := range plan.Searches {
+		keywordQuery := search.Text
+		if search.Kind == SearchHypothetical {
+			keywordQuery = "" // Invented details would only add noise to keyword matches
+		}
+		items, err := b.retrieve(search.Text, keywordQuery)
+		if err != nil {
+			log.Printf("Warning: %s search %q failed: %v", search.Kind, search.Text, err)
+			lastErr = err
+			continue
+		}
+		succeeded++
+		b.emit("search-results", SearchTrace{PlannedSearch: search, Results: itemLabels(items)})
+
+		for rank, item := range items {
+			key := itemKey(item)
+			m, ok := merged[key]
+			if !ok {
+				copied := item
+				copied.FusedScore = 0
+				m = &copied
+				merged[key] = m
+				order = append(order, key)
+			} else if item.VectorScore > m.VectorScore {
+				// Keep the strongest evidence, e.g. the best-matching passage
+				m.VectorScore, m.VectorRank, m.Passage = item.VectorScore, item.VectorRank, item.Passage
+			}
+			if item.KeywordRank > 0 && (m.KeywordRank == 0 || item.KeywordRank < m.KeywordRank) {
+				m.KeywordScore, m.KeywordRank = item.KeywordScore, item.KeywordRank
+			}
+			m.FusedScore += 1 / float64(reciprocalRankK+rank+1)
+		}
+	}
+	if succeeded == 0 {
+		return nil, lastErr
+	}
+
+	results := make([]RetrievedItem, 0, len(order))
+	for _, key := range order {
+		results = append(results, *merged[key])
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].FusedScore > results[j].FusedScore })
+	if limit := b.candidateLimit(); len(results) > limit {
+		results = results[:limit]
+	}
+	b.emit("merged-results", itemLabels(results))
+	return b.selectItems(req, b.rerank(plan.Searches[0].Text, results)), nil
+}
+
+// itemKey identifies the same item found by different searches.
+func itemKey(item RetrievedItem) string {
+	switch item.Source {
+	case SourceManuscript:
+		return fmt.Sprintf("manuscript:%s#%d", item.Manuscript.FilePath, item.Manuscript.ChunkIndex)
+	case SourceChatMemory:
+		return fmt.Sprintf("chat:%s#%d:%s", item.ChatMemory.Session, item.ChatMemory.MessageIndex, item.ChatMemory.Text)
+	default:
+		return fmt.Sprintf("codex:%d", item.Entry.ID)
+	}
+}
+
+// itemLabels names retrieved items for the trace view.
+func itemLabels(items []RetrievedItem) []string {
+	labels := make([]string, len(items))
+	for i, item := range items {
+		switch item.Source {
+		case SourceManuscript:
+			labels[i] = item.Manuscript.Citation()
+		case SourceChatMemory:
+			labels[i] = fmt.Sprintf("chat %s #%d", item.ChatMemory.Session, item.ChatMemory.MessageIndex)
+		default:
+			labels[i] = item.Entry.Name
+		}
+	}
+	return labels
+}
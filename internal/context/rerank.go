@@ -0,0 +1,256 @@
+// internal/context/rerank.go
+package context
+
+import (
+	"Llore/internal/provider"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const (
+	// DefaultRerankCandidates is how many fused results are handed to the reranker.
+	DefaultRerankCandidates = 30
+	// rerankDocumentChars caps the text of each candidate sent to the reranker.
+	rerankDocumentChars = 1500
+)
+
+// Reranker scores candidates by how well they answer a query, reading query and
+// candidate together rather than comparing separately computed vectors.
+type Reranker interface {
+	// Rerank returns one score per document, higher meaning more relevant.
+	Rerank(query string, documents []string) ([]float64, error)
+	Name() string
+}
+
+// RerankTrace shows the reranker's effect in the trace view.
+type RerankTrace struct {
+	Reranker string    `json:"reranker"`
+	Before   []string  `json:"before"`
+	After    []string  `json:"after"`
+	Scores   []float64 `json:"scores"` // Of the items in After order
+}
+
+// SetReranker adds a reranking step over the top candidates of fused retrieval. Nil
+// removes it. candidates <= 0 uses DefaultRerankCandidates.
+func (b *ContextBuilder) SetReranker(reranker Reranker, candidates int) {
+	if candidates <= 0 {
+		candidates = DefaultRerankCandidates
+	}
+	b.reranker = reranker
+	b.rerankCandidates = candidates
+}
+
+// candidateLimit is how many results each retriever contributes: enough for the
+// reranker to choose from, and at least maxEntries.
+func (b *ContextBuilder) candidateLimit() int {
+	if b.reranker != nil && b.rerankCandidates > b.maxEntries {
+		return b.rerankCandidates
+	}
+	return b.maxEntries
+}
+
+// rerank reorders items by the reranker's scores. Items past the candidate limit keep
+// their fused order after the reranked ones. If the reranker fails, the fused order is
+// kept.
+func (b *ContextBuilder) rerank(query string, items []RetrievedItem) []RetrievedItem {
+	if b.reranker == nil || len(items) < 2 {
+		return items
+	}
+	n := len(items)
+	if n > b.rerankCandidates {
+		n = b.rerankCandidates
+	}
+	docs := make([]string, n)
+	for i := range docs {
+		docs[i] = rerankDocument(items[i])
+	}
+	scores, err := b.reranker.Rerank(query, docs)
+	if err != nil {
+		log.Printf("Warning: %s reranking failed, keeping fused order: %v", b.reranker.Name(), err)
+		return items
+	}
+	if len(scores) != n {
+		log.Printf("Warning: %s returned %d scores for %d candidates, keeping fused order", b.reranker.Name(), len(scores), n)
+		return items
+	}
+
+	before := itemLabels(items[:n])
+	head := append([]RetrievedItem(nil), items[:n]...)
+	for i := range head {
+		head[i].RerankScore = scores[i]
+		head[i].Reranked = true
+	}
+	sort.SliceStable(head, func(i, j int) bool { return head[i].RerankScore > head[j].RerankScore })
+	reranked := append(head, items[n:]...)
+
+	trace := RerankTrace{Reranker: b.reranker.Name(), Before: before, After: itemLabels(head)}
+	for _, item := range head {
+		trace.Scores = append(trace.Scores, item.RerankScore)
+	}
+	b.emit("rerank", trace)
+	return reranked
+}
+
+// rerankDocument is the text of an item as the reranker sees it.
+func rerankDocument(item RetrievedItem) string {
+	var text string
+	switch item.Source {
+	case SourceManuscript:
+		text = fmt.Sprintf("Manuscript %s:\n%s", item.Manuscript.Citation(), item.Manuscript.Text)
+	case SourceChatMemory:
+		text = fmt.Sprintf("Earlier discussion:\n%s", item.ChatMemory.Text)
+	default:
+		body := item.Entry.Content
+		if item.Passage != nil {
+			body = item.Passage.Text
+		}
+		text = fmt.Sprintf("%s (%s):\n%s", item.Entry.Name, item.Entry.Type, body)
+	}
+	if len(text) > rerankDocumentChars {
+		text = text[:rerankDocumentChars]
+	}
+	return text
+}
+
+// LLMReranker asks a chat model to order all candidates at once (listwise reranking).
+type LLMReranker struct {
+	generate Generator
+	model    string // For Name only; the Generator picks the model
+}
+
+// NewLLMReranker creates a listwise reranker that completes prompts with generate.
+func NewLLMReranker(generate Generator, model string) *LLMReranker {
+	return &LLMReranker{generate: generate, model: model}
+}
+
+// Name identifies the reranker in logs and traces.
+func (r *LLMReranker) Name() string {
+	return "llm:" + r.model
+}
+
+// Rerank asks for the numbers of the relevant candidates, most relevant first. Listed
+// candidates score from 1 down towards 0 by position; unlisted ones score 0.
+func (r *LLMReranker) Rerank(query string, documents []string) ([]float64, error) {
+	var sb strings.Builder
+	sb.WriteString("You rank search results for a fiction writer's worldbuilding assistant.\n")
+	sb.WriteString("Order the numbered passages below by how useful they are for answering the query, most useful first. Leave out passages that are not relevant at all.\n")
+	sb.WriteString("Reply with a JSON array of passage numbers only, e.g. [3, 1, 7].\n\n")
+	sb.WriteString("QUERY:\n")
+	sb.WriteString(query)
+	sb.WriteString("\n\nPASSAGES:\n")
+	for i, doc := range documents {
+		sb.WriteString(fmt.Sprintf("[%d] %s\n\n", i+1, strings.TrimSpace(doc)))
+	}
+
+	reply, err := r.generate(sb.String())
+	if err != nil {
+		return nil, err
+	}
+	start, end := strings.Index(reply, "["), strings.LastIndex(reply, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no ranking in reply %q", reply)
+	}
+	var order []int
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &order); err != nil {
+		return nil, fmt.Errorf("unreadable ranking %q: %w", reply, err)
+	}
+
+	scores := make([]float64, len(documents))
+	position := 0
+	for _, number := range order {
+		i := number - 1
+		if i < 0 || i >= len(documents) || scores[i] > 0 {
+			continue // Out of range or listed twice
+		}
+		scores[i] = 1 - float64(position)/float64(len(documents))
+		position++
+	}
+	return scores, nil
+}
+
+// CrossEncoderReranker calls a rerank endpoint in the shape shared by Jina, Cohere,
+// vLLM, llama.cpp's server and Infinity: POST {model, query, documents} returning
+// {results: [{index, relevance_score}]}.
+type CrossEncoderReranker struct {
+	endpoint string
+	model    string
+	apiKey   string
+}
+
+// NewCrossEncoderReranker creates a reranker for the /rerank endpoint at endpoint, e.g.
+// "http://localhost:8080/v1/rerank". apiKey may be empty for local servers.
+func NewCrossEncoderReranker(endpoint, model, apiKey string) (*CrossEncoderReranker, error) {
+	if endpoint == "" {
+		return nil, fmt.Errorf("rerank endpoint cannot be empty")
+	}
+	return &CrossEncoderReranker{endpoint: endpoint, model: model, apiKey: apiKey}, nil
+}
+
+// Name identifies the reranker in logs and traces.
+func (r *CrossEncoderReranker) Name() string {
+	return "cross-encoder:" + r.model
+}
+
+// Rerank scores every document in one request.
+func (r *CrossEncoderReranker) Rerank(query string, documents []string) ([]float64, error) {
+	payload := struct {
+		Model     string   `json:"model,omitempty"`
+		Query     string   `json:"query"`
+		Documents []string `json:"documents"`
+		TopN      int      `json:"top_n"`
+	}{Model: r.model, Query: query, Documents: documents, TopN: len(documents)}
+	bodyBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal rerank request: %w", err)
+	}
+	req, err := http.NewRequest("POST", r.endpoint, bytes.NewBuffer(bodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rerank request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+r.apiKey)
+	}
+
+	resp, err := provider.HTTPClient(provider.ClientRerank).Do(req)
+	if err != nil {
+		return nil, provider.FromTransport("rerank", r.model, err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rerank response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, provider.FromHTTPStatus("rerank", r.model, resp.StatusCode, string(respBody))
+	}
+
+	var parsed struct {
+		Results []struct {
+			Index          int     `json:"index"`
+			RelevanceScore float64 `json:"relevance_score"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(respBody, &parsed); err != nil {
+		return nil, provider.NewError("rerank", r.model, provider.ErrInvalidResponse, fmt.Sprintf("failed to parse rerank response: %v", err), err)
+	}
+	if len(parsed.Results) == 0 {
+		return nil, provider.NewError("rerank", r.model, provider.ErrEmptyResponse, "rerank endpoint returned no results", nil)
+	}
+	scores := make([]float64, len(documents))
+	for i := range scores {
+		scores[i] = -1e9 // Below any real score, in case the endpoint omits some
+	}
+	for _, result := range parsed.Results {
+		if result.Index >= 0 && result.Index < len(documents) {
+			scores[result.Index] = result.RelevanceScore
+		}
+	}
+	return scores, nil
+}
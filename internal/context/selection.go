@@ -0,0 +1,306 @@
+// internal/context/selection.go
+package context
+
+import (
+	"Llore/internal/database"
+	"Llore/internal/embeddings"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// DefaultDiversity is the MMR lambda: how much relevance counts against redundancy
+	// with the items already selected. 1 selects by relevance alone.
+	DefaultDiversity = 0.7
+	// maxDocumentPins caps how many entries named in the document are pinned; the most
+	// often named come first. Entries named in the query are always pinned.
+	maxDocumentPins = 10
+	// minPinnedNameRunes skips very short names and aliases, which match by accident.
+	minPinnedNameRunes = 3
+)
+
+// Request is what a retrieval can take into account besides the query.
+type Request struct {
+	Query    string
+	History  []Turn // Earlier turns of the conversation, for query rewriting
+	Document string // The text being worked on, e.g. the current chapter; may be empty
+}
+
+// SelectionTrace shows the pinned entries and the effect of MMR in the trace view.
+type SelectionTrace struct {
+	Pinned   []string `json:"pinned"`
+	Before   []string `json:"before"` // Candidates in relevance order
+	Selected []string `json:"selected"`
+}
+
+// SetDiversity sets the MMR lambda used to pick the final items. Values outside (0, 1]
+// are ignored.
+func (b *ContextBuilder) SetDiversity(lambda float64) {
+	if lambda > 0 && lambda <= 1 {
+		b.mmrLambda = lambda
+	}
+}
+
+// selectItems picks the final context from the ranked candidates: first every entry
+// named in the query and the most-named ones in the document, then the rest by maximal
+// marginal relevance, up to maxEntries items in total. Pinned entries are kept even if
+// they alone exceed maxEntries.
+func (b *ContextBuilder) selectItems(req Request, candidates []RetrievedItem) []RetrievedItem {
+	pinned := b.pinnedEntries(req, candidates)
+	isPinned := make(map[int64]bool, len(pinned))
+	for _, item := range pinned {
+		isPinned[item.Entry.ID] = true
+	}
+	rest := make([]RetrievedItem, 0, len(candidates))
+	for _, item := range candidates {
+		if item.Source == SourceCodex && isPinned[item.Entry.ID] {
+			continue
+		}
+		rest = append(rest, item)
+	}
+
+	slots := b.maxEntries - len(pinned)
+	if slots < 0 {
+		slots = 0
+	}
+	selected := append(append([]RetrievedItem(nil), pinned...), b.diversify(pinned, rest, slots)...)
+	if len(pinned) > 0 || b.mmrLambda < 1 {
+		b.emit("selection", SelectionTrace{Pinned: itemLabels(pinned), Before: itemLabels(candidates), Selected: itemLabels(selected)})
+	}
+	return selected
+}
+
+// diversify picks up to n items from ranked by maximal marginal relevance: each pick
+// maximises lambda*relevance - (1-lambda)*(similarity to the closest item already
+// chosen, including the pinned ones). Fused, reranked and pinned items score on
+// different scales, so relevance comes from the rank: 1 for the first candidate down
+// towards 0 for the last.
+func (b *ContextBuilder) diversify(pinned, ranked []RetrievedItem, n int) []RetrievedItem {
+	if n > len(ranked) {
+		n = len(ranked)
+	}
+	if b.mmrLambda >= 1 || n <= 0 {
+		return ranked[:n]
+	}
+
+	sim := b.similarityFunc(append(append([]RetrievedItem(nil), pinned...), ranked...))
+	maxSim := make([]float64, len(ranked)) // Highest similarity to anything chosen so far
+	for i := range ranked {
+		for j := range pinned {
+			maxSim[i] = math.Max(maxSim[i], sim(ranked[i], pinned[j]))
+		}
+	}
+
+	chosen := make([]bool, len(ranked))
+	var selected []RetrievedItem
+	for len(selected) < n {
+		best, bestScore := -1, math.Inf(-1)
+		for i := range ranked {
+			if chosen[i] {
+				continue
+			}
+			relevance := 1 - float64(i)/float64(len(ranked))
+			score := b.mmrLambda*relevance - (1-b.mmrLambda)*maxSim[i]
+			if score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		chosen[best] = true
+		selected = append(selected, ranked[best])
+		for i := range ranked {
+			if !chosen[i] {
+				maxSim[i] = math.Max(maxSim[i], sim(ranked[i], ranked[best]))
+			}
+		}
+	}
+	return selected
+}
+
+// similarityFunc compares two items: by their stored vectors when both are codex entries
+// with one, otherwise by the words of their text.
+func (b *ContextBuilder) similarityFunc(items []RetrievedItem) func(x, y RetrievedItem) float64 {
+	var vectors map[int64][]float32
+	if b.embeddingService != nil {
+		var ids []int64
+		for _, item := range items {
+			if item.Source == SourceCodex {
+				ids = append(ids, item.Entry.ID)
+			}
+		}
+		var err error
+		if vectors, err = b.embeddingService.EntryVectors(ids); err != nil {
+			log.Printf("Warning: Comparing context candidates by text only: %v", err)
+		}
+	}
+	terms := make(map[string]map[string]float64)
+	termsOf := func(item RetrievedItem) map[string]float64 {
+		key := itemKey(item)
+		if t, ok := terms[key]; ok {
+			return t
+		}
+		t := termCounts(rerankDocument(item))
+		terms[key] = t
+		return t
+	}
+	return func(x, y RetrievedItem) float64 {
+		if x.Source == SourceCodex && y.Source == SourceCodex {
+			if vx, vy := vectors[x.Entry.ID], vectors[y.Entry.ID]; len(vx) > 0 && len(vx) == len(vy) {
+				return float64(embeddings.CosineSimilarity(vx, vy))
+			}
+		}
+		return termCosine(termsOf(x), termsOf(y))
+	}
+}
+
+// termCounts counts the lowercase words of a text.
+func termCounts(text string) map[string]float64 {
+	counts := make(map[string]float64)
+	for _, word := range words(text) {
+		counts[word]++
+	}
+	return counts
+}
+
+// termCosine is the cosine similarity of two word count vectors.
+func termCosine(a, b map[string]float64) float64 {
+	var dot, normA, normB float64
+	for word, count := range a {
+		dot += count * b[word]
+		normA += count * count
+	}
+	for _, count := range b {
+		normB += count * count
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// words splits text into lowercase words.
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// pinnedEntries returns the codex entries named, by name or alias, in the query or the
+// document. Entries already among the candidates keep their retrieval evidence; the
+// others are loaded from the database.
+func (b *ContextBuilder) pinnedEntries(req Request, candidates []RetrievedItem) []RetrievedItem {
+	if b.db == nil || (req.Query == "" && req.Document == "") {
+		return nil
+	}
+	names, err := database.DBListEntryNames(b.db)
+	if err != nil {
+		log.Printf("Warning: Not pinning named entries: %v", err)
+		return nil
+	}
+
+	ids := mentionedEntries(names, req.Query, 0)
+	seen := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		seen[id] = true
+	}
+	for _, id := range mentionedEntries(names, req.Document, maxDocumentPins) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	found := make(map[int64]RetrievedItem)
+	for _, item := range candidates {
+		if item.Source == SourceCodex && seen[item.Entry.ID] {
+			found[item.Entry.ID] = item
+		}
+	}
+	var missing []int64
+	for _, id := range ids {
+		if _, ok := found[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	entries, err := database.DBGetEntriesByIDs(b.db, missing)
+	if err != nil {
+		log.Printf("Warning: Failed to load named entries: %v", err)
+	}
+	for _, entry := range entries {
+		found[entry.ID] = RetrievedItem{Source: SourceCodex, Entry: entry}
+	}
+
+	pinned := make([]RetrievedItem, 0, len(ids))
+	for _, id := range ids {
+		if item, ok := found[id]; ok {
+			item.Pinned = true
+			pinned = append(pinned, item)
+		}
+	}
+	return pinned
+}
+
+// mentionedEntries returns the entries whose name or an alias appears in text as whole
+// words, ignoring case. With limit > 0 it returns at most that many, most often named
+// first; otherwise all of them in order of first mention.
+func mentionedEntries(names []database.EntryName, text string, limit int) []int64 {
+	tokens := words(text)
+	if len(tokens) == 0 {
+		return nil
+	}
+	starts := make(map[string][]int) // Positions of each word in text
+	for i, token := range tokens {
+		starts[token] = append(starts[token], i)
+	}
+
+	counts := make(map[int64]int)
+	first := make(map[int64]int)
+	for _, name := range names {
+		nameTokens := words(name.Name)
+		if len(nameTokens) == 0 || len([]rune(strings.Join(nameTokens, " "))) < minPinnedNameRunes {
+			continue
+		}
+		for _, pos := range starts[nameTokens[0]] {
+			if !tokensAt(tokens, pos, nameTokens) {
+				continue
+			}
+			if _, ok := first[name.EntryID]; !ok || pos < first[name.EntryID] {
+				first[name.EntryID] = pos
+			}
+			counts[name.EntryID]++
+		}
+	}
+
+	ids := make([]int64, 0, len(counts))
+	for id := range counts {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if limit > 0 && counts[ids[i]] != counts[ids[j]] {
+			return counts[ids[i]] > counts[ids[j]]
+		}
+		return first[ids[i]] < first[ids[j]]
+	})
+	if limit > 0 && len(ids) > limit {
+		ids = ids[:limit]
+	}
+	return ids
+}
+
+// tokensAt reports whether want occurs in tokens starting at pos.
+func tokensAt(tokens []string, pos int, want []string) bool {
+	if pos+len(want) > len(tokens) {
+		return false
+	}
+	for i, w := range want {
+		if tokens[pos+i] != w {
+			return false
+		}
+	}
+	return true
+}
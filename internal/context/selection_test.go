@@ -0,0 +1,88 @@
+package context
+
+import (
+	"Llore/internal/database"
+	"reflect"
+	"testing"
+)
+
+func testEntry(id int64, name, content string) RetrievedItem {
+	return RetrievedItem{Source: SourceCodex, Entry: database.CodexEntry{ID: id, Name: name, Type: "Location", Content: content}}
+}
+
+func TestDiversify(t *testing.T) {
+	// In relevance order. Old Harbor nearly duplicates Harbor; the others share little.
+	ranked := []RetrievedItem{
+		testEntry(1, "Harbor", "Ships leave the harbor docks at dawn with salt and fish."),
+		testEntry(2, "Old Harbor", "Ships leave the harbor docks at dawn with salt and fish."),
+		testEntry(3, "Frost Pass", "Snow closes the mountain road every winter."),
+		testEntry(4, "Deepwood", "Wolves hunt beneath a dark forest canopy."),
+	}
+	pinned := []RetrievedItem{testEntry(5, "Harbor Town", "Ships leave the harbor docks at dawn with salt and fish.")}
+
+	tests := []struct {
+		name   string
+		lambda float64
+		pinned []RetrievedItem
+		ranked []RetrievedItem
+		n      int
+		want   []string
+	}{
+		{"empty", DefaultDiversity, nil, nil, 5, []string{}},
+		{"no slots", DefaultDiversity, nil, ranked, 0, []string{}},
+		{"relevance only", 1, nil, ranked, 3, []string{"Harbor", "Old Harbor", "Frost Pass"}},
+		{"relevance only ignores pins", 1, pinned, ranked, 2, []string{"Harbor", "Old Harbor"}},
+		{"default defers the duplicate", DefaultDiversity, nil, ranked, 4, []string{"Harbor", "Frost Pass", "Old Harbor", "Deepwood"}},
+		{"default skips the duplicate", DefaultDiversity, nil, ranked, 2, []string{"Harbor", "Frost Pass"}},
+		{"diversity only", 0, nil, ranked, 4, []string{"Harbor", "Deepwood", "Frost Pass", "Old Harbor"}},
+		{"more slots than candidates", DefaultDiversity, nil, ranked, 10, []string{"Harbor", "Frost Pass", "Old Harbor", "Deepwood"}},
+		{"without pins", 0.5, nil, ranked, 2, []string{"Harbor", "Frost Pass"}},
+		{"pinned duplicate pushes both harbors out", 0.5, pinned, ranked, 2, []string{"Frost Pass", "Deepwood"}},
+		{"single candidate", 0, nil, ranked[2:3], 3, []string{"Frost Pass"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &ContextBuilder{mmrLambda: tt.lambda}
+			got := itemLabels(b.diversify(tt.pinned, tt.ranked, tt.n))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selected %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetDiversity(t *testing.T) {
+	tests := []struct {
+		lambda float64
+		want   float64
+	}{
+		{0.5, 0.5},
+		{1, 1},
+		{0, DefaultDiversity},
+		{-0.2, DefaultDiversity},
+		{1.5, DefaultDiversity},
+	}
+	for _, tt := range tests {
+		b := &ContextBuilder{mmrLambda: DefaultDiversity}
+		b.SetDiversity(tt.lambda)
+		if b.mmrLambda != tt.want {
+			t.Errorf("SetDiversity(%v): lambda = %v, want %v", tt.lambda, b.mmrLambda, tt.want)
+		}
+	}
+}
+
+func TestSelectItemsCapsWithoutPins(t *testing.T) {
+	var candidates []RetrievedItem
+	for i, name := range []string{"Harbor", "Frost Pass", "Deepwood"} {
+		candidates = append(candidates, testEntry(int64(i+1), name, "Text about "+name))
+	}
+	var traced bool
+	b := &ContextBuilder{mmrLambda: 1, maxEntries: 2, trace: func(string, interface{}) { traced = true }}
+	got := itemLabels(b.selectItems(Request{Query: "harbor"}, candidates))
+	if want := []string{"Harbor", "Frost Pass"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("selected %v, want %v", got, want)
+	}
+	if traced {
+		t.Error("selection traced although nothing was pinned or diversified")
+	}
+}
@@ -0,0 +1,57 @@
+// internal/context/sources.go
+package context
+
+// ContextSource is one item that was put into a prompt, so the answer can be shown with
+// its sources and the user can open them.
+type ContextSource struct {
+	Source       string  `json:"source"`             // SourceCodex, SourceManuscript or SourceChatMemory
+	EntryID      int64   `json:"entryId,omitempty"`  // Codex entries only
+	Name         string  `json:"name"`               // Entry name, manuscript citation or chat session
+	Type         string  `json:"type,omitempty"`     // Codex entry type
+	FilePath     string  `json:"filePath,omitempty"` // Manuscripts only
+	StartLine    int     `json:"startLine,omitempty"`
+	EndLine      int     `json:"endLine,omitempty"`
+	Session      string  `json:"session,omitempty"` // Chat memory only
+	MessageIndex int     `json:"messageIndex,omitempty"`
+	Excerpt      string  `json:"excerpt,omitempty"` // The passage used when not all of the item was
+	Score        float32 `json:"score"`             // Cosine similarity; 0 if found by keyword or name only
+	FusedScore   float64 `json:"fusedScore"`
+	RerankScore  float64 `json:"rerankScore,omitempty"`
+	Pinned       bool    `json:"pinned,omitempty"`
+}
+
+// Sources describes retrieved items as they appear in the context string.
+func (b *ContextBuilder) Sources(items []RetrievedItem) []ContextSource {
+	sources := make([]ContextSource, 0, len(items))
+	for _, item := range items {
+		source := ContextSource{
+			Source:      item.Source,
+			Score:       item.VectorScore,
+			FusedScore:  item.FusedScore,
+			RerankScore: item.RerankScore,
+			Pinned:      item.Pinned,
+		}
+		switch item.Source {
+		case SourceManuscript:
+			source.Name = item.Manuscript.Citation()
+			source.FilePath = item.Manuscript.FilePath
+			source.StartLine = item.Manuscript.StartLine
+			source.EndLine = item.Manuscript.EndLine
+			source.Excerpt = item.Manuscript.Text
+		case SourceChatMemory:
+			source.Name = item.ChatMemory.Session
+			source.Session = item.ChatMemory.Session
+			source.MessageIndex = item.ChatMemory.MessageIndex
+			source.Excerpt = item.ChatMemory.Text
+		default:
+			source.EntryID = item.Entry.ID
+			source.Name = item.Entry.Name
+			source.Type = item.Entry.Type
+			if b.injectPassages && item.Passage != nil {
+				source.Excerpt = item.Passage.Text
+			}
+		}
+		sources = append(sources, source)
+	}
+	return sources
+}
@@ -0,0 +1,118 @@
+// internal/database/aliases.go
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// EntryName is a name or alias by which the text of a query or manuscript can refer to a
+// codex entry.
+type EntryName struct {
+	EntryID int64
+	Name    string
+	IsAlias bool
+}
+
+// DBEnsureAliasesTable creates the codex_aliases table if it doesn't exist.
+func DBEnsureAliasesTable(dbConn *sql.DB) error {
+	_, err := dbConn.Exec(`CREATE TABLE IF NOT EXISTS codex_aliases (
+		codex_entry_id INTEGER NOT NULL,
+		alias TEXT NOT NULL COLLATE NOCASE,
+		FOREIGN KEY(codex_entry_id) REFERENCES codex_entries(id) ON DELETE CASCADE,
+		UNIQUE (codex_entry_id, alias)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create codex_aliases table: %w", err)
+	}
+	return nil
+}
+
+// DBGetAliases returns the aliases of an entry in alphabetical order.
+func DBGetAliases(dbConn *sql.DB, entryID int64) ([]string, error) {
+	rows, err := dbConn.Query(`SELECT alias FROM codex_aliases WHERE codex_entry_id = ? ORDER BY alias`, entryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query aliases of entry %d: %w", entryID, err)
+	}
+	defer rows.Close()
+	aliases := []string{}
+	for rows.Next() {
+		var alias string
+		if err := rows.Scan(&alias); err != nil {
+			return nil, fmt.Errorf("failed to scan alias: %w", err)
+		}
+		aliases = append(aliases, alias)
+	}
+	return aliases, rows.Err()
+}
+
+// DBSetAliases replaces the aliases of an entry. Blank and repeated aliases are dropped.
+func DBSetAliases(dbConn *sql.DB, entryID int64, aliases []string) error {
+	tx, err := dbConn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM codex_aliases WHERE codex_entry_id = ?`, entryID); err != nil {
+		return fmt.Errorf("failed to clear aliases of entry %d: %w", entryID, err)
+	}
+	for _, alias := range aliases {
+		if alias = strings.TrimSpace(alias); alias == "" {
+			continue
+		}
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO codex_aliases(codex_entry_id, alias) VALUES (?, ?)`, entryID, alias); err != nil {
+			return fmt.Errorf("failed to save alias %q of entry %d: %w", alias, entryID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// DBListEntryNames returns the name and aliases of every entry.
+func DBListEntryNames(dbConn *sql.DB) ([]EntryName, error) {
+	rows, err := dbConn.Query(`
+		SELECT id, name, 0 FROM codex_entries
+		UNION ALL
+		SELECT a.codex_entry_id, a.alias, 1 FROM codex_aliases a
+		JOIN codex_entries e ON e.id = a.codex_entry_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list entry names: %w", err)
+	}
+	defer rows.Close()
+	var names []EntryName
+	for rows.Next() {
+		var n EntryName
+		if err := rows.Scan(&n.EntryID, &n.Name, &n.IsAlias); err != nil {
+			return nil, fmt.Errorf("failed to scan entry name: %w", err)
+		}
+		names = append(names, n)
+	}
+	return names, rows.Err()
+}
+
+// DBGetEntriesByIDs fetches the given entries, in no particular order. Missing IDs are
+// skipped.
+func DBGetEntriesByIDs(dbConn *sql.DB, ids []int64) ([]CodexEntry, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	rows, err := dbConn.Query(`SELECT id, name, type, content, created_at, updated_at FROM codex_entries WHERE id IN (`+placeholders+`)`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch entries: %w", err)
+	}
+	defer rows.Close()
+	var entries []CodexEntry
+	for rows.Next() {
+		var e CodexEntry
+		if err := rows.Scan(&e.ID, &e.Name, &e.Type, &e.Content, &e.CreatedAt, &e.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan entry: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
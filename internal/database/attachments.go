@@ -0,0 +1,116 @@
+// internal/database/attachments.go
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// CodexAttachment is an image (portrait, map, ...) attached to a codex entry.
+// The file itself lives in the vault under Codex/Attachments/<entry id>/<filename>.
+type CodexAttachment struct {
+	ID           int64  `json:"id"`
+	CodexEntryID int64  `json:"codexEntryId"`
+	Filename     string `json:"filename"`
+	MimeType     string `json:"mimeType"`
+	Description  string `json:"description"` // Vision-model description, embedded with the entry for RAG
+	CreatedAt    string `json:"createdAt"`
+	UpdatedAt    string `json:"updatedAt"`
+}
+
+// DBEnsureAttachmentsTable creates the codex_attachments table if it doesn't exist.
+func DBEnsureAttachmentsTable(dbConn *sql.DB) error {
+	_, err := dbConn.Exec(`CREATE TABLE IF NOT EXISTS codex_attachments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		codex_entry_id INTEGER NOT NULL,
+		filename TEXT NOT NULL,
+		mime_type TEXT,
+		description TEXT NOT NULL DEFAULT '',
+		created_at TEXT,
+		updated_at TEXT,
+		FOREIGN KEY(codex_entry_id) REFERENCES codex_entries(id) ON DELETE CASCADE,
+		UNIQUE (codex_entry_id, filename)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create codex_attachments table: %w", err)
+	}
+	return nil
+}
+
+// DBInsertAttachment records a new attachment and returns its ID.
+func DBInsertAttachment(dbConn *sql.DB, entryID int64, filename, mimeType string) (int64, error) {
+	result, err := dbConn.Exec(
+		`INSERT INTO codex_attachments(codex_entry_id, filename, mime_type, description, created_at, updated_at)
+		 VALUES (?, ?, ?, '', datetime('now'), datetime('now'))`,
+		entryID, filename, mimeType,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert attachment: %w", err)
+	}
+	newID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to retrieve last insert ID: %w", err)
+	}
+	log.Printf("Inserted attachment %d ('%s') for entry %d", newID, filename, entryID)
+	return newID, nil
+}
+
+// DBGetAttachment fetches a single attachment by ID.
+func DBGetAttachment(dbConn *sql.DB, id int64) (CodexAttachment, error) {
+	var a CodexAttachment
+	err := dbConn.QueryRow(
+		`SELECT id, codex_entry_id, filename, COALESCE(mime_type, ''), description, created_at, updated_at
+		 FROM codex_attachments WHERE id = ?`, id,
+	).Scan(&a.ID, &a.CodexEntryID, &a.Filename, &a.MimeType, &a.Description, &a.CreatedAt, &a.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return a, fmt.Errorf("attachment %d not found", id)
+		}
+		return a, fmt.Errorf("failed to fetch attachment %d: %w", id, err)
+	}
+	return a, nil
+}
+
+// DBListAttachments returns all attachments for an entry, oldest first.
+func DBListAttachments(dbConn *sql.DB, entryID int64) ([]CodexAttachment, error) {
+	rows, err := dbConn.Query(
+		`SELECT id, codex_entry_id, filename, COALESCE(mime_type, ''), description, created_at, updated_at
+		 FROM codex_attachments WHERE codex_entry_id = ? ORDER BY id ASC`, entryID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query attachments for entry %d: %w", entryID, err)
+	}
+	defer rows.Close()
+
+	attachments := make([]CodexAttachment, 0)
+	for rows.Next() {
+		var a CodexAttachment
+		if err := rows.Scan(&a.ID, &a.CodexEntryID, &a.Filename, &a.MimeType, &a.Description, &a.CreatedAt, &a.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan attachment row: %w", err)
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments, rows.Err()
+}
+
+// DBUpdateAttachmentDescription stores the vision-model description of an attachment.
+func DBUpdateAttachmentDescription(dbConn *sql.DB, id int64, description string) error {
+	_, err := dbConn.Exec(
+		`UPDATE codex_attachments SET description = ?, updated_at = datetime('now') WHERE id = ?`,
+		description, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update description for attachment %d: %w", id, err)
+	}
+	return nil
+}
+
+// DBDeleteAttachment removes an attachment record. The caller removes the file.
+func DBDeleteAttachment(dbConn *sql.DB, id int64) error {
+	if _, err := dbConn.Exec(`DELETE FROM codex_attachments WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete attachment %d: %w", id, err)
+	}
+	log.Printf("Deleted attachment with ID: %d", id)
+	return nil
+}
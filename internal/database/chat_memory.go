@@ -0,0 +1,89 @@
+// internal/database/chat_memory.go
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// DBEnsureChatMemoryTables creates the tables behind long-term chat memory. Each saved
+// chat session (a log file in the vault's Chat folder) is split into exchanges, a user
+// message plus the replies to it, and every exchange is embedded. chat_sessions records
+// sessions the writer has excluded from memory.
+func DBEnsureChatMemoryTables(dbConn *sql.DB) error {
+	_, err := dbConn.Exec(`CREATE TABLE IF NOT EXISTS chat_memory (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		session TEXT NOT NULL,
+		vector_version TEXT NOT NULL,
+		message_index INTEGER NOT NULL,
+		chunk_index INTEGER NOT NULL,
+		exchange_hash TEXT NOT NULL,
+		chunk_text TEXT NOT NULL,
+		embedding BLOB NOT NULL,
+		created_at DATETIME NOT NULL,
+		UNIQUE (session, vector_version, message_index, chunk_index)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create chat_memory table: %w", err)
+	}
+	if _, err := dbConn.Exec(`CREATE INDEX IF NOT EXISTS idx_chat_memory_version ON chat_memory(vector_version)`); err != nil {
+		return fmt.Errorf("failed to create chat_memory index: %w", err)
+	}
+	_, err = dbConn.Exec(`CREATE TABLE IF NOT EXISTS chat_sessions (
+		session TEXT PRIMARY KEY,
+		memory_excluded INTEGER NOT NULL DEFAULT 0
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create chat_sessions table: %w", err)
+	}
+	return nil
+}
+
+// DBRemoveChatMemory drops every embedded exchange of a session, for all providers.
+func DBRemoveChatMemory(dbConn *sql.DB, session string) error {
+	if _, err := dbConn.Exec(`DELETE FROM chat_memory WHERE session = ?`, session); err != nil {
+		return fmt.Errorf("failed to remove chat memory of %s: %w", session, err)
+	}
+	return nil
+}
+
+// DBDeleteChatSession forgets a deleted session entirely, including its opt-out.
+func DBDeleteChatSession(dbConn *sql.DB, session string) error {
+	if err := DBRemoveChatMemory(dbConn, session); err != nil {
+		return err
+	}
+	if _, err := dbConn.Exec(`DELETE FROM chat_sessions WHERE session = ?`, session); err != nil {
+		return fmt.Errorf("failed to remove chat session %s: %w", session, err)
+	}
+	return nil
+}
+
+// DBSetChatMemoryExcluded records whether a session is kept out of chat memory. Excluding
+// a session also deletes what was already embedded from it.
+func DBSetChatMemoryExcluded(dbConn *sql.DB, session string, excluded bool) error {
+	_, err := dbConn.Exec(
+		`INSERT INTO chat_sessions (session, memory_excluded) VALUES (?, ?)
+		 ON CONFLICT(session) DO UPDATE SET memory_excluded = excluded.memory_excluded`,
+		session, excluded,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update chat session %s: %w", session, err)
+	}
+	if excluded {
+		return DBRemoveChatMemory(dbConn, session)
+	}
+	return nil
+}
+
+// DBIsChatMemoryExcluded reports whether a session has been kept out of chat memory.
+func DBIsChatMemoryExcluded(dbConn *sql.DB, session string) (bool, error) {
+	var excluded bool
+	err := dbConn.QueryRow(`SELECT memory_excluded FROM chat_sessions WHERE session = ?`, session).Scan(&excluded)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read chat session %s: %w", session, err)
+	}
+	return excluded, nil
+}
@@ -0,0 +1,32 @@
+// internal/database/chunks.go
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// DBEnsureEmbeddingChunksTable creates the table holding per-passage embeddings of long
+// entries. Offsets are byte positions into the entry's embedded body text.
+func DBEnsureEmbeddingChunksTable(dbConn *sql.DB) error {
+	_, err := dbConn.Exec(`CREATE TABLE IF NOT EXISTS codex_embedding_chunks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		codex_entry_id INTEGER NOT NULL,
+		vector_version TEXT NOT NULL,
+		chunk_index INTEGER NOT NULL,
+		start_offset INTEGER NOT NULL,
+		end_offset INTEGER NOT NULL,
+		chunk_text TEXT NOT NULL,
+		embedding BLOB NOT NULL,
+		created_at DATETIME NOT NULL,
+		FOREIGN KEY(codex_entry_id) REFERENCES codex_entries(id) ON DELETE CASCADE,
+		UNIQUE (codex_entry_id, vector_version, chunk_index)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create codex_embedding_chunks table: %w", err)
+	}
+	if _, err := dbConn.Exec(`CREATE INDEX IF NOT EXISTS idx_embedding_chunks_version ON codex_embedding_chunks(vector_version)`); err != nil {
+		return fmt.Errorf("failed to create codex_embedding_chunks index: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+// internal/database/clusters.go
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Statuses of a cluster suggestion.
+const (
+	ClusterPending   = "pending"
+	ClusterAccepted  = "accepted"
+	ClusterDismissed = "dismissed"
+)
+
+// ClusterSuggestionRecord is a stored group of related entries proposed as a tag.
+type ClusterSuggestionRecord struct {
+	ID          int64
+	Name        string
+	Description string
+	Status      string
+	Cohesion    float64
+	CreatedAt   string
+	EntryIDs    []int64 // Most typical first
+}
+
+// DBEnsureClusterTables creates the cluster_suggestions and cluster_suggestion_entries
+// tables if they don't exist.
+func DBEnsureClusterTables(dbConn *sql.DB) error {
+	_, err := dbConn.Exec(`CREATE TABLE IF NOT EXISTS cluster_suggestions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		status TEXT NOT NULL DEFAULT 'pending',
+		cohesion REAL NOT NULL DEFAULT 0,
+		created_at TEXT NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create cluster_suggestions table: %w", err)
+	}
+	_, err = dbConn.Exec(`CREATE TABLE IF NOT EXISTS cluster_suggestion_entries (
+		suggestion_id INTEGER NOT NULL,
+		codex_entry_id INTEGER NOT NULL,
+		position INTEGER NOT NULL,
+		FOREIGN KEY(suggestion_id) REFERENCES cluster_suggestions(id) ON DELETE CASCADE,
+		FOREIGN KEY(codex_entry_id) REFERENCES codex_entries(id) ON DELETE CASCADE,
+		UNIQUE (suggestion_id, codex_entry_id)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create cluster_suggestion_entries table: %w", err)
+	}
+	return nil
+}
+
+// DBReplacePendingClusterSuggestions drops the pending suggestions of an earlier run and
+// stores new ones, returning them with their IDs. Accepted and dismissed suggestions are
+// kept as history.
+func DBReplacePendingClusterSuggestions(dbConn *sql.DB, suggestions []ClusterSuggestionRecord) ([]ClusterSuggestionRecord, error) {
+	tx, err := dbConn.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	// Cascades aren't guaranteed to fire, so drop the members explicitly
+	if _, err := tx.Exec(`DELETE FROM cluster_suggestion_entries WHERE suggestion_id IN (SELECT id FROM cluster_suggestions WHERE status = ?)`, ClusterPending); err != nil {
+		return nil, fmt.Errorf("failed to clear pending cluster members: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM cluster_suggestions WHERE status = ?`, ClusterPending); err != nil {
+		return nil, fmt.Errorf("failed to clear pending cluster suggestions: %w", err)
+	}
+	for i := range suggestions {
+		s := &suggestions[i]
+		result, err := tx.Exec(`INSERT INTO cluster_suggestions(name, description, status, cohesion, created_at) VALUES (?, ?, ?, ?, datetime('now'))`,
+			s.Name, s.Description, ClusterPending, s.Cohesion)
+		if err != nil {
+			return nil, fmt.Errorf("failed to save cluster suggestion %q: %w", s.Name, err)
+		}
+		if s.ID, err = result.LastInsertId(); err != nil {
+			return nil, fmt.Errorf("failed to get cluster suggestion ID: %w", err)
+		}
+		s.Status = ClusterPending
+		for pos, entryID := range s.EntryIDs {
+			if _, err := tx.Exec(`INSERT OR IGNORE INTO cluster_suggestion_entries(suggestion_id, codex_entry_id, position) VALUES (?, ?, ?)`, s.ID, entryID, pos); err != nil {
+				return nil, fmt.Errorf("failed to save member %d of cluster suggestion %q: %w", entryID, s.Name, err)
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit cluster suggestions: %w", err)
+	}
+	return suggestions, nil
+}
+
+// DBListClusterSuggestions returns the cluster suggestions with the given status, or all
+// of them for "", newest first and largest first within a run.
+func DBListClusterSuggestions(dbConn *sql.DB, status string) ([]ClusterSuggestionRecord, error) {
+	rows, err := dbConn.Query(`SELECT id, name, description, status, cohesion, created_at FROM cluster_suggestions
+		WHERE ? = '' OR status = ? ORDER BY created_at DESC, id`, status, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cluster suggestions: %w", err)
+	}
+	suggestions := []ClusterSuggestionRecord{}
+	for rows.Next() {
+		var s ClusterSuggestionRecord
+		if err := rows.Scan(&s.ID, &s.Name, &s.Description, &s.Status, &s.Cohesion, &s.CreatedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan cluster suggestion: %w", err)
+		}
+		suggestions = append(suggestions, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list cluster suggestions: %w", err)
+	}
+	// Members are read once the rows above are closed; the pool has a single connection
+	for i := range suggestions {
+		if suggestions[i].EntryIDs, err = dbClusterMembers(dbConn, suggestions[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return suggestions, nil
+}
+
+// DBGetClusterSuggestion returns one cluster suggestion with its members.
+func DBGetClusterSuggestion(dbConn *sql.DB, id int64) (ClusterSuggestionRecord, error) {
+	var s ClusterSuggestionRecord
+	err := dbConn.QueryRow(`SELECT id, name, description, status, cohesion, created_at FROM cluster_suggestions WHERE id = ?`, id).
+		Scan(&s.ID, &s.Name, &s.Description, &s.Status, &s.Cohesion, &s.CreatedAt)
+	if err == sql.ErrNoRows {
+		return s, fmt.Errorf("cluster suggestion %d not found", id)
+	}
+	if err != nil {
+		return s, fmt.Errorf("failed to get cluster suggestion %d: %w", id, err)
+	}
+	s.EntryIDs, err = dbClusterMembers(dbConn, id)
+	return s, err
+}
+
+// DBSetClusterSuggestionStatus marks a cluster suggestion accepted or dismissed.
+func DBSetClusterSuggestionStatus(dbConn *sql.DB, id int64, status string) error {
+	if _, err := dbConn.Exec(`UPDATE cluster_suggestions SET status = ? WHERE id = ?`, status, id); err != nil {
+		return fmt.Errorf("failed to update cluster suggestion %d: %w", id, err)
+	}
+	return nil
+}
+
+// dbClusterMembers returns the entries of a cluster suggestion that still exist, most
+// typical first.
+func dbClusterMembers(dbConn *sql.DB, id int64) ([]int64, error) {
+	rows, err := dbConn.Query(`SELECT m.codex_entry_id FROM cluster_suggestion_entries m
+		JOIN codex_entries e ON e.id = m.codex_entry_id
+		WHERE m.suggestion_id = ? ORDER BY m.position`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query members of cluster suggestion %d: %w", id, err)
+	}
+	defer rows.Close()
+	ids := []int64{}
+	for rows.Next() {
+		var entryID int64
+		if err := rows.Scan(&entryID); err != nil {
+			return nil, fmt.Errorf("failed to scan cluster member: %w", err)
+		}
+		ids = append(ids, entryID)
+	}
+	return ids, rows.Err()
+}
@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
 
 	_ "modernc.org/sqlite"
 )
@@ -151,3 +153,134 @@ func DBDeleteEntry(dbConn *sql.DB, id int64) error {
 	log.Printf("Deleted entry with ID: %d", id)
 	return nil
 }
+
+// entryDependents are the tables holding rows that belong to a codex entry. They declare
+// ON DELETE CASCADE, but foreign keys are not enforced on every connection, so
+// DBDeleteEntryCascade deletes the rows itself.
+var entryDependents = []string{
+	"codex_embeddings",
+	"codex_embedding_chunks",
+	"codex_attachments",
+	"codex_aliases",
+	"codex_tags",
+	"cluster_suggestion_entries",
+}
+
+// DBDeleteEntryCascade removes an entry together with its embeddings, passages,
+// attachment records, aliases, tags, cluster memberships and queued embedding job, in one
+// transaction, so a failure leaves no orphan rows. Tables not created yet are skipped.
+// Attachment files on disk are the caller's to remove.
+func DBDeleteEntryCascade(dbConn *sql.DB, id int64) error {
+	tx, err := dbConn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	if err := deleteEntryRows(tx, id); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit deletion of entry %d: %w", id, err)
+	}
+
+	log.Printf("Deleted entry with ID: %d", id)
+	return nil
+}
+
+// deleteEntryRows deletes an entry and its dependent rows inside tx.
+func deleteEntryRows(tx *sql.Tx, id int64) error {
+	existing := make(map[string]bool)
+	rows, err := tx.Query(`SELECT name FROM sqlite_master WHERE type = 'table'`)
+	if err != nil {
+		return fmt.Errorf("failed to list tables: %w", err)
+	}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan table name: %w", err)
+		}
+		existing[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to list tables: %w", err)
+	}
+
+	for _, table := range entryDependents {
+		if !existing[table] {
+			continue
+		}
+		if _, err := tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE codex_entry_id = ?`, table), id); err != nil {
+			return fmt.Errorf("failed to delete %s rows of entry %d: %w", table, id, err)
+		}
+	}
+	if existing["embedding_jobs"] {
+		// A running job is left to its worker, which skips entries that no longer exist
+		if _, err := tx.Exec(`DELETE FROM embedding_jobs WHERE kind = ? AND target = ? AND status != ?`,
+			JobKindEntry, strconv.FormatInt(id, 10), JobRunning); err != nil {
+			return fmt.Errorf("failed to delete embedding job of entry %d: %w", id, err)
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM codex_entries WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete entry %d: %w", id, err)
+	}
+	return nil
+}
+
+// AttachmentMove relinks an attachment to the survivor of a merge under a new filename.
+type AttachmentMove struct {
+	ID       int64
+	Filename string
+}
+
+// DBMergeEntries applies a merge in one transaction: the survivor gets the given name,
+// type, content and aliases plus the tags of the merged entries, the attachments are
+// relinked, and the merged entries are deleted with their dependent rows. Moving the
+// attachment files is the caller's, once this has succeeded.
+func DBMergeEntries(dbConn *sql.DB, survivor CodexEntry, aliases []string, mergedIDs []int64, moves []AttachmentMove) error {
+	tx, err := dbConn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE codex_entries SET name = ?, type = ?, content = ?, updated_at = datetime('now') WHERE id = ?`,
+		survivor.Name, survivor.Type, survivor.Content, survivor.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update entry %d: %w", survivor.ID, err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("no entry found with ID %d to update", survivor.ID)
+	}
+	if _, err := tx.Exec(`DELETE FROM codex_aliases WHERE codex_entry_id = ?`, survivor.ID); err != nil {
+		return fmt.Errorf("failed to clear aliases of entry %d: %w", survivor.ID, err)
+	}
+	for _, alias := range aliases {
+		if alias = strings.TrimSpace(alias); alias == "" {
+			continue
+		}
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO codex_aliases(codex_entry_id, alias) VALUES (?, ?)`, survivor.ID, alias); err != nil {
+			return fmt.Errorf("failed to save alias %q of entry %d: %w", alias, survivor.ID, err)
+		}
+	}
+	for _, id := range mergedIDs {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO codex_tags(codex_entry_id, tag) SELECT ?, tag FROM codex_tags WHERE codex_entry_id = ?`, survivor.ID, id); err != nil {
+			return fmt.Errorf("failed to copy tags of entry %d to %d: %w", id, survivor.ID, err)
+		}
+	}
+	for _, m := range moves {
+		if _, err := tx.Exec(`UPDATE codex_attachments SET codex_entry_id = ?, filename = ?, updated_at = datetime('now') WHERE id = ?`, survivor.ID, m.Filename, m.ID); err != nil {
+			return fmt.Errorf("failed to move attachment %d to entry %d: %w", m.ID, survivor.ID, err)
+		}
+	}
+	for _, id := range mergedIDs {
+		if err := deleteEntryRows(tx, id); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit merge into entry %d: %w", survivor.ID, err)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package database
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := DBInitialize(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func countRows(t *testing.T, db *sql.DB, query string, args ...any) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(query, args...).Scan(&n); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return n
+}
+
+func TestDBDeleteEntryCascade(t *testing.T) {
+	db := openTestDB(t)
+	// Only some dependent tables exist, as in a vault opened by an older version
+	for _, ensure := range []func(*sql.DB) error{DBEnsureAttachmentsTable, DBEnsureAliasesTable, DBEnsureTagsTable, DBEnsureJobsTable, DBEnsureEmbeddingChunksTable} {
+		if err := ensure(db); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keep, err := DBInsertEntry(db, "Keep", "Character", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gone, err := DBInsertEntry(db, "Gone", "Character", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []int64{keep, gone} {
+		if _, err := DBInsertAttachment(db, id, "portrait.png", "image/png"); err != nil {
+			t.Fatal(err)
+		}
+		if err := DBSetAliases(db, id, []string{"alias", "other"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := DBAddTag(db, id, "hero"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := DBEnqueueJobs(db, JobKindEntry, []string{"1", "2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := DBDeleteEntryCascade(db, gone); err != nil {
+		t.Fatal(err)
+	}
+	if n := countRows(t, db, `SELECT COUNT(*) FROM codex_entries WHERE id = ?`, gone); n != 0 {
+		t.Errorf("entry still present")
+	}
+	for _, table := range []string{"codex_attachments", "codex_aliases", "codex_tags"} {
+		if n := countRows(t, db, `SELECT COUNT(*) FROM `+table+` WHERE codex_entry_id = ?`, gone); n != 0 {
+			t.Errorf("%d orphan rows left in %s", n, table)
+		}
+		if n := countRows(t, db, `SELECT COUNT(*) FROM `+table+` WHERE codex_entry_id = ?`, keep); n == 0 {
+			t.Errorf("rows of the other entry deleted from %s", table)
+		}
+	}
+	if n := countRows(t, db, `SELECT COUNT(*) FROM embedding_jobs WHERE target = '2'`); n != 0 {
+		t.Errorf("queued job of the deleted entry left behind")
+	}
+	if n := countRows(t, db, `SELECT COUNT(*) FROM embedding_jobs WHERE target = '1'`); n != 1 {
+		t.Errorf("job of the other entry deleted")
+	}
+}
@@ -0,0 +1,156 @@
+// internal/database/eval.go
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// GoldenQuery is a query with the codex entries a good retrieval must return for it.
+type GoldenQuery struct {
+	ID        int64    `json:"id"`
+	Query     string   `json:"query"`
+	Expected  []string `json:"expected"` // Entry names or aliases
+	CreatedAt string   `json:"createdAt"`
+}
+
+// EvalRunRecord is a stored evaluation run. Config and Results are JSON documents owned
+// by the caller.
+type EvalRunRecord struct {
+	ID        int64
+	CreatedAt string
+	K         int
+	Recall    float64
+	Precision float64
+	MRR       float64
+	Queries   int
+	Config    string
+	Results   string
+}
+
+// DBEnsureEvalTables creates the golden_queries and eval_runs tables if they don't exist.
+func DBEnsureEvalTables(dbConn *sql.DB) error {
+	_, err := dbConn.Exec(`CREATE TABLE IF NOT EXISTS golden_queries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		query TEXT NOT NULL,
+		expected TEXT NOT NULL,
+		created_at TEXT NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create golden_queries table: %w", err)
+	}
+	_, err = dbConn.Exec(`CREATE TABLE IF NOT EXISTS eval_runs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		created_at TEXT NOT NULL,
+		k INTEGER NOT NULL,
+		recall REAL NOT NULL,
+		precision REAL NOT NULL,
+		mrr REAL NOT NULL,
+		queries INTEGER NOT NULL,
+		config TEXT NOT NULL,
+		results TEXT NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create eval_runs table: %w", err)
+	}
+	return nil
+}
+
+// DBListGoldenQueries returns all golden queries, oldest first.
+func DBListGoldenQueries(dbConn *sql.DB) ([]GoldenQuery, error) {
+	rows, err := dbConn.Query(`SELECT id, query, expected, created_at FROM golden_queries ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list golden queries: %w", err)
+	}
+	defer rows.Close()
+	queries := []GoldenQuery{}
+	for rows.Next() {
+		var q GoldenQuery
+		var expected string
+		if err := rows.Scan(&q.ID, &q.Query, &expected, &q.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan golden query: %w", err)
+		}
+		if err := json.Unmarshal([]byte(expected), &q.Expected); err != nil {
+			return nil, fmt.Errorf("failed to read expected entries of golden query %d: %w", q.ID, err)
+		}
+		queries = append(queries, q)
+	}
+	return queries, rows.Err()
+}
+
+// DBSaveGoldenQuery inserts a golden query, or updates it if it has an ID, and returns
+// its ID.
+func DBSaveGoldenQuery(dbConn *sql.DB, q GoldenQuery) (int64, error) {
+	expected, err := json.Marshal(q.Expected)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode expected entries: %w", err)
+	}
+	if q.ID != 0 {
+		result, err := dbConn.Exec(`UPDATE golden_queries SET query = ?, expected = ? WHERE id = ?`, q.Query, string(expected), q.ID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to update golden query %d: %w", q.ID, err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return 0, fmt.Errorf("golden query %d not found", q.ID)
+		}
+		return q.ID, nil
+	}
+	result, err := dbConn.Exec(`INSERT INTO golden_queries(query, expected, created_at) VALUES (?, ?, datetime('now'))`, q.Query, string(expected))
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert golden query: %w", err)
+	}
+	return result.LastInsertId()
+}
+
+// DBDeleteGoldenQuery removes a golden query.
+func DBDeleteGoldenQuery(dbConn *sql.DB, id int64) error {
+	if _, err := dbConn.Exec(`DELETE FROM golden_queries WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete golden query %d: %w", id, err)
+	}
+	return nil
+}
+
+// DBInsertEvalRun stores an evaluation run and returns its ID.
+func DBInsertEvalRun(dbConn *sql.DB, run EvalRunRecord) (int64, error) {
+	result, err := dbConn.Exec(
+		`INSERT INTO eval_runs(created_at, k, recall, precision, mrr, queries, config, results)
+		 VALUES (datetime('now'), ?, ?, ?, ?, ?, ?, ?)`,
+		run.K, run.Recall, run.Precision, run.MRR, run.Queries, run.Config, run.Results,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to save evaluation run: %w", err)
+	}
+	return result.LastInsertId()
+}
+
+// DBListEvalRuns returns the most recent evaluation runs, newest first. limit <= 0
+// returns all of them.
+func DBListEvalRuns(dbConn *sql.DB, limit int) ([]EvalRunRecord, error) {
+	if limit <= 0 {
+		limit = -1 // No limit in SQLite
+	}
+	rows, err := dbConn.Query(`SELECT id, created_at, k, recall, precision, mrr, queries, config, results
+		FROM eval_runs ORDER BY id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list evaluation runs: %w", err)
+	}
+	defer rows.Close()
+	var runs []EvalRunRecord
+	for rows.Next() {
+		var r EvalRunRecord
+		if err := rows.Scan(&r.ID, &r.CreatedAt, &r.K, &r.Recall, &r.Precision, &r.MRR, &r.Queries, &r.Config, &r.Results); err != nil {
+			return nil, fmt.Errorf("failed to scan evaluation run: %w", err)
+		}
+		runs = append(runs, r)
+	}
+	return runs, rows.Err()
+}
+
+// DBDeleteEvalRun removes an evaluation run from the history.
+func DBDeleteEvalRun(dbConn *sql.DB, id int64) error {
+	if _, err := dbConn.Exec(`DELETE FROM eval_runs WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete evaluation run %d: %w", id, err)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+// internal/database/fts.go
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+)
+
+// KeywordHit is a codex entry matched by full-text search. Score is the negated BM25
+// rank, so higher is better like the vector similarity scores.
+type KeywordHit struct {
+	Entry CodexEntry `json:"entry"`
+	Score float64    `json:"score"`
+}
+
+// DBEnsureFTSIndex creates the FTS5 index over codex_entries and the triggers that keep
+// it in sync. The index is external-content: it stores only the inverted index and reads
+// rows back from codex_entries. An empty index over a non-empty table is rebuilt, which
+// covers vaults created before the index existed.
+func DBEnsureFTSIndex(dbConn *sql.DB) error {
+	statements := []string{
+		`CREATE VIRTUAL TABLE IF NOT EXISTS codex_entries_fts USING fts5(
+			name, type, content,
+			content='codex_entries', content_rowid='id',
+			tokenize='unicode61 remove_diacritics 2'
+		)`,
+		`CREATE TRIGGER IF NOT EXISTS codex_entries_fts_insert AFTER INSERT ON codex_entries BEGIN
+			INSERT INTO codex_entries_fts(rowid, name, type, content) VALUES (new.id, new.name, new.type, new.content);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS codex_entries_fts_delete AFTER DELETE ON codex_entries BEGIN
+			INSERT INTO codex_entries_fts(codex_entries_fts, rowid, name, type, content) VALUES ('delete', old.id, old.name, old.type, old.content);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS codex_entries_fts_update AFTER UPDATE ON codex_entries BEGIN
+			INSERT INTO codex_entries_fts(codex_entries_fts, rowid, name, type, content) VALUES ('delete', old.id, old.name, old.type, old.content);
+			INSERT INTO codex_entries_fts(rowid, name, type, content) VALUES (new.id, new.name, new.type, new.content);
+		END`,
+	}
+	for _, stmt := range statements {
+		if _, err := dbConn.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to create full-text index: %w", err)
+		}
+	}
+
+	// External-content tables report the content table's rows from a plain COUNT, so
+	// check the index's own document-size table to see whether it was ever populated.
+	var indexed, entries int
+	if err := dbConn.QueryRow(`SELECT COUNT(*) FROM codex_entries_fts_docsize`).Scan(&indexed); err != nil {
+		return fmt.Errorf("failed to inspect full-text index: %w", err)
+	}
+	if err := dbConn.QueryRow(`SELECT COUNT(*) FROM codex_entries`).Scan(&entries); err != nil {
+		return fmt.Errorf("failed to count codex entries: %w", err)
+	}
+	if indexed != entries {
+		log.Printf("Rebuilding full-text index (%d of %d entries indexed)", indexed, entries)
+		if _, err := dbConn.Exec(`INSERT INTO codex_entries_fts(codex_entries_fts) VALUES ('rebuild')`); err != nil {
+			return fmt.Errorf("failed to rebuild full-text index: %w", err)
+		}
+	}
+	return nil
+}
+
+// FTSMatchQuery turns free text into a safe FTS5 MATCH expression: every word becomes
+// a quoted term and terms are ORed, leaving ranking to BM25. Returns "" when the text
+// has no searchable words.
+func FTSMatchQuery(text string) string {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	seen := make(map[string]bool, len(words))
+	terms := make([]string, 0, len(words))
+	for _, w := range words {
+		w = strings.ToLower(w)
+		if seen[w] {
+			continue
+		}
+		seen[w] = true
+		terms = append(terms, `"`+w+`"`)
+	}
+	return strings.Join(terms, " OR ")
+}
+
+// DBKeywordSearch ranks entries against query with BM25. Matches in the name count ten
+// times as much as matches in the type or content.
+func DBKeywordSearch(dbConn *sql.DB, query string, limit int) ([]KeywordHit, error) {
+	match := FTSMatchQuery(query)
+	if match == "" {
+		return []KeywordHit{}, nil
+	}
+	if limit <= 0 {
+		limit = 20
+	}
+
+	rows, err := dbConn.Query(`
+		SELECT e.id, e.name, e.type, e.content, e.created_at, e.updated_at,
+		       bm25(codex_entries_fts, 10.0, 1.0, 1.0) AS rank
+		FROM codex_entries_fts
+		JOIN codex_entries e ON e.id = codex_entries_fts.rowid
+		WHERE codex_entries_fts MATCH ?
+		ORDER BY rank
+		LIMIT ?`, match, limit)
+	if err != nil {
+		return nil, fmt.Errorf("keyword search failed: %w", err)
+	}
+	defer rows.Close()
+
+	hits := []KeywordHit{}
+	for rows.Next() {
+		var hit KeywordHit
+		var rank float64
+		if err := rows.Scan(&hit.Entry.ID, &hit.Entry.Name, &hit.Entry.Type, &hit.Entry.Content, &hit.Entry.CreatedAt, &hit.Entry.UpdatedAt, &rank); err != nil {
+			return nil, fmt.Errorf("failed to scan keyword hit: %w", err)
+		}
+		hit.Score = -rank // bm25() is lower-is-better
+		hits = append(hits, hit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating keyword hits: %w", err)
+	}
+	return hits, nil
+}
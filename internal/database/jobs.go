@@ -0,0 +1,332 @@
+// internal/database/jobs.go
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Embedding job statuses. Finished jobs are deleted, so there is no "done" status.
+const (
+	JobQueued  = "queued"
+	JobRunning = "running"
+	JobFailed  = "failed"
+)
+
+// JobKindEntry is the kind of job that embeds a codex entry; its target is the entry ID.
+const JobKindEntry = "entry"
+
+// EmbeddingJob is a unit of background embedding work persisted in the vault, so it
+// survives restarts. Target identifies what to embed: an entry ID, a Library path or a
+// chat log filename, depending on Kind.
+type EmbeddingJob struct {
+	ID            int64  `json:"id"`
+	Kind          string `json:"kind"`
+	Target        string `json:"target"`
+	Status        string `json:"status"`
+	Attempts      int    `json:"attempts"`
+	LastError     string `json:"lastError"`
+	NextAttemptAt string `json:"nextAttemptAt"`
+	CreatedAt     string `json:"createdAt"`
+	UpdatedAt     string `json:"updatedAt"`
+}
+
+// JobCounts is the number of jobs in each status.
+type JobCounts struct {
+	Queued  int `json:"queued"`
+	Running int `json:"running"`
+	Failed  int `json:"failed"`
+}
+
+// DBEnsureJobsTable creates the embedding job table. Jobs left running by a previous
+// process that exited mid-batch are put back in the queue.
+func DBEnsureJobsTable(dbConn *sql.DB) error {
+	_, err := dbConn.Exec(`CREATE TABLE IF NOT EXISTS embedding_jobs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		kind TEXT NOT NULL,
+		target TEXT NOT NULL,
+		status TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT NOT NULL DEFAULT '',
+		requeue INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at DATETIME NOT NULL,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		UNIQUE (kind, target)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create embedding_jobs table: %w", err)
+	}
+	if _, err := dbConn.Exec(`CREATE INDEX IF NOT EXISTS idx_embedding_jobs_status ON embedding_jobs(status, next_attempt_at)`); err != nil {
+		return fmt.Errorf("failed to create embedding_jobs index: %w", err)
+	}
+	if _, err := dbConn.Exec(`UPDATE embedding_jobs SET status = ?, requeue = 0, updated_at = datetime('now') WHERE status = ?`, JobQueued, JobRunning); err != nil {
+		return fmt.Errorf("failed to requeue interrupted embedding jobs: %w", err)
+	}
+	return nil
+}
+
+// DBEnqueueJob adds a job, or resets an existing job for the same target so that many
+// edits to one entry collapse into a single pending job. Returns true if the job is new.
+func DBEnqueueJob(dbConn *sql.DB, kind, target string) (bool, error) {
+	return enqueueJob(dbConn, kind, target)
+}
+
+// DBEnqueueJobs enqueues many jobs of one kind in a single transaction and returns how
+// many were new.
+func DBEnqueueJobs(dbConn *sql.DB, kind string, targets []string) (int, error) {
+	tx, err := dbConn.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin enqueue: %w", err)
+	}
+	defer tx.Rollback()
+
+	added := 0
+	for _, target := range targets {
+		isNew, err := enqueueJob(tx, kind, target)
+		if err != nil {
+			return 0, err
+		}
+		if isNew {
+			added++
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit enqueued %s jobs: %w", kind, err)
+	}
+	return added, nil
+}
+
+// jobExecer is satisfied by both *sql.DB and *sql.Tx.
+type jobExecer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func enqueueJob(dbConn jobExecer, kind, target string) (bool, error) {
+	result, err := dbConn.Exec(
+		`INSERT INTO embedding_jobs (kind, target, status, attempts, last_error, next_attempt_at, created_at, updated_at)
+		 VALUES (?, ?, ?, 0, '', datetime('now'), datetime('now'), datetime('now'))
+		 ON CONFLICT(kind, target) DO NOTHING`,
+		kind, target, JobQueued,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to enqueue %s job for %s: %w", kind, target, err)
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		return true, nil
+	}
+	// A running job may already have read the old text, so it is flagged to run again
+	// once it finishes (see DBCompleteJob) instead of being reset under the worker.
+	_, err = dbConn.Exec(
+		`UPDATE embedding_jobs SET requeue = 1, updated_at = datetime('now') WHERE kind = ? AND target = ? AND status = ?`,
+		kind, target, JobRunning,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to re-enqueue %s job for %s: %w", kind, target, err)
+	}
+	_, err = dbConn.Exec(
+		`UPDATE embedding_jobs SET status = ?, attempts = 0, last_error = '', next_attempt_at = datetime('now'), updated_at = datetime('now')
+		 WHERE kind = ? AND target = ? AND status != ?`,
+		JobQueued, kind, target, JobRunning,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to re-enqueue %s job for %s: %w", kind, target, err)
+	}
+	return false, nil
+}
+
+// DBClaimJobs marks up to limit due jobs of one kind as running and returns them. The
+// kind is that of the oldest due job, so each claim is a homogeneous batch.
+func DBClaimJobs(dbConn *sql.DB, limit int) ([]EmbeddingJob, error) {
+	tx, err := dbConn.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin job claim: %w", err)
+	}
+	defer tx.Rollback()
+
+	var kind string
+	err = tx.QueryRow(`SELECT kind FROM embedding_jobs WHERE status = ? AND next_attempt_at <= datetime('now') ORDER BY id LIMIT 1`, JobQueued).Scan(&kind)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find due jobs: %w", err)
+	}
+
+	rows, err := tx.Query(
+		`SELECT id, kind, target, status, attempts, last_error, next_attempt_at, created_at, updated_at
+		 FROM embedding_jobs WHERE status = ? AND kind = ? AND next_attempt_at <= datetime('now') ORDER BY id LIMIT ?`,
+		JobQueued, kind, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query due jobs: %w", err)
+	}
+	var jobs []EmbeddingJob
+	for rows.Next() {
+		var j EmbeddingJob
+		if err := rows.Scan(&j.ID, &j.Kind, &j.Target, &j.Status, &j.Attempts, &j.LastError, &j.NextAttemptAt, &j.CreatedAt, &j.UpdatedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan job: %w", err)
+		}
+		jobs = append(jobs, j)
+	}
+	rows.Close()
+
+	ids := make([]interface{}, 0, len(jobs)+1)
+	ids = append(ids, JobRunning)
+	for i := range jobs {
+		jobs[i].Status = JobRunning
+		ids = append(ids, jobs[i].ID)
+	}
+	if len(jobs) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(jobs)), ",")
+		if _, err := tx.Exec(`UPDATE embedding_jobs SET status = ?, updated_at = datetime('now') WHERE id IN (`+placeholders+`)`, ids...); err != nil {
+			return nil, fmt.Errorf("failed to claim jobs: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit job claim: %w", err)
+	}
+	return jobs, nil
+}
+
+// DBCompleteJob removes a finished job, unless it was re-enqueued while running, in
+// which case it goes back to the queue. Returns true if the job was removed.
+func DBCompleteJob(dbConn *sql.DB, jobID int64) (bool, error) {
+	result, err := dbConn.Exec(`DELETE FROM embedding_jobs WHERE id = ? AND requeue = 0`, jobID)
+	if err != nil {
+		return false, fmt.Errorf("failed to complete job %d: %w", jobID, err)
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		return true, nil
+	}
+	_, err = dbConn.Exec(
+		`UPDATE embedding_jobs SET status = ?, requeue = 0, attempts = 0, last_error = '', next_attempt_at = datetime('now'), updated_at = datetime('now') WHERE id = ?`,
+		JobQueued, jobID,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to requeue job %d: %w", jobID, err)
+	}
+	return false, nil
+}
+
+// DBRetryJobLater records a failed attempt and schedules the next one after delay. A job
+// re-enqueued while it ran has fresh content, so like in DBFailJob it starts over with no
+// attempts counted and no delay.
+func DBRetryJobLater(dbConn *sql.DB, jobID int64, attempts int, lastError string, delay time.Duration) error {
+	_, err := dbConn.Exec(
+		`UPDATE embedding_jobs SET status = ?, attempts = CASE WHEN requeue = 1 THEN 0 ELSE ? END, last_error = ?,
+		 next_attempt_at = CASE WHEN requeue = 1 THEN datetime('now') ELSE datetime('now', ?) END,
+		 requeue = 0, updated_at = datetime('now') WHERE id = ?`,
+		JobQueued, attempts, lastError, fmt.Sprintf("+%d seconds", int(delay.Seconds())), jobID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to reschedule job %d: %w", jobID, err)
+	}
+	return nil
+}
+
+// DBFailJob marks a job as permanently failed. It stays in the table until retried or
+// cleared. A job re-enqueued while it ran is given a fresh start instead.
+func DBFailJob(dbConn *sql.DB, jobID int64, attempts int, lastError string) error {
+	_, err := dbConn.Exec(
+		`UPDATE embedding_jobs SET status = CASE WHEN requeue = 1 THEN ? ELSE ? END,
+		 attempts = CASE WHEN requeue = 1 THEN 0 ELSE ? END, last_error = ?, requeue = 0,
+		 next_attempt_at = datetime('now'), updated_at = datetime('now') WHERE id = ?`,
+		JobQueued, JobFailed, attempts, lastError, jobID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to mark job %d as failed: %w", jobID, err)
+	}
+	return nil
+}
+
+// DBCountJobs returns the number of jobs in each status.
+func DBCountJobs(dbConn *sql.DB) (JobCounts, error) {
+	var counts JobCounts
+	rows, err := dbConn.Query(`SELECT status, COUNT(*) FROM embedding_jobs GROUP BY status`)
+	if err != nil {
+		return counts, fmt.Errorf("failed to count jobs: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var status string
+		var n int
+		if err := rows.Scan(&status, &n); err != nil {
+			return counts, fmt.Errorf("failed to scan job count: %w", err)
+		}
+		switch status {
+		case JobQueued:
+			counts.Queued = n
+		case JobRunning:
+			counts.Running = n
+		case JobFailed:
+			counts.Failed = n
+		}
+	}
+	return counts, rows.Err()
+}
+
+// DBNextJobDue returns how long until the earliest queued job is due, or false when no
+// jobs are queued.
+func DBNextJobDue(dbConn *sql.DB) (time.Duration, bool, error) {
+	var seconds sql.NullFloat64
+	err := dbConn.QueryRow(
+		`SELECT MIN((julianday(next_attempt_at) - julianday('now')) * 86400.0) FROM embedding_jobs WHERE status = ?`, JobQueued,
+	).Scan(&seconds)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to find next due job: %w", err)
+	}
+	if !seconds.Valid {
+		return 0, false, nil
+	}
+	if seconds.Float64 < 0 {
+		return 0, true, nil
+	}
+	return time.Duration(seconds.Float64 * float64(time.Second)), true, nil
+}
+
+// DBListJobs returns jobs with the given status, oldest first.
+func DBListJobs(dbConn *sql.DB, status string) ([]EmbeddingJob, error) {
+	rows, err := dbConn.Query(
+		`SELECT id, kind, target, status, attempts, last_error, next_attempt_at, created_at, updated_at
+		 FROM embedding_jobs WHERE status = ? ORDER BY id`, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+	defer rows.Close()
+	jobs := []EmbeddingJob{}
+	for rows.Next() {
+		var j EmbeddingJob
+		if err := rows.Scan(&j.ID, &j.Kind, &j.Target, &j.Status, &j.Attempts, &j.LastError, &j.NextAttemptAt, &j.CreatedAt, &j.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan job: %w", err)
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+// DBRetryFailedJobs puts every failed job back in the queue with a fresh attempt count.
+func DBRetryFailedJobs(dbConn *sql.DB) (int, error) {
+	result, err := dbConn.Exec(
+		`UPDATE embedding_jobs SET status = ?, attempts = 0, next_attempt_at = datetime('now'), updated_at = datetime('now') WHERE status = ?`,
+		JobQueued, JobFailed,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to retry failed jobs: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return int(n), nil
+}
+
+// DBClearFailedJobs deletes every failed job.
+func DBClearFailedJobs(dbConn *sql.DB) (int, error) {
+	result, err := dbConn.Exec(`DELETE FROM embedding_jobs WHERE status = ?`, JobFailed)
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear failed jobs: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return int(n), nil
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDBRetryJobLaterKeepsRequeue(t *testing.T) {
+	db := openTestDB(t)
+	if err := DBEnsureJobsTable(db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DBEnqueueJobs(db, JobKindEntry, []string{"1", "2"}); err != nil {
+		t.Fatal(err)
+	}
+	jobs, err := DBClaimJobs(db, 10)
+	if err != nil || len(jobs) != 2 {
+		t.Fatalf("claimed %d jobs: %v", len(jobs), err)
+	}
+	// Entry 1 is edited while its job runs
+	if _, err := DBEnqueueJob(db, JobKindEntry, "1"); err != nil {
+		t.Fatal(err)
+	}
+	for _, job := range jobs {
+		if err := DBRetryJobLater(db, job.ID, 4, "provider unavailable", time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	due, err := DBClaimJobs(db, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(due) != 1 || due[0].Target != "1" {
+		t.Fatalf("due jobs = %+v, want only the re-enqueued entry 1", due)
+	}
+	if due[0].Attempts != 0 {
+		t.Errorf("re-enqueued job kept %d attempts, want 0", due[0].Attempts)
+	}
+	if n := countRows(t, db, `SELECT COUNT(*) FROM embedding_jobs WHERE target = '2' AND attempts = 4 AND requeue = 0`); n != 1 {
+		t.Error("job without fresh content lost its attempt count")
+	}
+}
+
+func TestDBFailJobKeepsRequeue(t *testing.T) {
+	db := openTestDB(t)
+	if err := DBEnsureJobsTable(db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DBEnqueueJobs(db, JobKindEntry, []string{"1", "2"}); err != nil {
+		t.Fatal(err)
+	}
+	jobs, err := DBClaimJobs(db, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DBEnqueueJob(db, JobKindEntry, "1"); err != nil {
+		t.Fatal(err)
+	}
+	for _, job := range jobs {
+		if err := DBFailJob(db, job.ID, 5, "bad key"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	counts, err := DBCountJobs(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts.Queued != 1 || counts.Failed != 1 {
+		t.Errorf("counts = %+v, want 1 queued and 1 failed", counts)
+	}
+}
@@ -0,0 +1,91 @@
+// internal/database/library_chunks.go
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// DBEnsureLibraryIndexTables creates the tables behind manuscript retrieval: one row per
+// indexed Library file recording the hash of the text that was embedded, and one row per
+// embedded passage. Paths are relative to the Library folder and use forward slashes.
+func DBEnsureLibraryIndexTables(dbConn *sql.DB) error {
+	_, err := dbConn.Exec(`CREATE TABLE IF NOT EXISTS library_documents (
+		file_path TEXT NOT NULL,
+		vector_version TEXT NOT NULL,
+		content_hash TEXT NOT NULL,
+		indexed_at DATETIME NOT NULL,
+		PRIMARY KEY (file_path, vector_version)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create library_documents table: %w", err)
+	}
+	_, err = dbConn.Exec(`CREATE TABLE IF NOT EXISTS library_chunks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		file_path TEXT NOT NULL,
+		vector_version TEXT NOT NULL,
+		chunk_index INTEGER NOT NULL,
+		start_offset INTEGER NOT NULL,
+		end_offset INTEGER NOT NULL,
+		start_line INTEGER NOT NULL,
+		end_line INTEGER NOT NULL,
+		chunk_text TEXT NOT NULL,
+		embedding BLOB NOT NULL,
+		created_at DATETIME NOT NULL,
+		UNIQUE (file_path, vector_version, chunk_index)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create library_chunks table: %w", err)
+	}
+	if _, err := dbConn.Exec(`CREATE INDEX IF NOT EXISTS idx_library_chunks_version ON library_chunks(vector_version)`); err != nil {
+		return fmt.Errorf("failed to create library_chunks index: %w", err)
+	}
+	return nil
+}
+
+// DBRemoveLibraryPath drops the passages of a file, or of every file under a folder, for
+// all providers.
+func DBRemoveLibraryPath(dbConn *sql.DB, path string) error {
+	prefix := escapeLike(path) + "/%"
+	for _, table := range []string{"library_chunks", "library_documents"} {
+		query := fmt.Sprintf(`DELETE FROM %s WHERE file_path = ? OR file_path LIKE ? ESCAPE '\'`, table)
+		if _, err := dbConn.Exec(query, path, prefix); err != nil {
+			return fmt.Errorf("failed to remove %s from library index: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// DBMoveLibraryPath re-points the passages of a moved file or folder without re-embedding.
+func DBMoveLibraryPath(dbConn *sql.DB, sourcePath, destPath string) error {
+	tx, err := dbConn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin library move transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	prefix := escapeLike(sourcePath) + "/%"
+	for _, table := range []string{"library_chunks", "library_documents"} {
+		// Anything already indexed at the destination is stale now
+		query := fmt.Sprintf(`DELETE FROM %s WHERE file_path = ? OR file_path LIKE ? ESCAPE '\'`, table)
+		if _, err := tx.Exec(query, destPath, escapeLike(destPath)+"/%"); err != nil {
+			return fmt.Errorf("failed to clear library index at %s: %w", destPath, err)
+		}
+		// substr counts characters, not bytes
+		query = fmt.Sprintf(`UPDATE %s SET file_path = ? || substr(file_path, ?) WHERE file_path = ? OR file_path LIKE ? ESCAPE '\'`, table)
+		if _, err := tx.Exec(query, destPath, utf8.RuneCountInString(sourcePath)+1, sourcePath, prefix); err != nil {
+			return fmt.Errorf("failed to move %s in library index: %w", sourcePath, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit library move: %w", err)
+	}
+	return nil
+}
+
+// escapeLike escapes LIKE wildcards so a path matches literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
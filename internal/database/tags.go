@@ -0,0 +1,119 @@
+// internal/database/tags.go
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// TagCount is a tag and how many entries carry it.
+type TagCount struct {
+	Tag     string `json:"tag"`
+	Entries int    `json:"entries"`
+}
+
+// DBEnsureTagsTable creates the codex_tags table if it doesn't exist.
+func DBEnsureTagsTable(dbConn *sql.DB) error {
+	_, err := dbConn.Exec(`CREATE TABLE IF NOT EXISTS codex_tags (
+		codex_entry_id INTEGER NOT NULL,
+		tag TEXT NOT NULL COLLATE NOCASE,
+		FOREIGN KEY(codex_entry_id) REFERENCES codex_entries(id) ON DELETE CASCADE,
+		UNIQUE (codex_entry_id, tag)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create codex_tags table: %w", err)
+	}
+	return nil
+}
+
+// DBGetTags returns the tags of an entry in alphabetical order.
+func DBGetTags(dbConn *sql.DB, entryID int64) ([]string, error) {
+	rows, err := dbConn.Query(`SELECT tag FROM codex_tags WHERE codex_entry_id = ? ORDER BY tag`, entryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags of entry %d: %w", entryID, err)
+	}
+	defer rows.Close()
+	tags := []string{}
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// DBSetTags replaces the tags of an entry. Blank and repeated tags are dropped.
+func DBSetTags(dbConn *sql.DB, entryID int64, tags []string) error {
+	tx, err := dbConn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM codex_tags WHERE codex_entry_id = ?`, entryID); err != nil {
+		return fmt.Errorf("failed to clear tags of entry %d: %w", entryID, err)
+	}
+	for _, tag := range tags {
+		if tag = strings.TrimSpace(tag); tag == "" {
+			continue
+		}
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO codex_tags(codex_entry_id, tag) VALUES (?, ?)`, entryID, tag); err != nil {
+			return fmt.Errorf("failed to save tag %q of entry %d: %w", tag, entryID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// DBAddTag tags an entry, if it isn't already.
+func DBAddTag(dbConn *sql.DB, entryID int64, tag string) error {
+	tag = strings.TrimSpace(tag)
+	if tag == "" {
+		return fmt.Errorf("tag cannot be empty")
+	}
+	if _, err := dbConn.Exec(`INSERT OR IGNORE INTO codex_tags(codex_entry_id, tag) VALUES (?, ?)`, entryID, tag); err != nil {
+		return fmt.Errorf("failed to tag entry %d with %q: %w", entryID, tag, err)
+	}
+	return nil
+}
+
+// DBListTags returns every tag in use with its number of entries, most used first.
+func DBListTags(dbConn *sql.DB) ([]TagCount, error) {
+	rows, err := dbConn.Query(`
+		SELECT t.tag, COUNT(*) FROM codex_tags t
+		JOIN codex_entries e ON e.id = t.codex_entry_id
+		GROUP BY t.tag ORDER BY COUNT(*) DESC, t.tag`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	defer rows.Close()
+	tags := []TagCount{}
+	for rows.Next() {
+		var tc TagCount
+		if err := rows.Scan(&tc.Tag, &tc.Entries); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, tc)
+	}
+	return tags, rows.Err()
+}
+
+// DBAllEntryTags returns the tags of every tagged entry.
+func DBAllEntryTags(dbConn *sql.DB) (map[int64][]string, error) {
+	rows, err := dbConn.Query(`SELECT codex_entry_id, tag FROM codex_tags ORDER BY codex_entry_id, tag`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load entry tags: %w", err)
+	}
+	defer rows.Close()
+	tags := make(map[int64][]string)
+	for rows.Next() {
+		var id int64
+		var tag string
+		if err := rows.Scan(&id, &tag); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags[id] = append(tags[id], tag)
+	}
+	return tags, rows.Err()
+}
@@ -0,0 +1,55 @@
+// internal/database/vault_settings.go
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// DBEnsureVaultSettingsTable creates the key/value table for settings that belong to a
+// vault rather than to the application config.
+func DBEnsureVaultSettingsTable(dbConn *sql.DB) error {
+	_, err := dbConn.Exec(`CREATE TABLE IF NOT EXISTS vault_settings (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL,
+		updated_at DATETIME NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create vault_settings table: %w", err)
+	}
+	return nil
+}
+
+// DBGetVaultSetting returns a vault setting, or false if it was never set.
+func DBGetVaultSetting(dbConn *sql.DB, key string) (string, bool, error) {
+	var value string
+	err := dbConn.QueryRow(`SELECT value FROM vault_settings WHERE key = ?`, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read vault setting %s: %w", key, err)
+	}
+	return value, true, nil
+}
+
+// DBSetVaultSetting stores a vault setting, replacing any previous value.
+func DBSetVaultSetting(dbConn *sql.DB, key, value string) error {
+	_, err := dbConn.Exec(
+		`INSERT INTO vault_settings (key, value, updated_at) VALUES (?, ?, datetime('now'))
+		 ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`,
+		key, value,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save vault setting %s: %w", key, err)
+	}
+	return nil
+}
+
+// DBDeleteVaultSetting removes a vault setting, if it was set.
+func DBDeleteVaultSetting(dbConn *sql.DB, key string) error {
+	if _, err := dbConn.Exec(`DELETE FROM vault_settings WHERE key = ?`, key); err != nil {
+		return fmt.Errorf("failed to delete vault setting %s: %w", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,281 @@
+// internal/database/vector_versions.go
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// vectorTables are the tables whose rows belong to one vector_version (one embedding
+// provider and model).
+var vectorTables = []string{"codex_embeddings", "codex_embedding_chunks", "library_chunks", "library_documents", "chat_memory"}
+
+// VectorVersionUsage counts the rows stored for one vector_version.
+type VectorVersionUsage struct {
+	VectorVersion      string `json:"vectorVersion"`
+	Entries            int    `json:"entries"`            // Codex entries with an embedding
+	Passages           int    `json:"passages"`           // Passage embeddings of long entries
+	ManuscriptPassages int    `json:"manuscriptPassages"` // Passage embeddings of Library files
+	ChatMemories       int    `json:"chatMemories"`       // Embedded chat exchange passages
+	Bytes              int64  `json:"bytes"`              // Total size of the stored vectors
+}
+
+// DBEnsureEmbeddingHashColumn adds content_hash to codex_embeddings in vaults created
+// before it existed. Those rows keep an empty hash, meaning "unverified".
+func DBEnsureEmbeddingHashColumn(dbConn *sql.DB) error {
+	rows, err := dbConn.Query(`PRAGMA table_info(codex_embeddings)`)
+	if err != nil {
+		return fmt.Errorf("failed to inspect codex_embeddings: %w", err)
+	}
+	found := false
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dflt, &pk); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan codex_embeddings column: %w", err)
+		}
+		if name == "content_hash" {
+			found = true
+		}
+	}
+	rows.Close()
+	if found {
+		return nil
+	}
+	if _, err := dbConn.Exec(`ALTER TABLE codex_embeddings ADD COLUMN content_hash TEXT NOT NULL DEFAULT ''`); err != nil {
+		return fmt.Errorf("failed to add content_hash to codex_embeddings: %w", err)
+	}
+	return nil
+}
+
+// DBListVectorVersions reports every vector_version that has stored vectors, with row
+// counts per kind, ordered by version name.
+func DBListVectorVersions(dbConn *sql.DB) ([]VectorVersionUsage, error) {
+	usage := make(map[string]*VectorVersionUsage)
+	get := func(version string) *VectorVersionUsage {
+		if u, ok := usage[version]; ok {
+			return u
+		}
+		u := &VectorVersionUsage{VectorVersion: version}
+		usage[version] = u
+		return u
+	}
+
+	counts := []struct {
+		table string
+		add   func(u *VectorVersionUsage, n int)
+	}{
+		{"codex_embeddings", func(u *VectorVersionUsage, n int) { u.Entries += n }},
+		{"codex_embedding_chunks", func(u *VectorVersionUsage, n int) { u.Passages += n }},
+		{"library_chunks", func(u *VectorVersionUsage, n int) { u.ManuscriptPassages += n }},
+		{"chat_memory", func(u *VectorVersionUsage, n int) { u.ChatMemories += n }},
+	}
+	for _, c := range counts {
+		rows, err := dbConn.Query(fmt.Sprintf(`SELECT vector_version, COUNT(*), COALESCE(SUM(length(embedding)), 0) FROM %s GROUP BY vector_version`, c.table))
+		if err != nil {
+			return nil, fmt.Errorf("failed to count vectors in %s: %w", c.table, err)
+		}
+		for rows.Next() {
+			var version string
+			var n int
+			var bytes int64
+			if err := rows.Scan(&version, &n, &bytes); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan vector counts: %w", err)
+			}
+			u := get(version)
+			c.add(u, n)
+			u.Bytes += bytes
+		}
+		rows.Close()
+	}
+
+	result := make([]VectorVersionUsage, 0, len(usage))
+	for _, u := range usage {
+		result = append(result, *u)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].VectorVersion < result[j].VectorVersion })
+	return result, nil
+}
+
+// DBPurgeVectorVersion deletes every row stored for a vector_version in one transaction
+// and returns how many rows were removed.
+func DBPurgeVectorVersion(dbConn *sql.DB, version string) (int64, error) {
+	tx, err := dbConn.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin purge: %w", err)
+	}
+	defer tx.Rollback()
+
+	var removed int64
+	for _, table := range vectorTables {
+		result, err := tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE vector_version = ?`, table), version)
+		if err != nil {
+			return 0, fmt.Errorf("failed to purge %s from %s: %w", version, table, err)
+		}
+		n, _ := result.RowsAffected()
+		removed += n
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit purge of %s: %w", version, err)
+	}
+	return removed, nil
+}
+
+// DBListEmbeddingHashes returns the stored content hash of every entry embedding, keyed
+// by vector_version and then entry ID.
+func DBListEmbeddingHashes(dbConn *sql.DB) (map[string]map[int64]string, error) {
+	rows, err := dbConn.Query(`SELECT vector_version, codex_entry_id, content_hash FROM codex_embeddings`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query embedding hashes: %w", err)
+	}
+	defer rows.Close()
+
+	hashes := make(map[string]map[int64]string)
+	for rows.Next() {
+		var version, hash string
+		var id int64
+		if err := rows.Scan(&version, &id, &hash); err != nil {
+			return nil, fmt.Errorf("failed to scan embedding hash: %w", err)
+		}
+		if hashes[version] == nil {
+			hashes[version] = make(map[int64]string)
+		}
+		hashes[version][id] = hash
+	}
+	return hashes, rows.Err()
+}
+
+// tableColumns lists a table's columns in declaration order.
+func tableColumns(q interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}, table string) ([]string, error) {
+	rows, err := q.Query(fmt.Sprintf(`PRAGMA table_info(%s)`, table))
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect %s: %w", table, err)
+	}
+	defer rows.Close()
+	var columns []string
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dflt, &pk); err != nil {
+			return nil, fmt.Errorf("failed to scan %s column: %w", table, err)
+		}
+		columns = append(columns, name)
+	}
+	return columns, rows.Err()
+}
+
+// DBDeriveVectorVersion copies every row of one vector_version to another in a single
+// transaction, passing each embedding through transform. This lets a vault switch to
+// vectors computed from the ones it has (e.g. truncated) without calling the provider.
+// Tables that already hold rows for the target version are left alone. It returns how
+// many rows were copied.
+func DBDeriveVectorVersion(dbConn *sql.DB, from, to string, transform func([]byte) []byte) (int64, error) {
+	const pageSize = 500
+
+	tx, err := dbConn.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin deriving %s: %w", to, err)
+	}
+	defer tx.Rollback()
+
+	var copied int64
+	for _, table := range vectorTables {
+		var existing int
+		if err := tx.QueryRow(fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE vector_version = ?`, table), to).Scan(&existing); err != nil {
+			return 0, fmt.Errorf("failed to count %s rows in %s: %w", to, table, err)
+		}
+		if existing > 0 {
+			continue
+		}
+
+		all, err := tableColumns(tx, table)
+		if err != nil {
+			return 0, err
+		}
+		var columns []string
+		hasID, versionAt, embeddingAt := false, -1, -1
+		for _, c := range all {
+			switch c {
+			case "id":
+				hasID = true
+				continue
+			case "vector_version":
+				versionAt = len(columns)
+			case "embedding":
+				embeddingAt = len(columns)
+			}
+			columns = append(columns, c)
+		}
+		if versionAt < 0 {
+			continue
+		}
+		list := strings.Join(columns, ", ")
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+		insert := fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s)`, table, list, placeholders)
+
+		// Rows are read a page at a time and fully consumed before inserting, since the
+		// vault has a single connection
+		lastID := int64(-1)
+		for {
+			query := fmt.Sprintf(`SELECT %s FROM %s WHERE vector_version = ?`, list, table)
+			args := []any{from}
+			if hasID {
+				query = fmt.Sprintf(`SELECT id, %s FROM %s WHERE vector_version = ? AND id > ? ORDER BY id LIMIT %d`, list, table, pageSize)
+				args = append(args, lastID)
+			}
+			rows, err := tx.Query(query, args...)
+			if err != nil {
+				return 0, fmt.Errorf("failed to read %s rows from %s: %w", from, table, err)
+			}
+			var page [][]any
+			for rows.Next() {
+				values := make([]any, len(columns))
+				dest := make([]any, 0, len(columns)+1)
+				if hasID {
+					dest = append(dest, &lastID)
+				}
+				for i := range values {
+					dest = append(dest, &values[i])
+				}
+				if err := rows.Scan(dest...); err != nil {
+					rows.Close()
+					return 0, fmt.Errorf("failed to scan %s row: %w", table, err)
+				}
+				values[versionAt] = to
+				if embeddingAt >= 0 {
+					if blob, ok := values[embeddingAt].([]byte); ok {
+						values[embeddingAt] = transform(blob)
+					}
+				}
+				page = append(page, values)
+			}
+			err = rows.Err()
+			rows.Close()
+			if err != nil {
+				return 0, fmt.Errorf("failed to read %s rows from %s: %w", from, table, err)
+			}
+
+			for _, values := range page {
+				if _, err := tx.Exec(insert, values...); err != nil {
+					return 0, fmt.Errorf("failed to copy %s row to %s: %w", table, to, err)
+				}
+				copied++
+			}
+			if !hasID || len(page) < pageSize {
+				break
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit %s: %w", to, err)
+	}
+	return copied, nil
+}
@@ -0,0 +1,139 @@
+// internal/embeddings/builtin_embedding_provider.go
+package embeddings
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+const (
+	// BuiltinEmbeddingModel names the built-in hashing model. Bump the version whenever
+	// tokenization or weighting changes, since stored vectors would no longer match.
+	BuiltinEmbeddingModel = "hash-v1"
+	// BuiltinEmbeddingDimensions is the size of the built-in vectors.
+	BuiltinEmbeddingDimensions = 1024
+)
+
+// Feature weights. Whole words carry the meaning; word pairs reward phrases; character
+// trigrams let inflections and misspelled names ("Aldric's", "Aldrik") still match.
+const (
+	builtinWordWeight    = 1.0
+	builtinPairWeight    = 0.5
+	builtinTrigramWeight = 0.25
+)
+
+// builtinStopWords are too common to say anything about what a text is about.
+var builtinStopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true,
+	"but": true, "by": true, "for": true, "from": true, "had": true, "has": true, "have": true,
+	"he": true, "her": true, "his": true, "in": true, "is": true, "it": true, "its": true,
+	"of": true, "on": true, "or": true, "she": true, "that": true, "the": true, "their": true,
+	"they": true, "this": true, "to": true, "was": true, "were": true, "what": true,
+	"which": true, "who": true, "with": true, "you": true,
+}
+
+// BuiltinEmbeddingProvider embeds text in-process by feature hashing: words, adjacent word
+// pairs and character trigrams are hashed into a fixed-size vector with sublinear term
+// weights. It needs no model server, network or model files, so it stands in when no
+// other provider is reachable. Its vectors capture shared vocabulary rather than meaning,
+// so retrieval is noticeably weaker than with a real embedding model.
+type BuiltinEmbeddingProvider struct {
+	dimensions int
+}
+
+// NewBuiltinEmbeddingProvider creates the built-in provider.
+func NewBuiltinEmbeddingProvider() *BuiltinEmbeddingProvider {
+	return &BuiltinEmbeddingProvider{dimensions: BuiltinEmbeddingDimensions}
+}
+
+// CreateEmbedding embeds content for storage.
+func (p *BuiltinEmbeddingProvider) CreateEmbedding(text string) ([]float32, error) {
+	vector := p.embed(text)
+	if vector == nil {
+		return nil, fmt.Errorf("no words to embed in text")
+	}
+	return vector, nil
+}
+
+// CreateQueryEmbedding embeds a search query. Hashed vectors have no separate query mode.
+func (p *BuiltinEmbeddingProvider) CreateQueryEmbedding(text string) ([]float32, error) {
+	return p.CreateEmbedding(text)
+}
+
+// BatchLimits is generous; embedding costs only CPU time proportional to the text length.
+func (p *BuiltinEmbeddingProvider) BatchLimits() BatchLimits {
+	return BatchLimits{MaxTexts: 512, MaxChars: 2000000}
+}
+
+// CreateEmbeddings embeds several texts. Texts without any words get a zero vector
+// rather than failing the whole batch.
+func (p *BuiltinEmbeddingProvider) CreateEmbeddings(texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		if vectors[i] = p.embed(text); vectors[i] == nil {
+			vectors[i] = make([]float32, p.dimensions)
+		}
+	}
+	return vectors, nil
+}
+
+// ModelIdentifier returns e.g. "local-builtin:hash-v1".
+func (p *BuiltinEmbeddingProvider) ModelIdentifier() string {
+	return "local-builtin:" + BuiltinEmbeddingModel
+}
+
+// embed returns the normalized feature vector of text, or nil if it has no words.
+func (p *BuiltinEmbeddingProvider) embed(text string) []float32 {
+	words := builtinWords(text)
+	if len(words) == 0 {
+		return nil
+	}
+
+	counts := make(map[string]float64)
+	for i, word := range words {
+		counts["w:"+word] += builtinWordWeight
+		if i > 0 {
+			counts["p:"+words[i-1]+" "+word] += builtinPairWeight
+		}
+		padded := []rune("^" + word + "$")
+		for j := 0; j+3 <= len(padded); j++ {
+			counts["t:"+string(padded[j:j+3])] += builtinTrigramWeight
+		}
+	}
+
+	vector := make([]float32, p.dimensions)
+	for feature, count := range counts {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+		// The top bit picks the sign, so colliding features tend to cancel out
+		// instead of adding up
+		weight := float32(1 + math.Log(count))
+		if sum>>63 == 1 {
+			weight = -weight
+		}
+		vector[sum%uint64(p.dimensions)] += weight
+	}
+	return normalize(vector)
+}
+
+// builtinWords lowercases text and splits it into words, dropping stop words and
+// possessive endings.
+func builtinWords(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\'' && r != '’'
+	})
+	words := make([]string, 0, len(fields))
+	for _, field := range fields {
+		field = strings.Trim(field, "'’")
+		field = strings.TrimSuffix(strings.TrimSuffix(field, "'s"), "’s")
+		if field == "" || builtinStopWords[field] {
+			continue
+		}
+		words = append(words, field)
+	}
+	return words
+}
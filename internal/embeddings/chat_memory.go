@@ -0,0 +1,232 @@
+// internal/embeddings/chat_memory.go
+package embeddings
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+)
+
+// ChatExchange is one user message of a saved chat session together with the replies
+// to it, flattened to text. MessageIndex is the position of the user message in the log.
+type ChatExchange struct {
+	MessageIndex int
+	Text         string
+}
+
+// ChatMemory is a past exchange matched by a search.
+type ChatMemory struct {
+	Session      string  `json:"session"` // Chat log filename
+	MessageIndex int     `json:"messageIndex"`
+	Text         string  `json:"text"`
+	Score        float32 `json:"score"`
+}
+
+// IndexChatSession embeds the exchanges of a session that are new or changed since it
+// was last indexed and drops those that no longer exist. Chat logs only grow between
+// saves, so usually just the latest exchange is embedded. Returns how many exchanges
+// were embedded.
+func (s *EmbeddingService) IndexChatSession(session string, exchanges []ChatExchange) (int, error) {
+	if s.db == nil {
+		return 0, fmt.Errorf("database connection is nil")
+	}
+	if s.provider == nil {
+		return 0, fmt.Errorf("no embedding provider configured for saving")
+	}
+	vectorVersion := s.vectorVersion()
+
+	stored := make(map[int]string)
+	rows, err := s.db.Query(`SELECT DISTINCT message_index, exchange_hash FROM chat_memory WHERE session = ? AND vector_version = ?`, session, vectorVersion)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read chat memory of %s: %w", session, err)
+	}
+	for rows.Next() {
+		var index int
+		var hash string
+		if err := rows.Scan(&index, &hash); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan chat memory row: %w", err)
+		}
+		stored[index] = hash
+	}
+	rows.Close()
+
+	type pendingChunk struct {
+		messageIndex int
+		chunkIndex   int
+		hash         string
+		text         string
+	}
+	var pending []pendingChunk
+	current := make(map[int]bool, len(exchanges))
+	changed := 0
+	for _, ex := range exchanges {
+		hash := ContentHash(ex.Text)
+		if stored[ex.MessageIndex] == hash {
+			current[ex.MessageIndex] = true
+			continue
+		}
+		chunks := ChunkText(ex.Text, DefaultChunkSize, DefaultChunkOverlap)
+		if len(chunks) == 0 {
+			continue // Blank exchange; any old rows for it are dropped as stale
+		}
+		current[ex.MessageIndex] = true
+		changed++
+		for _, chunk := range chunks {
+			pending = append(pending, pendingChunk{ex.MessageIndex, chunk.Index, hash, chunk.Text})
+		}
+	}
+
+	var stale []int
+	for index := range stored {
+		if !current[index] {
+			stale = append(stale, index)
+		}
+	}
+	if changed == 0 && len(stale) == 0 {
+		return 0, nil
+	}
+
+	var vectors [][]float32
+	if len(pending) > 0 {
+		texts := make([]string, len(pending))
+		for i, p := range pending {
+			texts[i] = "Earlier discussion with the writer:\n" + p.text
+		}
+		if vectors, err = s.CreateEmbeddings(texts); err != nil {
+			return 0, fmt.Errorf("failed to embed chat session %s: %w", session, err)
+		}
+	}
+
+	s.dbMutex.Lock()
+	defer s.dbMutex.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin chat memory transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	deleteExchange := func(index int) error {
+		_, err := tx.Exec(`DELETE FROM chat_memory WHERE session = ? AND vector_version = ? AND message_index = ?`, session, vectorVersion, index)
+		return err
+	}
+	for _, index := range stale {
+		if err := deleteExchange(index); err != nil {
+			return 0, fmt.Errorf("failed to drop stale chat memory: %w", err)
+		}
+	}
+	cleared := make(map[int]bool)
+	for i, p := range pending {
+		if !cleared[p.messageIndex] {
+			if err := deleteExchange(p.messageIndex); err != nil {
+				return 0, fmt.Errorf("failed to replace chat memory: %w", err)
+			}
+			cleared[p.messageIndex] = true
+		}
+		_, err := tx.Exec(
+			`INSERT INTO chat_memory
+			 (session, vector_version, message_index, chunk_index, exchange_hash, chunk_text, embedding, created_at)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, datetime('now'))`,
+			session, vectorVersion, p.messageIndex, p.chunkIndex, p.hash, p.text, serializeEmbedding(vectors[i]),
+		)
+		if err != nil {
+			return 0, fmt.Errorf("failed to save chat memory: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit chat memory of %s: %w", session, err)
+	}
+
+	log.Printf("Indexed chat session %s: %d exchanges embedded, %d removed (provider: %s)", session, changed, len(stale), vectorVersion)
+	return changed, nil
+}
+
+// IndexedChatSessions lists the sessions that have memory for the current provider.
+func (s *EmbeddingService) IndexedChatSessions() ([]string, error) {
+	if s.db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	if s.provider == nil {
+		return nil, fmt.Errorf("no embedding provider configured")
+	}
+	rows, err := s.db.Query(`SELECT DISTINCT session FROM chat_memory WHERE vector_version = ?`, s.vectorVersion())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list indexed chat sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []string
+	for rows.Next() {
+		var session string
+		if err := rows.Scan(&session); err != nil {
+			return nil, fmt.Errorf("failed to scan chat session: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// FindSimilarChatMemories searches past exchanges of sessions that aren't excluded from
+// memory. An exchange split into several passages is returned once, with its best passage.
+func (s *EmbeddingService) FindSimilarChatMemories(query string, limit int) ([]ChatMemory, error) {
+	if s.db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	if s.provider == nil {
+		return nil, fmt.Errorf("no embedding provider configured")
+	}
+	queryEmbedding, err := s.embedQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create query embedding: %w", err)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT m.session, m.message_index, m.chunk_text, m.embedding
+		FROM chat_memory m
+		LEFT JOIN chat_sessions cs ON cs.session = m.session
+		WHERE m.vector_version = ? AND COALESCE(cs.memory_excluded, 0) = 0`,
+		s.vectorVersion())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query chat memory: %w", err)
+	}
+	defer rows.Close()
+
+	type exchangeKey struct {
+		session string
+		index   int
+	}
+	best := make(map[exchangeKey]ChatMemory)
+	for rows.Next() {
+		var m ChatMemory
+		var embeddingBytes []byte
+		if err := rows.Scan(&m.Session, &m.MessageIndex, &m.Text, &embeddingBytes); err != nil {
+			log.Printf("Warning: Failed to scan chat memory: %v", err)
+			continue
+		}
+		m.Score = cosineSimilarity(queryEmbedding, deserializeEmbedding(embeddingBytes))
+		if math.IsNaN(float64(m.Score)) {
+			continue
+		}
+		key := exchangeKey{m.Session, m.MessageIndex}
+		if prev, ok := best[key]; !ok || m.Score > prev.Score {
+			best[key] = m
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Warning: Error during row iteration in FindSimilarChatMemories: %v", err)
+	}
+
+	results := make([]ChatMemory, 0, len(best))
+	for _, m := range best {
+		results = append(results, m)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
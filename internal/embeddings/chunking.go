@@ -0,0 +1,106 @@
+// internal/embeddings/chunking.go
+package embeddings
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultChunkSize is the target passage length in characters (~300-400 tokens).
+	DefaultChunkSize = 1500
+	// DefaultChunkOverlap is how many characters consecutive passages share, so a fact
+	// straddling a boundary is fully contained in at least one passage.
+	DefaultChunkOverlap = 300
+	// MaxEntryTextChars caps the text embedded as a whole entry (~1500 tokens), which fits
+	// the input limit of every supported model. Longer entries are covered by their passages.
+	MaxEntryTextChars = 4 * DefaultChunkSize
+)
+
+// Chunk is a passage of a longer text. Start and End are byte offsets into the original.
+type Chunk struct {
+	Index int
+	Start int
+	End   int
+	Text  string
+}
+
+// CapText returns text cut to at most limit bytes, at the last paragraph, sentence or
+// word boundary in the second half of the limit if there is one.
+func CapText(text string, limit int) string {
+	if limit <= 0 || len(text) <= limit {
+		return text
+	}
+	return strings.TrimSpace(text[:findBreak(text, limit/2, limit)])
+}
+
+// ChunkText splits text into overlapping passages of roughly size characters. Cuts are
+// moved back to the nearest paragraph, sentence or word boundary when one is close.
+// Text no longer than size is returned as a single chunk.
+func ChunkText(text string, size, overlap int) []Chunk {
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = size / 5
+	}
+	if len(text) <= size {
+		if strings.TrimSpace(text) == "" {
+			return nil
+		}
+		return []Chunk{{Index: 0, Start: 0, End: len(text), Text: text}}
+	}
+
+	var chunks []Chunk
+	start := 0
+	for start < len(text) {
+		end := start + size
+		if end >= len(text) {
+			end = len(text)
+		} else {
+			end = findBreak(text, start+size/2, end)
+		}
+
+		passage := strings.TrimSpace(text[start:end])
+		if passage != "" {
+			chunks = append(chunks, Chunk{Index: len(chunks), Start: start, End: end, Text: passage})
+		}
+		if end == len(text) {
+			break
+		}
+
+		// Step back by the overlap, then forward to a word start so passages don't begin mid-word
+		next := end - overlap
+		if next <= start {
+			next = end
+		}
+		for next < end && !unicode.IsSpace(rune(text[next-1])) {
+			next++
+		}
+		start = next
+	}
+	return chunks
+}
+
+// findBreak returns the best cut position in text[min:max]: the last paragraph break,
+// else the last sentence end, else the last whitespace, else max.
+func findBreak(text string, min, max int) int {
+	window := text[min:max]
+	if i := strings.LastIndex(window, "\n\n"); i != -1 {
+		return min + i + 2
+	}
+	for i := len(window) - 1; i > 0; i-- {
+		if (window[i-1] == '.' || window[i-1] == '!' || window[i-1] == '?') && unicode.IsSpace(rune(window[i])) {
+			return min + i + 1
+		}
+	}
+	if i := strings.LastIndexFunc(window, unicode.IsSpace); i != -1 {
+		return min + i + 1
+	}
+	// No whitespace at all: cut anyway, but never inside a multi-byte character
+	for max > min && !utf8.RuneStart(text[max]) {
+		max--
+	}
+	return max
+}
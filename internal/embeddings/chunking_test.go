@@ -0,0 +1,107 @@
+package embeddings
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestChunkText(t *testing.T) {
+	sentence := "The river runs north past the old mill. "
+	tests := []struct {
+		name       string
+		text       string
+		size       int
+		overlap    int
+		wantChunks int // -1 to only check the invariants
+	}{
+		{"empty", "", 100, 20, 0},
+		{"whitespace only", "   \n\n  ", 100, 20, 0},
+		{"shorter than size", "A short entry.", 100, 20, 1},
+		{"exactly size", strings.Repeat("a", 100), 100, 20, 1},
+		{"one over size", strings.Repeat("a", 101), 100, 20, 2},
+		{"sentences", strings.Repeat(sentence, 40), 200, 50, -1},
+		{"paragraphs", strings.Repeat("First paragraph line.\n\nSecond one here.\n\n", 30), 150, 30, -1},
+		{"no whitespace", strings.Repeat("x", 1000), 128, 32, -1},
+		{"multi-byte without spaces", strings.Repeat("é世", 300), 100, 10, -1},
+		{"overlap larger than size", strings.Repeat(sentence, 20), 100, 500, -1},
+		{"negative overlap", strings.Repeat(sentence, 20), 100, -1, -1},
+		{"zero size uses default", strings.Repeat(sentence, 100), 0, 0, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := ChunkText(tt.text, tt.size, tt.overlap)
+			if tt.wantChunks >= 0 && len(chunks) != tt.wantChunks {
+				t.Fatalf("got %d chunks, want %d", len(chunks), tt.wantChunks)
+			}
+			size := tt.size
+			if size <= 0 {
+				size = DefaultChunkSize
+			}
+			covered := 0
+			for i, c := range chunks {
+				if c.Index != i {
+					t.Errorf("chunk %d has index %d", i, c.Index)
+				}
+				if c.End-c.Start > size {
+					t.Errorf("chunk %d spans %d bytes, over the size %d", i, c.End-c.Start, size)
+				}
+				if c.Start > covered {
+					t.Errorf("gap before chunk %d: text[%d:%d] is in no chunk", i, covered, c.Start)
+				}
+				if i > 0 && c.Start <= chunks[i-1].Start {
+					t.Errorf("chunk %d does not advance: starts at %d after %d", i, c.Start, chunks[i-1].Start)
+				}
+				if c.Text != strings.TrimSpace(tt.text[c.Start:c.End]) {
+					t.Errorf("chunk %d text does not match its offsets", i)
+				}
+				if !utf8.ValidString(c.Text) {
+					t.Errorf("chunk %d cuts a multi-byte character", i)
+				}
+				covered = c.End
+			}
+			if len(chunks) > 0 && covered != len(tt.text) {
+				t.Errorf("chunks end at %d, text is %d bytes", covered, len(tt.text))
+			}
+		})
+	}
+}
+
+func TestChunkTextOverlap(t *testing.T) {
+	text := strings.Repeat("word ", 200) // 1000 bytes, breaks at every space
+	chunks := ChunkText(text, 100, 30)
+	for i := 1; i < len(chunks); i++ {
+		shared := chunks[i-1].End - chunks[i].Start
+		if shared <= 0 || shared > 30 {
+			t.Errorf("chunks %d and %d share %d bytes, want 1..30", i-1, i, shared)
+		}
+		if strings.HasPrefix(chunks[i].Text, "ord") {
+			t.Errorf("chunk %d starts mid-word: %q", i, chunks[i].Text[:10])
+		}
+	}
+}
+
+func TestChunkTextPrefersParagraphBreak(t *testing.T) {
+	text := strings.Repeat("a", 60) + ". More words here.\n\n" + strings.Repeat("b ", 100)
+	chunks := ChunkText(text, 100, 0)
+	if len(chunks) < 2 {
+		t.Fatalf("got %d chunks", len(chunks))
+	}
+	if !strings.HasSuffix(chunks[0].Text, "More words here.") {
+		t.Errorf("first chunk should end at the paragraph break, got %q", chunks[0].Text)
+	}
+}
+
+func TestCapText(t *testing.T) {
+	text := strings.Repeat("One sentence here. ", 1000)
+	capped := CapText(text, MaxEntryTextChars)
+	if len(capped) > MaxEntryTextChars || len(capped) < MaxEntryTextChars/2 {
+		t.Errorf("capped to %d bytes, want at most %d and at least half", len(capped), MaxEntryTextChars)
+	}
+	if !strings.HasSuffix(capped, "here.") {
+		t.Errorf("cap does not end at a sentence: %q", capped[len(capped)-20:])
+	}
+	if short := "Short entry."; CapText(short, MaxEntryTextChars) != short {
+		t.Error("short text changed")
+	}
+}
@@ -0,0 +1,220 @@
+// internal/embeddings/cluster.go
+package embeddings
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+const (
+	// maxAutoClusters caps the number of clusters tried when none is given.
+	maxAutoClusters = 20
+	// minEntriesPerCluster is the smallest average cluster size tried when picking k.
+	minEntriesPerCluster = 3
+	// kmeansIterations bounds Lloyd's iterations; assignments settle much sooner.
+	kmeansIterations = 50
+	// kmeansSeed makes clustering repeatable for the same vectors.
+	kmeansSeed = 1
+)
+
+// EntryCluster is a group of entries whose stored vectors are close.
+type EntryCluster struct {
+	EntryIDs []int64 `json:"entryIds"` // Closest to the centroid first
+	Cohesion float32 `json:"cohesion"` // Mean similarity of the members to the centroid
+}
+
+// ClusterEntries groups entries by their stored vectors with spherical k-means. entryIDs
+// limits the entries clustered; nil clusters all of them. Entries without a vector for the
+// current model are left out. With k <= 0 the number of clusters is picked by the
+// (simplified) silhouette score. Clusters are returned largest first.
+func (s *EmbeddingService) ClusterEntries(entryIDs []int64, k int) ([]EntryCluster, error) {
+	if s.db == nil || s.provider == nil {
+		return nil, fmt.Errorf("embedding service not initialized")
+	}
+	vectors, err := loadVectors(s.db, `SELECT codex_entry_id, embedding FROM codex_embeddings WHERE vector_version = ?`, s.vectorVersion())
+	if err != nil {
+		return nil, err
+	}
+	if entryIDs != nil {
+		wanted := make(map[int64]bool, len(entryIDs))
+		for _, id := range entryIDs {
+			wanted[id] = true
+		}
+		for id := range vectors {
+			if !wanted[id] {
+				delete(vectors, id)
+			}
+		}
+	}
+
+	ids := make([]int64, 0, len(vectors))
+	for id := range vectors {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	points := make([][]float32, 0, len(ids))
+	kept := ids[:0]
+	for _, id := range ids {
+		vec := vectors[id]
+		if len(points) > 0 && len(vec) != len(points[0]) {
+			continue // Stray dimension from an interrupted re-index
+		}
+		if p := normalize(vec); p != nil {
+			points = append(points, p)
+			kept = append(kept, id)
+		}
+	}
+	ids = kept
+
+	if len(points) < 2*minEntriesPerCluster {
+		return nil, fmt.Errorf("need at least %d embedded entries to cluster, have %d", 2*minEntriesPerCluster, len(points))
+	}
+	var assign []int
+	var centroids [][]float32
+	if k > 0 {
+		if k > len(points) {
+			k = len(points)
+		}
+		assign, centroids = kmeans(points, k)
+	} else {
+		best := float32(-2)
+		for try := 2; try <= maxAutoClusters && try <= len(points)/minEntriesPerCluster; try++ {
+			a, c := kmeans(points, try)
+			if score := silhouette(points, a, c); score > best {
+				best, assign, centroids = score, a, c
+			}
+		}
+	}
+
+	members := make([][]int, len(centroids))
+	for i, c := range assign {
+		members[c] = append(members[c], i)
+	}
+	clusters := make([]EntryCluster, 0, len(centroids))
+	for c, idx := range members {
+		if len(idx) == 0 {
+			continue
+		}
+		sims := make(map[int]float32, len(idx))
+		var total float32
+		for _, i := range idx {
+			sims[i] = dot(points[i], centroids[c])
+			total += sims[i]
+		}
+		sort.Slice(idx, func(x, y int) bool { return sims[idx[x]] > sims[idx[y]] })
+		cluster := EntryCluster{EntryIDs: make([]int64, len(idx)), Cohesion: total / float32(len(idx))}
+		for n, i := range idx {
+			cluster.EntryIDs[n] = ids[i]
+		}
+		clusters = append(clusters, cluster)
+	}
+	sort.SliceStable(clusters, func(i, j int) bool { return len(clusters[i].EntryIDs) > len(clusters[j].EntryIDs) })
+	return clusters, nil
+}
+
+// kmeans clusters unit vectors into k groups by cosine similarity, seeding with
+// k-means++. It returns each point's cluster and the unit centroids.
+func kmeans(points [][]float32, k int) ([]int, [][]float32) {
+	rng := rand.New(rand.NewSource(kmeansSeed))
+	centroids := make([][]float32, 0, k)
+	centroids = append(centroids, points[rng.Intn(len(points))])
+	dist := make([]float64, len(points))
+	for len(centroids) < k {
+		var total float64
+		for i, p := range points {
+			d := 1 - float64(dot(p, centroids[len(centroids)-1]))
+			if len(centroids) == 1 || d < dist[i] {
+				dist[i] = d
+			}
+			total += dist[i] * dist[i]
+		}
+		next := len(points) - 1
+		if total > 0 {
+			r := rng.Float64() * total
+			for i, d := range dist {
+				if r -= d * d; r <= 0 {
+					next = i
+					break
+				}
+			}
+		} else {
+			next = rng.Intn(len(points)) // All points coincide with a centroid
+		}
+		centroids = append(centroids, points[next])
+	}
+
+	// Every pass ends with an assignment step, so the result always assigns each point to
+	// its nearest returned centroid, also when the iteration limit cuts it short
+	assign := make([]int, len(points))
+	for iter := 0; ; iter++ {
+		changed := false
+		for i, p := range points {
+			best, bestSim := 0, float32(-2)
+			for c, centroid := range centroids {
+				if sim := dot(p, centroid); sim > bestSim {
+					best, bestSim = c, sim
+				}
+			}
+			if iter == 0 || assign[i] != best {
+				assign[i] = best
+				changed = true
+			}
+		}
+		if !changed || iter == kmeansIterations {
+			break
+		}
+
+		sums := make([][]float32, k)
+		counts := make([]int, k)
+		for i, c := range assign {
+			if sums[c] == nil {
+				sums[c] = make([]float32, len(points[i]))
+			}
+			for d, v := range points[i] {
+				sums[c][d] += v
+			}
+			counts[c]++
+		}
+		for c := range centroids {
+			if counts[c] == 0 {
+				// Re-seed an empty cluster with the point farthest from its own centroid
+				far, farSim := 0, float32(2)
+				for i, p := range points {
+					if sim := dot(p, centroids[assign[i]]); sim < farSim {
+						far, farSim = i, sim
+					}
+				}
+				centroids[c] = points[far]
+				continue
+			}
+			if unit := normalize(sums[c]); unit != nil {
+				centroids[c] = unit
+			}
+		}
+	}
+	return assign, centroids
+}
+
+// silhouette is the mean simplified silhouette of a clustering: for each point, how much
+// nearer it is to its own centroid than to the next nearest one, from -1 to 1. It costs
+// O(n·k) instead of the O(n²) of the full score.
+func silhouette(points [][]float32, assign []int, centroids [][]float32) float32 {
+	var total float32
+	for i, p := range points {
+		own := 1 - dot(p, centroids[assign[i]])
+		other := float32(math.MaxFloat32)
+		for c, centroid := range centroids {
+			if c != assign[i] {
+				if d := 1 - dot(p, centroid); d < other {
+					other = d
+				}
+			}
+		}
+		if m := float32(math.Max(float64(own), float64(other))); m > 0 {
+			total += (other - own) / m
+		}
+	}
+	return total / float32(len(points))
+}
@@ -0,0 +1,102 @@
+package embeddings
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// separatedBlobs draws per unit vectors around each of blobs random centres, tight
+// enough that no two blobs overlap. It returns the points and each point's blob.
+func separatedBlobs(rng *rand.Rand, blobs, per, dim int) ([][]float32, []int) {
+	var points [][]float32
+	var blob []int
+	for b := 0; b < blobs; b++ {
+		centre := randomVector(rng, dim, 1)
+		for i := 0; i < per; i++ {
+			p := randomVector(rng, dim, 0.1)
+			for d := range p {
+				p[d] += centre[d]
+			}
+			points = append(points, normalize(p))
+			blob = append(blob, b)
+		}
+	}
+	return points, blob
+}
+
+// checkNearestCentroid fails unless every point is assigned to its most similar centroid.
+func checkNearestCentroid(t *testing.T, points [][]float32, assign []int, centroids [][]float32) {
+	t.Helper()
+	for i, p := range points {
+		own := dot(p, centroids[assign[i]])
+		for c, centroid := range centroids {
+			if sim := dot(p, centroid); sim > own+1e-6 {
+				t.Fatalf("point %d is assigned to centroid %d (%.4f) but centroid %d is nearer (%.4f)", i, assign[i], own, c, sim)
+			}
+		}
+	}
+}
+
+func TestKMeansSeparatedBlobs(t *testing.T) {
+	points, blob := separatedBlobs(rand.New(rand.NewSource(1)), 4, 12, 32)
+	assign, centroids := kmeans(points, 4)
+	if len(assign) != len(points) || len(centroids) != 4 {
+		t.Fatalf("got %d assignments and %d centroids", len(assign), len(centroids))
+	}
+
+	clusterOf := make(map[int]int) // Blob -> cluster
+	used := make(map[int]bool)
+	for i, c := range assign {
+		if want, ok := clusterOf[blob[i]]; ok {
+			if c != want {
+				t.Fatalf("blob %d is split over clusters %d and %d", blob[i], want, c)
+			}
+			continue
+		}
+		if used[c] {
+			t.Fatalf("cluster %d holds more than one blob", c)
+		}
+		clusterOf[blob[i]], used[c] = c, true
+	}
+	for c, centroid := range centroids {
+		if norm := dot(centroid, centroid); math.Abs(float64(norm)-1) > 1e-4 {
+			t.Errorf("centroid %d has squared norm %v, want 1", c, norm)
+		}
+	}
+	checkNearestCentroid(t, points, assign, centroids)
+
+	again, _ := kmeans(points, 4)
+	if !reflect.DeepEqual(again, assign) {
+		t.Error("clustering the same points twice gave different results")
+	}
+}
+
+func TestKMeansAssignsToReturnedCentroids(t *testing.T) {
+	// Uniform points have no clusters to settle into; with these, k = 7 is still moving
+	// points between clusters when the iteration limit is reached
+	rng := rand.New(rand.NewSource(2))
+	points := make([][]float32, 2000)
+	for i := range points {
+		points[i] = normalize(randomVector(rng, 4, 1))
+	}
+	for _, k := range []int{2, 7, 20} {
+		assign, centroids := kmeans(points, k)
+		checkNearestCentroid(t, points, assign, centroids)
+	}
+}
+
+func TestSilhouettePicksBlobCount(t *testing.T) {
+	points, _ := separatedBlobs(rand.New(rand.NewSource(3)), 3, 10, 32)
+	best, bestK := float32(-2), 0
+	for k := 2; k <= 6; k++ {
+		assign, centroids := kmeans(points, k)
+		if score := silhouette(points, assign, centroids); score > best {
+			best, bestK = score, k
+		}
+	}
+	if bestK != 3 {
+		t.Errorf("silhouette picked k=%d, want 3", bestK)
+	}
+}
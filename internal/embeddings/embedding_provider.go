@@ -2,7 +2,25 @@
 package embeddings
 
 // EmbeddingProvider defines the interface for any embedding generation service.
+// Models trained for retrieval embed a search query differently from the content it
+// should find, so stored content goes through CreateEmbedding and searches through
+// CreateQueryEmbedding. Providers without that distinction embed both the same way.
 type EmbeddingProvider interface {
-	CreateEmbedding(text string) ([]float32, error)
-	ModelIdentifier() string // e.g., "local:all-MiniLM-L6-v2", "gemini:embedding-001"
+	CreateEmbedding(text string) ([]float32, error)      // Content to be stored and searched
+	CreateQueryEmbedding(text string) ([]float32, error) // A search query
+	ModelIdentifier() string                             // e.g., "local:all-MiniLM-L6-v2", "gemini:embedding-001"
+}
+
+// BatchEmbeddingProvider is implemented by providers that can embed many texts in one
+// request. Vectors are returned in input order. Providers without it are called in a loop.
+type BatchEmbeddingProvider interface {
+	EmbeddingProvider
+	CreateEmbeddings(texts []string) ([][]float32, error)
+	BatchLimits() BatchLimits
+}
+
+// BatchLimits bounds a single batch request.
+type BatchLimits struct {
+	MaxTexts int // Max inputs per request
+	MaxChars int // Approximate payload budget (sum of input lengths) per request
 }
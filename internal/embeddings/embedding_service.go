@@ -3,12 +3,16 @@ package embeddings
 
 import (
 	"Llore/internal/database"
+	"Llore/internal/provider"
+	"crypto/sha256"
 	"database/sql"
 	"encoding/binary"
+	"encoding/hex"
 	"fmt"
 	"log"
 	"math"
 	"sort"
+	"strings"
 	"sync"
 )
 
@@ -17,6 +21,16 @@ type EmbeddingService struct {
 	db       *sql.DB
 	provider EmbeddingProvider // Holds the actual implementation
 	dbMutex  sync.Mutex
+
+	indexMu  sync.RWMutex
+	index    *VectorIndex // Nil until OpenIndex; searches scan the table until it's ready
+	indexDir string
+
+	queryMu    sync.Mutex
+	lastQuery  string // Entries and manuscript passages are searched with the same query
+	lastVector []float32
+
+	storage VectorStorage // Set before the index is opened; see SetVectorStorage
 }
 
 // NewEmbeddingService creates a new embedding service
@@ -27,7 +41,33 @@ func NewEmbeddingService(db *sql.DB, provider EmbeddingProvider) *EmbeddingServi
 	return &EmbeddingService{
 		db:       db,
 		provider: provider,
+		storage:  VectorStorage{Quantization: QuantizeNone},
+	}
+}
+
+// SetVectorStorage applies a vault's truncation and quantization settings. Call it before
+// OpenIndex and before anything is embedded, since truncation changes the vector version.
+func (s *EmbeddingService) SetVectorStorage(storage VectorStorage) error {
+	storage, err := storage.Normalize()
+	if err != nil {
+		return err
 	}
+	s.storage = storage
+	s.queryMu.Lock()
+	s.lastQuery, s.lastVector = "", nil
+	s.queryMu.Unlock()
+	return nil
+}
+
+// VectorStorage returns the truncation and quantization settings in use.
+func (s *EmbeddingService) VectorStorage() VectorStorage {
+	return s.storage
+}
+
+// vectorVersion identifies the vectors this service stores and searches: the provider's
+// model, plus the truncated dimension if any.
+func (s *EmbeddingService) vectorVersion() string {
+	return s.storage.VectorVersion(s.provider.ModelIdentifier())
 }
 
 // CreateEmbedding delegates to the active provider
@@ -35,7 +75,94 @@ func (s *EmbeddingService) CreateEmbedding(text string) ([]float32, error) {
 	if s.provider == nil {
 		return nil, fmt.Errorf("no embedding provider configured")
 	}
-	return s.provider.CreateEmbedding(text)
+	vector, err := s.provider.CreateEmbedding(text)
+	if err != nil {
+		return nil, err
+	}
+	return TruncateVector(vector, s.storage.Dimensions), nil
+}
+
+// CreateQueryEmbedding embeds a search query with the active provider
+func (s *EmbeddingService) CreateQueryEmbedding(text string) ([]float32, error) {
+	if s.provider == nil {
+		return nil, fmt.Errorf("no embedding provider configured")
+	}
+	vector, err := s.provider.CreateQueryEmbedding(text)
+	if err != nil {
+		return nil, err
+	}
+	return TruncateVector(vector, s.storage.Dimensions), nil
+}
+
+// CreateEmbeddings embeds many texts, returning vectors in input order. Batch-capable
+// providers get requests sized to their limits; a batch rejected as too large is split
+// in half and retried. Other providers are called once per text.
+func (s *EmbeddingService) CreateEmbeddings(texts []string) ([][]float32, error) {
+	if s.provider == nil {
+		return nil, fmt.Errorf("no embedding provider configured")
+	}
+	batcher, ok := s.provider.(BatchEmbeddingProvider)
+	if !ok {
+		vectors := make([][]float32, len(texts))
+		for i, text := range texts {
+			vector, err := s.CreateEmbedding(text)
+			if err != nil {
+				return nil, err
+			}
+			vectors[i] = vector
+		}
+		return vectors, nil
+	}
+
+	vectors := make([][]float32, 0, len(texts))
+	for _, batch := range splitBatches(texts, batcher.BatchLimits()) {
+		batchVectors, err := s.createBatch(batcher, batch)
+		if err != nil {
+			return nil, err
+		}
+		for _, vector := range batchVectors {
+			vectors = append(vectors, TruncateVector(vector, s.storage.Dimensions))
+		}
+	}
+	return vectors, nil
+}
+
+// createBatch sends one batch, halving it while the provider rejects it as too large.
+func (s *EmbeddingService) createBatch(batcher BatchEmbeddingProvider, texts []string) ([][]float32, error) {
+	vectors, err := batcher.CreateEmbeddings(texts)
+	if err == nil || len(texts) == 1 || provider.CodeOf(err) != provider.ErrContextTooLong {
+		return vectors, err
+	}
+	log.Printf("Embedding batch of %d texts rejected as too large, splitting: %v", len(texts), err)
+	mid := len(texts) / 2
+	first, err := s.createBatch(batcher, texts[:mid])
+	if err != nil {
+		return nil, err
+	}
+	second, err := s.createBatch(batcher, texts[mid:])
+	if err != nil {
+		return nil, err
+	}
+	return append(first, second...), nil
+}
+
+// splitBatches groups texts into consecutive batches within the given limits.
+func splitBatches(texts []string, limits BatchLimits) [][]string {
+	var batches [][]string
+	start, chars := 0, 0
+	for i, text := range texts {
+		full := limits.MaxTexts > 0 && i-start >= limits.MaxTexts
+		tooLong := limits.MaxChars > 0 && i > start && chars+len(text) > limits.MaxChars
+		if full || tooLong {
+			batches = append(batches, texts[start:i])
+			start, chars = i, 0
+		}
+		chars += len(text)
+	}
+	if start < len(texts) {
+		batches = append(batches, texts[start:])
+	}
+	return batches
 }
 
 // ModelIdentifier delegates to the active provider
@@ -43,7 +170,7 @@ func (s *EmbeddingService) ModelIdentifier() string {
 	if s.provider == nil {
 		return "unknown-provider"
 	}
-	return s.provider.ModelIdentifier()
+	return s.vectorVersion()
 }
 
 // GetProvider returns the active embedding provider
@@ -51,8 +178,16 @@ func (s *EmbeddingService) GetProvider() EmbeddingProvider {
 	return s.provider
 }
 
+// ContentHash identifies the exact text an embedding was made from, so edits that never
+// got re-embedded can be detected later.
+func ContentHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
 // SaveEmbedding stores an embedding. Uses provider.ModelIdentifier() for vector_version.
-func (s *EmbeddingService) SaveEmbedding(entryID int64, embedding []float32) error {
+// contentHash is ContentHash of the embedded text.
+func (s *EmbeddingService) SaveEmbedding(entryID int64, embedding []float32, contentHash string) error {
 	if s.db == nil {
 		return fmt.Errorf("database connection is nil")
 	}
@@ -63,34 +198,162 @@ func (s *EmbeddingService) SaveEmbedding(entryID int64, embedding []float32) err
 		return fmt.Errorf("no embedding provider configured for saving")
 	}
 
-	embeddingBytes := make([]byte, len(embedding)*4)
-	for i, v := range embedding {
-		binary.LittleEndian.PutUint32(embeddingBytes[i*4:], math.Float32bits(v))
-	}
+	embeddingBytes := serializeEmbedding(embedding)
 
 	s.dbMutex.Lock()
 	defer s.dbMutex.Unlock()
 
-	vectorVersion := s.provider.ModelIdentifier()
+	vectorVersion := s.vectorVersion()
 
 	_, err := s.db.Exec(
 		`INSERT INTO codex_embeddings
-         (codex_entry_id, embedding, vector_version, created_at, updated_at)
-         VALUES (?, ?, ?, datetime('now'), datetime('now'))
+         (codex_entry_id, embedding, vector_version, content_hash, created_at, updated_at)
+         VALUES (?, ?, ?, ?, datetime('now'), datetime('now'))
          ON CONFLICT(codex_entry_id, vector_version) DO UPDATE SET
              embedding = excluded.embedding,
+             content_hash = excluded.content_hash,
              updated_at = datetime('now')`,
-		entryID, embeddingBytes, vectorVersion,
+		entryID, embeddingBytes, vectorVersion, contentHash,
 	)
 	if err != nil {
 		log.Printf("ERROR saving embedding for entry ID %d (provider: %s): %v", entryID, vectorVersion, err)
 		return fmt.Errorf("failed to save embedding to database: %w", err)
 	}
 
+	if idx := s.vectorIndex(); idx != nil {
+		idx.AddEntry(entryID, embedding)
+	}
+
 	log.Printf("Saved embedding for entry ID %d (provider: %s)", entryID, vectorVersion)
 	return nil
 }
 
+// SaveEmbeddings stores several embeddings in one transaction. entryIDs, vectors and
+// contentHashes are parallel.
+func (s *EmbeddingService) SaveEmbeddings(entryIDs []int64, vectors [][]float32, contentHashes []string) error {
+	if s.db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	if s.provider == nil {
+		return fmt.Errorf("no embedding provider configured for saving")
+	}
+	if len(entryIDs) != len(vectors) || len(entryIDs) != len(contentHashes) {
+		return fmt.Errorf("mismatched embedding batch: %d entries, %d vectors, %d hashes", len(entryIDs), len(vectors), len(contentHashes))
+	}
+
+	s.dbMutex.Lock()
+	defer s.dbMutex.Unlock()
+
+	vectorVersion := s.vectorVersion()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin embedding transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(
+		`INSERT INTO codex_embeddings
+         (codex_entry_id, embedding, vector_version, content_hash, created_at, updated_at)
+         VALUES (?, ?, ?, ?, datetime('now'), datetime('now'))
+         ON CONFLICT(codex_entry_id, vector_version) DO UPDATE SET
+             embedding = excluded.embedding,
+             content_hash = excluded.content_hash,
+             updated_at = datetime('now')`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare embedding insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for i, entryID := range entryIDs {
+		if len(vectors[i]) == 0 {
+			return fmt.Errorf("cannot save empty embedding for entry %d", entryID)
+		}
+		if _, err := stmt.Exec(entryID, serializeEmbedding(vectors[i]), vectorVersion, contentHashes[i]); err != nil {
+			return fmt.Errorf("failed to save embedding for entry %d: %w", entryID, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit embeddings: %w", err)
+	}
+	if idx := s.vectorIndex(); idx != nil {
+		for i, entryID := range entryIDs {
+			idx.AddEntry(entryID, vectors[i])
+		}
+	}
+
+	log.Printf("Saved %d embeddings (provider: %s)", len(entryIDs), vectorVersion)
+	return nil
+}
+
+// SaveEntryChunks embeds a long entry passage by passage. title (e.g. "Name: X\nType: Y")
+// is prepended to every passage so each one stays attributable to its entry; offsets are
+// relative to body. Bodies that fit in one passage need no chunks and any old ones are removed.
+func (s *EmbeddingService) SaveEntryChunks(entryID int64, title, body string) error {
+	if s.db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	if s.provider == nil {
+		return fmt.Errorf("no embedding provider configured for saving")
+	}
+	vectorVersion := s.vectorVersion()
+
+	chunks := ChunkText(body, DefaultChunkSize, DefaultChunkOverlap)
+	var vectors [][]float32
+	if len(chunks) > 1 {
+		texts := make([]string, len(chunks))
+		for i, chunk := range chunks {
+			texts[i] = title + "\n" + chunk.Text
+		}
+		var err error
+		if vectors, err = s.CreateEmbeddings(texts); err != nil {
+			return fmt.Errorf("failed to embed passages of entry %d: %w", entryID, err)
+		}
+	}
+
+	s.dbMutex.Lock()
+	defer s.dbMutex.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin chunk transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM codex_embedding_chunks WHERE codex_entry_id = ? AND vector_version = ?`, entryID, vectorVersion); err != nil {
+		return fmt.Errorf("failed to clear old passages of entry %d: %w", entryID, err)
+	}
+	chunkIDs := make([]int64, 0, len(chunks))
+	if len(chunks) > 1 {
+		for i, chunk := range chunks {
+			result, err := tx.Exec(
+				`INSERT INTO codex_embedding_chunks
+				 (codex_entry_id, vector_version, chunk_index, start_offset, end_offset, chunk_text, embedding, created_at)
+				 VALUES (?, ?, ?, ?, ?, ?, ?, datetime('now'))`,
+				entryID, vectorVersion, chunk.Index, chunk.Start, chunk.End, chunk.Text, serializeEmbedding(vectors[i]),
+			)
+			if err != nil {
+				return fmt.Errorf("failed to save passage %d of entry %d: %w", chunk.Index, entryID, err)
+			}
+			chunkID, err := result.LastInsertId()
+			if err != nil {
+				return fmt.Errorf("failed to read passage ID: %w", err)
+			}
+			chunkIDs = append(chunkIDs, chunkID)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit passages of entry %d: %w", entryID, err)
+	}
+	if idx := s.vectorIndex(); idx != nil {
+		idx.ReplaceChunks(entryID, chunkIDs, vectors)
+	}
+
+	if len(chunks) > 1 {
+		log.Printf("Saved %d passage embeddings for entry ID %d (provider: %s)", len(chunks), entryID, vectorVersion)
+	}
+	return nil
+}
+
 // GetEmbedding retrieves an embedding for a codex entry
 func (s *EmbeddingService) GetEmbedding(entryID int64) ([]float32, error) {
 	if s.db == nil {
@@ -100,7 +363,7 @@ func (s *EmbeddingService) GetEmbedding(entryID int64) ([]float32, error) {
 	if s.provider == nil {
 		return nil, fmt.Errorf("no embedding provider configured for retrieval")
 	}
-	vectorVersion := s.provider.ModelIdentifier()
+	vectorVersion := s.vectorVersion()
 
 	// Query database
 	var embeddingBytes []byte
@@ -130,10 +393,74 @@ func (s *EmbeddingService) GetEmbedding(entryID int64) ([]float32, error) {
 	return embedding, nil
 }
 
+// EntryVectors returns the stored vectors of the given entries under the current model.
+// Entries without one are left out.
+func (s *EmbeddingService) EntryVectors(entryIDs []int64) (map[int64][]float32, error) {
+	if s.db == nil || s.provider == nil {
+		return nil, fmt.Errorf("embedding service not initialized")
+	}
+	vectors := make(map[int64][]float32, len(entryIDs))
+	if len(entryIDs) == 0 {
+		return vectors, nil
+	}
+	args := []interface{}{s.vectorVersion()}
+	for _, id := range entryIDs {
+		args = append(args, id)
+	}
+	rows, err := s.db.Query(`SELECT codex_entry_id, embedding FROM codex_embeddings
+		WHERE vector_version = ? AND codex_entry_id IN (`+placeholders(len(entryIDs))+`)`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load entry vectors: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var data []byte
+		if err := rows.Scan(&id, &data); err != nil {
+			return nil, fmt.Errorf("failed to scan entry vector: %w", err)
+		}
+		if vec := deserializeEmbedding(data); len(vec) > 0 {
+			vectors[id] = vec
+		}
+	}
+	return vectors, rows.Err()
+}
+
+// CosineSimilarity compares two vectors; 0 if their lengths differ.
+func CosineSimilarity(a, b []float32) float32 {
+	return cosineSimilarity(a, b)
+}
+
+// embedQuery embeds a search query, reusing the vector of the previous query when the
+// text is the same so that one retrieval costs a single embedding request.
+func (s *EmbeddingService) embedQuery(query string) ([]float32, error) {
+	s.queryMu.Lock()
+	defer s.queryMu.Unlock()
+	if s.lastVector != nil && s.lastQuery == query {
+		return s.lastVector, nil
+	}
+	vector, err := s.CreateQueryEmbedding(query)
+	if err != nil {
+		return nil, err
+	}
+	s.lastQuery, s.lastVector = query, vector
+	return vector, nil
+}
+
 // SearchResult represents a search result with similarity score
 type SearchResult struct {
-	Entry database.CodexEntry
-	Score float32
+	Entry   database.CodexEntry
+	Score   float32
+	Passage *Passage // Best-matching passage of a long entry; nil for entries embedded whole
+}
+
+// Passage is one chunk of a long entry matched by a search.
+type Passage struct {
+	ChunkIndex int
+	Start      int // Byte offsets into the entry's embedded body
+	End        int
+	Text       string
+	Score      float32
 }
 
 // FindSimilarEntries finds entries similar to the query using cosine similarity
@@ -146,7 +473,7 @@ func (s *EmbeddingService) FindSimilarEntries(query string, limit int) ([]Search
 	}
 
 	// Generate embedding for query
-	queryEmbedding, err := s.CreateEmbedding(query)
+	queryEmbedding, err := s.embedQuery(query)
 	if err != nil {
 		// Log this error specifically
 		log.Printf("ERROR in FindSimilarEntries: failed to create query embedding: %v", err)
@@ -157,7 +484,12 @@ func (s *EmbeddingService) FindSimilarEntries(query string, limit int) ([]Search
 	if s.provider == nil {
 		return nil, fmt.Errorf("no embedding provider configured for search")
 	}
-	vectorVersion := s.provider.ModelIdentifier()
+	vectorVersion := s.vectorVersion()
+
+	// Use the ANN index once it's ready; it can't serve unlimited searches
+	if idx := s.vectorIndex(); idx != nil && idx.Ready() && limit > 0 {
+		return s.findWithIndex(idx, queryEmbedding, vectorVersion, limit)
+	}
 
 	rows, err := s.db.Query(`
 		SELECT e.id, e.name, e.type, e.content, e.created_at, e.updated_at, em.embedding FROM codex_entries e LEFT JOIN codex_embeddings em ON e.id = em.codex_entry_id AND em.vector_version = ?`, vectorVersion)
@@ -218,6 +550,9 @@ func (s *EmbeddingService) FindSimilarEntries(query string, limit int) ([]Search
 		// Depending on the error, you might want to return it, but often logging is sufficient
 	}
 
+	// Long entries are scored by their best-matching passage when it beats the whole-entry vector
+	s.applyPassageScores(queryEmbedding, vectorVersion, results, false)
+
 	// Sort by similarity (highest first)
 	sort.Slice(results, func(i, j int) bool {
 		// Handle NaN scores if any slipped through, putting them at the end
@@ -238,6 +573,226 @@ func (s *EmbeddingService) FindSimilarEntries(query string, limit int) ([]Search
 	return results, nil
 }
 
+// serializeEmbedding converts a float32 slice to little-endian bytes
+func serializeEmbedding(embedding []float32) []byte {
+	data := make([]byte, len(embedding)*4)
+	for i, v := range embedding {
+		binary.LittleEndian.PutUint32(data[i*4:], math.Float32bits(v))
+	}
+	return data
+}
+
+// applyPassageScores raises each result's score to its best passage score, recording the passage.
+// With onlyResults set, only passages of the given results are loaded.
+func (s *EmbeddingService) applyPassageScores(queryEmbedding []float32, vectorVersion string, results []SearchResult, onlyResults bool) {
+	if len(results) == 0 {
+		return
+	}
+	byEntry := make(map[int64]int, len(results))
+	args := []interface{}{vectorVersion}
+	for i, r := range results {
+		byEntry[r.Entry.ID] = i
+		args = append(args, r.Entry.ID)
+	}
+
+	query := `SELECT codex_entry_id, chunk_index, start_offset, end_offset, chunk_text, embedding
+		FROM codex_embedding_chunks WHERE vector_version = ?`
+	if onlyResults {
+		query += ` AND codex_entry_id IN (` + placeholders(len(results)) + `)`
+	} else {
+		args = args[:1]
+	}
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		log.Printf("Warning: Failed to query passage embeddings: %v", err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entryID int64
+		var passage Passage
+		var embeddingBytes []byte
+		if err := rows.Scan(&entryID, &passage.ChunkIndex, &passage.Start, &passage.End, &passage.Text, &embeddingBytes); err != nil {
+			log.Printf("Warning: Failed to scan passage row: %v", err)
+			continue
+		}
+		i, ok := byEntry[entryID]
+		if !ok {
+			continue
+		}
+		passage.Score = cosineSimilarity(queryEmbedding, deserializeEmbedding(embeddingBytes))
+		if math.IsNaN(float64(passage.Score)) {
+			continue
+		}
+		if best := results[i].Passage; best == nil || passage.Score > best.Score {
+			p := passage
+			results[i].Passage = &p
+			if p.Score > results[i].Score {
+				results[i].Score = p.Score
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Warning: Error iterating passage embeddings: %v", err)
+	}
+}
+
+// findWithIndex answers a search from the ANN index: candidates are the nearest entries
+// plus the owners of the nearest passages, then scored like the full scan. A quantized
+// index returns extra candidates, which are rescored from the stored float32 vectors.
+func (s *EmbeddingService) findWithIndex(idx *VectorIndex, queryEmbedding []float32, vectorVersion string, limit int) ([]SearchResult, error) {
+	candidates := limit
+	if idx.Quantized() {
+		candidates = limit * rescoreOversample
+	}
+	scores := make(map[int64]float32)
+	for _, hit := range idx.SearchEntries(queryEmbedding, candidates) {
+		scores[hit.ID] = hit.Score
+	}
+	if idx.Quantized() {
+		if err := s.rescoreEntries(queryEmbedding, vectorVersion, scores); err != nil {
+			log.Printf("Warning: Failed to rescore search candidates, using estimated scores: %v", err)
+		}
+	}
+	_, owners := idx.SearchChunks(queryEmbedding, candidates*2)
+	for _, owner := range owners {
+		if _, ok := scores[owner]; !ok {
+			scores[owner] = 0 // Raised to its passage score below
+		}
+	}
+	if len(scores) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]interface{}, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+	rows, err := s.db.Query(`SELECT id, name, type, content, created_at, updated_at FROM codex_entries WHERE id IN (`+placeholders(len(ids))+`)`, ids...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load matched entries: %w", err)
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var entry database.CodexEntry
+		if err := rows.Scan(&entry.ID, &entry.Name, &entry.Type, &entry.Content, &entry.CreatedAt, &entry.UpdatedAt); err != nil {
+			log.Printf("Warning: Failed to scan entry row during search: %v", err)
+			continue
+		}
+		results = append(results, SearchResult{Entry: entry, Score: scores[entry.ID]})
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Warning: Error during row iteration in findWithIndex: %v", err)
+	}
+
+	s.applyPassageScores(queryEmbedding, vectorVersion, results, true)
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// rescoreEntries replaces estimated scores from a quantized index with exact cosine
+// similarity against the stored vectors.
+func (s *EmbeddingService) rescoreEntries(queryEmbedding []float32, vectorVersion string, scores map[int64]float32) error {
+	if len(scores) == 0 {
+		return nil
+	}
+	args := []interface{}{vectorVersion}
+	for id := range scores {
+		args = append(args, id)
+	}
+	rows, err := s.db.Query(`SELECT codex_entry_id, embedding FROM codex_embeddings
+		WHERE vector_version = ? AND codex_entry_id IN (`+placeholders(len(scores))+`)`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to load candidate vectors: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var data []byte
+		if err := rows.Scan(&id, &data); err != nil {
+			return fmt.Errorf("failed to scan candidate vector: %w", err)
+		}
+		if vec := deserializeEmbedding(data); len(vec) > 0 {
+			scores[id] = cosineSimilarity(queryEmbedding, vec)
+		}
+	}
+	return rows.Err()
+}
+
+// --- Vector index lifecycle ---
+
+// IndexStats describes the state of the ANN index.
+type IndexStats struct {
+	Ready        bool   `json:"ready"`
+	Entries      int    `json:"entries"`
+	Passages     int    `json:"passages"`
+	Quantization string `json:"quantization"`
+	MemoryBytes  int64  `json:"memoryBytes"` // Memory taken by the indexed vectors
+}
+
+// OpenIndex loads the persisted ANN index from dir, or builds it from the stored vectors.
+// It blocks until done; saves and deletes made meanwhile are applied to the index.
+func (s *EmbeddingService) OpenIndex(dir string) error {
+	if s.db == nil || s.provider == nil {
+		return fmt.Errorf("embedding service not initialized")
+	}
+	idx := newVectorIndex(s.vectorVersion(), s.storage.Quantization)
+	s.indexMu.Lock()
+	s.index = idx
+	s.indexDir = dir
+	s.indexMu.Unlock()
+	return idx.load(s.db, dir)
+}
+
+// SaveIndex persists the ANN index if it changed since it was loaded or last saved.
+func (s *EmbeddingService) SaveIndex() error {
+	s.indexMu.RLock()
+	idx, dir := s.index, s.indexDir
+	s.indexMu.RUnlock()
+	if idx == nil {
+		return nil
+	}
+	return idx.save(s.db, dir)
+}
+
+// RemoveEntry drops a deleted entry from the ANN index.
+func (s *EmbeddingService) RemoveEntry(entryID int64) {
+	if idx := s.vectorIndex(); idx != nil {
+		idx.RemoveEntry(entryID)
+	}
+}
+
+// IndexStats reports whether the ANN index is ready and how many vectors it holds.
+func (s *EmbeddingService) IndexStats() IndexStats {
+	idx := s.vectorIndex()
+	if idx == nil {
+		return IndexStats{}
+	}
+	entries, passages, memory := idx.Stats()
+	return IndexStats{Ready: idx.Ready(), Entries: entries, Passages: passages, Quantization: idx.quantization, MemoryBytes: memory}
+}
+
+func (s *EmbeddingService) vectorIndex() *VectorIndex {
+	s.indexMu.RLock()
+	defer s.indexMu.RUnlock()
+	return s.index
+}
+
+// placeholders returns "?,?,..." for an IN clause with n values.
+func placeholders(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return strings.Repeat("?,", n-1) + "?"
+}
+
 // deserializeEmbedding converts bytes to float32 slice
 func deserializeEmbedding(data []byte) []float32 {
 	if len(data) == 0 || len(data)%4 != 0 {
@@ -0,0 +1,46 @@
+package embeddings
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitBatches(t *testing.T) {
+	text := func(n int) string { return strings.Repeat("x", n) }
+	tests := []struct {
+		name   string
+		texts  []string
+		limits BatchLimits
+		want   []int // Batch sizes
+	}{
+		{"empty", nil, BatchLimits{MaxTexts: 2, MaxChars: 10}, nil},
+		{"no limits", []string{"a", "b", "c"}, BatchLimits{}, []int{3}},
+		{"under text limit", []string{"a", "b"}, BatchLimits{MaxTexts: 3}, []int{2}},
+		{"exactly text limit", []string{"a", "b", "c"}, BatchLimits{MaxTexts: 3}, []int{3}},
+		{"one over text limit", []string{"a", "b", "c", "d"}, BatchLimits{MaxTexts: 3}, []int{3, 1}},
+		{"text limit of one", []string{"a", "b", "c"}, BatchLimits{MaxTexts: 1}, []int{1, 1, 1}},
+		{"exactly char limit", []string{text(4), text(6)}, BatchLimits{MaxChars: 10}, []int{2}},
+		{"one over char limit", []string{text(4), text(7)}, BatchLimits{MaxChars: 10}, []int{1, 1}},
+		{"single text over char limit", []string{text(25), text(1)}, BatchLimits{MaxChars: 10}, []int{1, 1}},
+		{"both limits", []string{text(3), text(3), text(3), text(3), text(3)}, BatchLimits{MaxTexts: 2, MaxChars: 5}, []int{1, 1, 1, 1, 1}},
+		{"char limit first", []string{text(2), text(2), text(2), text(9)}, BatchLimits{MaxTexts: 10, MaxChars: 8}, []int{3, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batches := splitBatches(tt.texts, tt.limits)
+			var sizes []int
+			var joined []string
+			for _, b := range batches {
+				sizes = append(sizes, len(b))
+				joined = append(joined, b...)
+			}
+			if !reflect.DeepEqual(sizes, tt.want) {
+				t.Errorf("batch sizes = %v, want %v", sizes, tt.want)
+			}
+			if len(tt.texts) > 0 && !reflect.DeepEqual(joined, tt.texts) {
+				t.Errorf("batches do not preserve the texts in order")
+			}
+		})
+	}
+}
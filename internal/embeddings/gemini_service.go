@@ -2,67 +2,152 @@
 package embeddings
 
 import (
+	"Llore/internal/provider"
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"sync"
 
 	"google.golang.org/genai"
 )
 
 const (
-	GeminiEmbeddingModel = "text-embedding-004" // Updated to a more current model
+	GeminiEmbeddingModel      = "text-embedding-004" // Default when no model is configured
 	TaskTypeRetrievalDocument = "RETRIEVAL_DOCUMENT"
+	TaskTypeRetrievalQuery    = "RETRIEVAL_QUERY"
 )
 
-// GeminiEmbeddingProvider implements the EmbeddingProvider interface for Gemini
-// It now correctly returns []float32 for CreateEmbedding.
+// GeminiEmbeddingProvider implements the EmbeddingProvider interface for Gemini.
+// Stored content is embedded as RETRIEVAL_DOCUMENT and searches as RETRIEVAL_QUERY.
 type GeminiEmbeddingProvider struct {
-	apiKey string
+	apiKey     string
+	model      string
+	dimensions int32 // Requested output dimensionality; 0 uses the model's default
+
+	clientMu sync.Mutex
+	client   *genai.Client // Created on first use and reused for every request
 }
 
-// NewGeminiEmbeddingProvider creates a new Gemini embedding provider
-func NewGeminiEmbeddingProvider(apiKey string) *GeminiEmbeddingProvider {
+// NewGeminiEmbeddingProvider creates a new Gemini embedding provider. An empty model uses
+// GeminiEmbeddingModel; dimensions > 0 asks the model for shorter vectors (supported by
+// text-embedding-004 and newer, not embedding-001).
+func NewGeminiEmbeddingProvider(apiKey, model string, dimensions int) *GeminiEmbeddingProvider {
+	if model == "" {
+		model = GeminiEmbeddingModel
+	}
+	if dimensions < 0 {
+		dimensions = 0
+	}
+	log.Printf("GeminiEmbeddingProvider: Initializing for model '%s' (dimensions: %d)", model, dimensions)
 	return &GeminiEmbeddingProvider{
-		apiKey: apiKey,
+		apiKey:     apiKey,
+		model:      model,
+		dimensions: int32(dimensions),
 	}
 }
 
-// CreateEmbedding generates an embedding for the given text using the Gemini SDK.
-// It now returns []float32 as expected by the EmbeddingProvider interface.
+// CreateEmbedding generates a document embedding for the given text using the Gemini SDK.
 func (p *GeminiEmbeddingProvider) CreateEmbedding(text string) ([]float32, error) {
-	if p.apiKey == "" {
-		return nil, fmt.Errorf("Gemini API key is not configured")
+	vectors, err := p.embed([]string{text}, TaskTypeRetrievalDocument)
+	if err != nil {
+		return nil, err
 	}
+	return vectors[0], nil
+}
 
-	ctx := context.Background()
-	client, err := genai.NewClient(ctx, &genai.ClientConfig{APIKey: p.apiKey})
+// CreateQueryEmbedding generates a query embedding for searching document embeddings.
+func (p *GeminiEmbeddingProvider) CreateQueryEmbedding(text string) ([]float32, error) {
+	vectors, err := p.embed([]string{text}, TaskTypeRetrievalQuery)
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+// BatchLimits reflects the batchEmbedContents limit of 100 requests per call.
+func (p *GeminiEmbeddingProvider) BatchLimits() BatchLimits {
+	return BatchLimits{MaxTexts: 100, MaxChars: 400000}
+}
+
+// CreateEmbeddings embeds several documents in one call; the SDK uses batchEmbedContents
+// whenever more than one content is passed.
+func (p *GeminiEmbeddingProvider) CreateEmbeddings(texts []string) ([][]float32, error) {
+	return p.embed(texts, TaskTypeRetrievalDocument)
+}
+
+// getClient returns the shared genai client, creating it on first use.
+func (p *GeminiEmbeddingProvider) getClient(ctx context.Context) (*genai.Client, error) {
+	p.clientMu.Lock()
+	defer p.clientMu.Unlock()
+	if p.client != nil {
+		return p.client, nil
+	}
+	client, err := genai.NewClient(ctx, &genai.ClientConfig{APIKey: p.apiKey, HTTPClient: provider.HTTPClient(provider.ClientEmbeddings)})
 	if err != nil {
 		log.Printf("GeminiEmbeddingProvider: failed to create genai client: %v", err)
 		return nil, fmt.Errorf("failed to create Gemini client: %w", err)
 	}
+	p.client = client
+	return client, nil
+}
+
+func (p *GeminiEmbeddingProvider) embed(texts []string, taskType string) ([][]float32, error) {
+	if p.apiKey == "" {
+		return nil, provider.NewError("gemini", p.model, provider.ErrNotConfigured, "Gemini API key is not configured", nil)
+	}
+	if len(texts) == 0 {
+		return nil, nil
+	}
 
-	resp, err := client.Models.EmbedContent(ctx, GeminiEmbeddingModel, genai.Text(text), &genai.EmbedContentConfig{TaskType: TaskTypeRetrievalDocument})
+	ctx := context.Background()
+	client, err := p.getClient(ctx)
 	if err != nil {
-		log.Printf("GeminiEmbeddingProvider: failed to embed content: %v", err)
-		return nil, fmt.Errorf("failed to embed content with Gemini: %w", err)
+		return nil, err
 	}
 
-	if resp == nil || len(resp.Embeddings) == 0 || resp.Embeddings[0] == nil {
-		log.Printf("GeminiEmbeddingProvider: received nil response or no embeddings or nil embedding")
-		return nil, fmt.Errorf("gemini embedding response contained no embeddings")
+	contents := make([]*genai.Content, len(texts))
+	for i, text := range texts {
+		contents[i] = genai.NewContentFromText(text, genai.RoleUser)
 	}
 
-	embeddingValues := resp.Embeddings[0].Values
-	if embeddingValues == nil {
-		log.Printf("GeminiEmbeddingProvider: embedding values are nil")
-		return nil, fmt.Errorf("gemini embedding values are nil")
+	config := &genai.EmbedContentConfig{TaskType: taskType}
+	if p.dimensions > 0 {
+		config.OutputDimensionality = &p.dimensions
+	}
+	resp, err := client.Models.EmbedContent(ctx, p.model, contents, config)
+	if err != nil {
+		log.Printf("GeminiEmbeddingProvider: failed to embed content: %v", err)
+		var apiErr genai.APIError
+		if errors.As(err, &apiErr) {
+			perr := provider.FromHTTPStatus("gemini", p.model, apiErr.Code, apiErr.Status+" "+apiErr.Message)
+			perr.Err = err
+			return nil, perr
+		}
+		return nil, provider.FromTransport("gemini", p.model, err)
 	}
 
-	// Return []float32 directly, as assumed from SDK and required by interface
-	return embeddingValues, nil
+	if resp == nil || len(resp.Embeddings) != len(texts) {
+		log.Printf("GeminiEmbeddingProvider: received nil response or wrong number of embeddings")
+		return nil, provider.NewError("gemini", p.model, provider.ErrEmptyResponse, "gemini embedding response did not contain one embedding per input", nil)
+	}
+
+	vectors := make([][]float32, len(texts))
+	for i, embedding := range resp.Embeddings {
+		if embedding == nil || len(embedding.Values) == 0 {
+			log.Printf("GeminiEmbeddingProvider: embedding values are nil for input %d", i)
+			return nil, provider.NewError("gemini", p.model, provider.ErrEmptyResponse, "gemini embedding values are nil", nil)
+		}
+		vectors[i] = embedding.Values
+	}
+	return vectors, nil
 }
 
-// ModelIdentifier returns the specific model identifier for this provider.
+// ModelIdentifier returns the specific model identifier for this provider. A reduced
+// output dimension is part of it, since those vectors can't be compared with full ones.
 func (p *GeminiEmbeddingProvider) ModelIdentifier() string {
-	return fmt.Sprintf("gemini:%s", GeminiEmbeddingModel)
+	if p.dimensions > 0 {
+		return fmt.Sprintf("gemini:%s@%d", p.model, p.dimensions)
+	}
+	return fmt.Sprintf("gemini:%s", p.model)
 }
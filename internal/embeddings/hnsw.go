@@ -0,0 +1,515 @@
+// internal/embeddings/hnsw.go
+package embeddings
+
+import (
+	"container/heap"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// HNSW is an in-memory Hierarchical Navigable Small World graph for approximate
+// nearest-neighbour search by cosine similarity (Malkov & Yashunin, 2016).
+// Vectors are normalized on insert so similarity is a dot product. They can be kept
+// quantized (see quantization.go) to cut the index's memory use.
+type HNSW struct {
+	mu             sync.RWMutex
+	m              int // Max links per node on upper layers
+	mMax0          int // Max links per node on layer 0
+	efConstruction int
+	efSearch       int
+	levelMult      float64
+	dim            int
+	quantization   string
+	nodes          map[int64]*hnswNode
+	entryPoint     int64
+	maxLevel       int
+	rng            *rand.Rand
+}
+
+type hnswNode struct {
+	code  vectorCode
+	level int
+	links [][]int64 // Neighbour IDs per layer, 0..level
+}
+
+// IndexHit is a search result from a vector index.
+type IndexHit struct {
+	ID    int64
+	Score float32 // Cosine similarity
+}
+
+// NewHNSW creates an empty index. m around 16 and efConstruction around 100 give high
+// recall for text embeddings; efSearch trades query latency for recall.
+func NewHNSW(m, efConstruction, efSearch int) *HNSW {
+	return NewQuantizedHNSW(m, efConstruction, efSearch, QuantizeNone)
+}
+
+// NewQuantizedHNSW creates an empty index that keeps its vectors in the given
+// quantization. Scores it returns are then estimates and should be rescored.
+func NewQuantizedHNSW(m, efConstruction, efSearch int, quantization string) *HNSW {
+	if m < 2 {
+		m = 16
+	}
+	if efConstruction < m {
+		efConstruction = 200
+	}
+	if efSearch <= 0 {
+		efSearch = 64
+	}
+	return &HNSW{
+		m:              m,
+		mMax0:          m * 2,
+		efConstruction: efConstruction,
+		efSearch:       efSearch,
+		levelMult:      1 / math.Log(float64(m)),
+		quantization:   quantization,
+		nodes:          make(map[int64]*hnswNode),
+		maxLevel:       -1,
+		rng:            rand.New(rand.NewSource(42)),
+	}
+}
+
+// Len returns the number of indexed vectors.
+func (h *HNSW) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.nodes)
+}
+
+// Quantization returns how the index stores its vectors.
+func (h *HNSW) Quantization() string {
+	return h.quantization
+}
+
+// MemoryBytes is the memory taken by the indexed vectors, excluding graph links.
+func (h *HNSW) MemoryBytes() int64 {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	var total int64
+	for _, node := range h.nodes {
+		total += int64(node.code.size())
+	}
+	return total
+}
+
+// Has reports whether id is indexed.
+func (h *HNSW) Has(id int64) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	_, ok := h.nodes[id]
+	return ok
+}
+
+// Add inserts or replaces the vector for id.
+func (h *HNSW) Add(id int64, vector []float32) error {
+	vec := normalize(vector)
+	if vec == nil {
+		return fmt.Errorf("cannot index zero or empty vector for id %d", id)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.nodes) == 0 {
+		h.dim = len(vec)
+	} else if len(vec) != h.dim {
+		return fmt.Errorf("vector for id %d has %d dimensions, index has %d", id, len(vec), h.dim)
+	}
+	if _, exists := h.nodes[id]; exists {
+		h.removeLocked(id)
+	}
+
+	level := int(math.Floor(-math.Log(1-h.rng.Float64()) * h.levelMult))
+	node := &hnswNode{code: encodeVector(vec, h.quantization), level: level, links: make([][]int64, level+1)}
+	query := newHNSWQuery(vec, h.quantization)
+	h.nodes[id] = node
+
+	if h.maxLevel < 0 {
+		h.entryPoint = id
+		h.maxLevel = level
+		return nil
+	}
+
+	ep := h.entryPoint
+	for l := h.maxLevel; l > level; l-- {
+		ep = h.greedyClosest(&query, ep, l)
+	}
+
+	eps := []int64{ep}
+	for l := minInt(level, h.maxLevel); l >= 0; l-- {
+		candidates := h.searchLayer(&query, eps, h.efConstruction, l)
+		neighbours := h.selectNeighbours(candidates, h.m)
+		node.links[l] = neighbours
+
+		for _, nb := range neighbours {
+			nbNode := h.nodes[nb]
+			nbNode.links[l] = append(nbNode.links[l], id)
+			if maxConn := h.maxConnections(l); len(nbNode.links[l]) > maxConn {
+				// Plain closest-first pruning here: running the heuristic on every overflow
+				// dominates build time for a small recall gain
+				nbNode.links[l] = closest(h.scored(&nbNode.code, nbNode.links[l]), maxConn)
+			}
+		}
+
+		eps = eps[:0]
+		for _, c := range candidates {
+			eps = append(eps, c.ID)
+		}
+	}
+
+	if level > h.maxLevel {
+		h.entryPoint = id
+		h.maxLevel = level
+	}
+	return nil
+}
+
+// Remove deletes id and reconnects its former neighbours so the graph stays navigable.
+func (h *HNSW) Remove(id int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.removeLocked(id)
+}
+
+func (h *HNSW) removeLocked(id int64) {
+	node, ok := h.nodes[id]
+	if !ok {
+		return
+	}
+	delete(h.nodes, id)
+
+	for l := 0; l <= node.level; l++ {
+		for _, nb := range node.links[l] {
+			nbNode, ok := h.nodes[nb]
+			if !ok || len(nbNode.links) <= l {
+				continue
+			}
+			// Candidate set: the neighbour's remaining links plus the removed node's links
+			seen := map[int64]bool{nb: true, id: true}
+			var pool []int64
+			for _, c := range append(append([]int64{}, nbNode.links[l]...), node.links[l]...) {
+				if !seen[c] {
+					if _, alive := h.nodes[c]; alive {
+						seen[c] = true
+						pool = append(pool, c)
+					}
+				}
+			}
+			nbNode.links[l] = h.selectNeighbours(h.scored(&nbNode.code, pool), h.maxConnections(l))
+		}
+	}
+
+	if h.entryPoint == id {
+		h.maxLevel = -1
+		for otherID, other := range h.nodes {
+			if other.level > h.maxLevel {
+				h.entryPoint = otherID
+				h.maxLevel = other.level
+			}
+		}
+	}
+}
+
+// Search returns up to k nearest neighbours of query, most similar first.
+func (h *HNSW) Search(query []float32, k int) []IndexHit {
+	vec := normalize(query)
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if vec == nil || h.maxLevel < 0 || len(vec) != h.dim || k <= 0 {
+		return nil
+	}
+
+	q := newHNSWQuery(vec, h.quantization)
+	ep := h.entryPoint
+	for l := h.maxLevel; l > 0; l-- {
+		ep = h.greedyClosest(&q, ep, l)
+	}
+	hits := h.searchLayer(&q, []int64{ep}, maxInt(h.efSearch, k), 0)
+	if len(hits) > k {
+		hits = hits[:k]
+	}
+	return hits
+}
+
+// SetEfSearch changes the query-time candidate list size.
+func (h *HNSW) SetEfSearch(ef int) {
+	if ef > 0 {
+		h.mu.Lock()
+		h.efSearch = ef
+		h.mu.Unlock()
+	}
+}
+
+func (h *HNSW) maxConnections(level int) int {
+	if level == 0 {
+		return h.mMax0
+	}
+	return h.m
+}
+
+// greedyClosest walks layer l from ep towards the node closest to q.
+func (h *HNSW) greedyClosest(q *hnswQuery, ep int64, l int) int64 {
+	best := ep
+	bestSim := querySim(q, &h.nodes[ep].code, h.dim)
+	for changed := true; changed; {
+		changed = false
+		for _, nb := range h.nodes[best].links[l] {
+			nbNode, ok := h.nodes[nb]
+			if !ok {
+				continue
+			}
+			if sim := querySim(q, &nbNode.code, h.dim); sim > bestSim {
+				best, bestSim, changed = nb, sim, true
+			}
+		}
+	}
+	return best
+}
+
+// searchLayer is the beam search of the HNSW paper; results are sorted most similar first.
+func (h *HNSW) searchLayer(q *hnswQuery, eps []int64, ef int, l int) []IndexHit {
+	visited := make(map[int64]bool, ef*4)
+	candidates := &hitHeap{max: true}
+	results := &hitHeap{}
+
+	for _, ep := range eps {
+		node, ok := h.nodes[ep]
+		if !ok || visited[ep] {
+			continue
+		}
+		visited[ep] = true
+		hit := IndexHit{ID: ep, Score: querySim(q, &node.code, h.dim)}
+		heap.Push(candidates, hit)
+		heap.Push(results, hit)
+	}
+
+	for candidates.Len() > 0 {
+		c := heap.Pop(candidates).(IndexHit)
+		if results.Len() >= ef && c.Score < results.items[0].Score {
+			break
+		}
+		node := h.nodes[c.ID]
+		if len(node.links) <= l {
+			continue
+		}
+		for _, nb := range node.links[l] {
+			if visited[nb] {
+				continue
+			}
+			visited[nb] = true
+			nbNode, ok := h.nodes[nb]
+			if !ok {
+				continue // Link left behind by a removal; harmless
+			}
+			sim := querySim(q, &nbNode.code, h.dim)
+			if results.Len() < ef || sim > results.items[0].Score {
+				hit := IndexHit{ID: nb, Score: sim}
+				heap.Push(candidates, hit)
+				heap.Push(results, hit)
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+
+	out := append([]IndexHit(nil), results.items...)
+	sort.Slice(out, func(i, j int) bool { return out[i].Score > out[j].Score })
+	return out
+}
+
+// selectNeighbours applies the paper's diversity heuristic: a candidate is kept only if it
+// is closer to the base than to any neighbour already kept. Remaining slots are filled
+// with the closest discarded candidates so sparse regions stay connected.
+func (h *HNSW) selectNeighbours(candidates []IndexHit, m int) []int64 {
+	sorted := append([]IndexHit(nil), candidates...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Score > sorted[j].Score })
+
+	selected := make([]int64, 0, m)
+	var discarded []int64
+	for _, c := range sorted {
+		if len(selected) >= m {
+			break
+		}
+		cCode := &h.nodes[c.ID].code
+		keep := true
+		for _, s := range selected {
+			if codeSim(cCode, &h.nodes[s].code, h.dim) > c.Score {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			selected = append(selected, c.ID)
+		} else {
+			discarded = append(discarded, c.ID)
+		}
+	}
+	for _, d := range discarded {
+		if len(selected) >= m {
+			break
+		}
+		selected = append(selected, d)
+	}
+	return selected
+}
+
+// closest returns the IDs of the m most similar hits.
+func closest(hits []IndexHit, m int) []int64 {
+	sort.Slice(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	if len(hits) > m {
+		hits = hits[:m]
+	}
+	ids := make([]int64, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.ID
+	}
+	return ids
+}
+
+// scored computes the similarity of each live id to base.
+func (h *HNSW) scored(base *vectorCode, ids []int64) []IndexHit {
+	hits := make([]IndexHit, 0, len(ids))
+	for _, id := range ids {
+		if node, ok := h.nodes[id]; ok {
+			hits = append(hits, IndexHit{ID: id, Score: codeSim(base, &node.code, h.dim)})
+		}
+	}
+	return hits
+}
+
+// --- Persistence ---
+
+// hnswGraph is the on-disk form of the index. Vectors are not stored: they already live in
+// the vault database and are attached again on load, which keeps the file small.
+type hnswGraph struct {
+	M, EfConstruction, EfSearch int
+	Dim                         int
+	Quantization                string // Empty in files saved before quantization existed
+	EntryPoint                  int64
+	MaxLevel                    int
+	IDs                         []int64
+	Levels                      []int
+	Links                       [][][]int64
+}
+
+// WriteGraph serializes the graph structure (not the vectors) to w.
+func (h *HNSW) WriteGraph(w io.Writer) error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	g := hnswGraph{
+		M: h.m, EfConstruction: h.efConstruction, EfSearch: h.efSearch,
+		Dim: h.dim, Quantization: h.quantization, EntryPoint: h.entryPoint, MaxLevel: h.maxLevel,
+	}
+	for id, node := range h.nodes {
+		g.IDs = append(g.IDs, id)
+		g.Levels = append(g.Levels, node.level)
+		g.Links = append(g.Links, node.links)
+	}
+	return gob.NewEncoder(w).Encode(&g)
+}
+
+// ReadHNSWGraph restores an index from a graph written by WriteGraph. vectors must hold
+// exactly the IDs in the graph; otherwise the graph is stale and an error is returned.
+func ReadHNSWGraph(r io.Reader, vectors map[int64][]float32) (*HNSW, error) {
+	var g hnswGraph
+	if err := gob.NewDecoder(r).Decode(&g); err != nil {
+		return nil, fmt.Errorf("failed to decode index graph: %w", err)
+	}
+	if len(g.IDs) != len(vectors) {
+		return nil, fmt.Errorf("index graph has %d vectors, database has %d", len(g.IDs), len(vectors))
+	}
+
+	if g.Quantization == "" {
+		g.Quantization = QuantizeNone
+	}
+	h := NewQuantizedHNSW(g.M, g.EfConstruction, g.EfSearch, g.Quantization)
+	h.dim = g.Dim
+	h.entryPoint = g.EntryPoint
+	h.maxLevel = g.MaxLevel
+	for i, id := range g.IDs {
+		vec := normalize(vectors[id])
+		if vec == nil || len(vec) != g.Dim {
+			return nil, fmt.Errorf("vector for id %d is missing or has the wrong dimension", id)
+		}
+		h.nodes[id] = &hnswNode{code: encodeVector(vec, h.quantization), level: g.Levels[i], links: g.Links[i]}
+	}
+	return h, nil
+}
+
+// --- Helpers ---
+
+// hitHeap is a min-heap on Score, or a max-heap when max is set.
+type hitHeap struct {
+	items []IndexHit
+	max   bool
+}
+
+func (hh hitHeap) Len() int { return len(hh.items) }
+func (hh hitHeap) Less(i, j int) bool {
+	if hh.max {
+		return hh.items[i].Score > hh.items[j].Score
+	}
+	return hh.items[i].Score < hh.items[j].Score
+}
+func (hh hitHeap) Swap(i, j int)       { hh.items[i], hh.items[j] = hh.items[j], hh.items[i] }
+func (hh *hitHeap) Push(x interface{}) { hh.items = append(hh.items, x.(IndexHit)) }
+func (hh *hitHeap) Pop() interface{} {
+	old := hh.items
+	item := old[len(old)-1]
+	hh.items = old[:len(old)-1]
+	return item
+}
+
+// normalize returns a unit-length copy of v, or nil for empty or zero vectors.
+func normalize(v []float32) []float32 {
+	var norm float64
+	for _, x := range v {
+		norm += float64(x) * float64(x)
+	}
+	if len(v) == 0 || norm == 0 {
+		return nil
+	}
+	inv := 1 / math.Sqrt(norm)
+	out := make([]float32, len(v))
+	for i, x := range v {
+		out[i] = float32(float64(x) * inv)
+	}
+	return out
+}
+
+func dot(a, b []float32) float32 {
+	b = b[:len(a)]
+	var s0, s1, s2, s3 float32
+	i := 0
+	for ; i+4 <= len(a); i += 4 {
+		s0 += a[i] * b[i]
+		s1 += a[i+1] * b[i+1]
+		s2 += a[i+2] * b[i+2]
+		s3 += a[i+3] * b[i+3]
+	}
+	for ; i < len(a); i++ {
+		s0 += a[i] * b[i]
+	}
+	return s0 + s1 + s2 + s3
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,183 @@
+// internal/embeddings/library_index.go
+package embeddings
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+)
+
+// LibraryPassage is a passage of a Library manuscript matched by a search. Lines are
+// 1-based and inclusive, offsets are bytes into the file; both are meant for citations.
+type LibraryPassage struct {
+	FilePath   string  `json:"filePath"` // Relative to the Library folder, forward slashes
+	ChunkIndex int     `json:"chunkIndex"`
+	Start      int     `json:"start"`
+	End        int     `json:"end"`
+	StartLine  int     `json:"startLine"`
+	EndLine    int     `json:"endLine"`
+	Text       string  `json:"text"`
+	Score      float32 `json:"score"`
+}
+
+// Citation formats the passage location as shown to the model and the user.
+func (p LibraryPassage) Citation() string {
+	if p.StartLine == p.EndLine {
+		return fmt.Sprintf("%s, line %d", p.FilePath, p.StartLine)
+	}
+	return fmt.Sprintf("%s, lines %d-%d", p.FilePath, p.StartLine, p.EndLine)
+}
+
+// IndexLibraryFile chunks and embeds a manuscript, replacing its previous passages.
+// Unchanged files (same content hash for the current provider) are skipped. Returns
+// whether the file was re-embedded.
+func (s *EmbeddingService) IndexLibraryFile(filePath, content string) (bool, error) {
+	if s.db == nil {
+		return false, fmt.Errorf("database connection is nil")
+	}
+	if s.provider == nil {
+		return false, fmt.Errorf("no embedding provider configured for saving")
+	}
+	vectorVersion := s.vectorVersion()
+	hash := ContentHash(content)
+
+	var storedHash string
+	err := s.db.QueryRow(`SELECT content_hash FROM library_documents WHERE file_path = ? AND vector_version = ?`, filePath, vectorVersion).Scan(&storedHash)
+	if err == nil && storedHash == hash {
+		return false, nil
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return false, fmt.Errorf("failed to look up library file %s: %w", filePath, err)
+	}
+
+	chunks := ChunkText(content, DefaultChunkSize, DefaultChunkOverlap)
+	var vectors [][]float32
+	if len(chunks) > 0 {
+		texts := make([]string, len(chunks))
+		for i, chunk := range chunks {
+			texts[i] = "Manuscript: " + filePath + "\n" + chunk.Text
+		}
+		if vectors, err = s.CreateEmbeddings(texts); err != nil {
+			return false, fmt.Errorf("failed to embed passages of %s: %w", filePath, err)
+		}
+	}
+
+	s.dbMutex.Lock()
+	defer s.dbMutex.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin library index transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM library_chunks WHERE file_path = ? AND vector_version = ?`, filePath, vectorVersion); err != nil {
+		return false, fmt.Errorf("failed to clear old passages of %s: %w", filePath, err)
+	}
+	for i, chunk := range chunks {
+		startLine := 1 + strings.Count(content[:chunk.Start], "\n")
+		endLine := startLine + strings.Count(strings.TrimRight(content[chunk.Start:chunk.End], "\n"), "\n")
+		_, err := tx.Exec(
+			`INSERT INTO library_chunks
+			 (file_path, vector_version, chunk_index, start_offset, end_offset, start_line, end_line, chunk_text, embedding, created_at)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, datetime('now'))`,
+			filePath, vectorVersion, chunk.Index, chunk.Start, chunk.End, startLine, endLine, chunk.Text, serializeEmbedding(vectors[i]),
+		)
+		if err != nil {
+			return false, fmt.Errorf("failed to save passage %d of %s: %w", chunk.Index, filePath, err)
+		}
+	}
+	_, err = tx.Exec(
+		`INSERT INTO library_documents (file_path, vector_version, content_hash, indexed_at)
+		 VALUES (?, ?, ?, datetime('now'))
+		 ON CONFLICT(file_path, vector_version) DO UPDATE SET content_hash = excluded.content_hash, indexed_at = excluded.indexed_at`,
+		filePath, vectorVersion, hash,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to record library file %s: %w", filePath, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit passages of %s: %w", filePath, err)
+	}
+
+	log.Printf("Indexed library file %s: %d passages (provider: %s)", filePath, len(chunks), vectorVersion)
+	return true, nil
+}
+
+// IndexedLibraryPaths lists the files that have passages for the current provider.
+func (s *EmbeddingService) IndexedLibraryPaths() ([]string, error) {
+	if s.db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	if s.provider == nil {
+		return nil, fmt.Errorf("no embedding provider configured")
+	}
+	rows, err := s.db.Query(`SELECT file_path FROM library_documents WHERE vector_version = ?`, s.vectorVersion())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list indexed library files: %w", err)
+	}
+	defer rows.Close()
+
+	var paths []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, fmt.Errorf("failed to scan library path: %w", err)
+		}
+		paths = append(paths, path)
+	}
+	return paths, rows.Err()
+}
+
+// FindSimilarPassages searches manuscript passages. Manuscripts are scanned rather than
+// indexed with HNSW: even a long novel is only a few hundred passages.
+func (s *EmbeddingService) FindSimilarPassages(query string, limit int) ([]LibraryPassage, error) {
+	if s.db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	if s.provider == nil {
+		return nil, fmt.Errorf("no embedding provider configured")
+	}
+	queryEmbedding, err := s.embedQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create query embedding: %w", err)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT file_path, chunk_index, start_offset, end_offset, start_line, end_line, chunk_text, embedding
+		FROM library_chunks WHERE vector_version = ?`, s.vectorVersion())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query library passages: %w", err)
+	}
+	defer rows.Close()
+
+	var results []LibraryPassage
+	for rows.Next() {
+		var p LibraryPassage
+		var embeddingBytes []byte
+		if err := rows.Scan(&p.FilePath, &p.ChunkIndex, &p.Start, &p.End, &p.StartLine, &p.EndLine, &p.Text, &embeddingBytes); err != nil {
+			log.Printf("Warning: Failed to scan library passage: %v", err)
+			continue
+		}
+		score := cosineSimilarity(queryEmbedding, deserializeEmbedding(embeddingBytes))
+		if math.IsNaN(float64(score)) {
+			continue
+		}
+		p.Score = score
+		results = append(results, p)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Warning: Error during row iteration in FindSimilarPassages: %v", err)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
@@ -2,18 +2,28 @@
 package embeddings
 
 import (
+	"Llore/internal/provider"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 )
 
 const (
 	// OllamaDefaultAPIEndpoint is the standard local endpoint for Ollama.
 	OllamaDefaultAPIEndpoint = "http://localhost:11434/api/embeddings"
+	// OllamaBatchAPIEndpoint accepts an array of inputs (Ollama 0.3.4+).
+	OllamaBatchAPIEndpoint = "http://localhost:11434/api/embed"
+	// ollamaTagsEndpoint lists the models Ollama has pulled.
+	ollamaTagsEndpoint = "http://localhost:11434/api/tags"
+	// ollamaPingTimeout bounds the availability check made when the provider is chosen.
+	ollamaPingTimeout = 3 * time.Second
 )
 
 // ollamaEmbeddingRequest defines the JSON structure for the request to Ollama.
@@ -28,13 +38,36 @@ type ollamaEmbeddingResponse struct {
 	Embedding []float32 `json:"embedding"`
 }
 
+// ollamaBatchEmbeddingRequest is the /api/embed request; Input takes several texts.
+type ollamaBatchEmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+// ollamaBatchEmbeddingResponse is the /api/embed response, one vector per input.
+type ollamaBatchEmbeddingResponse struct {
+	Embeddings [][]float32 `json:"embeddings"`
+}
+
+// ollamaTaskPrefixes are the instruction prefixes that retrieval models served by
+// Ollama were trained with, keyed by model name without namespace or tag. Ollama
+// doesn't add them itself, and without them query-to-document similarity suffers.
+var ollamaTaskPrefixes = map[string]struct{ query, document string }{
+	"nomic-embed-text":       {query: "search_query: ", document: "search_document: "},
+	"mxbai-embed-large":      {query: "Represent this sentence for searching relevant passages: "},
+	"snowflake-arctic-embed": {query: "Represent this sentence for searching relevant passages: "},
+}
+
 // LocalEmbeddingProvider implements the EmbeddingProvider interface by communicating
 // with a locally running Ollama instance.
 type LocalEmbeddingProvider struct {
 	modelName       string // The Ollama model tag (e.g., "nomic-embed-text")
 	apiEndpoint     string
+	batchEndpoint   string
 	httpClient      *http.Client
 	modelIdentifier string
+	queryPrefix     string
+	documentPrefix  string
 }
 
 // NewLocalEmbeddingProvider creates a new local embedding provider that connects to Ollama.
@@ -51,24 +84,100 @@ func NewLocalEmbeddingProvider(ollamaModelTag string) (*LocalEmbeddingProvider,
 	log.Println("INFO: This provider requires a local Ollama instance to be running.")
 	log.Printf("INFO: Ensure Ollama is running and that model '%s' has been pulled (e.g., `ollama pull %s`)", ollamaModelTag, ollamaModelTag)
 
-	client := &http.Client{
-		Timeout: 60 * time.Second, // Increased timeout as local models can sometimes be slow on first call.
-	}
+	// Shared transport; the embeddings timeout defaults to 60s as local models can be slow on first call.
+	client := provider.HTTPClient(provider.ClientEmbeddings)
 
 	// A preliminary check to see if Ollama is responsive can be added here,
 	// but it's often better to let the first CreateEmbedding call handle connection errors,
 	// as Ollama might be started *after* Llore.
 
-	return &LocalEmbeddingProvider{
+	p := &LocalEmbeddingProvider{
 		modelName:       ollamaModelTag,
 		apiEndpoint:     OllamaDefaultAPIEndpoint,
+		batchEndpoint:   OllamaBatchAPIEndpoint,
 		httpClient:      client,
 		modelIdentifier: fmt.Sprintf("ollama:%s", ollamaModelTag), // Unique ID for this provider configuration
-	}, nil
+	}
+	if prefixes, ok := ollamaTaskPrefixes[ollamaModelFamily(ollamaModelTag)]; ok {
+		p.queryPrefix, p.documentPrefix = prefixes.query, prefixes.document
+		log.Printf("LocalEmbeddingProvider: Using retrieval prefixes for '%s'", ollamaModelTag)
+		if p.documentPrefix != "" {
+			// Prefixed document vectors differ from those stored before prefixes were used
+			p.modelIdentifier += "+prefixed"
+		}
+	}
+	return p, nil
+}
+
+// ollamaModelFamily strips the namespace and tag from an Ollama model name, e.g.
+// "library/nomic-embed-text:v1.5" becomes "nomic-embed-text".
+func ollamaModelFamily(modelTag string) string {
+	name := strings.ToLower(modelTag)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	if i := strings.Index(name, ":"); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// Ping checks that Ollama is running and has the model pulled. Model names without a
+// tag match the ":latest" tag, as they do in Ollama itself.
+func (p *LocalEmbeddingProvider) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), ollamaPingTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", ollamaTagsEndpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create ollama HTTP request: %w", err)
+	}
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		perr := provider.FromTransport("ollama", p.modelName, err)
+		perr.Message = fmt.Sprintf("Ollama is not reachable at %s: %v", ollamaTagsEndpoint, err)
+		return perr
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read ollama response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return provider.FromHTTPStatus("ollama", p.modelName, resp.StatusCode, string(respBody))
+	}
+
+	var tags struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.Unmarshal(respBody, &tags); err != nil {
+		return provider.NewError("ollama", p.modelName, provider.ErrInvalidResponse, fmt.Sprintf("failed to parse ollama model list: %v", err), err)
+	}
+	want := p.modelName
+	if !strings.Contains(want, ":") {
+		want += ":latest"
+	}
+	for _, m := range tags.Models {
+		if m.Name == p.modelName || m.Name == want {
+			return nil
+		}
+	}
+	return provider.NewError("ollama", p.modelName, provider.ErrModelNotFound, fmt.Sprintf("Ollama has no model '%s'; pull it with `ollama pull %s`", p.modelName, p.modelName), nil)
 }
 
-// CreateEmbedding generates an embedding for the given text by calling the local Ollama /api/embeddings endpoint.
+// CreateEmbedding generates a document embedding for the given text.
 func (p *LocalEmbeddingProvider) CreateEmbedding(text string) ([]float32, error) {
+	return p.embedSingle(p.documentPrefix + text)
+}
+
+// CreateQueryEmbedding generates a query embedding, adding the model's query prefix if it has one.
+func (p *LocalEmbeddingProvider) CreateQueryEmbedding(text string) ([]float32, error) {
+	return p.embedSingle(p.queryPrefix + text)
+}
+
+// embedSingle embeds text as-is by calling the local Ollama /api/embeddings endpoint.
+func (p *LocalEmbeddingProvider) embedSingle(text string) ([]float32, error) {
 	if p.httpClient == nil {
 		return nil, fmt.Errorf("LocalEmbeddingProvider not initialized (httpClient is nil)")
 	}
@@ -96,7 +205,9 @@ func (p *LocalEmbeddingProvider) CreateEmbedding(text string) ([]float32, error)
 	resp, err := p.httpClient.Do(req)
 	if err != nil {
 		log.Printf("ERROR: LocalEmbeddingProvider (Ollama) request failed for model '%s'. Is Ollama running at %s? Error: %v", p.modelName, p.apiEndpoint, err)
-		return nil, fmt.Errorf("failed to connect to Ollama at %s (model: %s). Please ensure Ollama is running. Error: %w", p.apiEndpoint, p.modelName, err)
+		perr := provider.FromTransport("ollama", p.modelName, err)
+		perr.Message = fmt.Sprintf("failed to connect to Ollama at %s. Please ensure Ollama is running. Error: %v", p.apiEndpoint, err)
+		return nil, perr
 	}
 	defer resp.Body.Close()
 
@@ -116,25 +227,118 @@ func (p *LocalEmbeddingProvider) CreateEmbedding(text string) ([]float32, error)
 		if json.Unmarshal(respBody, &ollamaErrorResp) == nil && ollamaErrorResp.Error != "" {
 			apiErrorMsg = ollamaErrorResp.Error
 		}
-		return nil, fmt.Errorf("Ollama API error (Status %d) for model '%s': %s. (Ensure model is pulled and Ollama is running)", resp.StatusCode, p.modelName, apiErrorMsg)
+		return nil, provider.FromHTTPStatus("ollama", p.modelName, resp.StatusCode, apiErrorMsg)
 	}
 
 	// Parse Successful Response
 	var ollamaSuccessResp ollamaEmbeddingResponse
 	if err := json.Unmarshal(respBody, &ollamaSuccessResp); err != nil {
 		log.Printf("ERROR: LocalEmbeddingProvider (Ollama) failed to unmarshal successful response for model '%s': %v. Body: %s", p.modelName, err, string(respBody))
-		return nil, fmt.Errorf("failed to parse successful ollama response: %w", err)
+		return nil, provider.NewError("ollama", p.modelName, provider.ErrInvalidResponse, fmt.Sprintf("failed to parse successful ollama response: %v", err), err)
 	}
 
 	if len(ollamaSuccessResp.Embedding) == 0 {
 		log.Printf("WARNING: Ollama API returned successfully for model '%s' but with an empty embedding vector.", p.modelName)
-		return nil, fmt.Errorf("Ollama API returned an empty embedding vector for model '%s'", p.modelName)
+		return nil, provider.NewError("ollama", p.modelName, provider.ErrEmptyResponse, "Ollama API returned an empty embedding vector", nil)
 	}
 
 	// log.Printf("LocalEmbeddingProvider: Generated Ollama embedding via '%s' (Dimensions: %d)", p.modelName, len(ollamaSuccessResp.Embedding))
 	return ollamaSuccessResp.Embedding, nil
 }
 
+// BatchLimits keeps local batches small enough not to stall a CPU-only Ollama for minutes.
+func (p *LocalEmbeddingProvider) BatchLimits() BatchLimits {
+	return BatchLimits{MaxTexts: 64, MaxChars: 200000}
+}
+
+// CreateEmbeddings embeds several texts with one call to Ollama's /api/embed endpoint.
+// Older Ollama versions without that endpoint are handled by embedding one text at a time.
+func (p *LocalEmbeddingProvider) CreateEmbeddings(texts []string) ([][]float32, error) {
+	if p.httpClient == nil {
+		return nil, fmt.Errorf("LocalEmbeddingProvider not initialized (httpClient is nil)")
+	}
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	if p.documentPrefix != "" {
+		prefixed := make([]string, len(texts))
+		for i, text := range texts {
+			prefixed[i] = p.documentPrefix + text
+		}
+		texts = prefixed
+	}
+
+	bodyBytes, err := json.Marshal(ollamaBatchEmbeddingRequest{Model: p.modelName, Input: texts})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal ollama batch request: %w", err)
+	}
+	req, err := http.NewRequest("POST", p.batchEndpoint, bytes.NewBuffer(bodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ollama HTTP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		log.Printf("ERROR: LocalEmbeddingProvider (Ollama) batch request failed for model '%s'. Is Ollama running at %s? Error: %v", p.modelName, p.batchEndpoint, err)
+		perr := provider.FromTransport("ollama", p.modelName, err)
+		perr.Message = fmt.Sprintf("failed to connect to Ollama at %s. Please ensure Ollama is running. Error: %v", p.batchEndpoint, err)
+		return nil, perr
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ollama response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var ollamaErrorResp struct {
+			Error string `json:"error"`
+		}
+		// A plain-text 404 means this Ollama predates /api/embed; a JSON error is a real failure.
+		if resp.StatusCode == http.StatusNotFound && json.Unmarshal(respBody, &ollamaErrorResp) != nil {
+			log.Printf("LocalEmbeddingProvider: Ollama has no batch endpoint, embedding %d texts one at a time", len(texts))
+			return p.createEmbeddingsSequentially(texts)
+		}
+		apiErrorMsg := string(respBody)
+		if ollamaErrorResp.Error != "" {
+			apiErrorMsg = ollamaErrorResp.Error
+		}
+		log.Printf("ERROR: Ollama API returned status %d for model '%s'. Body: %s", resp.StatusCode, p.modelName, string(respBody))
+		return nil, provider.FromHTTPStatus("ollama", p.modelName, resp.StatusCode, apiErrorMsg)
+	}
+
+	var batchResp ollamaBatchEmbeddingResponse
+	if err := json.Unmarshal(respBody, &batchResp); err != nil {
+		return nil, provider.NewError("ollama", p.modelName, provider.ErrInvalidResponse, fmt.Sprintf("failed to parse ollama batch response: %v", err), err)
+	}
+	if len(batchResp.Embeddings) != len(texts) {
+		return nil, provider.NewError("ollama", p.modelName, provider.ErrEmptyResponse, fmt.Sprintf("Ollama returned %d embeddings for %d inputs", len(batchResp.Embeddings), len(texts)), nil)
+	}
+	for i, vector := range batchResp.Embeddings {
+		if len(vector) == 0 {
+			return nil, provider.NewError("ollama", p.modelName, provider.ErrEmptyResponse, fmt.Sprintf("Ollama returned an empty embedding vector for input %d", i), nil)
+		}
+	}
+	return batchResp.Embeddings, nil
+}
+
+// createEmbeddingsSequentially is the fallback for Ollama versions without /api/embed.
+// Texts already carry the document prefix.
+func (p *LocalEmbeddingProvider) createEmbeddingsSequentially(texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vector, err := p.embedSingle(text)
+		if err != nil {
+			return nil, err
+		}
+		vectors[i] = vector
+	}
+	return vectors, nil
+}
+
 // ModelIdentifier returns a string uniquely identifying the Ollama model being used.
 // This is crucial for caching and ensuring embeddings are compatible.
 func (p *LocalEmbeddingProvider) ModelIdentifier() string {
@@ -1,13 +1,13 @@
 package embeddings
 
 import (
+	"Llore/internal/provider"
 	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"time"
 )
 
 const (
@@ -40,25 +40,44 @@ func NewOpenAIEmbeddingProvider(apiKey string, modelIdentifier ...string) (*Open
 	return &OpenAIEmbeddingProvider{
 		apiKey:     apiKey,
 		modelName:  selectedModel,
-		httpClient: &http.Client{Timeout: 30 * time.Second},
+		httpClient: provider.HTTPClient(provider.ClientEmbeddings),
 	}, nil
 }
 
 // CreateEmbedding generates an embedding for the given text using the OpenAI API.
 func (p *OpenAIEmbeddingProvider) CreateEmbedding(text string) ([]float32, error) {
+	vectors, err := p.CreateEmbeddings([]string{text})
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+// CreateQueryEmbedding embeds a search query. OpenAI models have no separate query mode.
+func (p *OpenAIEmbeddingProvider) CreateQueryEmbedding(text string) ([]float32, error) {
+	return p.CreateEmbedding(text)
+}
+
+// BatchLimits reflects OpenAI's limits of 2048 inputs and ~300k tokens per request.
+func (p *OpenAIEmbeddingProvider) BatchLimits() BatchLimits {
+	return BatchLimits{MaxTexts: 2048, MaxChars: 600000}
+}
+
+// CreateEmbeddings embeds several texts in one request using OpenAI's array input.
+func (p *OpenAIEmbeddingProvider) CreateEmbeddings(texts []string) ([][]float32, error) {
 	if p.httpClient == nil {
 		return nil, fmt.Errorf("OpenAI HTTP client is not initialized")
 	}
-
-	// OpenAI API expects a slice of strings for input, even if it's just one.
-	inputTexts := []string{text}
+	if len(texts) == 0 {
+		return nil, nil
+	}
 
 	// Create the request payload
 	payload := struct {
 		Input []string `json:"input"`
 		Model string   `json:"model"`
 	}{
-		Input: inputTexts,
+		Input: texts,
 		Model: p.modelName,
 	}
 
@@ -81,7 +100,7 @@ func (p *OpenAIEmbeddingProvider) CreateEmbedding(text string) ([]float32, error
 	// Send the request
 	resp, err := p.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("OpenAI request failed: %w", err)
+		return nil, provider.FromTransport("openai", p.modelName, err)
 	}
 	defer resp.Body.Close()
 
@@ -94,7 +113,7 @@ func (p *OpenAIEmbeddingProvider) CreateEmbedding(text string) ([]float32, error
 	// Check for non-200 status codes
 	if resp.StatusCode != http.StatusOK {
 		log.Printf("ERROR: OpenAI API returned status %d. Body: %s", resp.StatusCode, string(body))
-		return nil, fmt.Errorf("OpenAI API error (Status %d): %s", resp.StatusCode, string(body))
+		return nil, provider.FromHTTPStatus("openai", p.modelName, resp.StatusCode, string(body))
 	}
 
 	// Parse the response
@@ -109,16 +128,23 @@ func (p *OpenAIEmbeddingProvider) CreateEmbedding(text string) ([]float32, error
 	}
 
 	if err := json.Unmarshal(body, &apiResp); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal OpenAI response: %w. Body: %s", err, string(body))
+		return nil, provider.NewError("openai", p.modelName, provider.ErrInvalidResponse, fmt.Sprintf("failed to unmarshal OpenAI response: %v. Body: %s", err, string(body)), err)
 	}
 
-	// Validate the response
-	if len(apiResp.Data) == 0 || len(apiResp.Data[0].Embedding) == 0 {
-		return nil, fmt.Errorf("OpenAI API returned no embedding data")
+	// Validate the response; data items carry their input index and may arrive out of order
+	if len(apiResp.Data) != len(texts) {
+		return nil, provider.NewError("openai", p.modelName, provider.ErrEmptyResponse, fmt.Sprintf("OpenAI API returned %d embeddings for %d inputs", len(apiResp.Data), len(texts)), nil)
+	}
+	vectors := make([][]float32, len(texts))
+	for _, item := range apiResp.Data {
+		if item.Index < 0 || item.Index >= len(texts) || len(item.Embedding) == 0 {
+			return nil, provider.NewError("openai", p.modelName, provider.ErrInvalidResponse, fmt.Sprintf("OpenAI API returned invalid embedding data at index %d", item.Index), nil)
+		}
+		vectors[item.Index] = item.Embedding
 	}
 
-	log.Printf("Generated OpenAI embedding with %d dimensions using model %s", len(apiResp.Data[0].Embedding), p.modelName)
-	return apiResp.Data[0].Embedding, nil
+	log.Printf("Generated %d OpenAI embeddings with %d dimensions using model %s", len(vectors), len(vectors[0]), p.modelName)
+	return vectors, nil
 }
 
 // ModelIdentifier returns a string uniquely identifying the OpenAI model being used.
@@ -0,0 +1,199 @@
+// internal/embeddings/quantization.go
+package embeddings
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+// Quantization modes for the in-memory search index.
+const (
+	QuantizeNone   = "none"   // float32, 4 bytes per dimension
+	QuantizeInt8   = "int8"   // One signed byte per dimension plus a scale
+	QuantizeBinary = "binary" // One bit per dimension (the sign); compared by Hamming distance
+)
+
+// rescoreOversample is how many candidates per requested result a quantized index
+// returns for rescoring against the full-precision vectors in the database.
+const rescoreOversample = 4
+
+// VectorStorage is a vault's choice of how compactly vectors are kept.
+//
+// Dimensions truncates every vector to its first N components and renormalizes it
+// (Matryoshka-style). Models trained for it, such as OpenAI's text-embedding-3 and
+// Gemini's newer embedding models, lose little accuracy this way. Truncated vectors are
+// what gets stored, so this shrinks the vault as well as the index, and it changes the
+// vector version.
+//
+// Quantization only affects the in-memory search index. The vault keeps float32 vectors,
+// so the top candidates are always rescored at full precision.
+type VectorStorage struct {
+	Quantization string `json:"quantization"` // QuantizeNone, QuantizeInt8 or QuantizeBinary
+	Dimensions   int    `json:"dimensions"`   // 0 keeps every dimension
+}
+
+// Normalize fills in defaults and rejects unknown settings.
+func (v VectorStorage) Normalize() (VectorStorage, error) {
+	switch v.Quantization {
+	case "":
+		v.Quantization = QuantizeNone
+	case QuantizeNone, QuantizeInt8, QuantizeBinary:
+	default:
+		return v, fmt.Errorf("unknown quantization %q (use none, int8 or binary)", v.Quantization)
+	}
+	if v.Dimensions < 0 {
+		return v, fmt.Errorf("dimensions cannot be negative")
+	}
+	if v.Dimensions > 0 && v.Dimensions < 32 {
+		return v, fmt.Errorf("truncating to %d dimensions would leave too little to search with (minimum 32)", v.Dimensions)
+	}
+	return v, nil
+}
+
+// versionSuffix marks vector versions whose vectors were truncated.
+func (v VectorStorage) versionSuffix() string {
+	if v.Dimensions > 0 {
+		return fmt.Sprintf("#d%d", v.Dimensions)
+	}
+	return ""
+}
+
+// VectorVersion is the vector version these settings store for a provider's model.
+func (v VectorStorage) VectorVersion(modelIdentifier string) string {
+	return modelIdentifier + v.versionSuffix()
+}
+
+// TruncateVector keeps the first dims components of vec and renormalizes them. Vectors
+// already that short, and dims <= 0, are returned unchanged.
+func TruncateVector(vec []float32, dims int) []float32 {
+	if dims <= 0 || len(vec) <= dims {
+		return vec
+	}
+	if out := normalize(vec[:dims]); out != nil {
+		return out
+	}
+	return vec[:dims]
+}
+
+// TruncateEmbeddingBlob applies TruncateVector to a stored float32 embedding.
+func TruncateEmbeddingBlob(data []byte, dims int) []byte {
+	vec := deserializeEmbedding(data)
+	if len(vec) == 0 {
+		return data
+	}
+	return serializeEmbedding(TruncateVector(vec, dims))
+}
+
+// vectorCode is a normalized vector in one of the quantized forms. Exactly one of f32,
+// i8 and bits is set, according to the index's quantization.
+type vectorCode struct {
+	f32   []float32
+	i8    []int8
+	scale float32 // i8[i] * scale approximates the normalized component
+	bits  []uint64
+}
+
+// hnswQuery is a search vector prepared for comparison with codes: always the normalized
+// float32 vector, plus its sign bits when the codes are binary.
+type hnswQuery struct {
+	vec  []float32
+	bits []uint64
+}
+
+// encodeVector quantizes a normalized vector.
+func encodeVector(vec []float32, quantization string) vectorCode {
+	switch quantization {
+	case QuantizeInt8:
+		var maxAbs float32
+		for _, x := range vec {
+			if a := float32(math.Abs(float64(x))); a > maxAbs {
+				maxAbs = a
+			}
+		}
+		code := vectorCode{i8: make([]int8, len(vec)), scale: maxAbs / 127}
+		if maxAbs > 0 {
+			for i, x := range vec {
+				code.i8[i] = int8(math.Round(float64(x / code.scale)))
+			}
+		}
+		return code
+	case QuantizeBinary:
+		return vectorCode{bits: signBits(vec)}
+	default:
+		return vectorCode{f32: vec}
+	}
+}
+
+// size is the memory taken by the vector data of a code, in bytes.
+func (c *vectorCode) size() int {
+	switch {
+	case c.i8 != nil:
+		return len(c.i8) + 4
+	case c.bits != nil:
+		return len(c.bits) * 8
+	default:
+		return len(c.f32) * 4
+	}
+}
+
+func newHNSWQuery(vec []float32, quantization string) hnswQuery {
+	q := hnswQuery{vec: vec}
+	if quantization == QuantizeBinary {
+		q.bits = signBits(vec)
+	}
+	return q
+}
+
+// querySim estimates cosine similarity between a query and a code. The query side stays
+// at full precision for int8 codes.
+func querySim(q *hnswQuery, c *vectorCode, dim int) float32 {
+	switch {
+	case c.i8 != nil:
+		var s float32
+		for i, x := range q.vec {
+			s += x * float32(c.i8[i])
+		}
+		return s * c.scale
+	case c.bits != nil:
+		return hammingSim(q.bits, c.bits, dim)
+	default:
+		return dot(q.vec, c.f32)
+	}
+}
+
+// codeSim estimates cosine similarity between two codes of the same kind.
+func codeSim(a, b *vectorCode, dim int) float32 {
+	switch {
+	case a.i8 != nil:
+		var s int32
+		for i, x := range a.i8 {
+			s += int32(x) * int32(b.i8[i])
+		}
+		return float32(s) * a.scale * b.scale
+	case a.bits != nil:
+		return hammingSim(a.bits, b.bits, dim)
+	default:
+		return dot(a.f32, b.f32)
+	}
+}
+
+// hammingSim maps the Hamming distance of sign bits to [-1, 1], where 1 means every sign
+// agrees. It approximates cosine similarity for high-dimensional embeddings.
+func hammingSim(a, b []uint64, dim int) float32 {
+	diff := 0
+	for i := range a {
+		diff += bits.OnesCount64(a[i] ^ b[i])
+	}
+	return 1 - 2*float32(diff)/float32(dim)
+}
+
+func signBits(vec []float32) []uint64 {
+	out := make([]uint64, (len(vec)+63)/64)
+	for i, x := range vec {
+		if x > 0 {
+			out[i/64] |= 1 << (uint(i) % 64)
+		}
+	}
+	return out
+}
@@ -0,0 +1,225 @@
+package embeddings
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestVectorStorageVersion(t *testing.T) {
+	tests := []struct {
+		storage VectorStorage
+		want    string
+	}{
+		{VectorStorage{}, "openai/text-embedding-3-small"},
+		{VectorStorage{Quantization: QuantizeInt8}, "openai/text-embedding-3-small"},
+		{VectorStorage{Quantization: QuantizeBinary, Dimensions: 256}, "openai/text-embedding-3-small#d256"},
+		{VectorStorage{Dimensions: 512}, "openai/text-embedding-3-small#d512"},
+	}
+	for _, tt := range tests {
+		if got := tt.storage.VectorVersion("openai/text-embedding-3-small"); got != tt.want {
+			t.Errorf("%+v: version = %q, want %q", tt.storage, got, tt.want)
+		}
+	}
+}
+
+func TestVectorStorageNormalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      VectorStorage
+		want    VectorStorage
+		wantErr bool
+	}{
+		{"defaults", VectorStorage{}, VectorStorage{Quantization: QuantizeNone}, false},
+		{"int8", VectorStorage{Quantization: QuantizeInt8, Dimensions: 256}, VectorStorage{Quantization: QuantizeInt8, Dimensions: 256}, false},
+		{"minimum dimensions", VectorStorage{Dimensions: 32}, VectorStorage{Quantization: QuantizeNone, Dimensions: 32}, false},
+		{"too few dimensions", VectorStorage{Dimensions: 31}, VectorStorage{}, true},
+		{"negative dimensions", VectorStorage{Dimensions: -1}, VectorStorage{}, true},
+		{"unknown quantization", VectorStorage{Quantization: "int4"}, VectorStorage{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.in.Normalize()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncateVector(t *testing.T) {
+	vec := []float32{3, 4, 12, 0, 5}
+	tests := []struct {
+		name string
+		dims int
+		want []float32
+	}{
+		{"zero keeps all", 0, vec},
+		{"negative keeps all", -4, vec},
+		{"exact length", 5, vec},
+		{"longer than vector", 10, vec},
+		{"truncates and renormalizes", 2, []float32{0.6, 0.8}},
+		{"single component", 1, []float32{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TruncateVector(vec, tt.dims)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d dimensions, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if math.Abs(float64(got[i]-tt.want[i])) > 1e-6 {
+					t.Errorf("got %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+
+	// A prefix of zeros can't be normalized and is kept as it is
+	if got := TruncateVector([]float32{0, 0, 1}, 2); len(got) != 2 || got[0] != 0 || got[1] != 0 {
+		t.Errorf("zero prefix = %v, want [0 0]", got)
+	}
+}
+
+func TestTruncateEmbeddingBlob(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	vec := normalize(randomVector(rng, 96, 1))
+	blob := serializeEmbedding(vec)
+
+	got := deserializeEmbedding(TruncateEmbeddingBlob(blob, 48))
+	want := TruncateVector(vec, 48)
+	if len(got) != 48 {
+		t.Fatalf("truncated blob holds %d dimensions, want 48", len(got))
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("component %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+	if norm := dot(got, got); math.Abs(float64(norm)-1) > 1e-5 {
+		t.Errorf("truncated vector has squared norm %v, want 1", norm)
+	}
+
+	// Truncating in two steps gives the same vector as truncating once
+	twice := deserializeEmbedding(TruncateEmbeddingBlob(TruncateEmbeddingBlob(blob, 64), 48))
+	for i := range twice {
+		if math.Abs(float64(twice[i]-got[i])) > 1e-6 {
+			t.Fatalf("component %d = %v after two truncations, want %v", i, twice[i], got[i])
+		}
+	}
+
+	for _, data := range [][]byte{nil, {1, 2, 3}} {
+		if out := TruncateEmbeddingBlob(data, 32); !bytes.Equal(out, data) {
+			t.Errorf("unreadable blob %v changed to %v", data, out)
+		}
+	}
+	if out := TruncateEmbeddingBlob(blob, 0); !bytes.Equal(out, blob) {
+		t.Error("dims 0 changed the blob")
+	}
+}
+
+func TestEncodeVectorRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	vec := normalize(randomVector(rng, 100, 1))
+
+	none := encodeVector(vec, QuantizeNone)
+	if none.i8 != nil || none.bits != nil || len(none.f32) != len(vec) {
+		t.Fatalf("none code = %+v, want only f32 set", none)
+	}
+	if none.size() != 400 {
+		t.Errorf("none size = %d, want 400", none.size())
+	}
+
+	i8 := encodeVector(vec, QuantizeInt8)
+	if i8.f32 != nil || i8.bits != nil || len(i8.i8) != len(vec) {
+		t.Fatalf("int8 code has the wrong fields set")
+	}
+	for i, x := range vec {
+		if diff := math.Abs(float64(float32(i8.i8[i])*i8.scale - x)); diff > float64(i8.scale)/2+1e-7 {
+			t.Errorf("component %d decodes %v off, more than half a step", i, diff)
+		}
+	}
+	if i8.size() != 104 {
+		t.Errorf("int8 size = %d, want 104", i8.size())
+	}
+
+	bin := encodeVector(vec, QuantizeBinary)
+	if bin.f32 != nil || bin.i8 != nil || len(bin.bits) != 2 {
+		t.Fatalf("binary code has the wrong fields set")
+	}
+	for i, x := range vec {
+		if set := bin.bits[i/64]&(1<<(uint(i)%64)) != 0; set != (x > 0) {
+			t.Errorf("bit %d = %v for component %v", i, set, x)
+		}
+	}
+	if bin.size() != 16 {
+		t.Errorf("binary size = %d, want 16", bin.size())
+	}
+
+	zero := encodeVector(make([]float32, 8), QuantizeInt8)
+	for _, x := range zero.i8 {
+		if x != 0 {
+			t.Fatalf("zero vector encoded as %v", zero.i8)
+		}
+	}
+}
+
+func TestEncodeVectorPreservesOrder(t *testing.T) {
+	const dim = 256
+	rng := rand.New(rand.NewSource(4))
+	query := normalize(randomVector(rng, dim, 1))
+	// Candidates at decreasing similarity to the query, roughly 0.97, 0.89, 0.71, 0.4 and 0
+	weights := []float32{4, 2, 1, 0.44, 0}
+	candidates := make([][]float32, len(weights))
+	for i, w := range weights {
+		v := normalize(randomVector(rng, dim, 1))
+		for d := range v {
+			v[d] += w * query[d]
+		}
+		candidates[i] = normalize(v)
+	}
+
+	for _, quantization := range []string{QuantizeNone, QuantizeInt8, QuantizeBinary} {
+		t.Run(quantization, func(t *testing.T) {
+			q := newHNSWQuery(query, quantization)
+			qc := encodeVector(query, quantization)
+			codes := make([]vectorCode, len(candidates))
+			for i, c := range candidates {
+				codes[i] = encodeVector(c, quantization)
+			}
+			byQuery := make([]int, len(codes))
+			byCode := make([]int, len(codes))
+			for i := range codes {
+				byQuery[i], byCode[i] = i, i
+			}
+			sort.SliceStable(byQuery, func(a, b int) bool {
+				return querySim(&q, &codes[byQuery[a]], dim) > querySim(&q, &codes[byQuery[b]], dim)
+			})
+			sort.SliceStable(byCode, func(a, b int) bool {
+				return codeSim(&qc, &codes[byCode[a]], dim) > codeSim(&qc, &codes[byCode[b]], dim)
+			})
+			for i := range codes {
+				if byQuery[i] != i || byCode[i] != i {
+					t.Fatalf("ranked %v by query and %v by code, want candidates in order", byQuery, byCode)
+				}
+			}
+
+			if quantization == QuantizeBinary {
+				return
+			}
+			// Int8 stays close to the exact similarity; binary only keeps the order
+			for i := range codes {
+				exact := dot(query, candidates[i])
+				if est := querySim(&q, &codes[i], dim); math.Abs(float64(est-exact)) > 0.01 {
+					t.Errorf("candidate %d: estimated %v, exact %v", i, est, exact)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+// internal/embeddings/related.go
+package embeddings
+
+import (
+	"Llore/internal/database"
+	"fmt"
+	"sort"
+)
+
+// pairNeighbours is how many nearest neighbours of each entry SimilarPairs looks at when
+// the ANN index is ready. Near-duplicates are always among the closest few.
+const pairNeighbours = 10
+
+// SimilarPair is two entries whose stored vectors are close. A < B.
+type SimilarPair struct {
+	A     int64   `json:"a"`
+	B     int64   `json:"b"`
+	Score float32 `json:"score"`
+}
+
+// RelatedEntries returns the entries closest to an entry's stored vector, best first,
+// without the entry itself. It needs no embedding request.
+func (s *EmbeddingService) RelatedEntries(entryID int64, limit int) ([]SearchResult, error) {
+	if s.db == nil || s.provider == nil {
+		return nil, fmt.Errorf("embedding service not initialized")
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+	vectors, err := loadVectors(s.db, `SELECT codex_entry_id, embedding FROM codex_embeddings WHERE vector_version = ?`, s.vectorVersion())
+	if err != nil {
+		return nil, err
+	}
+	target, ok := vectors[entryID]
+	if !ok {
+		return nil, fmt.Errorf("entry %d has no embedding for %s yet", entryID, s.vectorVersion())
+	}
+
+	scores := make(map[int64]float32, len(vectors))
+	for id, vec := range vectors {
+		if id != entryID && len(vec) == len(target) {
+			scores[id] = cosineSimilarity(target, vec)
+		}
+	}
+	ids := make([]int64, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return scores[ids[i]] > scores[ids[j]] })
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+
+	entries, err := database.DBGetEntriesByIDs(s.db, ids)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]SearchResult, 0, len(entries))
+	for _, entry := range entries {
+		results = append(results, SearchResult{Entry: entry, Score: scores[entry.ID]})
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	return results, nil
+}
+
+// SimilarPairs finds every pair of entries whose stored vectors have a cosine similarity
+// of at least minScore. With the ANN index ready only each entry's nearest neighbours are
+// compared; otherwise all pairs are.
+func (s *EmbeddingService) SimilarPairs(minScore float32) ([]SimilarPair, error) {
+	if s.db == nil || s.provider == nil {
+		return nil, fmt.Errorf("embedding service not initialized")
+	}
+	vectors, err := loadVectors(s.db, `SELECT codex_entry_id, embedding FROM codex_embeddings WHERE vector_version = ?`, s.vectorVersion())
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, 0, len(vectors))
+	for id := range vectors {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var pairs []SimilarPair
+	add := func(a, b int64) {
+		va, vb := vectors[a], vectors[b]
+		if len(va) != len(vb) {
+			return
+		}
+		if score := cosineSimilarity(va, vb); score >= minScore {
+			pairs = append(pairs, SimilarPair{A: a, B: b, Score: score})
+		}
+	}
+
+	if idx := s.vectorIndex(); idx != nil && idx.Ready() {
+		seen := make(map[[2]int64]bool)
+		for _, id := range ids {
+			for _, hit := range idx.SearchEntries(vectors[id], pairNeighbours+1) {
+				a, b := id, hit.ID
+				if a == b {
+					continue
+				}
+				if a > b {
+					a, b = b, a
+				}
+				if key := [2]int64{a, b}; !seen[key] {
+					seen[key] = true
+					if _, ok := vectors[hit.ID]; ok {
+						add(a, b) // Exact score, even when the index is quantized
+					}
+				}
+			}
+		}
+	} else {
+		for i := range ids {
+			for j := i + 1; j < len(ids); j++ {
+				add(ids[i], ids[j])
+			}
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].Score > pairs[j].Score })
+	return pairs, nil
+}
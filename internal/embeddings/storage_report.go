@@ -0,0 +1,177 @@
+// internal/embeddings/storage_report.go
+package embeddings
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+// StorageOption is one truncation and quantization setting measured on a vault's vectors.
+type StorageOption struct {
+	Quantization      string  `json:"quantization"`
+	Dimensions        int     `json:"dimensions"`        // Dimensions kept; equal to the full dimension when not truncated
+	StoredBytes       int64   `json:"storedBytes"`       // float32 vectors kept in the vault
+	IndexBytes        int64   `json:"indexBytes"`        // Vectors held by the in-memory search index
+	SavedPercent      float64 `json:"savedPercent"`      // Saving on stored plus index bytes versus the baseline
+	RecallAtK         float64 `json:"recallAtK"`         // Share of the exact top-k found by the compact vectors alone
+	RescoredRecallAtK float64 `json:"rescoredRecallAtK"` // The same after rescoring the top candidates at full precision
+}
+
+// StorageReport compares storage options against the vectors as they are stored now.
+type StorageReport struct {
+	VectorVersion string          `json:"vectorVersion"`
+	Vectors       int             `json:"vectors"`
+	Dimensions    int             `json:"dimensions"` // Of the stored vectors, i.e. the baseline
+	Queries       int             `json:"queries"`
+	K             int             `json:"k"`
+	Options       []StorageOption `json:"options"`
+}
+
+// candidateDimensions are the truncation sizes measured, when smaller than the vectors.
+var candidateDimensions = []int{1536, 1024, 768, 512, 256, 128}
+
+// MeasureStorage measures what each truncation and quantization setting would save on
+// the given vectors and how much recall it would cost. The ground truth is an exact
+// search over the vectors as given. Up to maxQueries of the vectors themselves serve as
+// queries, leaving each query out of its own results, so no embedding requests are made.
+func MeasureStorage(version string, vectors map[int64][]float32, maxQueries, k int) (StorageReport, error) {
+	report := StorageReport{VectorVersion: version, Vectors: len(vectors), K: k}
+	if len(vectors) <= k {
+		return report, fmt.Errorf("need more than %d vectors to measure recall at %d, have %d", k, k, len(vectors))
+	}
+
+	ids := make([]int64, 0, len(vectors))
+	for id := range vectors {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	base := make([][]float32, len(ids))
+	for i, id := range ids {
+		base[i] = normalize(vectors[id])
+		if base[i] == nil || (i > 0 && len(base[i]) != len(base[0])) {
+			return report, fmt.Errorf("vector %d is empty or has a different dimension", id)
+		}
+	}
+	full := len(base[0])
+	report.Dimensions = full
+
+	queries := rand.New(rand.NewSource(11)).Perm(len(base))
+	if len(queries) > maxQueries {
+		queries = queries[:maxQueries]
+	}
+	report.Queries = len(queries)
+
+	truth := make([][]int, len(queries))
+	for qi, q := range queries {
+		truth[qi] = topKExcluding(base[q], base, q, k)
+	}
+
+	dims := []int{full}
+	for _, d := range candidateDimensions {
+		if d < full {
+			dims = append(dims, d)
+		}
+	}
+	baselineBytes := float64(len(base) * full * 4 * 2) // Stored vectors plus the float32 index copy
+	for _, d := range dims {
+		truncated := make([][]float32, len(base))
+		for i, v := range base {
+			truncated[i] = TruncateVector(v, d)
+		}
+		for _, quantization := range []string{QuantizeNone, QuantizeInt8, QuantizeBinary} {
+			opt := StorageOption{Quantization: quantization, Dimensions: d, StoredBytes: int64(len(base) * d * 4)}
+			codes := make([]vectorCode, len(truncated))
+			for i, v := range truncated {
+				codes[i] = encodeVector(v, quantization)
+				opt.IndexBytes += int64(codes[i].size())
+			}
+			opt.SavedPercent = 100 * (1 - float64(opt.StoredBytes+opt.IndexBytes)/baselineBytes)
+
+			var found, rescoredFound int
+			for qi, q := range queries {
+				query := newHNSWQuery(truncated[q], quantization)
+				approx := make([]IndexHit, 0, len(codes)-1)
+				for i := range codes {
+					if i != q {
+						approx = append(approx, IndexHit{ID: int64(i), Score: querySim(&query, &codes[i], d)})
+					}
+				}
+				sort.Slice(approx, func(a, b int) bool { return approx[a].Score > approx[b].Score })
+
+				want := make(map[int]bool, k)
+				for _, i := range truth[qi] {
+					want[i] = true
+				}
+				for _, hit := range approx[:k] {
+					if want[int(hit.ID)] {
+						found++
+					}
+				}
+
+				// Rescore the oversampled candidates with the truncated float32 vectors,
+				// which is what the vault keeps
+				n := k * rescoreOversample
+				if n > len(approx) {
+					n = len(approx)
+				}
+				rescored := append([]IndexHit(nil), approx[:n]...)
+				for i := range rescored {
+					rescored[i].Score = dot(truncated[q], truncated[rescored[i].ID])
+				}
+				sort.Slice(rescored, func(a, b int) bool { return rescored[a].Score > rescored[b].Score })
+				for _, hit := range rescored[:minInt(k, len(rescored))] {
+					if want[int(hit.ID)] {
+						rescoredFound++
+					}
+				}
+			}
+			total := float64(len(queries) * k)
+			opt.RecallAtK = float64(found) / total
+			opt.RescoredRecallAtK = float64(rescoredFound) / total
+			report.Options = append(report.Options, opt)
+		}
+	}
+	return report, nil
+}
+
+// topKExcluding returns the indices of the k vectors most similar to query, skipping skip.
+func topKExcluding(query []float32, vectors [][]float32, skip, k int) []int {
+	hits := make([]IndexHit, 0, len(vectors)-1)
+	for i, v := range vectors {
+		if i != skip {
+			hits = append(hits, IndexHit{ID: int64(i), Score: dot(query, v)})
+		}
+	}
+	sort.Slice(hits, func(a, b int) bool { return hits[a].Score > hits[b].Score })
+	if len(hits) > k {
+		hits = hits[:k]
+	}
+	out := make([]int, len(hits))
+	for i, hit := range hits {
+		out[i] = int(hit.ID)
+	}
+	return out
+}
+
+// MeasureVectorStorage runs MeasureStorage on the vault's entry embeddings. It prefers
+// the provider's untruncated vectors when they are still stored, so smaller and larger
+// truncations than the current one can both be compared.
+func (s *EmbeddingService) MeasureVectorStorage(maxQueries, k int) (StorageReport, error) {
+	if s.db == nil || s.provider == nil {
+		return StorageReport{}, fmt.Errorf("embedding service not initialized")
+	}
+	const query = `SELECT codex_entry_id, embedding FROM codex_embeddings WHERE vector_version = ?`
+	version := s.provider.ModelIdentifier()
+	vectors, err := loadVectors(s.db, query, version)
+	if err != nil {
+		return StorageReport{}, err
+	}
+	if len(vectors) <= k && version != s.vectorVersion() {
+		version = s.vectorVersion()
+		if vectors, err = loadVectors(s.db, query, version); err != nil {
+			return StorageReport{}, err
+		}
+	}
+	return MeasureStorage(version, vectors, maxQueries, k)
+}
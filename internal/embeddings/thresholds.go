@@ -0,0 +1,108 @@
+// internal/embeddings/thresholds.go
+package embeddings
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// defaultSimilarityThreshold applies to models not listed in similarityThresholds.
+const defaultSimilarityThreshold = 0.4
+
+// minCalibrationEntries is the fewest embedded entries a calibration is based on.
+const minCalibrationEntries = 20
+
+// similarityThresholds are the minimum cosine similarity for a vector hit to count as
+// relevant, by vector version prefix. Models spread scores very differently: unrelated
+// texts score around 0.1 with OpenAI's text-embedding-3 but around 0.4 with nomic.
+var similarityThresholds = map[string]float32{
+	"openai:text-embedding-3":       0.25,
+	"openai:text-embedding-ada-002": 0.75,
+	"gemini:":                       0.45,
+	"ollama:nomic-embed-text":       0.5,
+	"ollama:mxbai-embed-large":      0.5,
+	"ollama:snowflake-arctic-embed": 0.3,
+	"local-builtin:":                0.1,
+}
+
+// DefaultSimilarityThreshold returns the built-in threshold for a vector version, using
+// the longest matching prefix.
+func DefaultSimilarityThreshold(vectorVersion string) float32 {
+	threshold, matched := float32(defaultSimilarityThreshold), 0
+	for prefix, t := range similarityThresholds {
+		if strings.HasPrefix(vectorVersion, prefix) && len(prefix) > matched {
+			threshold, matched = t, len(prefix)
+		}
+	}
+	return threshold
+}
+
+// ThresholdCalibration is a similarity threshold measured on a vault's own entries.
+type ThresholdCalibration struct {
+	VectorVersion string  `json:"vectorVersion"`
+	Entries       int     `json:"entries"`
+	Pairs         int     `json:"pairs"`  // Random entry pairs compared
+	Mean          float32 `json:"mean"`   // Mean similarity of random pairs
+	StdDev        float32 `json:"stdDev"` // Of random pair similarities
+	P95           float32 `json:"p95"`
+	Threshold     float32 `json:"threshold"`
+	Default       float32 `json:"default"` // The built-in threshold, for comparison
+}
+
+// CalibrateThreshold measures how similar unrelated entries look to the current model.
+// In a codex, two entries picked at random are almost always about different things, so
+// the 95th percentile of random-pair similarity is the score a hit has to beat to stand
+// out from background noise. That percentile becomes the threshold.
+func (s *EmbeddingService) CalibrateThreshold(pairs int) (ThresholdCalibration, error) {
+	if s.db == nil || s.provider == nil {
+		return ThresholdCalibration{}, fmt.Errorf("embedding service not initialized")
+	}
+	version := s.vectorVersion()
+	result := ThresholdCalibration{VectorVersion: version, Default: DefaultSimilarityThreshold(version)}
+	vectors, err := loadVectors(s.db, `SELECT codex_entry_id, embedding FROM codex_embeddings WHERE vector_version = ?`, version)
+	if err != nil {
+		return result, err
+	}
+	result.Entries = len(vectors)
+	if len(vectors) < minCalibrationEntries {
+		return result, fmt.Errorf("need at least %d embedded entries to calibrate, have %d", minCalibrationEntries, len(vectors))
+	}
+
+	ids := make([]int64, 0, len(vectors))
+	for id := range vectors {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	if max := len(ids) * (len(ids) - 1) / 2; pairs > max {
+		pairs = max
+	}
+
+	rng := rand.New(rand.NewSource(7))
+	sims := make([]float64, 0, pairs)
+	var sum float64
+	for len(sims) < pairs {
+		i, j := rng.Intn(len(ids)), rng.Intn(len(ids))
+		if i == j {
+			continue
+		}
+		sim := float64(cosineSimilarity(vectors[ids[i]], vectors[ids[j]]))
+		sims = append(sims, sim)
+		sum += sim
+	}
+	sort.Float64s(sims)
+	mean := sum / float64(len(sims))
+	var variance float64
+	for _, sim := range sims {
+		variance += (sim - mean) * (sim - mean)
+	}
+
+	result.Pairs = len(sims)
+	result.Mean = float32(mean)
+	result.StdDev = float32(math.Sqrt(variance / float64(len(sims))))
+	result.P95 = float32(sims[len(sims)*95/100])
+	result.Threshold = float32(math.Min(0.95, math.Max(0.05, float64(result.P95))))
+	return result, nil
+}
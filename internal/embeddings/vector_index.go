@@ -0,0 +1,426 @@
+// internal/embeddings/vector_index.go
+package embeddings
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/gob"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// VectorIndex keeps HNSW graphs of a vault's entry and passage embeddings for one
+// vector_version, so searches don't scan and deserialize every BLOB.
+type VectorIndex struct {
+	mu           sync.RWMutex
+	version      string
+	quantization string
+	entries      *HNSW
+	chunks       *HNSW
+	chunkEntry   map[int64]int64 // Passage row ID -> entry ID
+	ready        bool            // False while the initial load/build runs
+	removed      map[int64]bool  // Entries deleted during the initial build
+	dirty        bool            // Changed since last persisted
+}
+
+// indexFileHeader identifies which database state a persisted graph belongs to.
+type indexFileHeader struct {
+	Version            string
+	Quantization       string // Empty in files saved before quantization existed
+	EntryFingerprint   string
+	PassageFingerprint string
+	SavedAt            time.Time
+}
+
+func newVectorIndex(version, quantization string) *VectorIndex {
+	return &VectorIndex{
+		version:      version,
+		quantization: quantization,
+		entries:      NewQuantizedHNSW(16, 100, 64, quantization),
+		chunks:       NewQuantizedHNSW(16, 100, 64, quantization),
+		chunkEntry:   make(map[int64]int64),
+		removed:      make(map[int64]bool),
+	}
+}
+
+// Ready reports whether the initial load or build has finished.
+func (ix *VectorIndex) Ready() bool {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return ix.ready
+}
+
+// Quantized reports whether search scores are estimates that need rescoring.
+func (ix *VectorIndex) Quantized() bool {
+	return ix.quantization != QuantizeNone
+}
+
+// withGraphs runs fn while the graphs can't be swapped out by the initial load.
+func (ix *VectorIndex) withGraphs(fn func(entries, chunks *HNSW)) {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	fn(ix.entries, ix.chunks)
+}
+
+// AddEntry indexes or replaces an entry vector.
+func (ix *VectorIndex) AddEntry(entryID int64, vector []float32) {
+	var err error
+	ix.withGraphs(func(entries, _ *HNSW) { err = entries.Add(entryID, vector) })
+	if err != nil {
+		log.Printf("Warning: Vector index: %v", err)
+		return
+	}
+	ix.mu.Lock()
+	delete(ix.removed, entryID)
+	ix.dirty = true
+	ix.mu.Unlock()
+}
+
+// ReplaceChunks swaps the indexed passages of an entry for a new set.
+func (ix *VectorIndex) ReplaceChunks(entryID int64, chunkIDs []int64, vectors [][]float32) {
+	ix.mu.Lock()
+	var stale []int64
+	for chunkID, owner := range ix.chunkEntry {
+		if owner == entryID {
+			stale = append(stale, chunkID)
+		}
+	}
+	for _, chunkID := range stale {
+		delete(ix.chunkEntry, chunkID)
+	}
+	for _, chunkID := range chunkIDs {
+		ix.chunkEntry[chunkID] = entryID
+	}
+	ix.dirty = true
+	ix.mu.Unlock()
+
+	ix.withGraphs(func(_, chunks *HNSW) {
+		for _, chunkID := range stale {
+			chunks.Remove(chunkID)
+		}
+		for i, chunkID := range chunkIDs {
+			if err := chunks.Add(chunkID, vectors[i]); err != nil {
+				log.Printf("Warning: Vector index: %v", err)
+			}
+		}
+	})
+}
+
+// RemoveEntry drops an entry and its passages.
+func (ix *VectorIndex) RemoveEntry(entryID int64) {
+	ix.ReplaceChunks(entryID, nil, nil)
+	ix.withGraphs(func(entries, _ *HNSW) { entries.Remove(entryID) })
+	ix.mu.Lock()
+	if !ix.ready {
+		ix.removed[entryID] = true
+	}
+	ix.dirty = true
+	ix.mu.Unlock()
+}
+
+// SearchEntries returns the k entries nearest to query.
+func (ix *VectorIndex) SearchEntries(query []float32, k int) (hits []IndexHit) {
+	ix.withGraphs(func(entries, _ *HNSW) { hits = entries.Search(query, k) })
+	return hits
+}
+
+// SearchChunks returns the k passages nearest to query, with their entry IDs.
+func (ix *VectorIndex) SearchChunks(query []float32, k int) (hits []IndexHit, owners []int64) {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	hits = ix.chunks.Search(query, k)
+	owners = make([]int64, len(hits))
+	for i, hit := range hits {
+		owners[i] = ix.chunkEntry[hit.ID]
+	}
+	return hits, owners
+}
+
+// Stats reports index sizes and the memory taken by the indexed vectors.
+func (ix *VectorIndex) Stats() (entries, passages int, memoryBytes int64) {
+	ix.withGraphs(func(e, c *HNSW) {
+		entries, passages = e.Len(), c.Len()
+		memoryBytes = e.MemoryBytes() + c.MemoryBytes()
+	})
+	return entries, passages, memoryBytes
+}
+
+// RemoveIndexFile deletes the saved graph of a vector version, if there is one.
+func RemoveIndexFile(dir, version string) error {
+	if err := os.Remove(indexFilePath(dir, version)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove vector index file: %w", err)
+	}
+	return nil
+}
+
+// indexFilePath returns where the graph for a vector_version is persisted.
+func indexFilePath(dir, version string) string {
+	safe := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' || r == ' ' {
+			return '_'
+		}
+		return r
+	}, version)
+	return filepath.Join(dir, safe+".hnsw")
+}
+
+// entryFingerprint summarizes the entry embeddings of a version; any insert, update or
+// delete changes it, which invalidates a persisted graph.
+func entryFingerprint(db *sql.DB, version string) (string, error) {
+	var count, idSum int64
+	var maxUpdated string
+	err := db.QueryRow(`SELECT COUNT(*), COALESCE(SUM(codex_entry_id), 0), COALESCE(MAX(updated_at), '')
+		FROM codex_embeddings WHERE vector_version = ?`, version).Scan(&count, &idSum, &maxUpdated)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d/%d/%s", count, idSum, maxUpdated), nil
+}
+
+// passageFingerprint does the same for passage embeddings; passages are only ever
+// replaced, never updated in place, so row IDs are enough.
+func passageFingerprint(db *sql.DB, version string) (string, error) {
+	var count, idSum, maxID int64
+	err := db.QueryRow(`SELECT COUNT(*), COALESCE(SUM(id), 0), COALESCE(MAX(id), 0)
+		FROM codex_embedding_chunks WHERE vector_version = ?`, version).Scan(&count, &idSum, &maxID)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d/%d/%d", count, idSum, maxID), nil
+}
+
+// load populates the index from the persisted graph when it matches the database, and
+// otherwise rebuilds it from the stored vectors. Updates made meanwhile are kept.
+func (ix *VectorIndex) load(db *sql.DB, dir string) error {
+	start := time.Now()
+	entryVectors, err := loadVectors(db, `SELECT codex_entry_id, embedding FROM codex_embeddings WHERE vector_version = ?`, ix.version)
+	if err != nil {
+		return err
+	}
+	chunkVectors, chunkOwners, err := loadChunkVectors(db, ix.version)
+	if err != nil {
+		return err
+	}
+
+	if ix.loadPersisted(db, dir, entryVectors, chunkVectors) {
+		ix.mu.Lock()
+		for chunkID, owner := range chunkOwners {
+			ix.chunkEntry[chunkID] = owner
+		}
+		ix.ready = true
+		ix.mu.Unlock()
+		log.Printf("Vector index: loaded %d entries and %d passages for %s from disk in %v", len(entryVectors), len(chunkVectors), ix.version, time.Since(start))
+		return nil
+	}
+
+	log.Printf("Vector index: building for %d entries and %d passages (%s)...", len(entryVectors), len(chunkVectors), ix.version)
+	for id, vec := range entryVectors {
+		ix.mu.RLock()
+		skip := ix.removed[id]
+		ix.mu.RUnlock()
+		if skip {
+			continue
+		}
+		ix.withGraphs(func(entries, _ *HNSW) {
+			// A vector saved since we read the database is newer than ours
+			if entries.Has(id) {
+				return
+			}
+			if err := entries.Add(id, vec); err != nil {
+				log.Printf("Warning: Vector index: %v", err)
+			}
+		})
+	}
+	for chunkID, vec := range chunkVectors {
+		ix.mu.RLock()
+		owner := chunkOwners[chunkID]
+		_, known := ix.chunkEntry[chunkID]
+		skip := ix.removed[owner]
+		ix.mu.RUnlock()
+		if skip || known {
+			continue
+		}
+		var err error
+		ix.withGraphs(func(_, chunks *HNSW) { err = chunks.Add(chunkID, vec) })
+		if err != nil {
+			log.Printf("Warning: Vector index: %v", err)
+			continue
+		}
+		ix.mu.Lock()
+		ix.chunkEntry[chunkID] = owner
+		ix.mu.Unlock()
+	}
+
+	ix.mu.Lock()
+	ix.ready = true
+	ix.dirty = true
+	ix.removed = make(map[int64]bool)
+	ix.mu.Unlock()
+	log.Printf("Vector index: built in %v", time.Since(start))
+	return nil
+}
+
+// loadPersisted swaps in the graphs from disk if the file matches the database state.
+func (ix *VectorIndex) loadPersisted(db *sql.DB, dir string, entryVectors, chunkVectors map[int64][]float32) bool {
+	f, err := os.Open(indexFilePath(dir, ix.version))
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	dec := gob.NewDecoder(r)
+	var header indexFileHeader
+	if err := dec.Decode(&header); err != nil {
+		log.Printf("Vector index: ignoring unreadable index file: %v", err)
+		return false
+	}
+	entryFP, err1 := entryFingerprint(db, ix.version)
+	passageFP, err2 := passageFingerprint(db, ix.version)
+	if header.Quantization == "" {
+		header.Quantization = QuantizeNone
+	}
+	if err1 != nil || err2 != nil || header.Version != ix.version || header.Quantization != ix.quantization || header.EntryFingerprint != entryFP || header.PassageFingerprint != passageFP {
+		log.Printf("Vector index: persisted index is stale, rebuilding")
+		return false
+	}
+
+	var entryGraph, chunkGraph []byte
+	if err := dec.Decode(&entryGraph); err != nil {
+		return false
+	}
+	if err := dec.Decode(&chunkGraph); err != nil {
+		return false
+	}
+	entries, err := ReadHNSWGraph(bytes.NewReader(entryGraph), entryVectors)
+	if err != nil {
+		log.Printf("Vector index: %v, rebuilding", err)
+		return false
+	}
+	chunks, err := ReadHNSWGraph(bytes.NewReader(chunkGraph), chunkVectors)
+	if err != nil {
+		log.Printf("Vector index: %v, rebuilding", err)
+		return false
+	}
+
+	// Only swap in if nothing was written while we were reading
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if ix.entries.Len() > 0 || ix.chunks.Len() > 0 || len(ix.removed) > 0 {
+		return false
+	}
+	ix.entries, ix.chunks = entries, chunks
+	return true
+}
+
+// save writes the graphs to dir if they changed since the last save.
+func (ix *VectorIndex) save(db *sql.DB, dir string) error {
+	ix.mu.Lock()
+	if !ix.ready || !ix.dirty {
+		ix.mu.Unlock()
+		return nil
+	}
+	ix.dirty = false
+	ix.mu.Unlock()
+
+	entryFP, err := entryFingerprint(db, ix.version)
+	if err != nil {
+		return fmt.Errorf("failed to fingerprint embeddings: %w", err)
+	}
+	passageFP, err := passageFingerprint(db, ix.version)
+	if err != nil {
+		return fmt.Errorf("failed to fingerprint passage embeddings: %w", err)
+	}
+
+	var entryGraph, chunkGraph bytes.Buffer
+	var err1, err2 error
+	ix.withGraphs(func(entries, chunks *HNSW) {
+		err1 = entries.WriteGraph(&entryGraph)
+		err2 = chunks.WriteGraph(&chunkGraph)
+	})
+	if err1 != nil {
+		return err1
+	}
+	if err2 != nil {
+		return err2
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create index folder: %w", err)
+	}
+	path := indexFilePath(dir, ix.version)
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to write index file: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	enc := gob.NewEncoder(w)
+	header := indexFileHeader{Version: ix.version, Quantization: ix.quantization, EntryFingerprint: entryFP, PassageFingerprint: passageFP, SavedAt: time.Now()}
+	for _, v := range []interface{}{header, entryGraph.Bytes(), chunkGraph.Bytes()} {
+		if err := enc.Encode(v); err != nil {
+			f.Close()
+			os.Remove(tmp)
+			return fmt.Errorf("failed to encode index file: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write index file: %w", err)
+	}
+	f.Close()
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to replace index file: %w", err)
+	}
+	log.Printf("Vector index: saved %s", path)
+	return nil
+}
+
+func loadVectors(db *sql.DB, query, version string) (map[int64][]float32, error) {
+	rows, err := db.Query(query, version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load vectors: %w", err)
+	}
+	defer rows.Close()
+	vectors := make(map[int64][]float32)
+	for rows.Next() {
+		var id int64
+		var data []byte
+		if err := rows.Scan(&id, &data); err != nil {
+			return nil, fmt.Errorf("failed to scan vector: %w", err)
+		}
+		if vec := deserializeEmbedding(data); len(vec) > 0 {
+			vectors[id] = vec
+		}
+	}
+	return vectors, rows.Err()
+}
+
+func loadChunkVectors(db *sql.DB, version string) (map[int64][]float32, map[int64]int64, error) {
+	rows, err := db.Query(`SELECT id, codex_entry_id, embedding FROM codex_embedding_chunks WHERE vector_version = ?`, version)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load passage vectors: %w", err)
+	}
+	defer rows.Close()
+	vectors := make(map[int64][]float32)
+	owners := make(map[int64]int64)
+	for rows.Next() {
+		var id, entryID int64
+		var data []byte
+		if err := rows.Scan(&id, &entryID, &data); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan passage vector: %w", err)
+		}
+		if vec := deserializeEmbedding(data); len(vec) > 0 {
+			vectors[id] = vec
+			owners[id] = entryID
+		}
+	}
+	return vectors, owners, rows.Err()
+}
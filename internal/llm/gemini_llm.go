@@ -0,0 +1,102 @@
+// internal/llm/gemini_llm.go
+package llm
+
+import (
+	"Llore/internal/provider"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// CompleteWithGemini sends a prompt to a Gemini model via the genai SDK.
+func CompleteWithGemini(apiKey, prompt, modelID string) (Completion, error) {
+	// The prompt is already a string, so genai.Text(prompt) is appropriate.
+	return completeGeminiContents(apiKey, modelID, genai.Text(prompt))
+}
+
+// completeGeminiContents generates from arbitrary (possibly multimodal) contents.
+func completeGeminiContents(apiKey, modelID string, contents []*genai.Content) (Completion, error) {
+	if apiKey == "" {
+		return Completion{}, provider.NewError("gemini", modelID, provider.ErrNotConfigured, "Gemini API key not set. Cannot use Gemini LLM", nil)
+	}
+
+	genaiClient, err := genai.NewClient(context.Background(), &genai.ClientConfig{
+		APIKey:     apiKey,
+		HTTPClient: provider.HTTPClient(provider.ClientGemini),
+	})
+	if err != nil {
+		return Completion{}, provider.NewError("gemini", modelID, provider.ErrNotConfigured, fmt.Sprintf("failed to create Gemini client: %v", err), err)
+	}
+
+	resp, err := genaiClient.Models.GenerateContent(context.Background(), modelID, contents, nil)
+	if err != nil {
+		return Completion{}, classifyGeminiError(modelID, err)
+	}
+	if resp == nil {
+		return Completion{}, provider.NewError("gemini", modelID, provider.ErrEmptyResponse, "gemini response was empty", nil)
+	}
+	if resp.PromptFeedback != nil && resp.PromptFeedback.BlockReason != "" {
+		return Completion{}, provider.NewError("gemini", modelID, provider.ErrContentFiltered, fmt.Sprintf("prompt blocked by Gemini (reason: %s)", resp.PromptFeedback.BlockReason), nil)
+	}
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+		return Completion{}, provider.NewError("gemini", modelID, provider.ErrEmptyResponse, "gemini response was empty or not in expected format", nil)
+	}
+
+	candidate := resp.Candidates[0]
+	if perr := provider.FromFinishReason("gemini", modelID, string(candidate.FinishReason)); perr != nil {
+		return Completion{}, perr
+	}
+
+	// Thinking models return thought parts alongside the answer; keep them apart.
+	var answerParts, thoughtParts []string
+	for _, part := range candidate.Content.Parts {
+		if part == nil || part.Text == "" {
+			continue
+		}
+		if part.Thought {
+			thoughtParts = append(thoughtParts, part.Text)
+		} else {
+			answerParts = append(answerParts, part.Text)
+		}
+	}
+	answer, inlineReasoning := SplitReasoning(strings.Join(answerParts, ""))
+	reasoning := strings.TrimSpace(strings.Join(thoughtParts, "\n") + "\n\n" + inlineReasoning)
+	if answer == "" {
+		log.Printf("Gemini response contained no answer text")
+		return Completion{}, provider.NewError("gemini", modelID, provider.ErrEmptyResponse, "gemini response contained no answer text", nil)
+	}
+
+	log.Printf("Gemini Raw Response Part: %s", answer)
+	var usage Usage
+	if resp.UsageMetadata != nil {
+		usage = Usage{
+			PromptTokens:     int(resp.UsageMetadata.PromptTokenCount),
+			CompletionTokens: int(resp.UsageMetadata.CandidatesTokenCount),
+			TotalTokens:      int(resp.UsageMetadata.TotalTokenCount),
+		}
+	}
+	return Completion{Content: answer, Reasoning: reasoning, Model: modelID, Usage: usage}, nil
+}
+
+// classifyGeminiError converts a genai SDK error into a provider.Error.
+func classifyGeminiError(modelID string, err error) *provider.Error {
+	var apiErr genai.APIError
+	if errors.As(err, &apiErr) {
+		perr := provider.FromHTTPStatus("gemini", modelID, apiErr.Code, apiErr.Status+" "+apiErr.Message)
+		perr.Message = apiErr.Message
+		perr.Err = err
+		return perr
+	}
+	var apiErrPtr *genai.APIError
+	if errors.As(err, &apiErrPtr) {
+		perr := provider.FromHTTPStatus("gemini", modelID, apiErrPtr.Code, apiErrPtr.Status+" "+apiErrPtr.Message)
+		perr.Message = apiErrPtr.Message
+		perr.Err = err
+		return perr
+	}
+	return provider.FromTransport("gemini", modelID, err)
+}
@@ -2,6 +2,7 @@
 package llm
 
 import (
+	"Llore/internal/provider"
 	"bytes"
 	"encoding/json"
 	"fmt"
@@ -10,6 +11,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 )
 
@@ -23,6 +25,65 @@ type OpenRouterConfig struct {
 	ActiveMode              string `json:"active_mode,omitempty"` // "local", "openrouter", "openai", "gemini"
 	OpenAIAPIKey            string `json:"openai_api_key,omitempty"`
 	LocalEmbeddingModelName string `json:"local_embedding_model_name,omitempty"`
+	VisionModelID           string `json:"vision_model_id,omitempty"` // Image-capable model used to describe codex attachments
+
+	// EmbeddingProvider picks the embedding provider independently of the chat mode:
+	// "ollama" (LocalEmbeddingModelName), "gemini" or "openai". Empty picks it from ActiveMode.
+	EmbeddingProvider string `json:"embedding_provider,omitempty"`
+
+	// Gemini embedding model and output dimension. Empty uses text-embedding-004 and 0 the
+	// model's full dimension. Changing either starts a new vector version.
+	GeminiEmbeddingModel      string `json:"gemini_embedding_model,omitempty"`
+	GeminiEmbeddingDimensions int    `json:"gemini_embedding_dimensions,omitempty"`
+
+	// OpenAIEmbeddingModel is the OpenAI embedding model; empty uses text-embedding-3-small.
+	OpenAIEmbeddingModel string `json:"openai_embedding_model,omitempty"`
+
+	// ExposeReasoning forwards reasoning-model chain-of-thought to the frontend trace view.
+	// It is never included in returned completions either way.
+	ExposeReasoning bool `json:"expose_reasoning,omitempty"`
+
+	// Reciprocal-rank fusion weights for context retrieval. Leaving both at 0 uses equal
+	// weights; setting one to 0 disables that retriever.
+	RetrievalVectorWeight  float64 `json:"retrieval_vector_weight,omitempty"`
+	RetrievalKeywordWeight float64 `json:"retrieval_keyword_weight,omitempty"`
+
+	// RetrievalDiversity is the MMR lambda for picking the final context: lower values
+	// trade relevance for less redundancy. 0 uses 0.7; 1 picks by relevance alone.
+	RetrievalDiversity float64 `json:"retrieval_diversity,omitempty"`
+
+	// ChatMemoryEnabled embeds saved chat logs and lets retrieval pull in relevant past
+	// discussion. Individual sessions can still opt out.
+	ChatMemoryEnabled bool `json:"chat_memory_enabled,omitempty"`
+
+	// Pre-retrieval query planning. Rewriting turns follow-ups into standalone queries
+	// using recent chat turns, sub-queries split multi-part questions, and HyDE also
+	// searches with a hypothetical answer. Each costs an LLM call per message, made with
+	// QueryRewriteModelID or, if empty, the chat model.
+	QueryRewriteEnabled bool   `json:"query_rewrite_enabled,omitempty"`
+	QuerySubQueries     int    `json:"query_sub_queries,omitempty"` // Max sub-queries; 0 disables splitting
+	QueryHyDEEnabled    bool   `json:"query_hyde_enabled,omitempty"`
+	QueryRewriteModelID string `json:"query_rewrite_model_id,omitempty"`
+
+	// Reranking of the top fused candidates. RerankMode is empty (off), "llm" for listwise
+	// reranking by RerankModelID (or the chat model), or "cross-encoder" for a /rerank
+	// endpoint at RerankEndpoint serving RerankModelID.
+	RerankMode       string `json:"rerank_mode,omitempty"`
+	RerankModelID    string `json:"rerank_model_id,omitempty"`
+	RerankEndpoint   string `json:"rerank_endpoint,omitempty"`
+	RerankAPIKey     string `json:"rerank_api_key,omitempty"`
+	RerankCandidates int    `json:"rerank_candidates,omitempty"` // 0 uses 30
+
+	// SimilarityThresholds overrides the built-in minimum vector similarity, keyed by
+	// vector version prefix (e.g. "openai:text-embedding-3"). A threshold calibrated on
+	// the vault takes precedence over both.
+	SimilarityThresholds map[string]float64 `json:"similarity_thresholds,omitempty"`
+
+	// Network settings applied to every provider's HTTP client, including the SDK clients.
+	HTTPProxyURL        string         `json:"http_proxy_url,omitempty"`          // Empty uses HTTP(S)_PROXY from the environment
+	CACertPath          string         `json:"ca_cert_path,omitempty"`            // Extra PEM bundle, e.g. a corporate root CA
+	ProviderTimeouts    map[string]int `json:"provider_timeouts,omitempty"`       // Seconds, keyed by provider.Client* name
+	MaxIdleConnsPerHost int            `json:"max_idle_conns_per_host,omitempty"` // Connection pool size per provider host
 }
 
 var (
@@ -125,56 +186,117 @@ func SaveOpenRouterConfig() error {
 }
 
 // GetOpenRouterCompletion returns a completion from OpenRouter API
+// with any model reasoning stripped. Use CompleteWithOpenRouter to keep it.
 func GetOpenRouterCompletion(prompt, model string) (string, error) {
+	completion, err := CompleteWithOpenRouter(prompt, model)
+	if err != nil {
+		return "", err
+	}
+	return completion.Content, nil
+}
+
+// CompleteWithOpenRouter returns a completion from OpenRouter API, separating the
+// 'reasoning' field (and any inline <think> block) from the final answer.
+func CompleteWithOpenRouter(prompt, model string) (Completion, error) {
+	return completeOpenRouterMessages(model, []map[string]interface{}{
+		{"role": "user", "content": prompt},
+	})
+}
+
+// completeOpenRouterMessages posts OpenAI-format chat messages (content may be a string
+// or a list of text/image parts) to OpenRouter.
+func completeOpenRouterMessages(model string, messages []map[string]interface{}) (Completion, error) {
 	configMutex.RLock()
 	apiKey := openRouterConfig.APIKey
 	configMutex.RUnlock()
 	if apiKey == "" {
-		return "", fmt.Errorf("OpenRouter API key not set")
+		return Completion{}, provider.NewError("openrouter", model, provider.ErrNotConfigured, "OpenRouter API key not set", nil)
 	}
 
 	// Create request body
 	reqBody := map[string]interface{}{
-		"model": model,
-		"messages": []map[string]string{
-			{"role": "user", "content": prompt},
-		},
+		"model":    model,
+		"messages": messages,
 	}
 	reqJSON, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", err
+		return Completion{}, err
 	}
 
 	req, err := http.NewRequest("POST", "https://openrouter.ai/api/v1/chat/completions", bytes.NewBuffer(reqJSON))
 	if err != nil {
-		return "", err
+		return Completion{}, err
 	}
 	req.Header.Set("Authorization", "Bearer "+apiKey)
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := provider.HTTPClient(provider.ClientOpenRouter).Do(req)
 	if err != nil {
-		return "", err
+		return Completion{}, provider.FromTransport("openrouter", model, err)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != 200 {
 		body, _ := ioutil.ReadAll(resp.Body)
-		return "", fmt.Errorf("OpenRouter API error: %s", string(body))
+		return Completion{}, provider.FromHTTPStatus("openrouter", model, resp.StatusCode, openRouterErrorMessage(body))
 	}
 	var result struct {
+		Model   string `json:"model"`
 		Choices []struct {
 			Message struct {
-				Content string `json:"content"`
+				Content   string `json:"content"`
+				Reasoning string `json:"reasoning"`
 			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
 		} `json:"choices"`
+		// OpenRouter reports upstream failures with a 200 status and an error object.
+		Error *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+		Usage struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+			TotalTokens      int `json:"total_tokens"`
+		} `json:"usage"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", err
+		return Completion{}, provider.NewError("openrouter", model, provider.ErrInvalidResponse, fmt.Sprintf("failed to decode OpenRouter response: %v", err), err)
+	}
+	if result.Error != nil {
+		return Completion{}, provider.FromHTTPStatus("openrouter", model, result.Error.Code, result.Error.Message)
 	}
 	if len(result.Choices) == 0 {
-		return "", fmt.Errorf("No choices returned from OpenRouter")
+		return Completion{}, provider.NewError("openrouter", model, provider.ErrEmptyResponse, "No choices returned from OpenRouter", nil)
+	}
+	if perr := provider.FromFinishReason("openrouter", model, result.Choices[0].FinishReason); perr != nil {
+		return Completion{}, perr
+	}
+	message := result.Choices[0].Message
+	answer, inlineReasoning := SplitReasoning(message.Content)
+	reasoning := strings.TrimSpace(message.Reasoning + "\n\n" + inlineReasoning)
+	if answer == "" {
+		return Completion{}, provider.NewError("openrouter", model, provider.ErrEmptyResponse, "OpenRouter model returned empty content", nil)
+	}
+
+	servedModel := result.Model
+	if servedModel == "" {
+		servedModel = model
+	}
+	return Completion{Content: answer, Reasoning: reasoning, Model: servedModel, Usage: Usage(result.Usage)}, nil
+}
+
+// openRouterErrorMessage extracts error.message from an OpenRouter error body,
+// falling back to the raw body.
+func openRouterErrorMessage(body []byte) string {
+	var errResp struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &errResp) == nil && errResp.Error.Message != "" {
+		return errResp.Error.Message
 	}
-	return result.Choices[0].Message.Content, nil
+	return string(body)
 }
 
 // OpenRouter model definitions and model-fetching logic
@@ -199,7 +321,7 @@ func FetchOpenRouterModels(apiKey string) ([]OpenRouterModel, error) {
 		return nil, err
 	}
 	req.Header.Set("Authorization", "Bearer "+apiKey)
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := provider.HTTPClient(provider.ClientModelList).Do(req)
 	if err != nil {
 		return nil, err
 	}
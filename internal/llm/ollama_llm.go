@@ -2,6 +2,7 @@
 package llm
 
 import (
+	"Llore/internal/provider"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -22,19 +23,23 @@ const (
 
 // OllamaGenerateRequest defines the JSON structure for the /api/generate request to Ollama.
 type OllamaGenerateRequest struct {
-	Model  string `json:"model"`
-	Prompt string `json:"prompt"`
-	Stream bool   `json:"stream"`
+	Model  string   `json:"model"`
+	Prompt string   `json:"prompt"`
+	Stream bool     `json:"stream"`
+	Images []string `json:"images,omitempty"` // Base64-encoded images for multimodal models (e.g. llava)
 	// System string `json:"system,omitempty"` // Optional system prompt
 	// KeepAlive string `json:"keep_alive,omitempty"` // Optional: controls how long the model stays loaded in memory
 }
 
 // OllamaGenerateResponse defines the JSON structure for a successful non-streaming /api/generate response.
 type OllamaGenerateResponse struct {
-	Model     string    `json:"model"`
-	CreatedAt time.Time `json:"created_at"`
-	Response  string    `json:"response"`
-	Done      bool      `json:"done"`
+	Model           string    `json:"model"`
+	CreatedAt       time.Time `json:"created_at"`
+	Response        string    `json:"response"`
+	Thinking        string    `json:"thinking,omitempty"` // Populated by Ollama for thinking-capable models
+	Done            bool      `json:"done"`
+	PromptEvalCount int       `json:"prompt_eval_count,omitempty"` // Prompt tokens
+	EvalCount       int       `json:"eval_count,omitempty"`        // Generated tokens
 	// Context   []int     `json:"context,omitempty"` // If you want to manage conversation context
 	// Other fields like total_duration, load_duration, etc., can be added if needed.
 }
@@ -67,37 +72,54 @@ type OllamaChatResponse struct {
 
 // GetOllamaCompletion sends a prompt to a local Ollama model using the /api/generate endpoint.
 // modelTag is the specific Ollama model to use (e.g., "llama3", "mistral").
+// Any <think> preamble is stripped; use CompleteWithOllama to also get the reasoning.
 func GetOllamaCompletion(prompt, modelTag string) (string, error) {
+	completion, err := CompleteWithOllama(prompt, modelTag)
+	if err != nil {
+		return "", err
+	}
+	return completion.Content, nil
+}
+
+// CompleteWithOllama is GetOllamaCompletion with the model's reasoning returned separately.
+func CompleteWithOllama(prompt, modelTag string) (Completion, error) {
+	return generateWithOllama(OllamaGenerateRequest{
+		Model:  modelTag,
+		Prompt: prompt,
+		Stream: false, // For synchronous response
+	})
+}
+
+// generateWithOllama sends a non-streaming /api/generate request and parses the result.
+func generateWithOllama(requestPayload OllamaGenerateRequest) (completion Completion, err error) {
+	modelTag := requestPayload.Model
+
 	// Validate inputs to prevent crashes
 	if modelTag == "" {
-		return "", fmt.Errorf("Ollama model tag cannot be empty")
+		return Completion{}, provider.NewError("ollama", modelTag, provider.ErrNotConfigured, "Ollama model tag cannot be empty", nil)
 	}
 
 	// Recover from panics to prevent application crashes
 	defer func() {
 		if r := recover(); r != nil {
 			log.Printf("CRITICAL: Recovered from panic in GetOllamaCompletion: %v", r)
+			completion = Completion{}
+			err = provider.NewError("ollama", modelTag, provider.ErrUnknown, fmt.Sprintf("internal error while calling Ollama: %v", r), nil)
 		}
 	}()
 
-	httpClient := &http.Client{Timeout: 300 * time.Second} // Extended timeout (5 minutes) for larger local models like Mistral
-
-	requestPayload := OllamaGenerateRequest{
-		Model:  modelTag,
-		Prompt: prompt,
-		Stream: false, // For synchronous response
-	}
+	httpClient := provider.HTTPClient(provider.ClientOllama) // Extended timeout (5 minutes by default) for larger local models like Mistral
 
 	bodyBytes, err := json.Marshal(requestPayload)
 	if err != nil {
 		log.Printf("ERROR: Ollama LLM: Failed to marshal request for model '%s': %v", modelTag, err)
-		return "", fmt.Errorf("failed to marshal Ollama generate request: %w", err)
+		return Completion{}, fmt.Errorf("failed to marshal Ollama generate request: %w", err)
 	}
 
 	req, err := http.NewRequestWithContext(context.Background(), "POST", OllamaDefaultLLMAPIEndpoint, bytes.NewBuffer(bodyBytes))
 	if err != nil {
 		log.Printf("ERROR: Ollama LLM: Failed to create HTTP request for model '%s': %v", modelTag, err)
-		return "", fmt.Errorf("failed to create Ollama generate HTTP request: %w", err)
+		return Completion{}, fmt.Errorf("failed to create Ollama generate HTTP request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 
@@ -109,13 +131,15 @@ func GetOllamaCompletion(prompt, modelTag string) (string, error) {
 	resp, err := httpClient.Do(req)
 	if err != nil {
 		log.Printf("ERROR: Ollama LLM: Request failed for model '%s'. Is Ollama running at %s? Error: %v", modelTag, OllamaDefaultLLMAPIEndpoint, err)
-		return "", fmt.Errorf("failed to connect to Ollama at %s (model: %s). Please ensure Ollama is running. Error: %w", OllamaDefaultLLMAPIEndpoint, modelTag, err)
+		perr := provider.FromTransport("ollama", modelTag, err)
+		perr.Message = fmt.Sprintf("failed to connect to Ollama at %s. Please ensure Ollama is running. Error: %v", OllamaDefaultLLMAPIEndpoint, err)
+		return Completion{}, perr
 	}
 	defer resp.Body.Close()
 
 	respBody, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read Ollama generate response body: %w", err)
+		return Completion{}, provider.FromTransport("ollama", modelTag, err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
@@ -127,7 +151,11 @@ func GetOllamaCompletion(prompt, modelTag string) (string, error) {
 		if json.Unmarshal(respBody, &ollamaErrorResp) == nil && ollamaErrorResp.Error != "" {
 			apiErrorMsg = ollamaErrorResp.Error
 		}
-		return "", fmt.Errorf("Ollama LLM API error (Status %d) for model '%s': %s. (Ensure model is pulled: `ollama pull %s`)", resp.StatusCode, modelTag, apiErrorMsg, modelTag)
+		perr := provider.FromHTTPStatus("ollama", modelTag, resp.StatusCode, apiErrorMsg)
+		if perr.Code == provider.ErrModelNotFound {
+			perr.Message = fmt.Sprintf("%s (Ensure model is pulled: `ollama pull %s`)", apiErrorMsg, modelTag)
+		}
+		return Completion{}, perr
 	}
 
 	var ollamaSuccessResp OllamaGenerateResponse
@@ -140,25 +168,36 @@ func GetOllamaCompletion(prompt, modelTag string) (string, error) {
 			// Try to extract response field from partial JSON
 			if responseMatch := regexp.MustCompile(`"response"\s*:\s*"([^"]+)"`).FindStringSubmatch(respStr); len(responseMatch) > 1 {
 				log.Printf("Salvaged response content from partial JSON")
-				return responseMatch[1], nil
+				answer, reasoning := SplitReasoning(responseMatch[1])
+				return Completion{Content: answer, Reasoning: reasoning, Model: modelTag}, nil
 			}
-			// If all else fails, return the raw response with a warning
-			log.Printf("WARNING: Returning raw response due to JSON parse failure")
-			return fmt.Sprintf("[Error parsing Ollama response. Raw content: %s]", respStr), nil
 		}
-		return "", fmt.Errorf("failed to parse Ollama response: %w", err)
+		return Completion{}, provider.NewError("ollama", modelTag, provider.ErrInvalidResponse, fmt.Sprintf("failed to parse Ollama response: %v", err), err)
+	}
+
+	// Separate chain-of-thought from the answer. Newer Ollama versions report it in
+	// 'thinking'; older ones (and most GGUF reasoning models) inline <think> blocks.
+	answer, reasoning := SplitReasoning(ollamaSuccessResp.Response)
+	if ollamaSuccessResp.Thinking != "" {
+		reasoning = strings.TrimSpace(ollamaSuccessResp.Thinking + "\n\n" + reasoning)
 	}
 
-	if !ollamaSuccessResp.Done || ollamaSuccessResp.Response == "" {
+	if !ollamaSuccessResp.Done || answer == "" {
 		log.Printf("WARNING: Ollama LLM API for model '%s' returned successfully but with 'done: false' or empty response.", modelTag)
-		// Return a more helpful message instead of an empty response
-		if ollamaSuccessResp.Response == "" {
-			return fmt.Sprintf("[Ollama model '%s' returned an empty response. Please try again or use a different model.]", modelTag), nil
+		if answer == "" {
+			return Completion{}, provider.NewError("ollama", modelTag, provider.ErrEmptyResponse, "Ollama model returned an empty response. Please try again or use a different model", nil)
 		}
 	}
 
+	if reasoning != "" {
+		log.Printf("Ollama LLM: Separated %d chars of reasoning from '%s' output", len(reasoning), modelTag)
+	}
 	log.Printf("Ollama LLM: Received completion from '%s'", modelTag)
-	return ollamaSuccessResp.Response, nil
+	return Completion{Content: answer, Reasoning: reasoning, Model: modelTag, Usage: Usage{
+		PromptTokens:     ollamaSuccessResp.PromptEvalCount,
+		CompletionTokens: ollamaSuccessResp.EvalCount,
+		TotalTokens:      ollamaSuccessResp.PromptEvalCount + ollamaSuccessResp.EvalCount,
+	}}, nil
 }
 
 // OllamaModelInfo describes a locally available Ollama model.
@@ -178,7 +217,7 @@ type OllamaTagsResponse struct {
 
 // FetchOllamaModels retrieves the list of locally available Ollama models.
 func FetchOllamaModels() ([]OpenRouterModel, error) { // Reusing OpenRouterModel for simplicity in frontend
-	httpClient := &http.Client{Timeout: 10 * time.Second}
+	httpClient := provider.HTTPClient(provider.ClientModelList)
 	log.Println("Fetching local Ollama models from:", OllamaDefaultTagsAPIEndpoint)
 
 	req, err := http.NewRequestWithContext(context.Background(), "GET", OllamaDefaultTagsAPIEndpoint, nil)
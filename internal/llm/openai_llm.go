@@ -0,0 +1,75 @@
+// internal/llm/openai_llm.go
+package llm
+
+import (
+	"Llore/internal/provider"
+	"context"
+	"errors"
+	"log"
+
+	openai "github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// CompleteWithOpenAI sends a single-turn chat completion to OpenAI using the official SDK.
+func CompleteWithOpenAI(apiKey, prompt, modelID string) (Completion, error) {
+	return completeOpenAIMessages(apiKey, modelID, openai.UserMessage(prompt))
+}
+
+// completeOpenAIMessages runs a chat completion over arbitrary (possibly multimodal) messages.
+func completeOpenAIMessages(apiKey, modelID string, messages ...openai.ChatCompletionMessageParamUnion) (Completion, error) {
+	if apiKey == "" {
+		return Completion{}, provider.NewError("openai", modelID, provider.ErrNotConfigured, "OpenAI API key not set. Cannot use OpenAI LLM", nil)
+	}
+
+	client := openai.NewClient(
+		option.WithAPIKey(apiKey),
+		option.WithHTTPClient(provider.HTTPClient(provider.ClientOpenAI)),
+	)
+	log.Printf("Sending prompt to OpenAI model %s", modelID)
+
+	// Create a chat completion using the official SDK
+	completion, err := client.Chat.Completions.New(
+		context.Background(),
+		openai.ChatCompletionNewParams{
+			Model:    modelID,
+			Messages: messages,
+		},
+	)
+	if err != nil {
+		return Completion{}, classifyOpenAIError(modelID, err)
+	}
+	if len(completion.Choices) == 0 {
+		return Completion{}, provider.NewError("openai", modelID, provider.ErrEmptyResponse, "OpenAI returned no choices", nil)
+	}
+	choice := completion.Choices[0]
+	if perr := provider.FromFinishReason("openai", modelID, choice.FinishReason); perr != nil {
+		return Completion{}, perr
+	}
+
+	// OpenAI-hosted models don't expose reasoning, but fine-tunes and compatible
+	// endpoints may still inline <think> blocks.
+	answer, reasoning := SplitReasoning(choice.Message.Content)
+	if answer == "" {
+		return Completion{}, provider.NewError("openai", modelID, provider.ErrEmptyResponse, "OpenAI returned empty content", nil)
+	}
+	return Completion{Content: answer, Reasoning: reasoning, Model: modelID, Usage: Usage{
+		PromptTokens:     int(completion.Usage.PromptTokens),
+		CompletionTokens: int(completion.Usage.CompletionTokens),
+		TotalTokens:      int(completion.Usage.TotalTokens),
+	}}, nil
+}
+
+// classifyOpenAIError converts an SDK error into a provider.Error.
+func classifyOpenAIError(modelID string, err error) *provider.Error {
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) {
+		perr := provider.FromHTTPStatus("openai", modelID, apiErr.StatusCode, apiErr.Message+" "+apiErr.Code)
+		if apiErr.Message != "" {
+			perr.Message = apiErr.Message
+		}
+		perr.Err = err
+		return perr
+	}
+	return provider.FromTransport("openai", modelID, err)
+}
@@ -25,12 +25,31 @@ func NewPromptBuilder(contextBuilder *context.ContextBuilder) *PromptBuilder {
 
 // BuildPromptWithContext creates a prompt string including relevant context retrieved based on the user query
 func (b *PromptBuilder) BuildPromptWithContext(userQuery string) (string, error) {
+	return b.BuildPromptWithHistory(userQuery, nil)
+}
+
+// BuildPromptWithHistory is BuildPromptWithContext for a message in a conversation. The
+// earlier turns are only used to retrieve context; they are not added to the prompt.
+func (b *PromptBuilder) BuildPromptWithHistory(userQuery string, history []context.Turn) (string, error) {
+	return b.BuildPrompt(context.Request{Query: userQuery, History: history})
+}
+
+// BuildPrompt is BuildPromptWithContext for a full retrieval request. The document is
+// only used to retrieve context; it is not added to the prompt.
+func (b *PromptBuilder) BuildPrompt(req context.Request) (string, error) {
+	prompt, _, err := b.BuildPromptWithSources(req)
+	return prompt, err
+}
+
+// BuildPromptWithSources is BuildPrompt that also describes the context it included.
+func (b *PromptBuilder) BuildPromptWithSources(req context.Request) (string, []context.ContextSource, error) {
 	if b.contextBuilder == nil {
-		return "", fmt.Errorf("context builder is not initialized in PromptBuilder")
+		return "", nil, fmt.Errorf("context builder is not initialized in PromptBuilder")
 	}
+	userQuery := req.Query
 
 	// Get context string for the query
-	contextStr, err := b.contextBuilder.BuildContextForQuery(userQuery)
+	contextStr, sources, err := b.contextBuilder.BuildContextWithSources(req)
 	if err != nil {
 		// Log the error but proceed without context if retrieval fails
 		log.Printf("Warning: Failed to build context for prompt, proceeding without it: %v", err)
@@ -46,7 +65,9 @@ func (b *PromptBuilder) BuildPromptWithContext(userQuery string) (string, error)
 	sb.WriteString("SYSTEM INSTRUCTIONS:\n")
 	sb.WriteString("You are an AI assistant helping a fiction writer manage their worldbuilding codex (characters, locations, lore, etc.). ")
 	sb.WriteString("Your goal is to answer the user's query based on the provided CONTEXT INFORMATION below. ")
-	sb.WriteString("The context contains relevant entries from the writer's codex, ordered by relevance to the query. ")
+	sb.WriteString("The context contains relevant entries from the writer's codex and passages from their manuscripts, ordered by relevance to the query. ")
+	sb.WriteString("Manuscripts are the source of truth for what has happened in the story; when you rely on a manuscript passage, cite its Source (file and lines). ")
+	sb.WriteString("It may also include excerpts of earlier discussions with the writer; treat these as ideas under consideration, not established facts. ")
 	sb.WriteString("If the context contains information relevant to the query, prioritize using it in your answer. ")
 	sb.WriteString("If the context does not seem relevant or is insufficient to answer the query fully, clearly state that and then use your general knowledge to provide the best possible response. ")
 	sb.WriteString("Be creative and helpful, adopting the persona of a knowledgeable assistant for a writer.\n\n")
@@ -71,7 +92,7 @@ func (b *PromptBuilder) BuildPromptWithContext(userQuery string) (string, error)
 	finalPrompt := sb.String()
 	log.Printf("Built prompt with context (context length: %d chars)", len(contextStr)) // Log prompt creation
 
-	return finalPrompt, nil
+	return finalPrompt, sources, nil
 }
 
 // BuildSimplePrompt creates a basic prompt without context retrieval (useful for other tasks)
@@ -0,0 +1,83 @@
+// internal/llm/reasoning.go
+package llm
+
+import (
+	"strings"
+)
+
+// Completion holds a model's final answer with any chain-of-thought separated out.
+// Callers should only ever persist or insert Content; Reasoning is for the trace/debug view.
+type Completion struct {
+	Content   string `json:"content"`
+	Reasoning string `json:"reasoning,omitempty"`
+	Model     string `json:"model"`
+	Usage     Usage  `json:"usage"`
+}
+
+// Usage is the token accounting reported by the provider. Zero means the provider didn't report it.
+type Usage struct {
+	PromptTokens     int `json:"promptTokens"`
+	CompletionTokens int `json:"completionTokens"`
+	TotalTokens      int `json:"totalTokens"`
+}
+
+// reasoningTags are the tag names reasoning models use to wrap their thinking.
+var reasoningTags = []string{"think", "thinking", "reasoning"}
+
+// SplitReasoning separates <think>...</think> style blocks from the final answer.
+// It also handles a dangling closing tag (some chat templates emit the opening tag
+// in the prompt) and an unclosed opening tag (output truncated mid-thought).
+func SplitReasoning(text string) (answer string, reasoning string) {
+	var thoughts []string
+	remaining := text
+
+	for _, tag := range reasoningTags {
+		openTag := "<" + tag + ">"
+		closeTag := "</" + tag + ">"
+
+		// Closing tag with no opening tag: everything before it is reasoning.
+		closeIdx := indexFold(remaining, closeTag)
+		openIdx := indexFold(remaining, openTag)
+		if closeIdx >= 0 && (openIdx < 0 || openIdx > closeIdx) {
+			thoughts = append(thoughts, strings.TrimSpace(remaining[:closeIdx]))
+			remaining = remaining[closeIdx+len(closeTag):]
+		}
+
+		for {
+			start := indexFold(remaining, openTag)
+			if start < 0 {
+				break
+			}
+			end := indexFold(remaining[start+len(openTag):], closeTag)
+			if end < 0 {
+				// Unclosed block: the model never got to its answer.
+				thoughts = append(thoughts, strings.TrimSpace(remaining[start+len(openTag):]))
+				remaining = remaining[:start]
+				break
+			}
+			end += start + len(openTag)
+			thoughts = append(thoughts, strings.TrimSpace(remaining[start+len(openTag):end]))
+			remaining = remaining[:start] + remaining[end+len(closeTag):]
+		}
+	}
+
+	var nonEmpty []string
+	for _, t := range thoughts {
+		if t != "" {
+			nonEmpty = append(nonEmpty, t)
+		}
+	}
+
+	return strings.TrimSpace(remaining), strings.Join(nonEmpty, "\n\n")
+}
+
+// indexFold is a case-insensitive strings.Index for ASCII tags. It compares byte
+// windows directly so offsets stay valid for the original (possibly non-ASCII) text.
+func indexFold(s, substr string) int {
+	for i := 0; i+len(substr) <= len(s); i++ {
+		if s[i] == '<' && strings.EqualFold(s[i:i+len(substr)], substr) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,57 @@
+package llm
+
+import "testing"
+
+func TestSplitReasoning(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		answer    string
+		reasoning string
+	}{
+		{"no reasoning", "Just an answer.", "Just an answer.", ""},
+		{"empty", "", "", ""},
+		{"think", "<think>Plan it.</think>The answer.", "The answer.", "Plan it."},
+		{"thinking", "<thinking>Plan it.</thinking>\n\nThe answer.", "The answer.", "Plan it."},
+		{"reasoning", "<reasoning>Plan it.</reasoning>The answer.", "The answer.", "Plan it."},
+		{"mixed case", "<THINK>Plan it.</Think>The answer.", "The answer.", "Plan it."},
+		{"several blocks", "<think>One.</think>Mid <think>Two.</think>end.", "Mid end.", "One.\n\nTwo."},
+		{"different tags", "<thinking>One.</thinking><reasoning>Two.</reasoning>The answer.", "The answer.", "One.\n\nTwo."},
+		{"unclosed open tag", "The start <think>still thinking when cut off", "The start", "still thinking when cut off"},
+		{"only unclosed open tag", "<think>still thinking", "", "still thinking"},
+		{"stray close tag", "thought from the template</think>The answer.", "The answer.", "thought from the template"},
+		{"stray close tag then block", "first</think>Answer <think>second</think>done.", "Answer done.", "first\n\nsecond"},
+		{"nested", "<think>outer <reasoning>inner</reasoning></think>The answer.", "The answer.", "outer <reasoning>inner</reasoning>"},
+		{"empty block", "<think>  </think>The answer.", "The answer.", ""},
+		{"non-ASCII around tags", "<think>Überlegung</think>Réponse – fin.", "Réponse – fin.", "Überlegung"},
+		{"similar tag is kept", "<thinker>not reasoning</thinker>", "<thinker>not reasoning</thinker>", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			answer, reasoning := SplitReasoning(tt.text)
+			if answer != tt.answer {
+				t.Errorf("answer = %q, want %q", answer, tt.answer)
+			}
+			if reasoning != tt.reasoning {
+				t.Errorf("reasoning = %q, want %q", reasoning, tt.reasoning)
+			}
+		})
+	}
+}
+
+func TestIndexFold(t *testing.T) {
+	tests := []struct {
+		s, substr string
+		want      int
+	}{
+		{"abc<THINK>", "<think>", 3},
+		{"no tag here", "<think>", -1},
+		{"<thin", "<think>", -1},
+		{"é<Think>", "<think>", 2}, // Byte offset in the original text
+	}
+	for _, tt := range tests {
+		if got := indexFold(tt.s, tt.substr); got != tt.want {
+			t.Errorf("indexFold(%q, %q) = %d, want %d", tt.s, tt.substr, got, tt.want)
+		}
+	}
+}
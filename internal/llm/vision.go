@@ -0,0 +1,62 @@
+// internal/llm/vision.go
+package llm
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	openai "github.com/openai/openai-go"
+	"google.golang.org/genai"
+)
+
+// ImageInput is an image sent to a vision-capable model.
+type ImageInput struct {
+	Data     []byte
+	MIMEType string // e.g. "image/png"
+}
+
+// dataURL encodes the image for OpenAI-compatible image_url parts.
+func (img ImageInput) dataURL() string {
+	return fmt.Sprintf("data:%s;base64,%s", img.MIMEType, base64.StdEncoding.EncodeToString(img.Data))
+}
+
+// DescribeImageWithOllama sends an image to a multimodal Ollama model such as llava.
+func DescribeImageWithOllama(prompt string, image ImageInput, modelTag string) (Completion, error) {
+	return generateWithOllama(OllamaGenerateRequest{
+		Model:  modelTag,
+		Prompt: prompt,
+		Stream: false,
+		Images: []string{base64.StdEncoding.EncodeToString(image.Data)},
+	})
+}
+
+// DescribeImageWithOpenAI sends an image to a vision-capable OpenAI model (e.g. gpt-4o).
+func DescribeImageWithOpenAI(apiKey, prompt string, image ImageInput, modelID string) (Completion, error) {
+	return completeOpenAIMessages(apiKey, modelID, openai.UserMessage([]openai.ChatCompletionContentPartUnionParam{
+		openai.TextContentPart(prompt),
+		openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{URL: image.dataURL()}),
+	}))
+}
+
+// DescribeImageWithGemini sends an image to a Gemini model; all current Gemini models accept images.
+func DescribeImageWithGemini(apiKey, prompt string, image ImageInput, modelID string) (Completion, error) {
+	return completeGeminiContents(apiKey, modelID, []*genai.Content{
+		genai.NewContentFromParts([]*genai.Part{
+			genai.NewPartFromText(prompt),
+			genai.NewPartFromBytes(image.Data, image.MIMEType),
+		}, genai.RoleUser),
+	})
+}
+
+// DescribeImageWithOpenRouter sends an image using OpenRouter's OpenAI-compatible format.
+func DescribeImageWithOpenRouter(prompt string, image ImageInput, model string) (Completion, error) {
+	return completeOpenRouterMessages(model, []map[string]interface{}{
+		{
+			"role": "user",
+			"content": []map[string]interface{}{
+				{"type": "text", "text": prompt},
+				{"type": "image_url", "image_url": map[string]string{"url": image.dataURL()}},
+			},
+		},
+	})
+}
@@ -0,0 +1,175 @@
+// Package provider holds concerns shared by every LLM and embedding provider:
+// a structured error taxonomy the frontend can act on, and the HTTP transport.
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ErrorCode is a machine-readable category for a provider failure.
+type ErrorCode string
+
+const (
+	ErrAuth            ErrorCode = "auth"             // Missing, invalid or unauthorized API key
+	ErrRateLimit       ErrorCode = "rate_limit"       // Rate limited, quota exhausted or out of credits
+	ErrModelNotFound   ErrorCode = "model_not_found"  // Unknown model ID or Ollama model not pulled
+	ErrContextTooLong  ErrorCode = "context_too_long" // Prompt exceeds the model's context window
+	ErrContentFiltered ErrorCode = "content_filtered" // Blocked by the provider's safety filters
+	ErrNetwork         ErrorCode = "network"          // Connection refused, DNS failure, provider unavailable
+	ErrTimeout         ErrorCode = "timeout"          // Request or server-side deadline exceeded
+	ErrEmptyResponse   ErrorCode = "empty_response"   // Call succeeded but the model produced no answer
+	ErrInvalidResponse ErrorCode = "invalid_response" // Response could not be parsed
+	ErrNotConfigured   ErrorCode = "not_configured"   // Provider or model not set up in Settings
+	ErrUnknown         ErrorCode = "unknown"
+)
+
+// Error is the error type returned by all providers. It is serialized as-is over the
+// Wails binding so the frontend can switch on Code instead of parsing messages.
+type Error struct {
+	Code       ErrorCode `json:"code"`
+	Provider   string    `json:"provider"`
+	Model      string    `json:"model,omitempty"`
+	StatusCode int       `json:"status,omitempty"`
+	Message    string    `json:"message"`
+	Err        error     `json:"-"`
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	var sb strings.Builder
+	sb.WriteString(e.Provider)
+	if e.Model != "" {
+		sb.WriteString(" (" + e.Model + ")")
+	}
+	sb.WriteString(fmt.Sprintf(" [%s]: %s", e.Code, e.Message))
+	return sb.String()
+}
+
+// Unwrap returns the underlying cause, if any.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// NewError creates a provider error with an explicit code.
+func NewError(providerName, model string, code ErrorCode, message string, cause error) *Error {
+	return &Error{
+		Code:     code,
+		Provider: providerName,
+		Model:    model,
+		Message:  message,
+		Err:      cause,
+	}
+}
+
+// CodeOf returns the ErrorCode carried by err, or ErrUnknown if err is not a provider error.
+func CodeOf(err error) ErrorCode {
+	var perr *Error
+	if errors.As(err, &perr) {
+		return perr.Code
+	}
+	return ErrUnknown
+}
+
+// FromHTTPStatus classifies a non-2xx provider response from its status code and body.
+func FromHTTPStatus(providerName, model string, status int, body string) *Error {
+	message := strings.TrimSpace(body)
+	if message == "" {
+		message = http.StatusText(status)
+	}
+
+	code := classifyMessage(body)
+	if code == ErrUnknown {
+		switch {
+		case status == http.StatusUnauthorized || status == http.StatusForbidden:
+			code = ErrAuth
+		case status == http.StatusTooManyRequests || status == http.StatusPaymentRequired:
+			code = ErrRateLimit
+		case status == http.StatusNotFound:
+			code = ErrModelNotFound
+		case status == http.StatusRequestEntityTooLarge:
+			code = ErrContextTooLong
+		case status == http.StatusRequestTimeout || status == http.StatusGatewayTimeout:
+			code = ErrTimeout
+		case status >= 500:
+			code = ErrNetwork
+		}
+	}
+
+	return &Error{
+		Code:       code,
+		Provider:   providerName,
+		Model:      model,
+		StatusCode: status,
+		Message:    message,
+	}
+}
+
+// FromTransport classifies an error returned by http.Client.Do or an SDK call before
+// any response was received (connection refused, DNS, deadline exceeded).
+func FromTransport(providerName, model string, err error) *Error {
+	var perr *Error
+	if errors.As(err, &perr) {
+		return perr
+	}
+
+	code := ErrNetwork
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		code = ErrTimeout
+	} else if c := classifyMessage(err.Error()); c != ErrUnknown {
+		code = c
+	}
+
+	return &Error{
+		Code:     code,
+		Provider: providerName,
+		Model:    model,
+		Message:  err.Error(),
+		Err:      err,
+	}
+}
+
+// FromFinishReason maps a provider finish/stop reason to an error when it means the
+// answer was withheld. It returns nil for normal completions.
+func FromFinishReason(providerName, model, reason string) *Error {
+	switch strings.ToLower(reason) {
+	case "content_filter", "safety", "blocklist", "prohibited_content", "spii", "recitation":
+		return NewError(providerName, model, ErrContentFiltered, fmt.Sprintf("response blocked by provider (finish reason: %s)", reason), nil)
+	}
+	return nil
+}
+
+// classifyMessage looks for well-known phrases in provider error bodies. Providers
+// disagree on status codes for these cases (e.g. context overflow is often a plain 400).
+func classifyMessage(msg string) ErrorCode {
+	lower := strings.ToLower(msg)
+	switch {
+	case containsAny(lower, "context length", "context_length", "maximum context", "context window", "too many tokens", "token limit", "prompt is too long", "input is too long"):
+		return ErrContextTooLong
+	case containsAny(lower, "content filter", "content_filter", "content management policy", "safety", "moderation", "flagged"):
+		return ErrContentFiltered
+	case containsAny(lower, "invalid api key", "incorrect api key", "invalid_api_key", "api key not valid", "unauthorized", "no auth credentials"):
+		return ErrAuth
+	case containsAny(lower, "rate limit", "rate_limit", "quota", "insufficient credits", "resource_exhausted", "resource exhausted"):
+		return ErrRateLimit
+	case containsAny(lower, "model not found", "no such model", "model_not_found", "is not a valid model", "not found, try pulling"):
+		return ErrModelNotFound
+	case containsAny(lower, "connection refused", "no such host", "connection reset"):
+		return ErrNetwork
+	}
+	return ErrUnknown
+}
+
+func containsAny(s string, needles ...string) bool {
+	for _, n := range needles {
+		if strings.Contains(s, n) {
+			return true
+		}
+	}
+	return false
+}
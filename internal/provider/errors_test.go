@@ -0,0 +1,129 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestFromHTTPStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   ErrorCode
+	}{
+		{"401", http.StatusUnauthorized, "", ErrAuth},
+		{"403", http.StatusForbidden, "forbidden", ErrAuth},
+		{"429", http.StatusTooManyRequests, "", ErrRateLimit},
+		{"402 out of credits", http.StatusPaymentRequired, "", ErrRateLimit},
+		{"404", http.StatusNotFound, "", ErrModelNotFound},
+		{"413", http.StatusRequestEntityTooLarge, "", ErrContextTooLong},
+		{"408", http.StatusRequestTimeout, "", ErrTimeout},
+		{"504", http.StatusGatewayTimeout, "", ErrTimeout},
+		{"500", http.StatusInternalServerError, "", ErrNetwork},
+		{"503", http.StatusServiceUnavailable, "overloaded", ErrNetwork},
+		{"400 plain", http.StatusBadRequest, "bad request", ErrUnknown},
+		// The body wins over the status code, since providers disagree on codes
+		{"400 context overflow", http.StatusBadRequest, `{"error":{"message":"This model's maximum context length is 8192 tokens"}}`, ErrContextTooLong},
+		{"400 invalid key", http.StatusBadRequest, `{"error":{"message":"API key not valid. Please pass a valid API key."}}`, ErrAuth},
+		{"400 content filter", http.StatusBadRequest, `{"error":{"code":"content_filter"}}`, ErrContentFiltered},
+		{"403 quota", http.StatusForbidden, "RESOURCE_EXHAUSTED: quota exceeded", ErrRateLimit},
+		{"404 ollama model", http.StatusNotFound, `{"error":"model \"llama3\" not found, try pulling it first"}`, ErrModelNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := FromHTTPStatus("test", "model", tt.status, tt.body)
+			if err.Code != tt.want {
+				t.Errorf("code = %q, want %q", err.Code, tt.want)
+			}
+			if err.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", err.StatusCode, tt.status)
+			}
+			if err.Message == "" {
+				t.Error("message is empty")
+			}
+		})
+	}
+}
+
+func TestFromFinishReason(t *testing.T) {
+	tests := []struct {
+		reason string
+		want   ErrorCode // "" for no error
+	}{
+		{"stop", ""},
+		{"length", ""},
+		{"", ""},
+		{"content_filter", ErrContentFiltered},
+		{"SAFETY", ErrContentFiltered},
+		{"blocklist", ErrContentFiltered},
+		{"prohibited_content", ErrContentFiltered},
+		{"spii", ErrContentFiltered},
+		{"recitation", ErrContentFiltered},
+	}
+	for _, tt := range tests {
+		err := FromFinishReason("test", "model", tt.reason)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("FromFinishReason(%q) = %v, want nil", tt.reason, err)
+		case tt.want != "" && (err == nil || err.Code != tt.want):
+			t.Errorf("FromFinishReason(%q) = %v, want code %q", tt.reason, err, tt.want)
+		}
+	}
+}
+
+func TestClassifyMessage(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want ErrorCode
+	}{
+		{"prompt is too long: 210000 tokens > 200000 maximum", ErrContextTooLong},
+		{"Input is too long for requested model.", ErrContextTooLong},
+		{"The response was filtered due to the prompt triggering Azure OpenAI's content management policy", ErrContentFiltered},
+		{"Incorrect API key provided: sk-abc", ErrAuth},
+		{"No auth credentials found", ErrAuth},
+		{"Rate limit reached for requests", ErrRateLimit},
+		{"Insufficient credits. Add more using https://openrouter.ai/credits", ErrRateLimit},
+		{"openai/gpt-9 is not a valid model ID", ErrModelNotFound},
+		{"dial tcp 127.0.0.1:11434: connect: connection refused", ErrNetwork},
+		{"dial tcp: lookup api.example.com: no such host", ErrNetwork},
+		{"something unexpected", ErrUnknown},
+		{"", ErrUnknown},
+	}
+	for _, tt := range tests {
+		if got := classifyMessage(tt.msg); got != tt.want {
+			t.Errorf("classifyMessage(%q) = %q, want %q", tt.msg, got, tt.want)
+		}
+	}
+}
+
+func TestFromTransport(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorCode
+	}{
+		{"deadline", fmt.Errorf("request failed: %w", context.DeadlineExceeded), ErrTimeout},
+		{"refused", errors.New("dial tcp 127.0.0.1:11434: connect: connection refused"), ErrNetwork},
+		{"other", errors.New("EOF"), ErrNetwork},
+		{"already classified", NewError("test", "model", ErrAuth, "bad key", nil), ErrAuth},
+	}
+	for _, tt := range tests {
+		if got := FromTransport("test", "model", tt.err).Code; got != tt.want {
+			t.Errorf("%s: code = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCodeOf(t *testing.T) {
+	wrapped := fmt.Errorf("chat failed: %w", NewError("test", "model", ErrRateLimit, "slow down", nil))
+	if got := CodeOf(wrapped); got != ErrRateLimit {
+		t.Errorf("CodeOf(wrapped) = %q, want %q", got, ErrRateLimit)
+	}
+	if got := CodeOf(errors.New("plain")); got != ErrUnknown {
+		t.Errorf("CodeOf(plain) = %q, want %q", got, ErrUnknown)
+	}
+}
@@ -0,0 +1,177 @@
+// internal/provider/http.go
+package provider
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client names select the timeout used for a request. Every client shares one
+// transport, so proxy, CA and connection-pool settings apply to all of them.
+const (
+	ClientOpenRouter = "openrouter"
+	ClientOpenAI     = "openai"
+	ClientGemini     = "gemini"
+	ClientOllama     = "ollama"     // Local generation; large models can take minutes
+	ClientEmbeddings = "embeddings" // Embedding calls for every provider
+	ClientModelList  = "model-list" // Listing available models in Settings
+	ClientRerank     = "rerank"     // Cross-encoder reranking during retrieval
+)
+
+// defaultTimeouts are per-client request deadlines. Ollama generation keeps the 300s it
+// had before the transport was shared. OpenRouter used http.DefaultClient, which has no
+// timeout, and now gets the same 300s so a stalled request fails instead of hanging.
+var defaultTimeouts = map[string]time.Duration{
+	ClientOpenRouter: 300 * time.Second,
+	ClientOpenAI:     120 * time.Second,
+	ClientGemini:     120 * time.Second,
+	ClientOllama:     300 * time.Second,
+	ClientEmbeddings: 60 * time.Second,
+	ClientModelList:  15 * time.Second,
+	ClientRerank:     30 * time.Second,
+}
+
+// HTTPSettings configures the shared transport.
+type HTTPSettings struct {
+	ProxyURL            string                   // Empty means honour HTTP(S)_PROXY from the environment
+	CACertPath          string                   // PEM bundle appended to the system roots
+	Timeouts            map[string]time.Duration // Overrides keyed by client name
+	MaxIdleConnsPerHost int
+}
+
+var (
+	httpMutex     sync.RWMutex
+	httpTransport = newTransport()
+	httpTimeouts  = copyTimeouts(nil)
+)
+
+// ConfigureHTTP rebuilds the shared transport from settings. Clients handed out
+// earlier switch to the new transport on their next request; the old transport's idle
+// connections are closed. A configured proxy is never used for loopback hosts, so local
+// Ollama keeps working behind a corporate proxy.
+func ConfigureHTTP(settings HTTPSettings) error {
+	transport := newTransport()
+
+	if settings.ProxyURL != "" {
+		proxyURL, err := url.Parse(settings.ProxyURL)
+		if err != nil || proxyURL.Host == "" {
+			return fmt.Errorf("invalid proxy URL '%s': %v", settings.ProxyURL, err)
+		}
+		transport.Proxy = proxyExceptLoopback(http.ProxyURL(proxyURL))
+	}
+
+	if settings.CACertPath != "" {
+		pemData, err := os.ReadFile(settings.CACertPath)
+		if err != nil {
+			return fmt.Errorf("failed to read CA bundle '%s': %w", settings.CACertPath, err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			log.Printf("Warning: could not load system certificate pool (%v); using only the configured CA bundle", err)
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pemData) {
+			return fmt.Errorf("no PEM certificates found in CA bundle '%s'", settings.CACertPath)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+
+	if settings.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = settings.MaxIdleConnsPerHost
+	}
+
+	httpMutex.Lock()
+	old := httpTransport
+	httpTransport = transport
+	httpTimeouts = copyTimeouts(settings.Timeouts)
+	httpMutex.Unlock()
+
+	old.CloseIdleConnections()
+	log.Printf("HTTP transport configured (proxy set: %v, custom CA: %v)", settings.ProxyURL != "", settings.CACertPath != "")
+	return nil
+}
+
+// HTTPClient returns a client using the shared transport and the timeout for name.
+// Unknown names get the embeddings timeout. Proxy and CA changes reach the client
+// through the transport; a changed timeout only applies to clients created afterwards.
+func HTTPClient(name string) *http.Client {
+	httpMutex.RLock()
+	defer httpMutex.RUnlock()
+
+	timeout, ok := httpTimeouts[name]
+	if !ok {
+		timeout = httpTimeouts[ClientEmbeddings]
+	}
+	return &http.Client{Transport: sharedTransport{}, Timeout: timeout}
+}
+
+// sharedTransport sends each request through the transport current at that moment, so
+// providers built before ConfigureHTTP don't keep stale proxy or CA settings.
+type sharedTransport struct{}
+
+// RoundTrip implements http.RoundTripper.
+func (sharedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return currentTransport().RoundTrip(req)
+}
+
+// CloseIdleConnections lets http.Client.CloseIdleConnections reach the current transport.
+func (sharedTransport) CloseIdleConnections() {
+	currentTransport().CloseIdleConnections()
+}
+
+func currentTransport() *http.Transport {
+	httpMutex.RLock()
+	defer httpMutex.RUnlock()
+	return httpTransport
+}
+
+// proxyExceptLoopback wraps a proxy func so requests to localhost and loopback
+// addresses go direct, like http.ProxyFromEnvironment does.
+func proxyExceptLoopback(proxy func(*http.Request) (*url.URL, error)) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		if isLoopbackHost(req.URL.Hostname()) {
+			return nil, nil
+		}
+		return proxy(req)
+	}
+}
+
+func isLoopbackHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func newTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	transport.MaxIdleConns = 100
+	transport.MaxIdleConnsPerHost = 10
+	transport.IdleConnTimeout = 90 * time.Second
+	return transport
+}
+
+func copyTimeouts(overrides map[string]time.Duration) map[string]time.Duration {
+	timeouts := make(map[string]time.Duration, len(defaultTimeouts))
+	for name, d := range defaultTimeouts {
+		timeouts[name] = d
+	}
+	for name, d := range overrides {
+		if d > 0 {
+			timeouts[name] = d
+		}
+	}
+	return timeouts
+}
@@ -0,0 +1,58 @@
+package provider
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestProxySkipsLoopback(t *testing.T) {
+	proxyURL, _ := url.Parse("http://proxy.corp:3128")
+	proxy := proxyExceptLoopback(http.ProxyURL(proxyURL))
+	tests := []struct {
+		url       string
+		wantProxy bool
+	}{
+		{"http://localhost:11434/api/tags", false},
+		{"http://LOCALHOST:11434/api/tags", false},
+		{"http://127.0.0.1:11434/api/embed", false},
+		{"http://127.0.0.2:8080/", false},
+		{"http://[::1]:11434/api/chat", false},
+		{"http://ollama.localhost/api/chat", false},
+		{"https://openrouter.ai/api/v1/chat/completions", true},
+		{"http://192.168.1.20:11434/api/chat", true},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+		got, err := proxy(req)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.url, err)
+		}
+		if (got != nil) != tt.wantProxy {
+			t.Errorf("%s: proxy = %v, want proxied %v", tt.url, got, tt.wantProxy)
+		}
+	}
+}
+
+func TestClientFollowsReconfiguredTransport(t *testing.T) {
+	// A proxy that answers every request itself, so being proxied is visible
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Proxied", "yes")
+	}))
+	defer proxy.Close()
+	defer ConfigureHTTP(HTTPSettings{})
+
+	client := HTTPClient(ClientEmbeddings) // Built before the proxy is configured
+	if err := ConfigureHTTP(HTTPSettings{ProxyURL: proxy.URL}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Get("http://remote.example/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get("X-Proxied") != "yes" {
+		t.Error("client created before ConfigureHTTP did not use the new proxy")
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"Llore/internal/database"
+	"Llore/internal/embeddings"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// indexableLibraryExtensions are the manuscript formats embedded for retrieval.
+var indexableLibraryExtensions = map[string]bool{
+	".md":       true,
+	".markdown": true,
+	".txt":      true,
+}
+
+// IndexSyncResult summarizes a full pass over the Library or Chat folder.
+type IndexSyncResult struct {
+	Files     int `json:"files"`     // Manuscript files or chat logs found
+	Reindexed int `json:"reindexed"` // Files that had new or changed text embedded
+	Removed   int `json:"removed"`   // Index entries dropped for files that no longer exist
+	Failed    int `json:"failed"`
+}
+
+func isIndexableLibraryFile(path string) bool {
+	return indexableLibraryExtensions[strings.ToLower(filepath.Ext(path))]
+}
+
+// libraryIndexKey converts a Library-relative path to the form stored in the index.
+func libraryIndexKey(path string) string {
+	return filepath.ToSlash(filepath.Clean(path))
+}
+
+// queueLibraryIndex schedules a Library file or folder for re-indexing. Autosave can
+// queue the same chapter many times; the job table keeps one job per path.
+func (a *App) queueLibraryIndex(path string) {
+	a.enqueueEmbeddingJob(jobKindLibrary, libraryIndexKey(path))
+}
+
+// indexLibraryPath indexes one Library file, or every manuscript under a folder. Returns
+// how many files were re-embedded.
+func (a *App) indexLibraryPath(path string) (int, error) {
+	service := a.embeddingService
+	if service == nil || a.db == nil {
+		return 0, fmt.Errorf("embedding service not initialized")
+	}
+	libraryBase := filepath.Join(a.dbPath, "Library")
+	fullPath := filepath.Join(libraryBase, path)
+
+	reindexed := 0
+	err := filepath.WalkDir(fullPath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isIndexableLibraryFile(p) {
+			return nil
+		}
+		rel, err := filepath.Rel(libraryBase, p)
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", rel, err)
+		}
+		changed, err := service.IndexLibraryFile(libraryIndexKey(rel), string(content))
+		if err != nil {
+			return err
+		}
+		if changed {
+			reindexed++
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return reindexed, nil // Deleted or moved since it was queued
+	}
+	return reindexed, err
+}
+
+// ReindexLibrary brings the manuscript index up to date with the Library folder:
+// changed files are re-embedded and files that are gone are dropped from the index.
+func (a *App) ReindexLibrary() (IndexSyncResult, error) {
+	return a.reindexLibrary(a.embeddingService)
+}
+
+// reindexLibrary is ReindexLibrary for a given embedding service.
+func (a *App) reindexLibrary(service *embeddings.EmbeddingService) (IndexSyncResult, error) {
+	var result IndexSyncResult
+	if a.db == nil {
+		return result, fmt.Errorf("no vault is currently loaded")
+	}
+	if service == nil {
+		return result, fmt.Errorf("embedding service not initialized")
+	}
+
+	libraryBase := filepath.Join(a.dbPath, "Library")
+	present := make(map[string]bool)
+	err := filepath.WalkDir(libraryBase, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isIndexableLibraryFile(p) {
+			return nil
+		}
+		rel, err := filepath.Rel(libraryBase, p)
+		if err != nil {
+			return err
+		}
+		key := libraryIndexKey(rel)
+		present[key] = true
+		result.Files++
+
+		content, err := os.ReadFile(p)
+		if err != nil {
+			log.Printf("Warning: Failed to read library file %s: %v", rel, err)
+			result.Failed++
+			return nil
+		}
+		changed, err := service.IndexLibraryFile(key, string(content))
+		if err != nil {
+			log.Printf("Warning: Failed to index library file %s: %v", rel, err)
+			result.Failed++
+			return nil
+		}
+		if changed {
+			result.Reindexed++
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return result, fmt.Errorf("failed to walk Library folder: %w", err)
+	}
+
+	indexed, err := service.IndexedLibraryPaths()
+	if err != nil {
+		return result, err
+	}
+	for _, path := range indexed {
+		if present[path] {
+			continue
+		}
+		if err := database.DBRemoveLibraryPath(a.db, path); err != nil {
+			log.Printf("Warning: %v", err)
+			continue
+		}
+		result.Removed++
+	}
+
+	log.Printf("Library index up to date: %d files, %d re-indexed, %d removed, %d failed", result.Files, result.Reindexed, result.Removed, result.Failed)
+	return result, nil
+}
+
+// removeLibraryIndex drops a deleted Library file or folder from the index.
+func (a *App) removeLibraryIndex(path string) {
+	if a.db == nil {
+		return
+	}
+	if err := database.DBRemoveLibraryPath(a.db, libraryIndexKey(path)); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// moveLibraryIndex keeps the passages of a moved Library item, re-indexing only if the
+// move changed whether it is a manuscript at all (e.g. a renamed extension).
+func (a *App) moveLibraryIndex(sourcePath, destPath string) {
+	if a.db == nil {
+		return
+	}
+	if err := database.DBMoveLibraryPath(a.db, libraryIndexKey(sourcePath), libraryIndexKey(destPath)); err != nil {
+		log.Printf("Warning: %v", err)
+		a.queueLibraryIndex(destPath)
+		return
+	}
+	info, err := os.Stat(filepath.Join(a.dbPath, "Library", destPath))
+	if err == nil && !info.IsDir() && isIndexableLibraryFile(sourcePath) != isIndexableLibraryFile(destPath) {
+		a.removeLibraryIndex(destPath)
+		a.queueLibraryIndex(destPath)
+	}
+}
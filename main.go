@@ -32,6 +32,7 @@ func main() {
 		// OnDomReady:       app.domReady, // Uncomment if you have a domReady method
 		OnShutdown: app.shutdown,
 		// OnBeforeClose:    app.beforeClose, // Uncomment if you have a beforeClose method
+		ErrorFormatter: formatBindingError, // Provider errors reach the frontend as {code, message, ...}
 		Bind: []interface{}{
 			app,
 		},
@@ -0,0 +1,53 @@
+package main
+
+import (
+	ragcontext "Llore/internal/context"
+	"Llore/internal/llm"
+	"fmt"
+	"log"
+)
+
+// configureQueryPlanning applies the query rewriting settings to the context builder and
+// gives it an LLM to rewrite with. Steps are reported to the trace view.
+func (a *App) configureQueryPlanning(cfg llm.OpenRouterConfig) {
+	if a.contextBuilder == nil {
+		return
+	}
+	a.contextBuilder.SetQueryRewriting(cfg.QueryRewriteEnabled, cfg.QueryHyDEEnabled, cfg.QuerySubQueries)
+	a.contextBuilder.SetTracer(a.emitTrace)
+	if !cfg.QueryRewriteEnabled && !cfg.QueryHyDEEnabled && cfg.QuerySubQueries <= 0 {
+		a.contextBuilder.SetQueryGenerator(nil)
+		return
+	}
+	a.contextBuilder.SetQueryGenerator(a.retrievalGenerator(func(cfg llm.OpenRouterConfig) string { return cfg.QueryRewriteModelID }))
+	log.Printf("Query planning: rewrite=%v, sub-queries=%d, HyDE=%v", cfg.QueryRewriteEnabled, cfg.QuerySubQueries, cfg.QueryHyDEEnabled)
+}
+
+// retrievalGenerator completes prompts for a retrieval step with the model that model
+// picks from the current settings, or the chat model if it picks none.
+func (a *App) retrievalGenerator(model func(cfg llm.OpenRouterConfig) string) ragcontext.Generator {
+	return func(prompt string) (string, error) {
+		current := llm.GetConfig()
+		modelID := model(current)
+		if modelID == "" {
+			modelID = current.ChatModelID
+		}
+		if modelID == "" {
+			return "", fmt.Errorf("no model set for this retrieval step or for chat")
+		}
+		return a.GenerateLLMContent(prompt, modelID)
+	}
+}
+
+// chatTurns converts chat messages for the context builder.
+func chatTurns(messages []ChatMessage) []ragcontext.Turn {
+	turns := make([]ragcontext.Turn, 0, len(messages))
+	for _, msg := range messages {
+		role := "assistant"
+		if msg.Sender == "user" {
+			role = "user"
+		}
+		turns = append(turns, ragcontext.Turn{Role: role, Text: msg.Text})
+	}
+	return turns
+}
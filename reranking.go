@@ -0,0 +1,160 @@
+package main
+
+import (
+	ragcontext "Llore/internal/context"
+	"Llore/internal/database"
+	"Llore/internal/embeddings"
+	"Llore/internal/llm"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// Rerank modes in the settings.
+const (
+	rerankModeLLM          = "llm"
+	rerankModeCrossEncoder = "cross-encoder"
+)
+
+// thresholdSettingPrefix prefixes the vault_settings key holding the similarity threshold
+// calibrated for a vector version.
+const thresholdSettingPrefix = "similarity_threshold:"
+
+// calibrationPairs is how many random entry pairs a threshold calibration compares.
+const calibrationPairs = 2000
+
+// Where a similarity threshold came from.
+const (
+	thresholdSourceCalibrated = "calibrated"
+	thresholdSourceSettings   = "settings"
+	thresholdSourceDefault    = "default"
+)
+
+// SimilarityThreshold is the minimum vector similarity in use and where it came from.
+type SimilarityThreshold struct {
+	VectorVersion string  `json:"vectorVersion"`
+	Threshold     float32 `json:"threshold"`
+	Source        string  `json:"source"` // "calibrated", "settings" or "default"
+}
+
+// configureRetrievalTuning sets up the reranker, diversity and similarity threshold of
+// the context builder from the settings and the vault's calibration.
+func (a *App) configureRetrievalTuning(cfg llm.OpenRouterConfig) {
+	if a.contextBuilder == nil {
+		return
+	}
+	reranker, err := newReranker(a, cfg)
+	if err != nil {
+		log.Printf("Warning: Reranking disabled: %v", err)
+	}
+	a.contextBuilder.SetReranker(reranker, cfg.RerankCandidates)
+	if reranker != nil {
+		log.Printf("Reranking enabled: %s", reranker.Name())
+	}
+
+	if cfg.RetrievalDiversity > 0 {
+		a.contextBuilder.SetDiversity(cfg.RetrievalDiversity)
+	}
+
+	threshold := a.resolveSimilarityThreshold(cfg)
+	a.contextBuilder.SetSimilarityThreshold(threshold.Threshold)
+	log.Printf("Similarity threshold for %s: %.3f (%s)", threshold.VectorVersion, threshold.Threshold, threshold.Source)
+}
+
+// newReranker creates the reranker chosen in the settings, or nil if reranking is off.
+func newReranker(a *App, cfg llm.OpenRouterConfig) (ragcontext.Reranker, error) {
+	switch cfg.RerankMode {
+	case "":
+		return nil, nil
+	case rerankModeLLM:
+		model := cfg.RerankModelID
+		if model == "" {
+			model = cfg.ChatModelID
+		}
+		generate := a.retrievalGenerator(func(cfg llm.OpenRouterConfig) string { return cfg.RerankModelID })
+		return ragcontext.NewLLMReranker(generate, model), nil
+	case rerankModeCrossEncoder:
+		return ragcontext.NewCrossEncoderReranker(cfg.RerankEndpoint, cfg.RerankModelID, cfg.RerankAPIKey)
+	default:
+		return nil, fmt.Errorf("unknown rerank mode '%s'", cfg.RerankMode)
+	}
+}
+
+// resolveSimilarityThreshold picks the threshold for the current vectors: the one
+// calibrated on this vault, else a settings override, else the built-in default.
+func (a *App) resolveSimilarityThreshold(cfg llm.OpenRouterConfig) SimilarityThreshold {
+	if a.embeddingService == nil {
+		return SimilarityThreshold{Threshold: embeddings.DefaultSimilarityThreshold(""), Source: thresholdSourceDefault}
+	}
+	version := a.embeddingService.ModelIdentifier() // Includes any truncation suffix
+	if a.db != nil {
+		value, ok, err := database.DBGetVaultSetting(a.db, thresholdSettingPrefix+version)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+		} else if ok {
+			if t, err := strconv.ParseFloat(value, 32); err == nil && t > 0 {
+				return SimilarityThreshold{VectorVersion: version, Threshold: float32(t), Source: thresholdSourceCalibrated}
+			}
+			log.Printf("Warning: Ignoring unreadable calibrated threshold %q", value)
+		}
+	}
+
+	matched := ""
+	for prefix := range cfg.SimilarityThresholds {
+		if strings.HasPrefix(version, prefix) && len(prefix) > len(matched) {
+			matched = prefix
+		}
+	}
+	if t := cfg.SimilarityThresholds[matched]; matched != "" && t > 0 {
+		return SimilarityThreshold{VectorVersion: version, Threshold: float32(t), Source: thresholdSourceSettings}
+	}
+	return SimilarityThreshold{VectorVersion: version, Threshold: embeddings.DefaultSimilarityThreshold(version), Source: thresholdSourceDefault}
+}
+
+// GetSimilarityThreshold returns the minimum vector similarity retrieval currently uses.
+func (a *App) GetSimilarityThreshold() (SimilarityThreshold, error) {
+	if a.embeddingService == nil {
+		return SimilarityThreshold{}, fmt.Errorf("embedding service not initialized")
+	}
+	return a.resolveSimilarityThreshold(llm.GetConfig()), nil
+}
+
+// CalibrateSimilarityThreshold measures the threshold on the vault's own entries, saves it
+// for the current vector version and starts using it.
+func (a *App) CalibrateSimilarityThreshold() (embeddings.ThresholdCalibration, error) {
+	if a.db == nil || a.embeddingService == nil {
+		return embeddings.ThresholdCalibration{}, fmt.Errorf("no vault loaded")
+	}
+	calibration, err := a.embeddingService.CalibrateThreshold(calibrationPairs)
+	if err != nil {
+		return calibration, err
+	}
+	value := strconv.FormatFloat(float64(calibration.Threshold), 'f', 4, 32)
+	if err := database.DBSetVaultSetting(a.db, thresholdSettingPrefix+calibration.VectorVersion, value); err != nil {
+		return calibration, err
+	}
+	if a.contextBuilder != nil {
+		a.contextBuilder.SetSimilarityThreshold(calibration.Threshold)
+	}
+	log.Printf("Calibrated similarity threshold for %s: %.3f over %d pairs (default %.3f)",
+		calibration.VectorVersion, calibration.Threshold, calibration.Pairs, calibration.Default)
+	return calibration, nil
+}
+
+// ClearSimilarityCalibration forgets the calibrated threshold of the current vector
+// version, going back to the settings override or built-in default.
+func (a *App) ClearSimilarityCalibration() (SimilarityThreshold, error) {
+	if a.db == nil || a.embeddingService == nil {
+		return SimilarityThreshold{}, fmt.Errorf("no vault loaded")
+	}
+	version := a.embeddingService.ModelIdentifier() // Includes any truncation suffix
+	if err := database.DBDeleteVaultSetting(a.db, thresholdSettingPrefix+version); err != nil {
+		return SimilarityThreshold{}, err
+	}
+	threshold := a.resolveSimilarityThreshold(llm.GetConfig())
+	if a.contextBuilder != nil {
+		a.contextBuilder.SetSimilarityThreshold(threshold.Threshold)
+	}
+	return threshold, nil
+}
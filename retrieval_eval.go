@@ -0,0 +1,277 @@
+package main
+
+import (
+	ragcontext "Llore/internal/context"
+	"Llore/internal/database"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+const (
+	// defaultEvalK is the cutoff of recall@k and precision@k when none is given.
+	defaultEvalK = 10
+	// evalHistoryLimit is how many runs ListRetrievalEvalRuns returns.
+	evalHistoryLimit = 50
+)
+
+// EvalQueryResult is how retrieval did on one golden query.
+type EvalQueryResult struct {
+	QueryID        int64    `json:"queryId"`
+	Query          string   `json:"query"`
+	Expected       []string `json:"expected"`
+	Retrieved      []string `json:"retrieved"` // Codex entries in the top k, best first
+	Missing        []string `json:"missing"`   // Expected entries not in the top k
+	Recall         float64  `json:"recall"`
+	Precision      float64  `json:"precision"`
+	ReciprocalRank float64  `json:"reciprocalRank"` // 1/rank of the first expected entry; 0 if none
+	Error          string   `json:"error,omitempty"`
+}
+
+// EvalRun is one evaluation of the retrieval settings against all golden queries.
+// Metrics are means over the queries.
+type EvalRun struct {
+	ID          int64               `json:"id"`
+	CreatedAt   string              `json:"createdAt"`
+	K           int                 `json:"k"`
+	RequestedK  int                 `json:"requestedK,omitempty"` // k asked for, when it was above MaxEntries and lowered to it
+	Recall      float64             `json:"recall"`
+	Precision   float64             `json:"precision"`
+	MRR         float64             `json:"mrr"`
+	Queries     int                 `json:"queries"`
+	Settings    ragcontext.Settings `json:"settings"`
+	Results     []EvalQueryResult   `json:"results"`
+	Regressions []string            `json:"regressions"` // Queries whose recall dropped since the previous run
+}
+
+// ListGoldenQueries returns the vault's golden queries.
+func (a *App) ListGoldenQueries() ([]database.GoldenQuery, error) {
+	if a.db == nil {
+		return nil, fmt.Errorf("database is not initialized")
+	}
+	return database.DBListGoldenQueries(a.db)
+}
+
+// SaveGoldenQuery adds a golden query, or updates it if it has an ID. Expected holds the
+// names (or aliases) of the entries a good retrieval returns for the query.
+func (a *App) SaveGoldenQuery(query database.GoldenQuery) (database.GoldenQuery, error) {
+	if a.db == nil {
+		return database.GoldenQuery{}, fmt.Errorf("database is not initialized")
+	}
+	query.Query = strings.TrimSpace(query.Query)
+	if query.Query == "" {
+		return database.GoldenQuery{}, fmt.Errorf("golden query cannot be empty")
+	}
+	query.Expected = uniqueAliases(query.Expected, "")
+	if len(query.Expected) == 0 {
+		return database.GoldenQuery{}, fmt.Errorf("golden query needs at least one expected entry")
+	}
+	id, err := database.DBSaveGoldenQuery(a.db, query)
+	if err != nil {
+		return database.GoldenQuery{}, err
+	}
+	query.ID = id
+	return query, nil
+}
+
+// DeleteGoldenQuery removes a golden query.
+func (a *App) DeleteGoldenQuery(id int64) error {
+	if a.db == nil {
+		return fmt.Errorf("database is not initialized")
+	}
+	return database.DBDeleteGoldenQuery(a.db, id)
+}
+
+// RunRetrievalEval retrieves context for every golden query with the current settings
+// and scores it: recall@k, precision@k and mean reciprocal rank over the codex entries
+// retrieved. The run is stored in the history. k <= 0 uses 10, and k above the
+// MaxEntries setting is lowered to it, since retrieval returns no more entries than that.
+func (a *App) RunRetrievalEval(k int) (EvalRun, error) {
+	if a.db == nil || a.contextBuilder == nil {
+		return EvalRun{}, fmt.Errorf("no vault loaded")
+	}
+	if k <= 0 {
+		k = defaultEvalK
+	}
+	requestedK := 0
+	if maxEntries := a.contextBuilder.Settings().MaxEntries; maxEntries > 0 && k > maxEntries {
+		log.Printf("Warning: Evaluating at k=%d instead of %d, the most entries retrieval returns", maxEntries, k)
+		requestedK, k = k, maxEntries
+	}
+	queries, err := database.DBListGoldenQueries(a.db)
+	if err != nil {
+		return EvalRun{}, err
+	}
+	if len(queries) == 0 {
+		return EvalRun{}, fmt.Errorf("add golden queries before running an evaluation")
+	}
+	names, err := database.DBListEntryNames(a.db)
+	if err != nil {
+		return EvalRun{}, err
+	}
+	idsByName := make(map[string][]int64)
+	for _, n := range names {
+		key := strings.ToLower(strings.TrimSpace(n.Name))
+		idsByName[key] = append(idsByName[key], n.EntryID)
+	}
+
+	run := EvalRun{K: k, RequestedK: requestedK, Queries: len(queries), Settings: a.contextBuilder.Settings(), Results: []EvalQueryResult{}, Regressions: []string{}}
+	for _, q := range queries {
+		result := evaluateQuery(a.contextBuilder, q, idsByName, k)
+		run.Recall += result.Recall
+		run.Precision += result.Precision
+		run.MRR += result.ReciprocalRank
+		run.Results = append(run.Results, result)
+	}
+	run.Recall /= float64(len(queries))
+	run.Precision /= float64(len(queries))
+	run.MRR /= float64(len(queries))
+
+	if previous, err := database.DBListEvalRuns(a.db, 1); err != nil {
+		log.Printf("Warning: Not comparing with the previous evaluation: %v", err)
+	} else if len(previous) == 1 {
+		run.Regressions = regressions(previous[0], run.Results)
+	}
+
+	settings, err := json.Marshal(run.Settings)
+	if err != nil {
+		return run, fmt.Errorf("failed to encode retrieval settings: %w", err)
+	}
+	results, err := json.Marshal(run.Results)
+	if err != nil {
+		return run, fmt.Errorf("failed to encode evaluation results: %w", err)
+	}
+	run.ID, err = database.DBInsertEvalRun(a.db, database.EvalRunRecord{
+		K: k, Recall: run.Recall, Precision: run.Precision, MRR: run.MRR, Queries: run.Queries,
+		Config: string(settings), Results: string(results),
+	})
+	if err != nil {
+		return run, err
+	}
+	log.Printf("Retrieval evaluation over %d queries: recall@%d %.3f, precision@%d %.3f, MRR %.3f",
+		run.Queries, k, run.Recall, k, run.Precision, run.MRR)
+	return run, nil
+}
+
+// evaluateQuery retrieves context for one golden query and scores the codex entries in
+// it.
+func evaluateQuery(builder *ragcontext.ContextBuilder, q database.GoldenQuery, idsByName map[string][]int64, k int) EvalQueryResult {
+	items, err := builder.RetrieveFor(ragcontext.Request{Query: q.Query})
+	if err != nil {
+		return EvalQueryResult{QueryID: q.ID, Query: q.Query, Expected: q.Expected, Retrieved: []string{}, Missing: q.Expected, Error: err.Error()}
+	}
+	return scoreQuery(q, items, idsByName, k)
+}
+
+// scoreQuery scores the codex entries among the top k retrieved items. Expected names
+// that resolve to the same entries, like an entry's name and one of its aliases, are one
+// target, so recall can't exceed 1. An expected name matching no entry counts as missed.
+func scoreQuery(q database.GoldenQuery, items []ragcontext.RetrievedItem, idsByName map[string][]int64, k int) EvalQueryResult {
+	result := EvalQueryResult{QueryID: q.ID, Query: q.Query, Expected: q.Expected, Retrieved: []string{}, Missing: []string{}}
+	rankOf := make(map[int64]int) // 1-based rank among codex entries
+	for _, item := range items {
+		if item.Source != ragcontext.SourceCodex || len(result.Retrieved) == k {
+			continue
+		}
+		result.Retrieved = append(result.Retrieved, item.Entry.Name)
+		rankOf[item.Entry.ID] = len(result.Retrieved)
+	}
+
+	targets := make(map[string]bool)
+	foundIDs := make(map[int64]bool)
+	found, firstRank := 0, 0
+	for _, name := range q.Expected {
+		key := strings.ToLower(strings.TrimSpace(name))
+		ids := append([]int64(nil), idsByName[key]...)
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		target := "name:" + key
+		if len(ids) > 0 {
+			target = fmt.Sprint(ids)
+		}
+		if targets[target] {
+			continue
+		}
+		targets[target] = true
+
+		best, bestID := 0, int64(0)
+		for _, id := range ids {
+			if r, ok := rankOf[id]; ok && (best == 0 || r < best) {
+				best, bestID = r, id
+			}
+		}
+		if best == 0 {
+			result.Missing = append(result.Missing, name)
+			continue
+		}
+		found++
+		foundIDs[bestID] = true
+		if firstRank == 0 || best < firstRank {
+			firstRank = best
+		}
+	}
+	result.Recall = float64(found) / float64(len(targets))
+	if len(result.Retrieved) > 0 {
+		result.Precision = float64(len(foundIDs)) / float64(len(result.Retrieved))
+	}
+	if firstRank > 0 {
+		result.ReciprocalRank = 1 / float64(firstRank)
+	}
+	return result
+}
+
+// regressions lists the queries whose recall is lower than in a previous run.
+func regressions(previous database.EvalRunRecord, results []EvalQueryResult) []string {
+	var before []EvalQueryResult
+	if err := json.Unmarshal([]byte(previous.Results), &before); err != nil {
+		log.Printf("Warning: Not comparing with evaluation %d: %v", previous.ID, err)
+		return []string{}
+	}
+	recallBefore := make(map[int64]float64, len(before))
+	for _, r := range before {
+		recallBefore[r.QueryID] = r.Recall
+	}
+	worse := []string{}
+	for _, r := range results {
+		if old, ok := recallBefore[r.QueryID]; ok && r.Recall < old {
+			worse = append(worse, r.Query)
+		}
+	}
+	return worse
+}
+
+// ListRetrievalEvalRuns returns the most recent evaluation runs, newest first, so changes
+// in the metrics can be followed across settings changes.
+func (a *App) ListRetrievalEvalRuns() ([]EvalRun, error) {
+	if a.db == nil {
+		return nil, fmt.Errorf("database is not initialized")
+	}
+	records, err := database.DBListEvalRuns(a.db, evalHistoryLimit)
+	if err != nil {
+		return nil, err
+	}
+	runs := make([]EvalRun, 0, len(records))
+	for i, rec := range records {
+		run := EvalRun{ID: rec.ID, CreatedAt: rec.CreatedAt, K: rec.K, Recall: rec.Recall, Precision: rec.Precision, MRR: rec.MRR, Queries: rec.Queries, Regressions: []string{}}
+		if err := json.Unmarshal([]byte(rec.Config), &run.Settings); err != nil {
+			log.Printf("Warning: Unreadable settings in evaluation %d: %v", rec.ID, err)
+		}
+		if err := json.Unmarshal([]byte(rec.Results), &run.Results); err != nil {
+			log.Printf("Warning: Unreadable results in evaluation %d: %v", rec.ID, err)
+		}
+		if i+1 < len(records) {
+			run.Regressions = regressions(records[i+1], run.Results)
+		}
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
+
+// DeleteRetrievalEvalRun removes a run from the history.
+func (a *App) DeleteRetrievalEvalRun(id int64) error {
+	if a.db == nil {
+		return fmt.Errorf("database is not initialized")
+	}
+	return database.DBDeleteEvalRun(a.db, id)
+}
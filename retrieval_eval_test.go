@@ -0,0 +1,61 @@
+package main
+
+import (
+	ragcontext "Llore/internal/context"
+	"Llore/internal/database"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestScoreQuery(t *testing.T) {
+	codex := func(id int64, name string) ragcontext.RetrievedItem {
+		return ragcontext.RetrievedItem{Source: ragcontext.SourceCodex, Entry: database.CodexEntry{ID: id, Name: name}}
+	}
+	items := []ragcontext.RetrievedItem{
+		{Source: ragcontext.SourceManuscript},
+		codex(1, "Reginald"),
+		codex(2, "Castle Black"),
+		codex(3, "Old Tower"),
+	}
+	idsByName := map[string][]int64{
+		"reginald":     {1},
+		"sir reggie":   {1}, // Alias of Reginald
+		"castle black": {2},
+		"old tower":    {3},
+		"the north":    {4},
+	}
+	tests := []struct {
+		name          string
+		expected      []string
+		k             int
+		wantRecall    float64
+		wantPrecision float64
+		wantRR        float64
+		wantMissing   []string
+	}{
+		{"all found", []string{"Reginald", "Old Tower"}, 10, 1, 2.0 / 3, 1, []string{}},
+		{"name and alias are one entry", []string{"Reginald", "Sir Reggie"}, 10, 1, 1.0 / 3, 1, []string{}},
+		{"beyond k", []string{"Old Tower"}, 2, 0, 0, 0, []string{"Old Tower"}},
+		{"unknown and unretrieved names", []string{"Castle Black", "Nowhere", "The North"}, 10, 1.0 / 3, 1.0 / 3, 0.5, []string{"Nowhere", "The North"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := scoreQuery(database.GoldenQuery{Query: "q", Expected: tt.expected}, items, idsByName, tt.k)
+			for _, m := range []struct {
+				name      string
+				got, want float64
+			}{{"recall", got.Recall, tt.wantRecall}, {"precision", got.Precision, tt.wantPrecision}, {"reciprocal rank", got.ReciprocalRank, tt.wantRR}} {
+				if math.Abs(m.got-m.want) > 1e-9 {
+					t.Errorf("%s = %v, want %v", m.name, m.got, m.want)
+				}
+			}
+			if !reflect.DeepEqual(got.Missing, tt.wantMissing) {
+				t.Errorf("missing = %v, want %v", got.Missing, tt.wantMissing)
+			}
+			if len(got.Retrieved) > tt.k {
+				t.Errorf("retrieved %d entries, more than k", len(got.Retrieved))
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"Llore/internal/database"
+	"Llore/internal/embeddings"
+	"Llore/internal/llm"
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+// vectorStorageSetting is the vault_settings key holding the vault's VectorStorage.
+const vectorStorageSetting = "vector_storage"
+
+// Sizes of the storage report: how many entries serve as test queries, and the k of recall@k.
+const (
+	storageReportQueries = 100
+	storageReportK       = 10
+)
+
+// loadVectorStorage returns the vault's truncation and quantization settings, or the
+// defaults (full float32 vectors) when none were saved or they can't be read.
+func (a *App) loadVectorStorage() embeddings.VectorStorage {
+	defaults := embeddings.VectorStorage{Quantization: embeddings.QuantizeNone}
+	if a.db == nil {
+		return defaults
+	}
+	value, ok, err := database.DBGetVaultSetting(a.db, vectorStorageSetting)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return defaults
+	}
+	if !ok {
+		return defaults
+	}
+	var storage embeddings.VectorStorage
+	if err := json.Unmarshal([]byte(value), &storage); err != nil {
+		log.Printf("Warning: Ignoring unreadable vector storage setting: %v", err)
+		return defaults
+	}
+	storage, err = storage.Normalize()
+	if err != nil {
+		log.Printf("Warning: Ignoring invalid vector storage setting: %v", err)
+		return defaults
+	}
+	return storage
+}
+
+// GetVectorStorage returns how compactly the current vault keeps its vectors.
+func (a *App) GetVectorStorage() (embeddings.VectorStorage, error) {
+	if a.db == nil {
+		return embeddings.VectorStorage{}, fmt.Errorf("no vault is currently loaded")
+	}
+	return a.loadVectorStorage(), nil
+}
+
+// SetVectorStorage changes the vault's truncation and quantization settings and reloads
+// the embedding service with them. When the truncated dimension changes, the new vector
+// version is derived from vectors already stored (the provider's full vectors, or a
+// wider truncation) so nothing has to be re-embedded. Vectors that can't be derived that
+// way are regenerated in the background. The previous version's rows are kept; they can
+// be purged from the embedding coverage report.
+func (a *App) SetVectorStorage(storage embeddings.VectorStorage) error {
+	if a.db == nil {
+		return fmt.Errorf("no vault is currently loaded")
+	}
+	storage, err := storage.Normalize()
+	if err != nil {
+		return err
+	}
+	previous := a.loadVectorStorage()
+
+	data, err := json.Marshal(storage)
+	if err != nil {
+		return fmt.Errorf("failed to encode vector storage: %w", err)
+	}
+	if err := database.DBSetVaultSetting(a.db, vectorStorageSetting, string(data)); err != nil {
+		return err
+	}
+	log.Printf("Vector storage set to quantization=%s dimensions=%d (was %s/%d)", storage.Quantization, storage.Dimensions, previous.Quantization, previous.Dimensions)
+
+	if service := a.embeddingService; service != nil && storage.Dimensions != previous.Dimensions && storage.Dimensions > 0 {
+		if err := a.deriveTruncatedVectors(service.GetProvider().ModelIdentifier(), previous, storage); err != nil {
+			log.Printf("Warning: Failed to derive truncated vectors, they will be re-embedded instead: %v", err)
+		}
+	}
+
+	a.initializeEmbeddingServices(llm.GetConfig())
+	return nil
+}
+
+// deriveTruncatedVectors fills the vector version for storage by truncating vectors the
+// vault already has. The provider's full vectors are preferred; the previous setting's
+// vectors are used when they are at least as wide as the new dimension.
+func (a *App) deriveTruncatedVectors(model string, previous, storage embeddings.VectorStorage) error {
+	versions, err := database.DBListVectorVersions(a.db)
+	if err != nil {
+		return err
+	}
+	stored := make(map[string]bool, len(versions))
+	for _, v := range versions {
+		stored[v.VectorVersion] = true
+	}
+
+	source := ""
+	switch {
+	case stored[model]:
+		source = model
+	case previous.Dimensions > storage.Dimensions && stored[previous.VectorVersion(model)]:
+		source = previous.VectorVersion(model)
+	default:
+		log.Printf("No stored vectors for %s wide enough to truncate to %d dimensions", model, storage.Dimensions)
+		return nil
+	}
+
+	target := storage.VectorVersion(model)
+	a.stopEmbeddingJobs() // Nothing may write to the source version while it is copied
+	copied, err := database.DBDeriveVectorVersion(a.db, source, target, func(blob []byte) []byte {
+		return embeddings.TruncateEmbeddingBlob(blob, storage.Dimensions)
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("Derived %d rows of %s from %s", copied, target, source)
+	return nil
+}
+
+// MeasureVectorStorage reports, for each truncation and quantization setting, how much
+// storage it would save on this vault's codex and how much recall it would cost.
+func (a *App) MeasureVectorStorage() (embeddings.StorageReport, error) {
+	if a.db == nil {
+		return embeddings.StorageReport{}, fmt.Errorf("no vault is currently loaded")
+	}
+	service := a.embeddingService
+	if service == nil {
+		return embeddings.StorageReport{}, fmt.Errorf("embedding service not initialized")
+	}
+	report, err := service.MeasureVectorStorage(storageReportQueries, storageReportK)
+	if err != nil {
+		return report, err
+	}
+	log.Printf("Measured %d storage options on %d vectors of %s", len(report.Options), report.Vectors, report.VectorVersion)
+	return report, nil
+}
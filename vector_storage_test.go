@@ -0,0 +1,130 @@
+package main
+
+import (
+	"Llore/internal/database"
+	"Llore/internal/embeddings"
+	"database/sql"
+	"encoding/binary"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func openVectorTestVault(t *testing.T) *App {
+	t.Helper()
+	db, err := database.DBInitialize(filepath.Join(t.TempDir(), "codex_data.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	// Same schema SwitchVault creates
+	if _, err := db.Exec(`CREATE TABLE codex_embeddings (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		codex_entry_id INTEGER NOT NULL,
+		embedding BLOB NOT NULL,
+		vector_version TEXT NOT NULL,
+		content_hash TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		UNIQUE (codex_entry_id, vector_version)
+	)`); err != nil {
+		t.Fatal(err)
+	}
+	for _, ensure := range []func(*sql.DB) error{database.DBEnsureEmbeddingChunksTable, database.DBEnsureLibraryIndexTables, database.DBEnsureChatMemoryTables, database.DBEnsureAttachmentsTable} {
+		if err := ensure(db); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &App{db: db}
+}
+
+func storeTestVector(t *testing.T, db *sql.DB, entryID int64, version string, vec []float32) {
+	t.Helper()
+	blob := make([]byte, len(vec)*4)
+	for i, x := range vec {
+		binary.LittleEndian.PutUint32(blob[i*4:], math.Float32bits(x))
+	}
+	if _, err := db.Exec(`INSERT INTO codex_embeddings (codex_entry_id, embedding, vector_version, created_at, updated_at)
+		VALUES (?, ?, ?, datetime('now'), datetime('now'))`, entryID, blob, version); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func loadTestVector(t *testing.T, db *sql.DB, entryID int64, version string) []float32 {
+	t.Helper()
+	var blob []byte
+	err := db.QueryRow(`SELECT embedding FROM codex_embeddings WHERE codex_entry_id = ? AND vector_version = ?`, entryID, version).Scan(&blob)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	vec := make([]float32, len(blob)/4)
+	for i := range vec {
+		vec[i] = math.Float32frombits(binary.LittleEndian.Uint32(blob[i*4:]))
+	}
+	return vec
+}
+
+func testVector(dim int) []float32 {
+	vec := make([]float32, dim)
+	for i := range vec {
+		vec[i] = float32(i%7) - 3
+	}
+	return vec
+}
+
+func TestDeriveTruncatedVectors(t *testing.T) {
+	const model = "openai/text-embedding-3-small"
+	tests := []struct {
+		name       string
+		stored     map[string]int // Stored vector version -> dimensions
+		previous   int
+		dims       int
+		wantSource string // "" when nothing can be derived
+	}{
+		{"from full vectors", map[string]int{model: 128}, 0, 64, model},
+		{"full vectors preferred over a wider truncation", map[string]int{model: 128, model + "#d96": 96}, 96, 64, model},
+		{"from a wider truncation", map[string]int{model + "#d96": 96}, 96, 64, model + "#d96"},
+		{"not from a narrower truncation", map[string]int{model + "#d48": 48}, 48, 64, ""},
+		{"not from another model", map[string]int{"other/model": 128}, 0, 64, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := openVectorTestVault(t)
+			for version, dim := range tt.stored {
+				storeTestVector(t, a.db, 1, version, testVector(dim))
+			}
+			previous := embeddings.VectorStorage{Quantization: embeddings.QuantizeNone, Dimensions: tt.previous}
+			storage := embeddings.VectorStorage{Quantization: embeddings.QuantizeNone, Dimensions: tt.dims}
+			if err := a.deriveTruncatedVectors(model, previous, storage); err != nil {
+				t.Fatal(err)
+			}
+
+			target := storage.VectorVersion(model)
+			if target != model+"#d64" {
+				t.Fatalf("target version = %q", target)
+			}
+			got := loadTestVector(t, a.db, 1, target)
+			if tt.wantSource == "" {
+				if got != nil {
+					t.Fatalf("derived %s although no source was wide enough", target)
+				}
+				return
+			}
+			want := embeddings.TruncateVector(testVector(tt.stored[tt.wantSource]), tt.dims)
+			if len(got) != len(want) {
+				t.Fatalf("derived %d dimensions, want %d", len(got), len(want))
+			}
+			for i := range got {
+				if math.Abs(float64(got[i]-want[i])) > 1e-6 {
+					t.Fatalf("component %d = %v, want %v", i, got[i], want[i])
+				}
+			}
+			if len(loadTestVector(t, a.db, 1, tt.wantSource)) != tt.stored[tt.wantSource] {
+				t.Error("source vectors were changed")
+			}
+		})
+	}
+}